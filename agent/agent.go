@@ -3,22 +3,28 @@
 package agent
 
 import (
-	"bytes"
+	"context"
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"math"
 	"net/http"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gliderlab/cogate/memory"
 	"github.com/gliderlab/cogate/rpcproto"
 	"github.com/gliderlab/cogate/storage"
+	"github.com/gliderlab/cogate/tokens"
 	"github.com/gliderlab/cogate/tools"
 )
 
@@ -48,17 +54,207 @@ type Agent struct {
 	autoRecall     bool
 	recallLimit    int
 	recallMinScore float64
-	systemTools    []rpcproto.Tool
+	recallTemplate string
+	// recallDecay is the per-day recency decay rate of recall re-ranking (0 = off)
+	recallDecay float64
+	// recallKeywordFallback runs a keyword search when vector recall finds nothing
+	recallKeywordFallback bool
+	// recallBoost is the re-rank boost per memory category
+	recallBoost map[string]float64
+	// recallCache holds PrecomputeRecall results for recallCacheTTL (0 = default)
+	recallCache    recallCache
+	recallCacheTTL time.Duration
+	// Provider-specific headers/query params added to every upstream request
+	extraHeaders map[string]string
+	extraQuery   map[string]string
+	// Model patterns whose system messages are merged into user messages
+	noSystemRole []string
+	// toolSchemaRules pick a tool schema profile per model
+	toolSchemaRules []ToolSchemaRule
+	// toolSelect caps the tools offered per request; toolVecs caches their description embeddings
+	toolSelect ToolSelection
+	toolVecs   toolEmbeddings
+	// emptyResponse is the policy for upstream responses without choices
+	emptyResponse string
+	// toolRepeatLimit caps identical tool calls per turn (0 = default, negative = off)
+	toolRepeatLimit int
+	// apiMaxRetries is the per-turn budget for resending 429/5xx answers (0 = default, negative = off)
+	apiMaxRetries int
+	// apiTimeout bounds one upstream request; ctx ends with Shutdown and cancels
+	// the requests still in flight
+	apiTimeout time.Duration
+	ctx        context.Context
+	cancel     context.CancelFunc
+	// activeTurns counts chat turns still running, for WaitIdle after Shutdown
+	activeTurns atomic.Int32
+	// tokenCounter overrides tokens.ForModel(model) for session token totals
+	tokenCounter tokens.Counter
+	// startedAt is reported by Ping
+	startedAt time.Time
+	// output filters and caps every reply
+	output OutputPolicy
+	// Cached tool specs sent to the model, rebuilt when the registry version changes
+	toolsMu      sync.Mutex
+	systemTools  []rpcproto.Tool
+	toolsVersion uint64
 	// Pulse/Heartbeat system
 	pulse *PulseHandler
+	// Conversation retention policy
+	retention RetentionPolicy
+	// Auto-capture importance per memory category
+	captureWeights map[string]float64
+	// Also auto-capture salient sentences from assistant replies
+	captureAssistant bool
+	// configSource tells where apiKey/baseURL/model came from (configSource* consts)
+	configSource string
+	// Open ask_user questions per session, injected before the answer
+	pendingMu sync.Mutex
+	pending   map[string]*rpcproto.Clarification
+	// Sessions with a compaction or retention pass in progress (see beginRewrite)
+	compactMu  sync.Mutex
+	compacting map[string]bool
+	// One turn at a time per session (see lockSession)
+	sessionMu    sync.Mutex
+	sessionLocks map[string]*sessionLock
+}
+
+// ChatResult is the outcome of a Chat call
+type ChatResult struct {
+	Content string
+	// Clarification is set when the model called ask_user instead of answering
+	Clarification *rpcproto.Clarification
+	// Choices holds every completion of the final answer when N > 1 or Logprobs
+	Choices []rpcproto.ChatChoice
+	// Trace records the tool calls of the turn when ChatOptions.Trace is set
+	Trace []rpcproto.ToolTrace
+	// Err is set when the upstream failed the turn (ErrEmptyUpstream)
+	Err error
+}
+
+// ChatOptions are per-request sampling overrides forwarded upstream
+type ChatOptions struct {
+	// Seed asks the provider for deterministic sampling (where supported)
+	Seed *int64
+	// Temperature overrides the default; nil keeps it (0 when Seed is set)
+	Temperature *float64
+	// N asks for several completions; tool calls are followed on the first only
+	N int
+	// Logprobs/TopLogprobs return token log probabilities (where supported)
+	Logprobs    bool
+	TopLogprobs int
+	// Trace collects every tool call with its arguments, result and duration
+	Trace bool
+	// OnToken, when set, asks the upstream to stream and receives the reply text as
+	// it arrives, on every round of a tool-call chain (see Agent.streams). The text
+	// passed on adds up to the final reply, or to a prefix of it.
+	OnToken TokenFunc
+	// SessionKey is the session the turn is stored, locked and compacted under
+	// (empty = DefaultSessionKey)
+	SessionKey string
+}
+
+// DefaultSessionKey is the session of turns that name none
+const DefaultSessionKey = "default"
+
+// session returns the session key of the turn
+func (o ChatOptions) session() string {
+	if o.SessionKey == "" {
+		return DefaultSessionKey
+	}
+	return o.SessionKey
+}
+
+// wantsChoices reports whether the caller needs the raw upstream choices
+func (o ChatOptions) wantsChoices() bool {
+	return o.N > 1 || o.Logprobs
+}
+
+// defaultTemperature is used when the request sets neither temperature nor seed
+const defaultTemperature = 0.7
+
+// temperature resolves the sampling temperature for a request
+func (o ChatOptions) temperature() float64 {
+	if o.Temperature != nil {
+		return *o.Temperature
+	}
+	if o.Seed != nil {
+		// Seeded runs are for reproducibility; greedy sampling keeps them stable
+		return 0
+	}
+	return defaultTemperature
+}
+
+// chatTurn carries per-call state through the tool-call chain
+type chatTurn struct {
+	opts          ChatOptions
+	clarification *rpcproto.Clarification
+	// compact requests a compaction check once the reply is returned
+	compact bool
+	// choices of the final upstream response (see ChatOptions.wantsChoices)
+	choices []rpcproto.ChatChoice
+	// argRetries counts corrective rounds for malformed tool arguments
+	argRetries int
+	// trace of executed tool calls (opts.Trace only)
+	trace []rpcproto.ToolTrace
+	// tools offered upstream, picked on the first round (see selectTools)
+	tools []rpcproto.Tool
+	// toolsGated is set while the tools are withheld (ToolSelection.OnDemand);
+	// toolsWanted once the reply asked for them and the round was resent
+	toolsGated  bool
+	toolsWanted bool
+	// err ends the turn with an error reply instead of content
+	err error
+	// emptyRetried is set once an empty upstream response was retried
+	emptyRetried bool
+	// apiRetries counts upstream requests resent after 429/5xx (see postUpstream)
+	apiRetries int
+	// toolCalls counts executions per tool name + arguments (see repeatedCall)
+	toolCalls map[string]int
+	// preamble is the text streamed in rounds that went on to call tools; it is
+	// already with the caller, so the final reply starts with it (see streamRound)
+	preamble string
 }
 
 type Message struct {
-	Role                 string       `json:"role"`
-	Content              string       `json:"content"`
-	ToolCalls            []ToolCall   `json:"tool_calls,omitempty"`
-	ToolCallID           string       `json:"tool_call_id,omitempty"`
-	ToolExecutionResults []ToolResult `json:"tool_results,omitempty"`
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Parts is sent upstream as the content array when set (vision models)
+	Parts                []rpcproto.ContentPart `json:"-"`
+	ToolCalls            []ToolCall             `json:"tool_calls,omitempty"`
+	ToolCallID           string                 `json:"tool_call_id,omitempty"`
+	ToolExecutionResults []ToolResult           `json:"tool_results,omitempty"`
+}
+
+// MarshalJSON sends multimodal content as an OpenAI content-parts array.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type alias Message
+	content, err := rpcproto.MarshalContent(m.Content, m.Parts)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		alias
+		Content json.RawMessage `json:"content"`
+	}{alias: alias(m), Content: content})
+}
+
+// UnmarshalJSON accepts string, null or parts-array content from upstream.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type alias Message
+	aux := struct {
+		*alias
+		Content json.RawMessage `json:"content"`
+	}{alias: (*alias)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	content, parts, err := rpcproto.UnmarshalContent(aux.Content)
+	if err != nil {
+		return err
+	}
+	m.Content = content
+	m.Parts = parts
+	return nil
 }
 
 type ToolCall struct {
@@ -84,11 +280,48 @@ func (a *Agent) ToolSpecs() []map[string]any {
 	return a.registry.GetToolSpecs()
 }
 
-// update tool specs cache
-func (a *Agent) refreshToolSpecs() {
+// toolSpecs returns the cached tool specs, rebuilding them when the registry changed
+func (a *Agent) toolSpecs() []rpcproto.Tool {
 	if a.registry == nil {
-		return
+		return nil
+	}
+	a.toolsMu.Lock()
+	defer a.toolsMu.Unlock()
+	if a.systemTools == nil || a.toolsVersion != a.registry.Version() {
+		a.refreshToolSpecsLocked()
+	}
+	return a.systemTools
+}
+
+// RefreshTools forces a rebuild of the tool specs cache and returns the tool names now offered to the model
+func (a *Agent) RefreshTools() []string {
+	if a.registry == nil {
+		return nil
+	}
+	a.toolsMu.Lock()
+	defer a.toolsMu.Unlock()
+	a.refreshToolSpecsLocked()
+	return a.cachedToolNamesLocked()
+}
+
+// CachedTools lists the tool names currently in the specs cache
+func (a *Agent) CachedTools() []string {
+	a.toolsMu.Lock()
+	defer a.toolsMu.Unlock()
+	return a.cachedToolNamesLocked()
+}
+
+func (a *Agent) cachedToolNamesLocked() []string {
+	names := make([]string, 0, len(a.systemTools))
+	for _, t := range a.systemTools {
+		names = append(names, t.Function.Name)
 	}
+	return names
+}
+
+// update tool specs cache (caller holds toolsMu)
+func (a *Agent) refreshToolSpecsLocked() {
+	a.toolsVersion = a.registry.Version()
 	specs := a.registry.GetToolSpecs()
 	a.systemTools = make([]rpcproto.Tool, 0, len(specs))
 	for _, s := range specs {
@@ -102,7 +335,7 @@ func (a *Agent) refreshToolSpecs() {
 		name, _ := functionObj["name"].(string)
 		desc, _ := functionObj["description"].(string)
 		params, _ := functionObj["parameters"].(map[string]interface{})
-		log.Printf("🔧 Converting tool: name='%s', desc='%s', params=%+v", name, desc, params)
+		slog.Debug("converting tool", "name", name, "description", desc, "params", params)
 		a.systemTools = append(a.systemTools, rpcproto.Tool{
 			Type: "function",
 			Function: rpcproto.ToolFunction{
@@ -117,9 +350,14 @@ func (a *Agent) refreshToolSpecs() {
 type ChatRequest struct {
 	Model       string          `json:"model"`
 	Messages    []Message       `json:"messages"`
-	Temperature float64         `json:"temperature,omitempty"`
+	Temperature *float64        `json:"temperature,omitempty"`
 	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Seed        *int64          `json:"seed,omitempty"`
+	N           int             `json:"n,omitempty"`
+	Logprobs    bool            `json:"logprobs,omitempty"`
+	TopLogprobs int             `json:"top_logprobs,omitempty"`
 	Tools       []rpcproto.Tool `json:"tools,omitempty"`
+	Stream      bool            `json:"stream,omitempty"`
 }
 
 type ChatResponse struct {
@@ -131,9 +369,10 @@ type ChatResponse struct {
 }
 
 type Choice struct {
-	Index        int     `json:"index"`
-	Message      Message `json:"message"`
-	FinishReason string  `json:"finish_reason"`
+	Index        int             `json:"index"`
+	Message      Message         `json:"message"`
+	FinishReason string          `json:"finish_reason"`
+	Logprobs     json.RawMessage `json:"logprobs,omitempty"`
 }
 
 // Config
@@ -147,9 +386,56 @@ type Config struct {
 	AutoRecall     bool
 	RecallLimit    int
 	RecallMinScore float64
+	// RecallTemplate formats injected memories; "{{memories}}" is replaced by the list
+	// (empty = tools.DefaultRecallTemplate)
+	RecallTemplate string
+	// RecallDecay weights recalled memories by exp(-RecallDecay * ageInDays) on top of
+	// the category/importance boost (0 = age ignored)
+	RecallDecay float64
+	// RecallCategoryBoost overrides by category (merged onto DefaultRecallCategoryBoost);
+	// recall weights a memory by 1 + its category's boost
+	RecallCategoryBoost map[string]float64
+	// RecallKeywordFallback recalls by keyword match (FTS, or LIKE without it) when
+	// no memory passes the vector min score
+	RecallKeywordFallback bool
+	// RecallCacheTTL is how long PrecomputeRecall results are reused (0 = DefaultRecallCacheTTL)
+	RecallCacheTTL time.Duration
+	// Extra upstream headers (e.g. OpenRouter HTTP-Referer/X-Title, Azure api-key)
+	// and query params (e.g. Azure api-version); an empty header value removes it
+	ExtraHeaders map[string]string
+	ExtraQuery   map[string]string
+	// Models that reject the system role (globs, e.g. "gemma-*"); their system
+	// messages are merged into the adjacent user message
+	NoSystemRoleModels []string
+	// Tool schema shims per model (see ParseToolSchemaRules); none = passthrough
+	ToolSchemaRules []ToolSchemaRule
+	// Cap and strategy for the tools sent per request (zero value = send all)
+	ToolSelection ToolSelection
+	// EmptyResponse handles responses without choices: EmptyResponseError (default),
+	// EmptyResponseRetry or EmptyResponseMessage
+	EmptyResponse string
+	// ToolRepeatLimit is how often the same tool call (name and arguments) may run in
+	// one turn; later repeats are answered with an error. 0 = DefaultToolRepeatLimit, negative = no limit
+	ToolRepeatLimit int
+	// APIMaxRetries is how often one turn may resend an upstream request answered
+	// with 429 or 5xx, across all tool rounds. 0 = DefaultAPIMaxRetries, negative = no retries
+	APIMaxRetries int
+	// APITimeout bounds each upstream request, reading the reply included
+	// (0 = DefaultAPITimeout)
+	APITimeout time.Duration
+	// TokenCounter sizes sessions for compaction (nil = tokens.ForModel(Model))
+	TokenCounter tokens.Counter
+	// Output filters and length cap for every reply (zero value = unchanged)
+	Output OutputPolicy
 	// Pulse/Heartbeat system configuration
 	PulseEnabled bool
 	PulseConfig  *PulseConfig
+	// Conversation retention/auto-archive policy (zero value = keep forever)
+	Retention RetentionPolicy
+	// Auto-capture importance overrides by category (merged onto DefaultCaptureImportance)
+	CaptureImportance map[string]float64
+	// Auto-capture from assistant replies too (source "assistant-auto")
+	CaptureAssistant bool
 }
 
 func New(cfg Config) *Agent {
@@ -158,11 +444,20 @@ func New(cfg Config) *Agent {
 		model:       cfg.Model,
 		apiKey:      cfg.APIKey,
 		baseURL:     cfg.BaseURL,
-		client:      &http.Client{Timeout: 30 * time.Second},
+		client:      &http.Client{}, // deadlines come from the request context
+		apiTimeout:  cfg.APITimeout,
 		store:       cfg.Storage,
 		memoryStore: cfg.MemoryStore,
 		registry:    cfg.Registry,
 	}
+	if a.apiTimeout <= 0 {
+		a.apiTimeout = DefaultAPITimeout
+	}
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	a.startedAt = time.Now()
+	a.captureWeights = mergeCaptureImportance(cfg.CaptureImportance)
+	a.captureAssistant = cfg.CaptureAssistant
+	a.configSource = configSourceEnv
 
 	// Use default registry if none is provided
 	if a.registry == nil {
@@ -172,6 +467,11 @@ func New(cfg Config) *Agent {
 	// Load configuration from database
 	if cfg.Storage != nil {
 		a.loadConfigFromDB()
+		// Storage-backed tools; the scratchpad is switched to the turn's session
+		// for every call (see executeToolCalls)
+		if _, ok := a.registry.Get("scratchpad"); !ok {
+			a.registry.Register(tools.NewScratchpadTool(cfg.Storage, DefaultSessionKey))
+		}
 	}
 
 	a.autoRecall = cfg.AutoRecall
@@ -181,6 +481,23 @@ func New(cfg Config) *Agent {
 	if cfg.RecallMinScore > 0 {
 		a.recallMinScore = cfg.RecallMinScore
 	}
+	a.recallTemplate = cfg.RecallTemplate
+	if cfg.RecallDecay > 0 {
+		a.recallDecay = cfg.RecallDecay
+	}
+	a.recallKeywordFallback = cfg.RecallKeywordFallback
+	a.recallBoost = mergeWeights(DefaultRecallCategoryBoost, cfg.RecallCategoryBoost)
+	a.recallCacheTTL = cfg.RecallCacheTTL
+	a.extraHeaders = cfg.ExtraHeaders
+	a.extraQuery = cfg.ExtraQuery
+	a.noSystemRole = cfg.NoSystemRoleModels
+	a.toolSchemaRules = cfg.ToolSchemaRules
+	a.toolSelect = cfg.ToolSelection
+	a.emptyResponse = cfg.EmptyResponse
+	a.toolRepeatLimit = cfg.ToolRepeatLimit
+	a.apiMaxRetries = cfg.APIMaxRetries
+	a.tokenCounter = cfg.TokenCounter
+	a.output = cfg.Output
 
 	// Initialize pulse/heartbeat system
 	if cfg.PulseEnabled && cfg.Storage != nil {
@@ -189,6 +506,11 @@ func New(cfg Config) *Agent {
 		log.Printf("[Agent] Pulse/Heartbeat system started")
 	}
 
+	a.retention = cfg.Retention
+	if a.retention.Enabled() && cfg.Storage != nil {
+		a.startRetention()
+	}
+
 	return a
 }
 
@@ -258,6 +580,7 @@ func (a *Agent) loadConfigFromDB() {
 	if v, ok := config["model"]; ok && v != "" {
 		a.model = v
 	}
+	a.configSource = configSourceDatabase
 
 	log.Printf("✅ config loaded from database")
 }
@@ -282,6 +605,7 @@ func (a *Agent) UpdateConfig(apiKey, baseURL, model string) {
 	a.apiKey = apiKey
 	a.baseURL = baseURL
 	a.model = model
+	a.configSource = configSourceSetup
 	if a.store != nil {
 		a.saveConfigToDB()
 	}
@@ -291,7 +615,83 @@ func (a *Agent) GetConfig() (apiKey, baseURL, model string) {
 	return a.apiKey, a.baseURL, a.model
 }
 
+// SetupStatus reports whether an LLM is configured. Without an API key
+// Chat falls back to simpleResponse, so the UI shows onboarding instead.
+func (a *Agent) SetupStatus() rpcproto.SetupStatusReply {
+	status := rpcproto.SetupStatusReply{
+		APIKeySet: a.apiKey != "",
+		BaseURL:   a.baseURL,
+		Model:     a.model,
+	}
+	if a.apiKey == "" {
+		status.Missing = append(status.Missing, "apiKey")
+	}
+	if a.baseURL == "" {
+		status.Missing = append(status.Missing, "baseUrl")
+	}
+	if a.model == "" {
+		status.Missing = append(status.Missing, "model")
+	}
+	status.Configured = len(status.Missing) == 0
+	if !status.Configured {
+		status.Message = "setup required: configure " + strings.Join(status.Missing, ", ")
+	}
+	return status
+}
+
+// Shutdown cancels the upstream requests in flight; their turns end with an
+// "API error: context canceled" reply. Chats started afterwards fail the same way.
+func (a *Agent) Shutdown() {
+	a.cancel()
+}
+
+// WaitIdle waits up to timeout for the running chat turns to return and
+// reports whether they all did
+func (a *Agent) WaitIdle(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for a.activeTurns.Load() > 0 {
+		if time.Now().After(deadline) {
+			return false
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	return true
+}
+
 func (a *Agent) Chat(messages []Message) string {
+	return a.ChatWithResult(messages).Content
+}
+
+// ChatWithResult runs a chat turn and reports whether it ended in a clarification request
+func (a *Agent) ChatWithResult(messages []Message) ChatResult {
+	return a.ChatWithOptions(messages, ChatOptions{})
+}
+
+// ChatWithOptions is ChatWithResult with per-request sampling options
+func (a *Agent) ChatWithOptions(messages []Message, opts ChatOptions) ChatResult {
+	a.activeTurns.Add(1)
+	defer a.activeTurns.Add(-1)
+	// Compaction stays outside the lock: it only replaces rows up to its snapshot
+	session := opts.session()
+	unlock := a.lockSession(session)
+	defer unlock()
+
+	turn := &chatTurn{opts: opts}
+	content := a.output.apply(a.chat(a.ctx, turn, a.withPendingClarification(session, messages)))
+	for i := range turn.choices {
+		turn.choices[i].Content = a.output.apply(turn.choices[i].Content)
+	}
+	if a.captureAssistant && turn.clarification == nil && content != "" {
+		// Off the reply path: capture needs an embedding per candidate sentence
+		go a.captureAssistantReply(content)
+	}
+	if turn.compact {
+		a.compactAsync(session)
+	}
+	return ChatResult{Content: content, Clarification: turn.clarification, Choices: turn.choices, Trace: turn.trace, Err: turn.err}
+}
+
+func (a *Agent) chat(ctx context.Context, turn *chatTurn, messages []Message) string {
 	if a.store != nil {
 		lastMsg := ""
 		for i := len(messages) - 1; i >= 0; i-- {
@@ -301,27 +701,30 @@ func (a *Agent) Chat(messages []Message) string {
 			}
 		}
 		if lastMsg != "" {
-			a.store.AddMessage("default", "user", "[redacted]")
+			a.store.AddMessage(turn.opts.session(), "user", "[redacted]")
+			// Long-term memory is one store for all sessions, like the memory
+			// tools and /memory API that read it: a fact learned in one chat is
+			// recalled in the others. Only history and the scratchpad are per session.
 			if a.memoryStore != nil && tools.ShouldCapture(lastMsg) {
 				category := tools.DetectCategory(lastMsg)
 				results, _ := a.memoryStore.Search(lastMsg, 1, 0.95)
 				if len(results) == 0 {
-					_, err := a.memoryStore.StoreWithSource(lastMsg, category, 0.6, "auto")
+					_, err := a.memoryStore.StoreWithSource(lastMsg, category, a.captureImportance(category), "auto")
 					if err != nil {
 						log.Printf("⚠️ auto memory write failed")
 					}
 				}
 			}
 			// Soft-trigger memory flush (based on message count + time)
-			a.maybeFlushMemory(lastMsg)
-			// compaction check
-			a.maybeCompact("default", messages)
+			a.maybeFlushMemory(turn.opts.session(), lastMsg)
+			// compaction check runs after the reply (see compactAsync)
+			turn.compact = true
 		}
 	}
 
 	// Handle tool calls
 	if len(messages) > 0 && len(messages[len(messages)-1].ToolCalls) > 0 {
-		return a.handleToolCalls(messages, messages[len(messages)-1].ToolCalls, nil, 0)
+		return a.handleToolCalls(ctx, turn, messages, messages[len(messages)-1].ToolCalls, nil, 0)
 	}
 
 	// Detect edit intent
@@ -355,24 +758,50 @@ func (a *Agent) Chat(messages []Message) string {
 	}
 
 	if a.apiKey == "" {
-		return a.simpleResponse(messages)
+		return a.simpleResponse(turn.opts.session(), messages)
 	}
 
-	return a.callAPI(messages)
+	return a.callAPI(ctx, turn, messages)
 }
 
-func (a *Agent) executeToolCalls(toolCalls []ToolCall) []ToolResult {
+func (a *Agent) executeToolCalls(turn *chatTurn, toolCalls []ToolCall) []ToolResult {
 	results := make([]ToolResult, 0, len(toolCalls))
 
 	for _, call := range toolCalls {
 		var result interface{}
 		var err error
 
+		args, argErr := decodeToolArgs(call.Function.Arguments)
+		if argErr != nil {
+			// Running the tool with no args only yields misleading "missing argument" errors
+			slog.Warn("malformed tool arguments", "tool", call.Function.Name, "error", argErr)
+			turn.traceTool(call, nil, argErr, 0)
+			results = append(results, ToolResult{
+				ID:     call.ID,
+				Type:   "function",
+				Result: malformedArgsResult(call.Function.Name, argErr),
+			})
+			continue
+		}
+		if n, repeated := a.repeatedCall(turn, call.Function.Name, args); repeated {
+			result := repeatedCallResult(call.Function.Name, n)
+			turn.traceTool(call, nil, fmt.Errorf("repeated call skipped"), 0)
+			results = append(results, ToolResult{ID: call.ID, Type: "function", Result: result})
+			continue
+		}
+
+		start := time.Now()
 		if a.registry != nil {
-			result, err = a.registry.CallTool(call.Function.Name, parseArgs(call.Function.Arguments))
+			result, err = a.registry.CallToolForSession(turn.opts.session(), call.Function.Name, args)
 		} else {
 			err = fmt.Errorf("tool registry not initialized")
 		}
+		var memoryID string
+		if m, ok := result.(tools.Memorable); ok && err == nil {
+			result = m.Result
+			memoryID = a.rememberToolResult(call.Function.Name, m)
+		}
+		turn.traceTool(call, result, err, time.Since(start))
 
 		if err != nil {
 			result = map[string]interface{}{
@@ -381,11 +810,15 @@ func (a *Agent) executeToolCalls(toolCalls []ToolCall) []ToolResult {
 				"success": false,
 			}
 		} else {
-			result = map[string]interface{}{
+			wrapped := map[string]interface{}{
 				"result":  result,
 				"tool":    call.Function.Name,
 				"success": true,
 			}
+			if memoryID != "" {
+				wrapped["memoryId"] = memoryID
+			}
+			result = wrapped
 		}
 
 		results = append(results, ToolResult{
@@ -398,8 +831,26 @@ func (a *Agent) executeToolCalls(toolCalls []ToolCall) []ToolResult {
 	return results
 }
 
-func (a *Agent) handleToolCalls(messages []Message, toolCalls []ToolCall, assistantMsg *Message, depth int) string {
-	results := a.executeToolCalls(toolCalls)
+func (a *Agent) handleToolCalls(ctx context.Context, turn *chatTurn, messages []Message, toolCalls []ToolCall, assistantMsg *Message, depth int) string {
+	// ask_user ends the turn: surface the question instead of running tools
+	if q := findClarification(toolCalls); q != nil {
+		turn.clarification = q
+		a.setPendingClarification(turn.opts.session(), q)
+		if a.store != nil {
+			a.store.AddMessage(turn.opts.session(), "assistant", "[redacted]")
+		}
+		return q.Question
+	}
+
+	results := a.executeToolCalls(turn, toolCalls)
+
+	// A round where every call had unparseable JSON is a correction, not progress:
+	// give the model a couple of retries before it counts against the chain depth
+	nextDepth := depth + 1
+	if allMalformedArgs(toolCalls) && turn.argRetries < maxArgRetries {
+		turn.argRetries++
+		nextDepth = depth
+	}
 
 	resp := ToolResponse{
 		ToolResults: results,
@@ -431,15 +882,135 @@ func (a *Agent) handleToolCalls(messages []Message, toolCalls []ToolCall, assist
 		newMessages = append(newMessages, toolMsg)
 	}
 
-	return a.callAPIWithDepth(newMessages, depth+1)
+	return a.callAPIWithDepth(ctx, turn, newMessages, nextDepth)
+}
+
+// findClarification returns the first valid ask_user call, if any
+func findClarification(toolCalls []ToolCall) *rpcproto.Clarification {
+	for _, call := range toolCalls {
+		if call.Function.Name != tools.AskUserToolName {
+			continue
+		}
+		req, err := tools.ParseAskUserArgs(parseArgs(call.Function.Arguments))
+		if err != nil {
+			continue
+		}
+		return &rpcproto.Clarification{Question: req.Question, Options: req.Options, Context: req.Context}
+	}
+	return nil
+}
+
+func (a *Agent) setPendingClarification(sessionKey string, q *rpcproto.Clarification) {
+	a.pendingMu.Lock()
+	defer a.pendingMu.Unlock()
+	if a.pending == nil {
+		a.pending = make(map[string]*rpcproto.Clarification)
+	}
+	a.pending[sessionKey] = q
+}
+
+// withPendingClarification puts an open question back in front of the user's
+// answer so the model sees what was asked, unless the caller's history has it.
+func (a *Agent) withPendingClarification(sessionKey string, messages []Message) []Message {
+	a.pendingMu.Lock()
+	q := a.pending[sessionKey]
+	delete(a.pending, sessionKey)
+	a.pendingMu.Unlock()
+
+	if q == nil || len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		return messages
+	}
+	for _, m := range messages {
+		if m.Role == "assistant" && strings.Contains(m.Content, q.Question) {
+			return messages
+		}
+	}
+	last := len(messages) - 1
+	out := make([]Message, 0, len(messages)+1)
+	out = append(out, messages[:last]...)
+	out = append(out, Message{Role: "assistant", Content: q.Question})
+	return append(out, messages[last])
 }
 
 func parseArgs(argsJSON string) map[string]interface{} {
-	var args map[string]interface{}
+	args, _ := decodeToolArgs(argsJSON)
+	return args
+}
+
+// maxArgRetries bounds the corrective rounds for malformed tool arguments per turn
+const maxArgRetries = 2
+
+// decodeToolArgs parses tool-call arguments; empty arguments mean no arguments.
+// On error the returned map is empty (never nil).
+func decodeToolArgs(argsJSON string) (map[string]interface{}, error) {
+	args := make(map[string]interface{})
+	if strings.TrimSpace(argsJSON) == "" {
+		return args, nil
+	}
 	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return make(map[string]interface{}), err
+	}
+	if args == nil {
+		// "null" decodes to a nil map
 		args = make(map[string]interface{})
 	}
-	return args
+	// An explicit null means "not given" (strict tool schemas require every key)
+	for k, v := range args {
+		if v == nil {
+			delete(args, k)
+		}
+	}
+	return args, nil
+}
+
+// malformedArgsResult is the tool result sent back so the model can fix its JSON
+func malformedArgsResult(tool string, err error) map[string]interface{} {
+	return map[string]interface{}{
+		"error":   fmt.Sprintf("invalid JSON in arguments: %v. The tool was not run; call %s again with a valid JSON object (no trailing commas, double-quoted keys and strings).", err, tool),
+		"tool":    tool,
+		"success": false,
+	}
+}
+
+func allMalformedArgs(toolCalls []ToolCall) bool {
+	if len(toolCalls) == 0 {
+		return false
+	}
+	for _, call := range toolCalls {
+		if _, err := decodeToolArgs(call.Function.Arguments); err == nil {
+			return false
+		}
+	}
+	return true
+}
+
+// maxTraceResult caps each result in a tool trace (bytes of JSON)
+const maxTraceResult = 2000
+
+// traceTool appends a call to the turn's trace when tracing was requested
+func (t *chatTurn) traceTool(call ToolCall, result interface{}, err error, took time.Duration) {
+	if !t.opts.Trace {
+		return
+	}
+	entry := rpcproto.ToolTrace{
+		Name:       call.Function.Name,
+		Arguments:  call.Function.Arguments,
+		DurationMs: took.Milliseconds(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		data, _ := json.Marshal(result)
+		entry.Result = string(data)
+		if len(entry.Result) > maxTraceResult {
+			cut := maxTraceResult
+			for cut > 0 && !utf8.RuneStart(entry.Result[cut]) {
+				cut--
+			}
+			entry.Result = entry.Result[:cut] + "...(truncated)"
+		}
+	}
+	t.trace = append(t.trace, entry)
 }
 
 // parseCustomToolCalls parses custom tool call format from MiniMax and similar models
@@ -458,14 +1029,14 @@ func parseCustomToolCalls(content string) []ToolCall {
 
 	matches := append(matches1, matches2...)
 
-	log.Printf("🔍 parseCustomToolCalls: content length=%d, matches found=%d", len(content), len(matches))
+	slog.Debug("custom tool calls", "contentLen", len(content), "matches", len(matches))
 
 	for _, m := range matches {
 		if len(m) >= 3 {
 			toolName := m[1]
 			paramsStr := m[2]
 
-			log.Printf("🔍 Found tool: %s, params: %s", toolName, paramsStr[:min(100, len(paramsStr))])
+			slog.Debug("custom tool call", "tool", toolName, "params", paramsStr[:min(100, len(paramsStr))])
 
 			// Parse parameters
 			args := make(map[string]interface{})
@@ -479,7 +1050,7 @@ func parseCustomToolCalls(content string) []ToolCall {
 					key := pm[1]
 					value := strings.TrimSpace(pm[2])
 					args[key] = value
-					log.Printf("🔍   param: %s = %s", key, value)
+					slog.Debug("custom tool param", "key", key, "value", value)
 				}
 			}
 
@@ -593,11 +1164,22 @@ func (a *Agent) handleEdit(args map[string]interface{}) string {
 	return fmt.Sprintf("Edit completed: %s", string(b))
 }
 
-// recallRelevantMemories automatically retrieves memories related to the prompt
+// recallRelevantMemories automatically retrieves memories related to the prompt,
+// reusing a PrecomputeRecall result when one is still fresh. Memory is shared by
+// all sessions, so the result depends on the prompt alone.
 func (a *Agent) recallRelevantMemories(prompt string) string {
 	if a.memoryStore == nil {
 		return ""
 	}
+	if memories, ok := a.recallCache.get(prompt, time.Now()); ok {
+		slog.Debug("recall: precomputed", "found", memories != "")
+		return memories
+	}
+	return a.searchRecall(prompt)
+}
+
+// searchRecall searches and re-ranks the memories for one prompt
+func (a *Agent) searchRecall(prompt string) string {
 	limit := a.recallLimit
 	if limit <= 0 {
 		limit = 3
@@ -607,30 +1189,90 @@ func (a *Agent) recallRelevantMemories(prompt string) string {
 		minScore = 0.3
 	}
 
-	results, err := a.memoryStore.Search(prompt, limit*2, minScore)
+	var results []memory.MemoryResult
+	var err error
+	if queries := splitRecallQueries(prompt); len(queries) > 1 {
+		results, err = a.memoryStore.SearchMultiScored(queries, limit*2, minScore)
+	} else {
+		results, err = a.memoryStore.Search(prompt, limit*2, minScore)
+	}
+	if err == nil && len(results) == 0 && a.recallKeywordFallback {
+		// Phrasing can differ enough for cosine to miss while the key terms match
+		results, err = a.memoryStore.KeywordSearch(prompt, limit*2)
+		if len(results) > 0 {
+			slog.Debug("recall: keyword fallback", "results", len(results))
+		}
+	}
 	if err != nil || len(results) == 0 {
 		return ""
 	}
 
 	// re-rank by category/importance weighting
-	catBoost := map[string]float32{
-		"decision":   0.2,
-		"preference": 0.15,
-		"fact":       0.1,
-		"entity":     0.05,
+	now := time.Now()
+	weight := func(r memory.MemoryResult) float32 {
+		w := r.Score * (1 + float32(r.Entry.Importance)) * (1 + float32(a.recallBoost[strings.ToLower(r.Entry.Category)]))
+		return w * recencyDecay(r.Entry, a.recallDecay, now)
 	}
 	sort.Slice(results, func(i, j int) bool {
-		ri := results[i]
-		rj := results[j]
-		wi := ri.Score * (1 + float32(ri.Entry.Importance)) * (1 + catBoost[strings.ToLower(ri.Entry.Category)])
-		wj := rj.Score * (1 + float32(rj.Entry.Importance)) * (1 + catBoost[strings.ToLower(rj.Entry.Category)])
-		return wi > wj
+		return weight(results[i]) > weight(results[j])
 	})
 	if len(results) > limit {
 		results = results[:limit]
 	}
 
-	return tools.FormatMemoriesForContext(results)
+	return tools.FormatMemoriesWithTemplate(results, a.recallTemplate)
+}
+
+// recencyDecay is exp(-lambda * ageInDays) for the later of the entry's created and
+// updated times, so an edited memory counts as fresh; 1 when lambda is 0
+func recencyDecay(e memory.MemoryEntry, lambda float64, now time.Time) float32 {
+	if lambda <= 0 {
+		return 1
+	}
+	ts := e.CreatedAt
+	if e.UpdatedAt > ts {
+		ts = e.UpdatedAt
+	}
+	if ts <= 0 {
+		return 1
+	}
+	days := now.Sub(time.Unix(ts, 0)).Hours() / 24
+	if days < 0 {
+		days = 0
+	}
+	return float32(math.Exp(-lambda * days))
+}
+
+// splitRecallQueries decomposes a multi-topic prompt into sub-queries
+// (sentences/lines), keeping the full prompt first. Capped at 4 queries.
+func splitRecallQueries(prompt string) []string {
+	prompt = strings.TrimSpace(prompt)
+	if prompt == "" {
+		return nil
+	}
+	queries := []string{prompt}
+	parts := strings.FieldsFunc(prompt, func(r rune) bool {
+		switch r {
+		case '.', '?', '!', ';', '\n', '。', '？', '！', '；':
+			return true
+		}
+		return false
+	})
+	if len(parts) < 2 {
+		return queries
+	}
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		// Skip fragments too short to carry a topic
+		if len([]rune(p)) < 8 {
+			continue
+		}
+		queries = append(queries, p)
+		if len(queries) == 4 {
+			break
+		}
+	}
+	return queries
 }
 
 func isRecallRequest(msg string) bool {
@@ -645,23 +1287,24 @@ func isRecallRequest(msg string) bool {
 }
 
 // maybeFlushMemory soft-triggers long memory flush (SQLite storage)
-// Rules: trigger every 50 messages with a minimum interval of 10 minutes
-func (a *Agent) maybeFlushMemory(lastMsg string) {
+// Rules: trigger every 50 messages of the session with a minimum interval of
+// 10 minutes per session
+func (a *Agent) maybeFlushMemory(sessionKey, lastMsg string) {
 	if a.store == nil || a.memoryStore == nil {
 		return
 	}
 
-	stats, err := a.store.Stats()
+	msgCount, err := a.store.CountMessages(sessionKey)
 	if err != nil {
 		return
 	}
-	msgCount := stats["messages"]
 	if msgCount == 0 || msgCount%50 != 0 {
 		return
 	}
 
-	lastFlushAtStr, _ := a.store.GetConfig("memory", "lastFlushAt")
-	lastFlushCountStr, _ := a.store.GetConfig("memory", "lastFlushCount")
+	atKey, countKey := flushConfigKeys(sessionKey)
+	lastFlushAtStr, _ := a.store.GetConfig("memory", atKey)
+	lastFlushCountStr, _ := a.store.GetConfig("memory", countKey)
 	lastFlushAt, _ := strconv.ParseInt(lastFlushAtStr, 10, 64)
 	lastFlushCount, _ := strconv.Atoi(lastFlushCountStr)
 
@@ -674,14 +1317,56 @@ func (a *Agent) maybeFlushMemory(lastMsg string) {
 
 	if lastMsg != "" && tools.ShouldCapture(lastMsg) {
 		category := tools.DetectCategory(lastMsg)
-		_, _ = a.memoryStore.StoreWithSource(lastMsg, category, 0.5, "flush")
+		_, _ = a.memoryStore.StoreWithSource(lastMsg, category, a.captureImportance(category), "flush")
+	}
+
+	_ = a.store.SetConfig("memory", atKey, fmt.Sprintf("%d", time.Now().Unix()))
+	_ = a.store.SetConfig("memory", countKey, fmt.Sprintf("%d", msgCount))
+}
+
+// flushConfigKeys are the config keys of a session's flush state; the default
+// session keeps the keys used before there were sessions
+func flushConfigKeys(sessionKey string) (at, count string) {
+	if sessionKey == DefaultSessionKey {
+		return "lastFlushAt", "lastFlushCount"
+	}
+	return "lastFlushAt:" + sessionKey, "lastFlushCount:" + sessionKey
+}
+
+// compactAsync runs maybeCompact in the background so it never delays a reply.
+// A session that is already compacting is skipped; the next turn re-checks.
+func (a *Agent) compactAsync(sessionKey string) {
+	if !a.beginRewrite(sessionKey) {
+		return
+	}
+	go func() {
+		defer a.endRewrite(sessionKey)
+		a.maybeCompact(sessionKey)
+	}()
+}
+
+// beginRewrite claims a session for a pass that rewrites or archives its rows
+// (compaction, retention); false when another such pass holds it
+func (a *Agent) beginRewrite(sessionKey string) bool {
+	a.compactMu.Lock()
+	defer a.compactMu.Unlock()
+	if a.compacting == nil {
+		a.compacting = make(map[string]bool)
+	}
+	if a.compacting[sessionKey] {
+		return false
 	}
+	a.compacting[sessionKey] = true
+	return true
+}
 
-	_ = a.store.SetConfig("memory", "lastFlushAt", fmt.Sprintf("%d", time.Now().Unix()))
-	_ = a.store.SetConfig("memory", "lastFlushCount", fmt.Sprintf("%d", msgCount))
+func (a *Agent) endRewrite(sessionKey string) {
+	a.compactMu.Lock()
+	delete(a.compacting, sessionKey)
+	a.compactMu.Unlock()
 }
 
-func (a *Agent) maybeCompact(sessionKey string, messages []Message) {
+func (a *Agent) maybeCompact(sessionKey string) {
 	if a.store == nil {
 		return
 	}
@@ -695,12 +1380,12 @@ func (a *Agent) maybeCompact(sessionKey string, messages []Message) {
 		return
 	}
 
-	tokens := estimateTokensFromStore(stored)
-	meta.TotalTokens = tokens
+	total := a.countStoredTokens(stored)
+	meta.TotalTokens = total
 	_ = a.store.UpsertSessionMeta(meta)
 
 	threshold := DEFAULT_CONTEXT_TOKENS - DEFAULT_RESERVE_TOKENS - DEFAULT_SOFT_TOKENS
-	if tokens < threshold || len(stored) <= DEFAULT_KEEP_MESSAGES {
+	if total < threshold || len(stored) <= DEFAULT_KEEP_MESSAGES {
 		return
 	}
 
@@ -715,35 +1400,41 @@ func (a *Agent) maybeCompact(sessionKey string, messages []Message) {
 	meta.MemoryFlushAt = time.Now()
 	_ = a.store.UpsertSessionMeta(meta)
 
-	// archive old messages
+	// archive old messages and rewrite the session atomically. Kept rows keep
+	// their IDs and timestamps, and the summary takes the place of the last
+	// archived row, so turns added meanwhile still sort after all of them.
+	var archiveThrough int64
 	if len(old) > 0 {
-		_ = a.store.ArchiveMessages(sessionKey, old[len(old)-1].ID)
-	}
-	_ = a.store.ClearMessages(sessionKey)
-	for _, m := range keep {
-		_ = a.store.AddMessage(sessionKey, m.Role, m.Content)
+		archiveThrough = old[len(old)-1].ID
 	}
+	var rows []storage.Message
 	if summary != "" {
-		_ = a.store.AddMessage(sessionKey, "system", "[summary]\n"+summary)
+		last := old[len(old)-1]
+		rows = append(rows, storage.Message{ID: last.ID, Role: "system", Content: "[summary]\n" + summary, CreatedAt: last.CreatedAt})
+	}
+	rows = append(rows, keep...)
+	if err := a.store.CompactMessages(sessionKey, archiveThrough, stored[len(stored)-1].ID, rows); err != nil {
+		log.Printf("⚠️ Compaction failed: session=%s: %v", sessionKey, err)
+		return
 	}
-	log.Printf("🧹 Compaction done: session=%s, kept=%d, totalTokens=%d", sessionKey, len(keep), tokens)
+	log.Printf("🧹 Compaction done: session=%s, kept=%d, totalTokens=%d", sessionKey, len(keep), total)
 }
 
-func estimateTokens(messages []Message) int {
-	// Simple estimate: character count / 4 + 4 tokens per message
-	total := 0
-	for _, m := range messages {
-		total += len(m.Content)/4 + 4
+// tokenCount returns the configured counter, or the one registered for the model
+func (a *Agent) tokenCount() tokens.Counter {
+	if a.tokenCounter != nil {
+		return a.tokenCounter
 	}
-	return total
+	return tokens.ForModel(a.model)
 }
 
-func estimateTokensFromStore(messages []storage.Message) int {
-	total := 0
-	for _, m := range messages {
-		total += len(m.Content)/4 + 4
+// countStoredTokens sizes a stored session with the agent's counter
+func (a *Agent) countStoredTokens(messages []storage.Message) int {
+	contents := make([]string, len(messages))
+	for i, m := range messages {
+		contents[i] = m.Content
 	}
-	return total
+	return tokens.CountMessages(a.tokenCount(), contents...)
 }
 
 func buildSummary(msgs []storage.Message) string {
@@ -765,53 +1456,65 @@ func buildSummary(msgs []storage.Message) string {
 	return summary
 }
 
-func (a *Agent) callAPI(messages []Message) string {
-	return a.callAPIWithDepth(messages, 0)
+func (a *Agent) callAPI(ctx context.Context, turn *chatTurn, messages []Message) string {
+	return a.callAPIWithDepth(ctx, turn, messages, 0)
 }
 
-func (a *Agent) callAPIWithDepth(messages []Message, depth int) string {
+func (a *Agent) callAPIWithDepth(ctx context.Context, turn *chatTurn, messages []Message, depth int) string {
+	// Always sent (pointer) so an explicit 0 is not dropped by omitempty
+	temperature := turn.opts.temperature()
+	if a.needsSystemRewrite(a.model) {
+		messages = mergeSystemMessages(messages)
+	}
+	messages = sanitizeRoles(messages)
 	reqBody := ChatRequest{
 		Model:       a.model,
 		Messages:    messages,
-		Temperature: 0.7,
+		Temperature: &temperature,
 		MaxTokens:   1000,
+		Seed:        turn.opts.Seed,
+		Stream:      a.streams(turn),
 	}
-	if len(a.systemTools) == 0 {
-		a.refreshToolSpecs()
+	systemTools := shapeToolSpecs(a.toolSchemaProfile(a.model), a.selectTools(turn, messages))
+	// n and logprobs only go out on a round without tools: a tool-calling round
+	// would multiply its cost by n, and only the first choice's calls are followed
+	choicesRound := turn.opts.wantsChoices() && len(systemTools) == 0
+	if choicesRound {
+		reqBody.N, reqBody.Logprobs, reqBody.TopLogprobs = turn.opts.N, turn.opts.Logprobs, turn.opts.TopLogprobs
 	}
 
-	// Debug: log tools count
-	log.Printf("🔧 Tools count: %d", len(a.systemTools))
-	if len(a.systemTools) > 0 {
-		for i, t := range a.systemTools {
-			log.Printf("🔧 Tool[%d]: Type=%s, Func=%+v", i, t.Type, t.Function)
-		}
+	slog.Debug("upstream request", "model", a.model, "messages", len(messages), "tools", len(systemTools), "depth", depth)
+	for i, t := range systemTools {
+		slog.Debug("tool spec", "index", i, "type", t.Type, "name", t.Function.Name, "params", t.Function.Parameters)
 	}
 
-	reqBody.Tools = a.systemTools
+	reqBody.Tools = systemTools
 
 	body, _ := json.Marshal(reqBody)
 	url := a.baseURL + "/chat/completions"
 
-	req, _ := http.NewRequest("POST", url, bytes.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+a.apiKey)
-
-	resp, err := a.client.Do(req)
+	resp, err := a.postUpstream(ctx, turn, url, body)
 	if err != nil {
 		return fmt.Sprintf("API error: %v", err)
 	}
 	defer resp.Body.Close()
 
-	respBody, _ := io.ReadAll(resp.Body)
+	var chatResp ChatResponse
+	var streamed strings.Builder
+	if reqBody.Stream && resp.StatusCode == 200 && isEventStream(resp) {
+		if chatResp, err = readStream(resp.Body, turn.streamRound(&streamed)); err != nil {
+			return fmt.Sprintf("parse error: %v", err)
+		}
+	} else {
+		respBody, _ := io.ReadAll(resp.Body)
 
-	if resp.StatusCode != 200 {
-		return fmt.Sprintf("API error (%d): %s", resp.StatusCode, string(respBody))
-	}
+		if resp.StatusCode != 200 {
+			return fmt.Sprintf("API error (%d): %s", resp.StatusCode, string(respBody))
+		}
 
-	var chatResp ChatResponse
-	if err := json.Unmarshal(respBody, &chatResp); err != nil {
-		return fmt.Sprintf("parse error: %v", err)
+		if err := json.Unmarshal(respBody, &chatResp); err != nil {
+			return fmt.Sprintf("parse error: %v", err)
+		}
 	}
 
 	// handle tool call chain if returned (standard format)
@@ -825,7 +1528,8 @@ func (a *Agent) callAPIWithDepth(messages []Message, depth int) string {
 		}
 		if len(validCalls) > 0 {
 			assistantMsg := chatResp.Choices[0].Message
-			return a.handleToolCalls(messages, validCalls, &assistantMsg, depth)
+			turn.preamble += streamed.String()
+			return a.handleToolCalls(ctx, turn, messages, validCalls, &assistantMsg, depth)
 		}
 		// If all invalid, try custom format
 	}
@@ -838,19 +1542,48 @@ func (a *Agent) callAPIWithDepth(messages []Message, depth int) string {
 		toolCalls := parseCustomToolCalls(content)
 		if len(toolCalls) > 0 {
 			assistantMsg := Message{Role: "assistant", Content: content, ToolCalls: toolCalls}
-			return a.handleToolCalls(messages, toolCalls, &assistantMsg, depth)
+			turn.preamble += streamed.String()
+			return a.handleToolCalls(ctx, turn, messages, toolCalls, &assistantMsg, depth)
+		}
+		if a.retryWithTools(turn, content) {
+			return a.callAPIWithDepth(ctx, turn, messages, depth)
+		}
+		if turn.opts.wantsChoices() && !choicesRound {
+			// The answer is final: ask for it again without tools, with n and logprobs
+			slog.Debug("final answer, requesting choices without tools", "n", turn.opts.N)
+			turn.tools = []rpcproto.Tool{}
+			return a.callAPIWithDepth(ctx, turn, messages, depth)
 		}
 
 		if a.store != nil {
-			a.store.AddMessage("default", "assistant", "[redacted]")
+			a.store.AddMessage(turn.opts.session(), "assistant", "[redacted]")
+		}
+		if turn.opts.wantsChoices() {
+			turn.choices = collectChoices(chatResp.Choices)
 		}
-		return content
+		return joinStreamed(turn.preamble, content)
 	}
 
-	return "no response"
+	return a.emptyUpstream(ctx, turn, messages, depth)
 }
 
-func (a *Agent) simpleResponse(messages []Message) string {
+// collectChoices converts upstream choices for the RPC reply, keeping logprobs verbatim
+func collectChoices(choices []Choice) []rpcproto.ChatChoice {
+	out := make([]rpcproto.ChatChoice, len(choices))
+	for i, c := range choices {
+		out[i] = rpcproto.ChatChoice{
+			Index:        c.Index,
+			Content:      c.Message.Content,
+			FinishReason: c.FinishReason,
+		}
+		if len(c.Logprobs) > 0 && string(c.Logprobs) != "null" {
+			out[i].Logprobs = string(c.Logprobs)
+		}
+	}
+	return out
+}
+
+func (a *Agent) simpleResponse(session string, messages []Message) string {
 	var userMsg string
 	for i := len(messages) - 1; i >= 0; i-- {
 		if messages[i].Role == "user" {
@@ -866,7 +1599,7 @@ func (a *Agent) simpleResponse(messages []Message) string {
 	case strings.Contains(input, "hello") || strings.Contains(input, "hi"):
 		response = "Hello! I am OpenClaw-Go.\n\nAvailable tools:\n- exec: run commands\n- read: read files\n- write: write files"
 	case strings.Contains(input, "time"):
-		response = time.Now().Format("2006-01-02 15:04:05")
+		response = time.Now().Format("2006-01-02 15:04:05 MST (-07:00)")
 	case strings.Contains(input, "stat"):
 		stats, _ := a.store.Stats()
 		response = fmt.Sprintf("Storage stats:\n- messages: %d\n- memories: %d\n- files: %d", stats["messages"], stats["memories"], stats["files"])
@@ -881,10 +1614,13 @@ func (a *Agent) simpleResponse(messages []Message) string {
 		response = "OpenClaw-Go\n\nCommands:\n- hello - greeting\n- time - time\n- stat - stats\n- tools - list tools\n- help - help"
 	default:
 		response = "I received:: " + userMsg
+		if status := a.SetupStatus(); !status.Configured {
+			response += "\n\n⚠️ No model configured (" + strings.Join(status.Missing, ", ") + "). Open the web UI to finish setup."
+		}
 	}
 
 	if a.store != nil {
-		a.store.AddMessage("default", "assistant", response)
+		a.store.AddMessage(session, "assistant", response)
 	}
 
 	return response
@@ -0,0 +1,146 @@
+// Auto-capture importance - per-category defaults for automatically stored memories
+
+package agent
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gliderlab/cogate/tools"
+)
+
+// DefaultCaptureImportance is the importance auto-capture assigns per detected category.
+// Decisions and preferences outrank generic facts so they win the recall re-rank.
+var DefaultCaptureImportance = map[string]float64{
+	"decision":   0.8,
+	"preference": 0.75,
+	"entity":     0.65,
+	"fact":       0.55,
+	"other":      0.5,
+}
+
+// defaultCaptureFallback is used for categories missing from the map
+const defaultCaptureFallback = 0.5
+
+// ParseCaptureImportance parses "decision=0.9,preference=0.8" into a category map.
+func ParseCaptureImportance(s string) (map[string]float64, error) {
+	return parseWeights(s, "capture importance", 1)
+}
+
+// parseWeights parses "category=value,..." with every value in 0..max
+// (max = +Inf for no upper bound); what names the setting in errors
+func parseWeights(s, what string, max float64) (map[string]float64, error) {
+	want := fmt.Sprintf("0..%g", max)
+	if math.IsInf(max, 1) {
+		want = "0 or more"
+	}
+	out := make(map[string]float64)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid %s %q (want category=value)", what, pair)
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil || f < 0 || f > max {
+			return nil, fmt.Errorf("invalid %s for %s: %q (want %s)", what, k, v, want)
+		}
+		out[strings.ToLower(strings.TrimSpace(k))] = f
+	}
+	return out, nil
+}
+
+// mergeCaptureImportance layers overrides on top of the defaults
+func mergeCaptureImportance(overrides map[string]float64) map[string]float64 {
+	return mergeWeights(DefaultCaptureImportance, overrides)
+}
+
+// mergeWeights copies defaults and layers overrides on top
+func mergeWeights(defaults, overrides map[string]float64) map[string]float64 {
+	merged := make(map[string]float64, len(defaults)+len(overrides))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range overrides {
+		merged[k] = v
+	}
+	return merged
+}
+
+// captureImportance returns the auto-capture importance for category
+func (a *Agent) captureImportance(category string) float64 {
+	if v, ok := a.captureWeights[category]; ok {
+		return v
+	}
+	if v, ok := a.captureWeights["other"]; ok {
+		return v
+	}
+	return defaultCaptureFallback
+}
+
+// maxAssistantCaptures caps memories taken from one assistant reply
+const maxAssistantCaptures = 2
+
+// sentenceSplit breaks a reply into sentences/lines for capture checks
+var sentenceSplit = regexp.MustCompile(`(?:[.!?]+\s+|\n+)`)
+
+// captureAssistantReply stores capture-worthy sentences of an assistant reply
+// (source "assistant-auto"). Replies are usually longer than a memory, so each
+// sentence is checked on its own; near-duplicates of existing memories are skipped.
+func (a *Agent) captureAssistantReply(content string) {
+	if a.memoryStore == nil || strings.HasPrefix(content, "API error") {
+		return
+	}
+	stored := 0
+	for _, sentence := range sentenceSplit.Split(content, -1) {
+		sentence = strings.TrimSpace(strings.TrimLeft(sentence, "-*• "))
+		if !tools.ShouldCapture(sentence) {
+			continue
+		}
+		if results, _ := a.memoryStore.Search(sentence, 1, 0.95); len(results) > 0 {
+			continue
+		}
+		category := tools.DetectCategory(sentence)
+		if _, err := a.memoryStore.StoreWithSource(sentence, category, a.captureImportance(category), "assistant-auto"); err != nil {
+			log.Printf("⚠️ assistant memory write failed: %v", err)
+			continue
+		}
+		stored++
+		if stored >= maxAssistantCaptures {
+			return
+		}
+	}
+}
+
+// rememberToolResult stores the text a tool flagged as memory-worthy with source
+// "tool" and returns the new memory ID ("" when skipped or failed). Near-duplicates
+// of existing memories are skipped like auto-captures.
+func (a *Agent) rememberToolResult(tool string, m tools.Memorable) string {
+	if a.memoryStore == nil {
+		return ""
+	}
+	if results, _ := a.memoryStore.Search(m.Text, 1, 0.95); len(results) > 0 {
+		return results[0].Entry.ID
+	}
+	category := m.Category
+	if category == "" {
+		category = tools.DetectCategory(m.Text)
+	}
+	importance := m.Importance
+	if importance <= 0 {
+		importance = a.captureImportance(category)
+	}
+	id, err := a.memoryStore.StoreWithSource(m.Text, category, importance, "tool")
+	if err != nil {
+		log.Printf("⚠️ %s result memory write failed: %v", tool, err)
+		return ""
+	}
+	return id
+}
@@ -0,0 +1,127 @@
+// Chat streams over RPC - a streamed turn runs in the background and the caller
+// polls its text, since net/rpc has no server push
+
+package agent
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+const (
+	// streamPollWait is how long ChatStreamNext waits for new text before it
+	// answers with none, so one poll never outlives the caller's RPC timeout
+	streamPollWait = 10 * time.Second
+	// streamAbandonAfter drops a finished stream nobody polled for this long
+	streamAbandonAfter = 2 * time.Minute
+)
+
+// chatStream is one streamed turn: the text not yet polled and, once the turn
+// returned, its reply
+type chatStream struct {
+	mu       sync.Mutex
+	pending  strings.Builder
+	done     bool
+	doneAt   time.Time
+	reply    rpcproto.ChatReply
+	wake     chan struct{} // signalled on new text and on done
+	lastPoll time.Time
+}
+
+func (st *chatStream) signal() {
+	select {
+	case st.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (st *chatStream) write(delta string) {
+	st.mu.Lock()
+	st.pending.WriteString(delta)
+	st.mu.Unlock()
+	st.signal()
+}
+
+func (st *chatStream) finish(reply rpcproto.ChatReply) {
+	st.mu.Lock()
+	st.done, st.doneAt, st.reply = true, time.Now(), reply
+	st.mu.Unlock()
+	st.signal()
+}
+
+// next waits up to wait for text or the end of the turn and takes what is there
+func (st *chatStream) next(wait time.Duration) rpcproto.ChatStreamNextReply {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		st.mu.Lock()
+		st.lastPoll = time.Now()
+		if st.pending.Len() > 0 || st.done {
+			out := rpcproto.ChatStreamNextReply{Delta: st.pending.String(), Done: st.done}
+			st.pending.Reset()
+			if st.done {
+				out.Reply = st.reply
+			}
+			st.mu.Unlock()
+			return out
+		}
+		st.mu.Unlock()
+		select {
+		case <-st.wake:
+		case <-timer.C:
+			return rpcproto.ChatStreamNextReply{}
+		}
+	}
+}
+
+// chatStreams holds the streams of the running and unread turns by ID
+type chatStreams struct {
+	mu sync.Mutex
+	m  map[string]*chatStream
+}
+
+func (cs *chatStreams) add() (string, *chatStream) {
+	b := make([]byte, 12)
+	rand.Read(b)
+	id := "stream-" + hex.EncodeToString(b)
+	st := &chatStream{wake: make(chan struct{}, 1), lastPoll: time.Now()}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if cs.m == nil {
+		cs.m = make(map[string]*chatStream)
+	}
+	// A caller that went away never reads the end of its stream
+	for old, s := range cs.m {
+		s.mu.Lock()
+		stale := s.done && time.Since(s.lastPoll) > streamAbandonAfter && time.Since(s.doneAt) > streamAbandonAfter
+		s.mu.Unlock()
+		if stale {
+			delete(cs.m, old)
+		}
+	}
+	cs.m[id] = st
+	return id, st
+}
+
+func (cs *chatStreams) get(id string) (*chatStream, error) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	st, ok := cs.m[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown chat stream %q", id)
+	}
+	return st, nil
+}
+
+func (cs *chatStreams) remove(id string) {
+	cs.mu.Lock()
+	delete(cs.m, id)
+	cs.mu.Unlock()
+}
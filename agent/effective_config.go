@@ -0,0 +1,80 @@
+// Effective config - what the agent actually runs with, for /debug/config
+
+package agent
+
+// Where the LLM settings (apiKey, baseUrl, model) came from
+const (
+	configSourceEnv      = "env"      // env vars / env.config / config.json, resolved by cmd/agent
+	configSourceDatabase = "database" // the llm section of the config table
+	configSourceSetup    = "setup"    // UpdateConfig (/setup/config)
+)
+
+// EffectiveConfig reports the resolved agent configuration with secrets masked:
+// the API key as maskKey prints it, extra header and query values as "****"
+func (a *Agent) EffectiveConfig() map[string]interface{} {
+	emptyResponse := a.emptyResponse
+	if emptyResponse == "" {
+		emptyResponse = EmptyResponseError
+	}
+	cfg := map[string]interface{}{
+		"llm": map[string]interface{}{
+			"apiKey":  maskKey(a.apiKey),
+			"baseUrl": a.baseURL,
+			"model":   a.model,
+			"source":  a.configSource,
+		},
+		"extraHeaders":       maskedKeys(a.extraHeaders),
+		"extraQuery":         maskedKeys(a.extraQuery),
+		"noSystemRoleModels": a.noSystemRole,
+		"toolSchemaRules":    a.toolSchemaRules,
+		"toolSelection":      a.toolSelect,
+		"toolRepeatLimit":    a.repeatLimit(),
+		"apiMaxRetries":      a.maxRetries(),
+		"apiTimeout":         a.apiTimeout.String(),
+		"emptyResponse":      emptyResponse,
+		"recall": map[string]interface{}{
+			"auto":            a.autoRecall,
+			"limit":           a.recallLimit,
+			"minScore":        a.recallMinScore,
+			"decay":           a.recallDecay,
+			"keywordFallback": a.recallKeywordFallback,
+			"cacheTTL":        a.recallTTL().String(),
+			"customTemplate":  a.recallTemplate != "",
+			"categoryBoost":   a.recallBoost,
+		},
+		"capture": map[string]interface{}{
+			"assistant":  a.captureAssistant,
+			"importance": a.captureWeights,
+		},
+		"output": map[string]interface{}{
+			"maxChars": a.output.MaxChars,
+			"filters":  len(a.output.Filters),
+		},
+		"retention": map[string]interface{}{
+			"maxMessages": a.retention.MaxMessages,
+			"maxAge":      a.retention.MaxAge.String(),
+		},
+		"pulse":  a.pulse != nil,
+		"memory": nil,
+	}
+	if a.memoryStore != nil {
+		cfg["memory"] = a.memoryStore.Settings()
+	}
+	return cfg
+}
+
+// maskedKeys keeps the names of m and hides the values (headers may carry keys);
+// an empty value, which removes the header, stays empty
+func maskedKeys(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		if v != "" {
+			v = "****"
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,93 @@
+// Output policy - content filters and a length cap on every reply before it leaves the agent
+
+package agent
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// OutputFilter rewrites outbound reply content (redaction, profanity masking, ...).
+// Filters run in order; the length cap applies to their result.
+type OutputFilter func(content string) string
+
+// OutputPolicy is applied to the final reply of every turn (zero value = unchanged)
+type OutputPolicy struct {
+	// MaxChars caps the reply length in characters; longer replies are cut and end
+	// with a truncation marker (0 = no cap)
+	MaxChars int
+	Filters  []OutputFilter
+}
+
+// truncatedMarker ends a reply that was cut at OutputPolicy.MaxChars
+const truncatedMarker = "\n\n[output truncated: %d of %d characters shown]"
+
+// apply runs the filters, then enforces the cap
+func (p OutputPolicy) apply(content string) string {
+	for _, f := range p.Filters {
+		content = f(content)
+	}
+	if p.MaxChars <= 0 {
+		return content
+	}
+	runes := []rune(content)
+	if len(runes) <= p.MaxChars {
+		return content
+	}
+	// Prefer a word boundary when one is close to the cap
+	head := string(runes[:p.MaxChars])
+	if i := strings.LastIndexAny(head, " \n\t"); i > len(head)*9/10 {
+		head = head[:i]
+	}
+	head = strings.TrimRight(head, " \n\t")
+	slog.Warn("reply truncated", "chars", len(runes), "max", p.MaxChars)
+	return head + fmt.Sprintf(truncatedMarker, len([]rune(head)), len(runes))
+}
+
+// Patterns masked by RedactPII
+var (
+	piiEmail = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	piiCard  = regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`)
+	piiPhone = regexp.MustCompile(`\+?\d[\d ().-]{7,}\d`)
+)
+
+// RedactPII masks email addresses, card-like digit runs and phone numbers.
+// A phone number needs 9+ digits, so dates and versions are left alone.
+func RedactPII(content string) string {
+	content = piiEmail.ReplaceAllString(content, "[email]")
+	content = piiCard.ReplaceAllString(content, "[number]")
+	return piiPhone.ReplaceAllStringFunc(content, func(m string) string {
+		digits := 0
+		for _, r := range m {
+			if r >= '0' && r <= '9' {
+				digits++
+			}
+		}
+		if digits < 9 {
+			return m
+		}
+		return "[phone]"
+	})
+}
+
+// NewBlocklistFilter masks the given words (case-insensitive, whole words) with
+// asterisks; nil when words is empty
+func NewBlocklistFilter(words []string) OutputFilter {
+	quoted := make([]string, 0, len(words))
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, regexp.QuoteMeta(w))
+		}
+	}
+	if len(quoted) == 0 {
+		return nil
+	}
+	re := regexp.MustCompile(`(?i)\b(?:` + strings.Join(quoted, "|") + `)\b`)
+	return func(content string) string {
+		return re.ReplaceAllStringFunc(content, func(m string) string {
+			return strings.Repeat("*", len([]rune(m)))
+		})
+	}
+}
@@ -0,0 +1,20 @@
+// Recall category boost - per-category weights of the auto-recall re-rank
+
+package agent
+
+import "math"
+
+// DefaultRecallCategoryBoost is added to 1 to weight recalled memories by category;
+// categories missing from the map get no boost
+var DefaultRecallCategoryBoost = map[string]float64{
+	"decision":   0.2,
+	"preference": 0.15,
+	"fact":       0.1,
+	"entity":     0.05,
+}
+
+// ParseRecallCategoryBoost parses "fact=0.3,preference=0.05" into a category map.
+// A boost of 0 removes a default one.
+func ParseRecallCategoryBoost(s string) (map[string]float64, error) {
+	return parseWeights(s, "recall boost", math.Inf(1))
+}
@@ -0,0 +1,95 @@
+// Precomputed recall - search anticipated prompts ahead of the turn that sends them
+
+package agent
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRecallCacheTTL is how long a precomputed recall stays valid when
+// Config.RecallCacheTTL is 0. Kept short: memories stored in the meantime are
+// not part of a cached result.
+const DefaultRecallCacheTTL = 2 * time.Minute
+
+// recallCacheMax bounds the number of precomputed prompts kept at once
+const recallCacheMax = 256
+
+type recallCacheEntry struct {
+	memories string // formatted recall block, "" when nothing matched
+	expires  time.Time
+}
+
+// recallCache maps normalized prompts to their recall block. It is not keyed by
+// session: recall searches the one long-term memory every session shares.
+type recallCache struct {
+	mu      sync.Mutex
+	entries map[string]recallCacheEntry
+}
+
+// recallKey makes "Hello  World" and "hello world" hit the same entry
+func recallKey(prompt string) string {
+	return strings.Join(strings.Fields(strings.ToLower(prompt)), " ")
+}
+
+func (c *recallCache) get(prompt string, now time.Time) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[recallKey(prompt)]
+	if !ok || now.After(e.expires) {
+		return "", false
+	}
+	return e.memories, true
+}
+
+func (c *recallCache) put(prompt, memories string, expires time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]recallCacheEntry)
+	}
+	if len(c.entries) >= recallCacheMax {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+	}
+	if len(c.entries) >= recallCacheMax {
+		return
+	}
+	c.entries[recallKey(prompt)] = recallCacheEntry{memories: memories, expires: expires}
+}
+
+// recallTTL resolves the configured lifetime of precomputed recall
+func (a *Agent) recallTTL() time.Duration {
+	if a.recallCacheTTL > 0 {
+		return a.recallCacheTTL
+	}
+	return DefaultRecallCacheTTL
+}
+
+// PrecomputeRecall runs auto-recall for prompts the caller expects next (guided
+// onboarding, suggested replies, ...) and keeps the results for the recall TTL.
+// A Chat turn whose last user message matches one of them, ignoring case and
+// whitespace, injects the cached memories instead of searching again.
+// Returns how many prompts were cached.
+func (a *Agent) PrecomputeRecall(queries []string) int {
+	if a.memoryStore == nil {
+		return 0
+	}
+	cached := 0
+	for _, q := range queries {
+		if strings.TrimSpace(q) == "" {
+			continue
+		}
+		memories := a.searchRecall(q)
+		a.recallCache.put(q, memories, time.Now().Add(a.recallTTL()))
+		cached++
+	}
+	slog.Debug("recall precomputed", "queries", cached, "ttl", a.recallTTL())
+	return cached
+}
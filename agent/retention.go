@@ -0,0 +1,100 @@
+// Conversation retention - archives messages beyond the configured policy
+
+package agent
+
+import (
+	"log"
+	"time"
+)
+
+// RetentionPolicy bounds live messages per session. Messages outside the
+// policy are moved to messages_archive, never deleted.
+type RetentionPolicy struct {
+	MaxMessages int           // Keep at most N newest messages per session (0 = unlimited)
+	MaxAge      time.Duration // Archive messages older than this (0 = unlimited)
+	Interval    time.Duration // Maintenance pass interval (default 1 hour)
+}
+
+// Enabled reports whether any retention rule is set
+func (p RetentionPolicy) Enabled() bool {
+	return p.MaxMessages > 0 || p.MaxAge > 0
+}
+
+// startRetention runs the maintenance pass once at startup and then on every
+// interval, until the agent shuts down
+func (a *Agent) startRetention() {
+	interval := a.retention.Interval
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	log.Printf("[Retention] enabled: maxMessages=%d maxAge=%s interval=%s",
+		a.retention.MaxMessages, a.retention.MaxAge, interval)
+
+	go func() {
+		a.EnforceRetention()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-a.ctx.Done():
+				return
+			case <-ticker.C:
+				a.EnforceRetention()
+			}
+		}
+	}()
+}
+
+// EnforceRetention archives messages beyond the policy in every session and
+// refreshes session_meta token totals. Returns the number of archived messages.
+func (a *Agent) EnforceRetention() int64 {
+	if a.store == nil || !a.retention.Enabled() {
+		return 0
+	}
+
+	keys, err := a.store.ListSessionKeys()
+	if err != nil {
+		log.Printf("[Retention] list sessions failed: %v", err)
+		return 0
+	}
+
+	var total int64
+	for _, key := range keys {
+		if a.ctx.Err() != nil {
+			break
+		}
+		total += a.retainSession(key)
+	}
+	return total
+}
+
+// retainSession archives one session's messages beyond the policy. It holds the
+// session lock, and skips a session that is compacting, since compaction
+// re-inserts the rows it keeps and would bring archived ones back; the next
+// pass retries it.
+func (a *Agent) retainSession(key string) int64 {
+	unlock := a.lockSession(key)
+	defer unlock()
+	if !a.beginRewrite(key) {
+		return 0
+	}
+	defer a.endRewrite(key)
+
+	n, err := a.store.ArchiveBeyondRetention(key, a.retention.MaxMessages, a.retention.MaxAge)
+	if err != nil {
+		log.Printf("[Retention] session=%s archive failed: %v", key, err)
+		return 0
+	}
+	if n == 0 {
+		return 0
+	}
+
+	if meta, err := a.store.GetSessionMeta(key); err == nil {
+		if stored, err := a.store.GetMessages(key, 500); err == nil {
+			meta.TotalTokens = a.countStoredTokens(stored)
+			_ = a.store.UpsertSessionMeta(meta)
+		}
+	}
+	log.Printf("🗄️ Retention: session=%s archived=%d", key, n)
+	return n
+}
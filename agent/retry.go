@@ -0,0 +1,119 @@
+// Upstream retries - resend a chat completion on 429 and 5xx with backoff
+
+package agent
+
+import (
+	"context"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// DefaultAPIMaxRetries is the per-turn retry budget when Config.APIMaxRetries is 0
+const DefaultAPIMaxRetries = 2
+
+// Backoff doubles from retryBaseDelay with full jitter and never exceeds
+// retryMaxDelay. A longer Retry-After is not waited out: the error goes to the user.
+const (
+	retryBaseDelay = 500 * time.Millisecond
+	retryMaxDelay  = 30 * time.Second
+)
+
+// maxRetries resolves the configured budget; negative disables retries
+func (a *Agent) maxRetries() int {
+	if a.apiMaxRetries == 0 {
+		return DefaultAPIMaxRetries
+	}
+	if a.apiMaxRetries < 0 {
+		return 0
+	}
+	return a.apiMaxRetries
+}
+
+// retryable reports whether a status may succeed when resent. Other 4xx codes
+// are the request's fault and fail the same way every time.
+func retryable(status int) bool {
+	return status == http.StatusTooManyRequests || status >= 500
+}
+
+// postUpstream sends body and retries 429/5xx answers. The budget belongs to the
+// turn, not the request, so a tool-call chain of N rounds retries at most
+// maxRetries times in total instead of N*maxRetries. Each attempt gets its own
+// apiTimeout deadline, released when the caller closes the response body.
+func (a *Agent) postUpstream(ctx context.Context, turn *chatTurn, url string, body []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		attemptCtx, cancel := context.WithTimeout(ctx, a.apiTimeout)
+		req, err := a.newUpstreamRequest(attemptCtx, "POST", url, body)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		resp, err := a.client.Do(req)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		resp.Body = cancelOnClose{resp.Body, cancel}
+		if !retryable(resp.StatusCode) || turn.apiRetries >= a.maxRetries() {
+			return resp, nil
+		}
+		wait, ok := retryDelay(resp.Header.Get("Retry-After"), attempt)
+		if !ok {
+			return resp, nil
+		}
+		// Drain so the connection is reused
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		turn.apiRetries++
+		log.Printf("⚠️ Upstream answered %d, retry %d/%d in %v (model=%s)", resp.StatusCode, turn.apiRetries, a.maxRetries(), wait.Round(time.Millisecond), a.model)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// cancelOnClose ends an attempt's context together with its response body
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// retryDelay honours Retry-After (seconds or an HTTP date); without it the delay
+// is a random duration up to retryBaseDelay*2^attempt. ok is false when the
+// server asks for longer than retryMaxDelay.
+func retryDelay(retryAfter string, attempt int) (time.Duration, bool) {
+	if retryAfter != "" {
+		var wait time.Duration
+		if secs, err := strconv.Atoi(retryAfter); err == nil {
+			wait = time.Duration(secs) * time.Second
+		} else if at, err := http.ParseTime(retryAfter); err == nil {
+			wait = time.Until(at)
+		} else {
+			return backoff(attempt), true
+		}
+		if wait > retryMaxDelay {
+			return 0, false
+		}
+		return max(wait, 0), true
+	}
+	return backoff(attempt), true
+}
+
+func backoff(attempt int) time.Duration {
+	ceiling := retryMaxDelay
+	if attempt < 16 {
+		ceiling = min(retryBaseDelay<<attempt, retryMaxDelay)
+	}
+	return rand.N(ceiling) + 1
+}
@@ -0,0 +1,90 @@
+// Role ordering - repair message sequences that strict providers reject
+
+package agent
+
+import (
+	"log/slog"
+	"strings"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+// sanitizeRoles repairs the role sequence before it is sent upstream:
+//   - consecutive system, user, or plain assistant messages are merged
+//   - a tool message without a preceding assistant tool call (matching tool_call_id)
+//     becomes user text
+//
+// Valid sequences come back unchanged. The input slice is not modified.
+func sanitizeRoles(messages []Message) []Message {
+	out := make([]Message, 0, len(messages))
+	open := make(map[string]bool) // tool_call ids of the last assistant tool-call message
+	repairs := 0
+	for _, m := range messages {
+		if m.Role == "tool" {
+			if len(open) > 0 && (m.ToolCallID == "" || open[m.ToolCallID]) {
+				out = append(out, m)
+				continue
+			}
+			m = Message{Role: "user", Content: "Tool result: " + m.Content}
+			repairs++
+		} else {
+			open = make(map[string]bool)
+		}
+
+		if n := len(out); n > 0 && mergeableRoles(out[n-1], m) {
+			out[n-1] = appendMessage(out[n-1], m)
+			repairs++
+		} else {
+			out = append(out, m)
+		}
+		if m.Role == "assistant" {
+			for _, tc := range m.ToolCalls {
+				open[tc.ID] = true
+			}
+		}
+	}
+	if repairs > 0 {
+		slog.Debug("message roles repaired", "repairs", repairs, "before", len(messages), "after", len(out))
+	}
+	return out
+}
+
+// mergeableRoles reports whether next can be folded into prev without losing tool structure
+func mergeableRoles(prev, next Message) bool {
+	if prev.Role != next.Role {
+		return false
+	}
+	switch prev.Role {
+	case "system", "user":
+		return true
+	case "assistant":
+		return len(prev.ToolCalls) == 0 && len(next.ToolCalls) == 0
+	}
+	return false
+}
+
+// appendMessage adds next's content after m, keeping multimodal parts of either side
+func appendMessage(m, next Message) Message {
+	if len(m.Parts) > 0 || len(next.Parts) > 0 {
+		m.Parts = append(textParts(m), textParts(next)...)
+	}
+	switch {
+	case strings.TrimSpace(next.Content) == "":
+	case strings.TrimSpace(m.Content) == "":
+		m.Content = next.Content
+	default:
+		m.Content = m.Content + "\n\n" + next.Content
+	}
+	return m
+}
+
+// textParts returns m as content parts, its plain text becoming a text part
+func textParts(m Message) []rpcproto.ContentPart {
+	if len(m.Parts) > 0 {
+		return append([]rpcproto.ContentPart(nil), m.Parts...)
+	}
+	if m.Content == "" {
+		return nil
+	}
+	return []rpcproto.ContentPart{{Type: "text", Text: m.Content}}
+}
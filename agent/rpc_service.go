@@ -2,14 +2,21 @@ package agent
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/gliderlab/cogate/memory"
 	"github.com/gliderlab/cogate/rpcproto"
+	"github.com/gliderlab/cogate/storage"
 	"github.com/gliderlab/cogate/tools"
 )
 
 type RPCService struct {
 	agent *Agent
+	// streams are the turns started with ChatStream
+	streams chatStreams
 }
 
 func NewRPCService(a *Agent) *RPCService {
@@ -20,12 +27,50 @@ func (s *RPCService) Chat(args rpcproto.ChatArgs, reply *rpcproto.ChatReply) err
 	if s.agent == nil {
 		return fmt.Errorf("agent not initialized")
 	}
+	msgs, opts := chatRequest(args)
+	*reply = chatReply(s.agent.ChatWithOptions(msgs, opts))
+	return nil
+}
 
+// ChatStream starts a turn whose text is read with ChatStreamNext as the model
+// writes it. The turn runs to the end even if nobody polls.
+func (s *RPCService) ChatStream(args rpcproto.ChatArgs, reply *rpcproto.ChatStreamReply) error {
+	if s.agent == nil {
+		return fmt.Errorf("agent not initialized")
+	}
+	msgs, opts := chatRequest(args)
+	id, st := s.streams.add()
+	opts.OnToken = st.write
+	go func() {
+		st.finish(chatReply(s.agent.ChatWithOptions(msgs, opts)))
+	}()
+	reply.StreamID = id
+	return nil
+}
+
+// ChatStreamNext returns the text a stream produced since the last call, waiting
+// briefly when there is none yet. The reply with Done set carries the turn's
+// ChatReply and ends the stream.
+func (s *RPCService) ChatStreamNext(args rpcproto.ChatStreamNextArgs, reply *rpcproto.ChatStreamNextReply) error {
+	st, err := s.streams.get(args.StreamID)
+	if err != nil {
+		return err
+	}
+	*reply = st.next(streamPollWait)
+	if reply.Done {
+		s.streams.remove(args.StreamID)
+	}
+	return nil
+}
+
+// chatRequest converts RPC chat arguments for ChatWithOptions
+func chatRequest(args rpcproto.ChatArgs) ([]Message, ChatOptions) {
 	msgs := make([]Message, len(args.Messages))
 	for i, m := range args.Messages {
 		msgs[i] = Message{
 			Role:    m.Role,
 			Content: m.Content,
+			Parts:   m.Parts,
 		}
 		if len(m.ToolCalls) > 0 {
 			msgs[i].ToolCalls = make([]ToolCall, len(m.ToolCalls))
@@ -51,8 +96,36 @@ func (s *RPCService) Chat(args rpcproto.ChatArgs, reply *rpcproto.ChatReply) err
 		}
 	}
 
-	reply.Content = s.agent.Chat(msgs)
-	return nil
+	return msgs, ChatOptions{
+		Seed:        args.Seed,
+		Temperature: args.Temperature,
+		N:           args.N,
+		Logprobs:    args.Logprobs,
+		TopLogprobs: args.TopLogprobs,
+		Trace:       args.Trace,
+		SessionKey:  args.SessionKey,
+	}
+}
+
+// chatReply converts a turn's result to the RPC reply
+func chatReply(result ChatResult) rpcproto.ChatReply {
+	reply := rpcproto.ChatReply{
+		Content: result.Content,
+		Choices: result.Choices,
+		Trace:   result.Trace,
+		Type:    rpcproto.ReplyTypeMessage,
+	}
+	if result.Err != nil {
+		reply.Error = result.Err.Error()
+		if errors.Is(result.Err, ErrEmptyUpstream) {
+			reply.ErrorCode = rpcproto.ReplyErrorEmptyUpstream
+		}
+	}
+	if result.Clarification != nil {
+		reply.Type = rpcproto.ReplyTypeAskUser
+		reply.Clarification = result.Clarification
+	}
+	return reply
 }
 
 func (s *RPCService) Stats(_ struct{}, reply *rpcproto.StatsReply) error {
@@ -74,10 +147,13 @@ func (s *RPCService) MemorySearch(args rpcproto.MemorySearchArgs, reply *rpcprot
 
 	tool := tools.NewMemoryTool(s.agent.MemoryStore())
 	result, err := tool.Execute(map[string]interface{}{
-		"query":    args.Query,
-		"category": args.Category,
-		"limit":    args.Limit,
-		"minScore": args.MinScore,
+		"query":         args.Query,
+		"category":      args.Category,
+		"limit":         args.Limit,
+		"minScore":      args.MinScore,
+		"ef":            args.Ef,
+		"fields":        args.Fields,
+		"snippetLength": args.Snippet,
 	})
 	if err != nil {
 		return err
@@ -111,9 +187,10 @@ func (s *RPCService) MemoryStore(args rpcproto.MemoryStoreArgs, reply *rpcproto.
 
 	tool := tools.NewMemoryStoreTool(s.agent.MemoryStore())
 	result, err := tool.Execute(map[string]interface{}{
-		"text":       args.Text,
-		"category":   args.Category,
-		"importance": args.Importance,
+		"text":        args.Text,
+		"category":    args.Category,
+		"importance":  args.Importance,
+		"external_id": args.ExternalID,
 	})
 	if err != nil {
 		return err
@@ -124,25 +201,266 @@ func (s *RPCService) MemoryStore(args rpcproto.MemoryStoreArgs, reply *rpcproto.
 	return nil
 }
 
-// PulseArgs represents arguments for pulse operations
-type PulseArgs struct {
-	Action   string // "add", "status", "list"
-	Title    string
-	Content  string
-	Priority int    // 0-3
-	Channel  string
-	Limit    int
+// MemoryStoreBatch stores memories whose vectors the caller computed, in one
+// transaction. They go through the agent's store, so its HNSW index, category
+// partitions and label mapping include them right away.
+func (s *RPCService) MemoryStoreBatch(args rpcproto.MemoryStoreBatchArgs, reply *rpcproto.MemoryStoreBatchReply) error {
+	if s.agent == nil || s.agent.MemoryStore() == nil {
+		return fmt.Errorf("memory store not initialized")
+	}
+	entries := make([]memory.MemoryEntry, len(args.Entries))
+	for i, e := range args.Entries {
+		entries[i] = memory.MemoryEntry{
+			Text:       e.Text,
+			Vector:     e.Vector,
+			Category:   e.Category,
+			Importance: e.Importance,
+			Source:     e.Source,
+		}
+	}
+	ids, err := s.agent.MemoryStore().StoreEmbedded(entries)
+	if err != nil {
+		return err
+	}
+	reply.IDs = ids
+	return nil
 }
 
-// PulseReply represents the result of pulse operations
-type PulseReply struct {
-	Result  string
-	EventID int64
-	Status  string
+// MemoryDelete removes a memory; the result has deleted=false when the ID is unknown
+func (s *RPCService) MemoryDelete(args rpcproto.MemoryDeleteArgs, reply *rpcproto.ToolResultReply) error {
+	if s.agent == nil || s.agent.MemoryStore() == nil {
+		return fmt.Errorf("memory store not initialized")
+	}
+
+	tool := tools.NewMemoryDeleteTool(s.agent.MemoryStore())
+	result, err := tool.Execute(map[string]interface{}{"path": args.ID})
+	if err != nil {
+		return err
+	}
+	// Convert to JSON string to support gob serialization
+	jsonBytes, _ := json.Marshal(result)
+	reply.Result = string(jsonBytes)
+	return nil
+}
+
+// SetupStatus reports whether the LLM is configured (onboarding)
+func (s *RPCService) SetupStatus(_ struct{}, reply *rpcproto.SetupStatusReply) error {
+	if s.agent == nil {
+		return fmt.Errorf("agent not initialized")
+	}
+	*reply = s.agent.SetupStatus()
+	return nil
+}
+
+// Warmup primes tool specs, the upstream connection and the embedding provider;
+// ocg start calls it once the agent socket is up
+func (s *RPCService) Warmup(_ struct{}, reply *rpcproto.WarmupReply) error {
+	if s.agent == nil {
+		return fmt.Errorf("agent not initialized")
+	}
+	*reply = s.agent.Warmup()
+	return nil
+}
+
+// Ping is the gateway heartbeat; it takes no locks so a busy agent still answers
+func (s *RPCService) Ping(_ struct{}, reply *rpcproto.PingReply) error {
+	if s.agent == nil {
+		return fmt.Errorf("agent not initialized")
+	}
+	reply.UptimeMs = time.Since(s.agent.startedAt).Milliseconds()
+	return nil
+}
+
+// UpdateConfig applies LLM config from the UI and returns the new setup status
+func (s *RPCService) UpdateConfig(args rpcproto.UpdateConfigArgs, reply *rpcproto.SetupStatusReply) error {
+	if s.agent == nil {
+		return fmt.Errorf("agent not initialized")
+	}
+	apiKey, baseURL, model := s.agent.GetConfig()
+	if args.APIKey != "" {
+		apiKey = args.APIKey
+	}
+	if args.BaseURL != "" {
+		baseURL = args.BaseURL
+	}
+	if args.Model != "" {
+		model = args.Model
+	}
+	s.agent.UpdateConfig(apiKey, baseURL, model)
+	*reply = s.agent.SetupStatus()
+	return nil
+}
+
+// RefreshTools rebuilds the tool specs cache from the registry and lists the tools the model now sees
+func (s *RPCService) RefreshTools(_ struct{}, reply *rpcproto.ToolResultReply) error {
+	if s.agent == nil {
+		return fmt.Errorf("agent not initialized")
+	}
+	names := s.agent.RefreshTools()
+	jsonBytes, _ := json.Marshal(map[string]interface{}{
+		"tools": names,
+		"count": len(names),
+	})
+	reply.Result = string(jsonBytes)
+	return nil
+}
+
+// HNSWStatus returns the active HNSW parameters
+func (s *RPCService) HNSWStatus(_ struct{}, reply *rpcproto.HNSWStatusReply) error {
+	if s.agent == nil || s.agent.MemoryStore() == nil {
+		return fmt.Errorf("memory store not initialized")
+	}
+	cfg, ok := s.agent.MemoryStore().HNSWConfig()
+	*reply = rpcproto.HNSWStatusReply{Enabled: ok}
+	if ok {
+		reply.Dim = cfg.Dim
+		reply.M = cfg.M
+		reply.EfSearch = cfg.EfSearch
+		reply.EfConstruct = cfg.EfConstruct
+		reply.Distance = cfg.Distance
+	}
+	return nil
+}
+
+// EmbeddingStats reports embedding provider calls, latency and errors (JSON in Result)
+func (s *RPCService) EmbeddingStats(_ struct{}, reply *rpcproto.ToolResultReply) error {
+	if s.agent == nil || s.agent.MemoryStore() == nil {
+		return fmt.Errorf("memory store not initialized")
+	}
+	data, err := json.Marshal(s.agent.MemoryStore().EmbeddingStats())
+	if err != nil {
+		return err
+	}
+	reply.Result = string(data)
+	return nil
+}
+
+// EffectiveConfig returns the resolved, masked agent configuration (JSON in Result)
+func (s *RPCService) EffectiveConfig(_ struct{}, reply *rpcproto.ToolResultReply) error {
+	if s.agent == nil {
+		return fmt.Errorf("agent not initialized")
+	}
+	data, err := json.Marshal(s.agent.EffectiveConfig())
+	if err != nil {
+		return err
+	}
+	reply.Result = string(data)
+	return nil
+}
+
+// SetEfSearch tunes HNSW search-time ef at runtime
+func (s *RPCService) SetEfSearch(args rpcproto.SetEfSearchArgs, reply *rpcproto.HNSWStatusReply) error {
+	if s.agent == nil || s.agent.MemoryStore() == nil {
+		return fmt.Errorf("memory store not initialized")
+	}
+	if err := s.agent.MemoryStore().SetEfSearch(args.EfSearch); err != nil {
+		return err
+	}
+	return s.HNSWStatus(struct{}{}, reply)
+}
+
+// KVGet reads a kv_cache entry (used by the gateway for idempotent replays)
+func (s *RPCService) KVGet(args rpcproto.KVGetArgs, reply *rpcproto.KVGetReply) error {
+	if s.agent == nil || s.agent.Store() == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	value, found, err := s.agent.Store().KVGet(args.Namespace, args.Key)
+	if err != nil {
+		return fmt.Errorf("kv get: %v", err)
+	}
+	reply.Value = value
+	reply.Found = found
+	return nil
+}
+
+// KVSet writes a kv_cache entry
+func (s *RPCService) KVSet(args rpcproto.KVSetArgs, reply *rpcproto.ToolResultReply) error {
+	if s.agent == nil || s.agent.Store() == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	ttl := time.Duration(args.TTLSeconds) * time.Second
+	if err := s.agent.Store().KVSet(args.Namespace, args.Key, args.Value, ttl); err != nil {
+		return fmt.Errorf("kv set: %v", err)
+	}
+	reply.Result = "ok"
+	return nil
+}
+
+// MemorySnapshot writes an online backup of the memory DB and index to args.Dir
+func (s *RPCService) MemorySnapshot(args rpcproto.SnapshotArgs, reply *rpcproto.ToolResultReply) error {
+	if s.agent == nil || s.agent.MemoryStore() == nil {
+		return fmt.Errorf("memory store not initialized")
+	}
+	if args.Dir == "" {
+		return fmt.Errorf("dir is required")
+	}
+	if err := s.agent.MemoryStore().Snapshot(args.Dir); err != nil {
+		return err
+	}
+	reply.Result = args.Dir
+	return nil
+}
+
+// MemoryRestore replaces the live memories with the snapshot in args.Dir
+func (s *RPCService) MemoryRestore(args rpcproto.SnapshotArgs, reply *rpcproto.ToolResultReply) error {
+	if s.agent == nil || s.agent.MemoryStore() == nil {
+		return fmt.Errorf("memory store not initialized")
+	}
+	if args.Dir == "" {
+		return fmt.Errorf("dir is required")
+	}
+	if err := s.agent.MemoryStore().RestoreFromSnapshot(args.Dir); err != nil {
+		return err
+	}
+	reply.Result = args.Dir
+	return nil
+}
+
+// AuditAppend records an admin/config operation
+func (s *RPCService) AuditAppend(args rpcproto.AuditAppendArgs, reply *rpcproto.ToolResultReply) error {
+	if s.agent == nil || s.agent.Store() == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	id, err := s.agent.Store().AppendAudit(storage.AuditEntry{
+		Actor:        args.Actor,
+		KeyID:        args.KeyID,
+		ClaimedActor: args.ClaimedActor,
+		Action:       args.Action,
+		Target:       args.Target,
+		Status:       args.Status,
+		RemoteAddr:   args.RemoteAddr,
+	})
+	if err != nil {
+		return fmt.Errorf("audit append: %v", err)
+	}
+	reply.Result = strconv.FormatInt(id, 10)
+	return nil
+}
+
+// AuditList returns audit entries as JSON {"entries": [...], "count": N}
+func (s *RPCService) AuditList(args rpcproto.AuditListArgs, reply *rpcproto.ToolResultReply) error {
+	if s.agent == nil || s.agent.Store() == nil {
+		return fmt.Errorf("storage not initialized")
+	}
+	entries, err := s.agent.Store().ListAudit(storage.AuditFilter{
+		Actor:    args.Actor,
+		Action:   args.Action,
+		BeforeID: args.BeforeID,
+		Limit:    args.Limit,
+	})
+	if err != nil {
+		return fmt.Errorf("audit list: %v", err)
+	}
+	if entries == nil {
+		entries = []storage.AuditEntry{}
+	}
+	data, _ := json.Marshal(map[string]interface{}{"entries": entries, "count": len(entries)})
+	reply.Result = string(data)
+	return nil
 }
 
 // PulseAdd adds a new pulse event
-func (s *RPCService) PulseAdd(args PulseArgs, reply *PulseReply) error {
+func (s *RPCService) PulseAdd(args rpcproto.PulseArgs, reply *rpcproto.PulseReply) error {
 	if s.agent == nil {
 		return fmt.Errorf("agent not initialized")
 	}
@@ -159,7 +477,7 @@ func (s *RPCService) PulseAdd(args PulseArgs, reply *PulseReply) error {
 }
 
 // PulseStatus returns the current pulse system status
-func (s *RPCService) PulseStatus(args struct{}, reply *PulseReply) error {
+func (s *RPCService) PulseStatus(args struct{}, reply *rpcproto.PulseReply) error {
 	if s.agent == nil {
 		return fmt.Errorf("agent not initialized")
 	}
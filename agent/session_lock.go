@@ -0,0 +1,46 @@
+// Session locks - serialize turns on one session, keep sessions independent
+
+package agent
+
+import (
+	"log"
+	"sync"
+)
+
+// sessionLock is a per-session mutex, dropped from the map once nobody holds or waits on it
+type sessionLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// lockSession blocks until no other turn runs on sessionKey and returns the unlock func.
+// History writes, recall injection and pending clarifications of a turn then never
+// interleave with another turn of the same session.
+func (a *Agent) lockSession(sessionKey string) func() {
+	a.sessionMu.Lock()
+	if a.sessionLocks == nil {
+		a.sessionLocks = make(map[string]*sessionLock)
+	}
+	l := a.sessionLocks[sessionKey]
+	if l == nil {
+		l = &sessionLock{}
+		a.sessionLocks[sessionKey] = l
+	}
+	l.refs++
+	a.sessionMu.Unlock()
+
+	if !l.mu.TryLock() {
+		log.Printf("⏳ Session %s busy, waiting for the previous turn", sessionKey)
+		l.mu.Lock()
+	}
+
+	return func() {
+		l.mu.Unlock()
+		a.sessionMu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(a.sessionLocks, sessionKey)
+		}
+		a.sessionMu.Unlock()
+	}
+}
@@ -0,0 +1,203 @@
+// Upstream streaming - read the provider's SSE reply and pass the text on as it arrives
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// TokenFunc receives reply text in the order the upstream produces it
+type TokenFunc func(delta string)
+
+// customToolCallTag opens a MiniMax-style tool call written into the content
+const customToolCallTag = "<minimax:tool_call>"
+
+// streamChunk is one "chat.completion.chunk" event of a streamed completion
+type streamChunk struct {
+	Choices []struct {
+		Index int `json:"index"`
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Type     string `json:"type"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason *string `json:"finish_reason"`
+	} `json:"choices"`
+}
+
+// streams reports whether this turn asks the upstream for a streamed reply. Only
+// single-completion turns stream, and only without an output policy: filters and
+// the length cap run on the finished reply, which streamed text would bypass.
+func (a *Agent) streams(turn *chatTurn) bool {
+	return turn.opts.OnToken != nil && !turn.opts.wantsChoices() &&
+		a.output.MaxChars <= 0 && len(a.output.Filters) == 0
+}
+
+// streamSeparator sets the text of a round apart from what earlier rounds streamed
+const streamSeparator = "\n\n"
+
+// streamRound wraps OnToken for one upstream round and collects what it passes
+// on. Text a model writes before calling a tool has already reached the caller,
+// so it stays part of the reply: the next round is set off from it by
+// streamSeparator, and joinStreamed puts the same text in front of the final
+// content.
+func (turn *chatTurn) streamRound(round *strings.Builder) TokenFunc {
+	return func(delta string) {
+		if round.Len() == 0 && turn.preamble != "" {
+			round.WriteString(streamSeparator)
+			turn.opts.OnToken(streamSeparator)
+		}
+		round.WriteString(delta)
+		turn.opts.OnToken(delta)
+	}
+}
+
+// joinStreamed is the final reply of a turn whose earlier rounds streamed preamble
+func joinStreamed(preamble, content string) string {
+	if preamble == "" {
+		return content
+	}
+	if content == "" {
+		return preamble
+	}
+	return preamble + streamSeparator + content
+}
+
+// isEventStream tells a streamed answer from a provider that ignored stream:true
+func isEventStream(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+// readStream assembles the SSE body of a streamed completion into the response the
+// non-streamed path decodes, so tool-call handling is the same for both. Content
+// is passed to emit as it arrives, except what may belong to a tool call.
+func readStream(body io.Reader, emit TokenFunc) (ChatResponse, error) {
+	var resp ChatResponse
+	var content strings.Builder
+	var calls []ToolCall
+	finish := ""
+	gate := &tokenGate{emit: emit}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, "data:") {
+			continue // blank separators, ": keep-alive" comments, event: lines
+		}
+		data := strings.TrimSpace(strings.TrimPrefix(line, "data:"))
+		if data == "[DONE]" {
+			break
+		}
+		var chunk streamChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return resp, fmt.Errorf("bad stream chunk: %v", err)
+		}
+		for _, c := range chunk.Choices {
+			if c.Index != 0 {
+				continue
+			}
+			if c.Delta.Content != "" {
+				content.WriteString(c.Delta.Content)
+				gate.write(c.Delta.Content)
+			}
+			for _, d := range c.Delta.ToolCalls {
+				// Text next to a tool call is not the answer; stop passing it on
+				gate.hold()
+				for len(calls) <= d.Index {
+					calls = append(calls, ToolCall{})
+				}
+				tc := &calls[d.Index]
+				if d.ID != "" {
+					tc.ID = d.ID
+				}
+				if d.Type != "" {
+					tc.Type = d.Type
+				}
+				tc.Function.Name += d.Function.Name
+				tc.Function.Arguments += d.Function.Arguments
+			}
+			if c.FinishReason != nil {
+				finish = *c.FinishReason
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return resp, fmt.Errorf("stream read: %v", err)
+	}
+	gate.flush()
+
+	if content.Len() == 0 && len(calls) == 0 && finish == "" {
+		return resp, nil // no choices, as in an empty non-streamed response
+	}
+	resp.Choices = []Choice{{
+		Message:      Message{Role: "assistant", Content: content.String(), ToolCalls: calls},
+		FinishReason: finish,
+	}}
+	return resp, nil
+}
+
+// tokenGate forwards streamed text but keeps back anything that may start a
+// custom tool call, so the caller never sees a half-written <minimax:tool_call>
+type tokenGate struct {
+	emit    TokenFunc
+	pending string
+	held    bool
+}
+
+func (g *tokenGate) write(delta string) {
+	if g.held {
+		return
+	}
+	g.pending += delta
+	lower := strings.ToLower(g.pending)
+	if len(lower) != len(g.pending) {
+		lower = g.pending // offsets must match the original bytes
+	}
+	if i := strings.Index(lower, customToolCallTag); i >= 0 {
+		g.send(g.pending[:i])
+		g.hold()
+		return
+	}
+	// Keep a trailing partial tag ("<mini") until the next delta decides it
+	keep := 0
+	for n := len(customToolCallTag) - 1; n > 0; n-- {
+		if strings.HasSuffix(lower, customToolCallTag[:n]) {
+			keep = n
+			break
+		}
+	}
+	g.send(g.pending[:len(g.pending)-keep])
+	g.pending = g.pending[len(g.pending)-keep:]
+}
+
+// hold stops forwarding for the rest of this response
+func (g *tokenGate) hold() {
+	g.held = true
+	g.pending = ""
+}
+
+// flush forwards a held-back partial tag that turned out to be plain text
+func (g *tokenGate) flush() {
+	if !g.held {
+		g.send(g.pending)
+	}
+	g.pending = ""
+}
+
+func (g *tokenGate) send(text string) {
+	if text != "" {
+		g.emit(text)
+	}
+}
@@ -0,0 +1,151 @@
+package agent
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+func sseBody(events ...string) string {
+	var b strings.Builder
+	for _, e := range events {
+		b.WriteString("data: " + e + "\n\n")
+	}
+	b.WriteString("data: [DONE]\n\n")
+	return b.String()
+}
+
+func TestTokenGate(t *testing.T) {
+	cases := []struct {
+		name   string
+		deltas []string
+		want   string
+	}{
+		{"plain", []string{"Hello", ", world"}, "Hello, world"},
+		{"tag split across deltas", []string{"Sure. <mini", "max:tool", "_call>{\"name\":\"read\"}"}, "Sure. "},
+		{"tag in one delta", []string{"ok<minimax:tool_call>x", "more"}, "ok"},
+		{"upper-case tag", []string{"a<MINIMAX:TOOL_CALL>"}, "a"},
+		{"partial tag is text", []string{"1 <mini", "2"}, "1 <mini2"},
+		{"partial tag at the end", []string{"x <minimax:to"}, "x <minimax:to"},
+	}
+	for _, c := range cases {
+		var got strings.Builder
+		g := &tokenGate{emit: func(d string) { got.WriteString(d) }}
+		for _, d := range c.deltas {
+			g.write(d)
+		}
+		g.flush()
+		if got.String() != c.want {
+			t.Errorf("%s: emitted %q, want %q", c.name, got.String(), c.want)
+		}
+	}
+}
+
+func TestReadStreamTextThenToolCall(t *testing.T) {
+	body := sseBody(
+		`{"choices":[{"index":0,"delta":{"content":"Let me check. "}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read","arguments":"{\"path\":"}}]}}]}`,
+		`{"choices":[{"index":0,"delta":{"content":"ignored"}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a.txt\"}"}}]},"finish_reason":"tool_calls"}]}`,
+	)
+	var emitted strings.Builder
+	resp, err := readStream(strings.NewReader(body), func(d string) { emitted.WriteString(d) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if emitted.String() != "Let me check. " {
+		t.Errorf("emitted %q, want the text before the tool call only", emitted.String())
+	}
+	if len(resp.Choices) != 1 {
+		t.Fatalf("got %d choices", len(resp.Choices))
+	}
+	msg := resp.Choices[0].Message
+	if len(msg.ToolCalls) != 1 {
+		t.Fatalf("got %d tool calls", len(msg.ToolCalls))
+	}
+	tc := msg.ToolCalls[0]
+	if tc.ID != "call_1" || tc.Function.Name != "read" || tc.Function.Arguments != `{"path":"a.txt"}` {
+		t.Errorf("tool call = %+v", tc)
+	}
+	if resp.Choices[0].FinishReason != "tool_calls" {
+		t.Errorf("finish reason = %q", resp.Choices[0].FinishReason)
+	}
+}
+
+// The text streamed over a tool-call chain must be a prefix of the final reply
+func TestStreamedTextPrefixesReply(t *testing.T) {
+	var sent strings.Builder
+	turn := &chatTurn{opts: ChatOptions{OnToken: func(d string) { sent.WriteString(d) }}}
+
+	// Round 1 writes text and then calls a tool
+	var round1 strings.Builder
+	if _, err := readStream(strings.NewReader(sseBody(
+		`{"choices":[{"index":0,"delta":{"content":"Checking the file."}}]}`,
+		`{"choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"c","type":"function","function":{"name":"read","arguments":"{}"}}]}}]}`,
+	)), turn.streamRound(&round1)); err != nil {
+		t.Fatal(err)
+	}
+	turn.preamble += round1.String()
+
+	// Round 2 answers
+	var round2 strings.Builder
+	resp, err := readStream(strings.NewReader(sseBody(
+		`{"choices":[{"index":0,"delta":{"content":"It says hi."}}]}`,
+	)), turn.streamRound(&round2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := joinStreamed(turn.preamble, resp.Choices[0].Message.Content)
+
+	if want := "Checking the file.\n\nIt says hi."; reply != want {
+		t.Errorf("reply = %q, want %q", reply, want)
+	}
+	if sent.String() != reply {
+		t.Errorf("streamed %q, reply %q", sent.String(), reply)
+	}
+}
+
+func TestChatStreamNext(t *testing.T) {
+	var streams chatStreams
+	id, st := streams.add()
+
+	if got := st.next(10 * time.Millisecond); got.Delta != "" || got.Done {
+		t.Errorf("idle poll = %+v, want empty", got)
+	}
+	st.write("Hel")
+	st.write("lo")
+	if got := st.next(time.Second); got.Delta != "Hello" || got.Done {
+		t.Errorf("poll = %+v, want Hello", got)
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		st.write("!")
+		st.finish(rpcproto.ChatReply{Content: "Hello!"})
+	}()
+	var text strings.Builder
+	text.WriteString("Hello")
+	for {
+		got := st.next(time.Second)
+		text.WriteString(got.Delta)
+		if got.Done {
+			if got.Reply.Content != "Hello!" {
+				t.Errorf("reply = %q", got.Reply.Content)
+			}
+			break
+		}
+	}
+	if text.String() != "Hello!" {
+		t.Errorf("streamed %q", text.String())
+	}
+
+	if _, err := streams.get(id); err != nil {
+		t.Errorf("get: %v", err)
+	}
+	streams.remove(id)
+	if _, err := streams.get(id); err == nil {
+		t.Error("removed stream still found")
+	}
+}
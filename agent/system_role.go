@@ -0,0 +1,97 @@
+// System role rewriting - for models that reject or mishandle "system" messages
+
+package agent
+
+import (
+	"path"
+	"strings"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+// ParseModelPatterns splits a comma-separated model list ("gemma-*,mistral-7b*").
+// Patterns are matched case-insensitively as globs against the configured model.
+func ParseModelPatterns(s string) []string {
+	var out []string
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p != "" {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// needsSystemRewrite reports whether model matches one of the no-system-role patterns.
+// Provider prefixes ("google/gemma-2-9b") are tried both with and without the prefix.
+func (a *Agent) needsSystemRewrite(model string) bool {
+	for _, p := range a.noSystemRole {
+		if matchModel(p, model) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchModel matches a lower-case glob against model, with and without its provider prefix
+func matchModel(pattern, model string) bool {
+	model = strings.ToLower(model)
+	base := model
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		base = model[i+1:]
+	}
+	if ok, _ := path.Match(pattern, model); ok || pattern == model {
+		return true
+	}
+	ok, _ := path.Match(pattern, base)
+	return ok
+}
+
+// mergeSystemMessages folds system messages into the next user message, or into
+// the preceding user message when none follows. With no user message at all the
+// system text is sent as a user message. The input slice is not modified.
+func mergeSystemMessages(messages []Message) []Message {
+	out := make([]Message, 0, len(messages))
+	var pending []string
+	for _, m := range messages {
+		if m.Role == "system" {
+			if strings.TrimSpace(m.Content) != "" {
+				pending = append(pending, m.Content)
+			}
+			continue
+		}
+		if m.Role == "user" && len(pending) > 0 {
+			m = prependText(m, strings.Join(pending, "\n\n"))
+			pending = nil
+		}
+		out = append(out, m)
+	}
+	if len(pending) == 0 {
+		return out
+	}
+
+	text := strings.Join(pending, "\n\n")
+	for i := len(out) - 1; i >= 0; i-- {
+		if out[i].Role == "user" {
+			out[i] = prependText(out[i], text)
+			return out
+		}
+	}
+	return append([]Message{{Role: "user", Content: text}}, out...)
+}
+
+// prependText puts instructions ahead of a user message, keeping multimodal parts intact
+func prependText(m Message, text string) Message {
+	if len(m.Parts) > 0 {
+		parts := make([]rpcproto.ContentPart, 0, len(m.Parts)+1)
+		parts = append(parts, rpcproto.ContentPart{Type: "text", Text: text})
+		m.Parts = append(parts, m.Parts...)
+		return m
+	}
+	if m.Content == "" {
+		m.Content = text
+	} else {
+		m.Content = text + "\n\n" + m.Content
+	}
+	return m
+}
@@ -0,0 +1,159 @@
+// On-demand tools - leave the tool list out of conversational turns and bring it
+// back when the reply shows the model wanted one
+
+package agent
+
+import (
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+// Limits under which a message without tool cues counts as conversational.
+// Longer messages are usually tasks, so they keep the tools.
+const (
+	chatMaxWords = 12
+	chatMaxRunes = 80
+)
+
+// toolCues are words that suggest the message asks for something a tool does
+var toolCues = []string{
+	"search", "find", "look up", "lookup", "google", "browse", "web", "http", "www.",
+	"url", "link", "fetch", "download", "weather", "news", "price",
+	"file", "folder", "directory", "read", "write", "edit", "open", "save", "delete",
+	"create", "list", "run", "exec", "command", "shell", "script", "install", "code",
+	"remember", "recall", "memory", "memorize", "forget", "note",
+	"remind", "schedule", "cron", "timer", "every day", "tomorrow",
+	"session", "process", "kill", "status", "check", "calculate", "convert",
+	"搜索", "查", "找", "文件", "读取", "写入", "运行", "执行", "命令", "记住", "记忆",
+	"提醒", "定时", "网页", "打开", "下载", "天气", "新闻",
+}
+
+// toolWishes are phrases of a tool-less reply that mean the model wanted a tool
+var toolWishes = []string{
+	"i don't have access to", "i do not have access to", "i don't have the ability to",
+	"i can't browse", "i cannot browse", "i can't access", "i cannot access",
+	"i can't run", "i cannot run", "i can't execute", "i cannot execute",
+	"i would need to use", "i'd need to use", "i need to use", "use a tool", "use the tool",
+	"let me search", "let me check", "let me look", "let me run", "let me read",
+	"i'll search", "i'll check", "i'll look", "i'll run", "i'll read",
+	"tool_call", "<tool", "function call",
+}
+
+// gateTools reports whether this turn's tools are withheld: OnDemand is set, the
+// retry has not already brought them back, and the latest user message looks
+// like conversation. Streamed turns keep their tools since a retry would send
+// the client a second answer.
+func (a *Agent) gateTools(turn *chatTurn, messages []Message, specs []rpcproto.Tool) bool {
+	if !a.toolSelect.OnDemand || turn.toolsWanted || len(specs) == 0 || a.streams(turn) {
+		return false
+	}
+	if len(messages) == 0 || messages[len(messages)-1].Role != "user" {
+		return false
+	}
+	return !needsTools(messages[len(messages)-1].Content, specs)
+}
+
+// needsTools is the cheap relevance test: a long message, one with a tool cue
+// or one naming a registered tool is assumed to need the tools
+func needsTools(text string, specs []rpcproto.Tool) bool {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return false
+	}
+	if len(strings.Fields(text)) > chatMaxWords || utf8.RuneCountInString(text) > chatMaxRunes {
+		return true
+	}
+	if strings.ContainsAny(text, "/\\`") {
+		return true
+	}
+	for _, cue := range toolCues {
+		if strings.Contains(text, cue) {
+			return true
+		}
+	}
+	return mentionsTool(text, specs)
+}
+
+// wantsTool reports whether a reply given without tools asks for one. Plain
+// mentions of a tool name do not count, since names like read or exec are
+// ordinary words; only the toolWishes phrases and call syntax do.
+func wantsTool(content string, specs []rpcproto.Tool) bool {
+	content = strings.ToLower(content)
+	for _, w := range toolWishes {
+		if strings.Contains(content, w) {
+			return true
+		}
+	}
+	return callsTool(content, specs)
+}
+
+// callsTool reports whether text writes a registered tool as code: `name`,
+// `name ...` or name( as a whole word
+func callsTool(text string, specs []rpcproto.Tool) bool {
+	for _, t := range specs {
+		name := strings.ToLower(t.Function.Name)
+		if name == "" {
+			continue
+		}
+		if strings.Contains(text, "`"+name+"`") || strings.Contains(text, "`"+name+" ") {
+			return true
+		}
+		for i := strings.Index(text, name+"("); i >= 0; {
+			if !isNameByte(text, i-1) {
+				return true
+			}
+			next := strings.Index(text[i+1:], name+"(")
+			if next < 0 {
+				break
+			}
+			i += 1 + next
+		}
+	}
+	return false
+}
+
+// mentionsTool reports whether text names a registered tool as a whole word
+func mentionsTool(text string, specs []rpcproto.Tool) bool {
+	for _, t := range specs {
+		name := strings.ToLower(t.Function.Name)
+		if name == "" {
+			continue
+		}
+		for i := strings.Index(text, name); i >= 0; {
+			end := i + len(name)
+			if !isNameByte(text, i-1) && !isNameByte(text, end) {
+				return true
+			}
+			next := strings.Index(text[end:], name)
+			if next < 0 {
+				break
+			}
+			i = end + next
+		}
+	}
+	return false
+}
+
+func isNameByte(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return false
+	}
+	c := s[i]
+	return c == '_' || c == '-' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+// retryWithTools reports whether a tool-less round is to be resent with the
+// tools because its reply asks for one; at most once per turn
+func (a *Agent) retryWithTools(turn *chatTurn, content string) bool {
+	if !turn.toolsGated || turn.toolsWanted || !wantsTool(content, a.toolSpecs()) {
+		return false
+	}
+	slog.Debug("tools: reply asks for a tool, retrying with tools")
+	turn.toolsWanted = true
+	turn.toolsGated = false
+	turn.tools = nil
+	return true
+}
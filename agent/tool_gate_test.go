@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+func gateSpecs(names ...string) []rpcproto.Tool {
+	specs := make([]rpcproto.Tool, len(names))
+	for i, n := range names {
+		specs[i] = rpcproto.Tool{Type: "function", Function: rpcproto.ToolFunction{Name: n}}
+	}
+	return specs
+}
+
+func TestNeedsTools(t *testing.T) {
+	specs := gateSpecs("read", "write", "exec", "web_search", "memory_search")
+	cases := []struct {
+		text string
+		want bool
+	}{
+		{"hello", false},
+		{"Hi there, how are you?", false},
+		{"thanks, that was great!", false},
+		{"good morning :)", false},
+		{"你好", false},
+		{"", false},
+		{"search the news for me", true},
+		{"what's in /etc/hosts", true},
+		{"please exec ls", true},
+		{"run `uptime`", true},
+		{"use web_search on this", true},
+		{"remember that I like tea", true},
+		{"帮我搜索一下", true},
+		{"tell me a long story about a dragon who lived in a castle by the sea and loved tea", true},
+	}
+	for _, c := range cases {
+		if got := needsTools(c.text, specs); got != c.want {
+			t.Errorf("needsTools(%q) = %v, want %v", c.text, got, c.want)
+		}
+	}
+}
+
+func TestWantsTool(t *testing.T) {
+	specs := gateSpecs("read", "write", "edit", "exec", "process", "nodes", "web_search")
+	chat := []string{
+		"Happy to read it!",
+		"Hello! Nice to meet you.",
+		"You write beautifully, and I'd edit nothing.",
+		"That process sounds stressful. How many nodes are in your graph?",
+		"Sure, here's a poem about the sea.",
+	}
+	for _, reply := range chat {
+		if wantsTool(reply, specs) {
+			t.Errorf("plain chat reply counted as a tool request: %q", reply)
+		}
+	}
+	asks := []string{
+		"Let me check the weather for you.",
+		"I don't have access to the internet from here.",
+		"I would call `web_search` for that.",
+		"Running `exec ls -la` would show it.",
+		"web_search(\"cogate release\")",
+		"<tool_call>{\"name\":\"read\"}</tool_call>",
+	}
+	for _, reply := range asks {
+		if !wantsTool(reply, specs) {
+			t.Errorf("tool request not detected: %q", reply)
+		}
+	}
+	if wantsTool("myread(x) is a helper", specs) {
+		t.Error("call syntax inside a longer name counted as a tool call")
+	}
+}
@@ -0,0 +1,52 @@
+// Tool repeat guard - stop a model from re-running the same tool call within a turn
+
+package agent
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+)
+
+// DefaultToolRepeatLimit is how often one identical call may run per turn when
+// Config.ToolRepeatLimit is 0
+const DefaultToolRepeatLimit = 2
+
+// repeatLimit resolves the configured limit; negative disables the guard
+func (a *Agent) repeatLimit() int {
+	if a.toolRepeatLimit == 0 {
+		return DefaultToolRepeatLimit
+	}
+	return a.toolRepeatLimit
+}
+
+// repeatedCall counts the call under its name and arguments and reports whether it
+// exceeds the limit, in which case it is answered without running the tool
+func (a *Agent) repeatedCall(turn *chatTurn, name string, args map[string]interface{}) (int, bool) {
+	limit := a.repeatLimit()
+	if limit < 0 {
+		return 0, false
+	}
+	// Map keys marshal sorted, so key order and whitespace in the raw JSON do not matter
+	canon, _ := json.Marshal(args)
+	key := name + "\x00" + string(canon)
+	if turn.toolCalls == nil {
+		turn.toolCalls = make(map[string]int)
+	}
+	turn.toolCalls[key]++
+	n := turn.toolCalls[key]
+	if n > limit {
+		slog.Warn("repeated tool call skipped", "tool", name, "count", n, "limit", limit)
+		return n, true
+	}
+	return n, false
+}
+
+// repeatedCallResult is the tool result sent back instead of running a repeated call
+func repeatedCallResult(tool string, count int) map[string]interface{} {
+	return map[string]interface{}{
+		"error":   fmt.Sprintf("repeated call: %s was already called %d times this turn with these exact arguments. The tool was not run again; use the earlier result, change the arguments, or answer the user.", tool, count-1),
+		"tool":    tool,
+		"success": false,
+	}
+}
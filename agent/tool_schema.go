@@ -0,0 +1,307 @@
+// Tool schema shims - reshape tool parameter schemas for providers with stricter validators
+
+package agent
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+// Tool schema profiles
+const (
+	// ToolSchemaPassthrough sends the registry schemas unchanged
+	ToolSchemaPassthrough = "passthrough"
+	// ToolSchemaCompat fills in what loose schemas leave out: parameters, properties,
+	// required:[] and additionalProperties:false on every object
+	ToolSchemaCompat = "compat"
+	// ToolSchemaStrict is OpenAI strict mode: compat plus strict:true, every property
+	// listed in required (optional ones become nullable) and no defaults
+	ToolSchemaStrict = "strict"
+	// ToolSchemaGemini drops keywords Gemini rejects (additionalProperties, default, $schema)
+	// and omits parameters for tools without arguments
+	ToolSchemaGemini = "gemini"
+)
+
+var toolSchemaProfiles = map[string]bool{
+	ToolSchemaPassthrough: true,
+	ToolSchemaCompat:      true,
+	ToolSchemaStrict:      true,
+	ToolSchemaGemini:      true,
+}
+
+// ToolSchemaRule selects a schema profile for models matching Pattern (glob, as in NoSystemRoleModels)
+type ToolSchemaRule struct {
+	Pattern string
+	Profile string
+}
+
+// ParseToolSchemaRules parses "compat" (all models) or "gpt-4o*=strict,gemini-*=gemini,*=compat".
+// The first matching rule wins.
+func ParseToolSchemaRules(s string) ([]ToolSchemaRule, error) {
+	var rules []ToolSchemaRule
+	for _, item := range strings.Split(s, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		pattern, profile, ok := strings.Cut(item, "=")
+		if !ok {
+			pattern, profile = "*", item
+		}
+		pattern, profile = strings.TrimSpace(pattern), strings.TrimSpace(profile)
+		if !toolSchemaProfiles[profile] {
+			return nil, fmt.Errorf("unknown tool schema profile %q (want passthrough, compat, strict or gemini)", profile)
+		}
+		if pattern == "" {
+			return nil, fmt.Errorf("empty model pattern in %q", item)
+		}
+		rules = append(rules, ToolSchemaRule{Pattern: pattern, Profile: profile})
+	}
+	return rules, nil
+}
+
+// toolSchemaProfile returns the profile for model (passthrough when no rule matches)
+func (a *Agent) toolSchemaProfile(model string) string {
+	for _, r := range a.toolSchemaRules {
+		if matchModel(r.Pattern, model) {
+			return r.Profile
+		}
+	}
+	return ToolSchemaPassthrough
+}
+
+// shapeToolSpecs returns copies of specs adjusted to profile; the cached specs are not modified
+func shapeToolSpecs(profile string, specs []rpcproto.Tool) []rpcproto.Tool {
+	if profile == ToolSchemaPassthrough || profile == "" {
+		return specs
+	}
+	out := make([]rpcproto.Tool, len(specs))
+	for i, t := range specs {
+		out[i] = t
+		params := copySchema(t.Function.Parameters)
+		switch profile {
+		case ToolSchemaCompat:
+			out[i].Function.Parameters = compatSchema(rootSchema(params))
+		case ToolSchemaStrict:
+			out[i].Function.Parameters = strictSchema(rootSchema(params))
+			out[i].Function.Strict = true
+		case ToolSchemaGemini:
+			params = geminiSchema(params)
+			if props, _ := params["properties"].(map[string]interface{}); len(props) == 0 {
+				params = nil
+			}
+			out[i].Function.Parameters = params
+		}
+	}
+	return out
+}
+
+// rootSchema makes sure the parameters are an object schema
+func rootSchema(params map[string]interface{}) map[string]interface{} {
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	if _, ok := params["type"]; !ok {
+		params["type"] = "object"
+	}
+	return params
+}
+
+// compatSchema completes every object schema in place
+func compatSchema(schema map[string]interface{}) map[string]interface{} {
+	if isObjectSchema(schema) {
+		props, _ := schema["properties"].(map[string]interface{})
+		if props == nil {
+			props = map[string]interface{}{}
+			schema["properties"] = props
+		}
+		// Drop required names without a property: validators reject them
+		required := make([]interface{}, 0)
+		for _, name := range schemaRequired(schema) {
+			if _, ok := props[name]; ok {
+				required = append(required, name)
+			}
+		}
+		schema["required"] = required
+		if _, ok := schema["additionalProperties"]; !ok {
+			schema["additionalProperties"] = false
+		}
+	}
+	eachSubschema(schema, func(sub map[string]interface{}) { compatSchema(sub) })
+	return schema
+}
+
+// strictSchema applies OpenAI strict-mode rules in place
+func strictSchema(schema map[string]interface{}) map[string]interface{} {
+	delete(schema, "default")
+	if isObjectSchema(schema) {
+		props, _ := schema["properties"].(map[string]interface{})
+		if props == nil {
+			props = map[string]interface{}{}
+			schema["properties"] = props
+		}
+		wasRequired := make(map[string]bool)
+		for _, name := range schemaRequired(schema) {
+			wasRequired[name] = true
+		}
+		names := make([]string, 0, len(props))
+		for name := range props {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		required := make([]interface{}, len(names))
+		for i, name := range names {
+			required[i] = name
+			if prop, ok := props[name].(map[string]interface{}); ok && !wasRequired[name] {
+				makeNullable(prop)
+			}
+		}
+		schema["required"] = required
+		schema["additionalProperties"] = false
+	}
+	eachSubschema(schema, func(sub map[string]interface{}) { strictSchema(sub) })
+	return schema
+}
+
+// geminiSchema removes keywords Gemini's function declarations reject, in place
+func geminiSchema(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	for _, k := range []string{"additionalProperties", "default", "$schema", "strict"} {
+		delete(schema, k)
+	}
+	eachSubschema(schema, func(sub map[string]interface{}) { geminiSchema(sub) })
+	return schema
+}
+
+// makeNullable lets an optional property be sent as null (strict mode requires every key)
+func makeNullable(prop map[string]interface{}) {
+	switch t := prop["type"].(type) {
+	case string:
+		if t != "null" {
+			prop["type"] = []interface{}{t, "null"}
+		}
+	case []interface{}:
+		for _, v := range t {
+			if v == "null" {
+				return
+			}
+		}
+		prop["type"] = append(t, "null")
+	case []string:
+		types := make([]interface{}, 0, len(t)+1)
+		for _, v := range t {
+			if v == "null" {
+				return
+			}
+			types = append(types, v)
+		}
+		prop["type"] = append(types, "null")
+	default:
+		return
+	}
+	if enum := schemaEnum(prop); enum != nil {
+		prop["enum"] = append(enum, nil)
+	}
+}
+
+func isObjectSchema(schema map[string]interface{}) bool {
+	switch t := schema["type"].(type) {
+	case string:
+		return t == "object"
+	case []interface{}:
+		for _, v := range t {
+			if v == "object" {
+				return true
+			}
+		}
+	}
+	_, hasProps := schema["properties"]
+	return hasProps && schema["type"] == nil
+}
+
+// schemaRequired reads "required" whether it is []string (tool registry) or []interface{} (decoded JSON)
+func schemaRequired(schema map[string]interface{}) []string {
+	switch r := schema["required"].(type) {
+	case []string:
+		return r
+	case []interface{}:
+		out := make([]string, 0, len(r))
+		for _, v := range r {
+			if s, ok := v.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func schemaEnum(schema map[string]interface{}) []interface{} {
+	switch e := schema["enum"].(type) {
+	case []interface{}:
+		return e
+	case []string:
+		out := make([]interface{}, len(e))
+		for i, v := range e {
+			out[i] = v
+		}
+		return out
+	}
+	return nil
+}
+
+// eachSubschema calls fn for property, items and anyOf/oneOf/allOf schemas
+func eachSubschema(schema map[string]interface{}, fn func(map[string]interface{})) {
+	if props, ok := schema["properties"].(map[string]interface{}); ok {
+		for _, p := range props {
+			if sub, ok := p.(map[string]interface{}); ok {
+				fn(sub)
+			}
+		}
+	}
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		fn(items)
+	}
+	for _, k := range []string{"anyOf", "oneOf", "allOf"} {
+		if list, ok := schema[k].([]interface{}); ok {
+			for _, v := range list {
+				if sub, ok := v.(map[string]interface{}); ok {
+					fn(sub)
+				}
+			}
+		}
+	}
+}
+
+// copySchema deep-copies the maps and slices of a schema so shims never touch the cache
+func copySchema(schema map[string]interface{}) map[string]interface{} {
+	if schema == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(schema))
+	for k, v := range schema {
+		out[k] = copySchemaValue(v)
+	}
+	return out
+}
+
+func copySchemaValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		return copySchema(t)
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, e := range t {
+			out[i] = copySchemaValue(e)
+		}
+		return out
+	case []string:
+		return append([]string(nil), t...)
+	}
+	return v
+}
@@ -0,0 +1,203 @@
+// Tool selection - cap the tools sent upstream by priority or by relevance to the query
+
+package agent
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+// Tool selection strategies
+const (
+	// ToolSelectAll sends every registered tool (MaxTools still caps the count)
+	ToolSelectAll = "all"
+	// ToolSelectPriority keeps the first MaxTools tools after ordering by ToolPriority
+	ToolSelectPriority = "priority"
+	// ToolSelectRelevance always keeps ToolPriority tools, then fills the remaining slots
+	// with the tools whose descriptions embed closest to the user's message
+	ToolSelectRelevance = "relevance"
+)
+
+// ToolSelection configures how many and which tools are offered to the model
+type ToolSelection struct {
+	// MaxTools caps the tool list; 0 = no cap
+	MaxTools int
+	// Strategy is ToolSelectAll (default), ToolSelectPriority or ToolSelectRelevance
+	Strategy string
+	// Priority lists tool names (globs) in the order they should be kept
+	Priority []string
+	// OnDemand sends no tools when the user's message looks conversational and
+	// repeats the request with them if the reply asks for one
+	OnDemand bool
+}
+
+// ParseToolSelectStrategy validates a strategy name ("" = all)
+func ParseToolSelectStrategy(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "":
+		return ToolSelectAll, nil
+	case ToolSelectAll, ToolSelectPriority, ToolSelectRelevance:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown tool selection strategy %q (want all, priority or relevance)", s)
+}
+
+// toolEmbeddings caches description embeddings by tool name
+type toolEmbeddings struct {
+	mu   sync.Mutex
+	text map[string]string // text the vector was computed from
+	vecs map[string][]float32
+}
+
+// selectTools returns the tools offered for this turn; the choice is made once per
+// turn so every round of a tool-call chain sees the same list
+func (a *Agent) selectTools(turn *chatTurn, messages []Message) []rpcproto.Tool {
+	if turn.tools != nil {
+		return turn.tools
+	}
+	specs := a.toolSpecs()
+	if a.gateTools(turn, messages, specs) {
+		slog.Debug("tools withheld for a conversational message", "registered", len(specs))
+		turn.toolsGated = true
+		turn.tools = []rpcproto.Tool{}
+		return turn.tools
+	}
+	sel := a.toolSelect
+	if sel.MaxTools <= 0 || len(specs) <= sel.MaxTools {
+		turn.tools = specs
+		return specs
+	}
+
+	var picked []rpcproto.Tool
+	switch sel.Strategy {
+	case ToolSelectRelevance:
+		picked = a.relevantTools(specs, lastUserText(messages))
+	case ToolSelectPriority:
+		picked = byToolPriority(specs, sel.Priority)[:sel.MaxTools]
+	default:
+		picked = specs[:sel.MaxTools]
+	}
+	slog.Debug("tools selected", "strategy", sel.Strategy, "offered", len(picked), "registered", len(specs))
+	turn.tools = picked
+	return picked
+}
+
+// relevantTools keeps the pinned (priority) tools and fills the rest by similarity to query.
+// Without an embedding provider (or on error) it falls back to priority order.
+func (a *Agent) relevantTools(specs []rpcproto.Tool, query string) []rpcproto.Tool {
+	max := a.toolSelect.MaxTools
+	ordered := byToolPriority(specs, a.toolSelect.Priority)
+	if a.memoryStore == nil || strings.TrimSpace(query) == "" {
+		return ordered[:max]
+	}
+	queryVec, err := a.memoryStore.Embed(query)
+	if err != nil {
+		log.Printf("⚠️ Tool relevance unavailable, using priority order: %v", err)
+		return ordered[:max]
+	}
+
+	out := make([]rpcproto.Tool, 0, max)
+	var rest []rpcproto.Tool
+	for _, t := range ordered {
+		if toolPriorityRank(t.Function.Name, a.toolSelect.Priority) >= 0 && len(out) < max {
+			out = append(out, t)
+		} else {
+			rest = append(rest, t)
+		}
+	}
+	scores := make(map[string]float32, len(rest))
+	for _, t := range rest {
+		if vec := a.toolEmbedding(t); vec != nil {
+			scores[t.Function.Name] = dotProduct(queryVec, vec)
+		}
+	}
+	sort.SliceStable(rest, func(i, j int) bool {
+		return scores[rest[i].Function.Name] > scores[rest[j].Function.Name]
+	})
+	for _, t := range rest {
+		if len(out) == max {
+			break
+		}
+		out = append(out, t)
+	}
+	return out
+}
+
+// toolEmbedding returns the cached embedding of "name: description", recomputed when it changed
+func (a *Agent) toolEmbedding(t rpcproto.Tool) []float32 {
+	text := t.Function.Name + ": " + t.Function.Description
+	c := &a.toolVecs
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.text[t.Function.Name] == text {
+		return c.vecs[t.Function.Name]
+	}
+	vec, err := a.memoryStore.Embed(text)
+	if err != nil {
+		log.Printf("⚠️ Tool %s embedding failed: %v", t.Function.Name, err)
+		return nil
+	}
+	if c.vecs == nil {
+		c.text = make(map[string]string)
+		c.vecs = make(map[string][]float32)
+	}
+	c.text[t.Function.Name] = text
+	c.vecs[t.Function.Name] = vec
+	return vec
+}
+
+// byToolPriority orders tools by their first matching priority pattern; the rest keep registry order
+func byToolPriority(specs []rpcproto.Tool, priority []string) []rpcproto.Tool {
+	out := append([]rpcproto.Tool(nil), specs...)
+	if len(priority) == 0 {
+		return out
+	}
+	rank := func(t rpcproto.Tool) int {
+		if r := toolPriorityRank(t.Function.Name, priority); r >= 0 {
+			return r
+		}
+		return len(priority)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return rank(out[i]) < rank(out[j]) })
+	return out
+}
+
+// toolPriorityRank is the index of the first pattern matching name, -1 if none
+func toolPriorityRank(name string, priority []string) int {
+	for i, p := range priority {
+		if ok, _ := path.Match(p, name); ok || p == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// lastUserText is the text of the most recent user message
+func lastUserText(messages []Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" {
+			return messages[i].Content
+		}
+	}
+	return ""
+}
+
+// dotProduct of two normalized vectors is their cosine similarity
+func dotProduct(a, b []float32) float32 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var dot float32
+	for i := range a {
+		dot += a[i] * b[i]
+	}
+	return dot
+}
@@ -0,0 +1,124 @@
+// Upstream requests - provider-specific headers and query params (OpenRouter, Azure)
+
+package agent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultAPITimeout bounds an upstream request when Config.APITimeout is 0
+const DefaultAPITimeout = 30 * time.Second
+
+// newUpstreamRequest builds a request to the LLM provider with the default auth
+// headers, then applies the configured extra headers and query params.
+// An extra header with an empty value removes it (e.g. Authorization for Azure api-key auth).
+func (a *Agent) newUpstreamRequest(ctx context.Context, method, endpoint string, body []byte) (*http.Request, error) {
+	if len(a.extraQuery) > 0 {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upstream url: %v", err)
+		}
+		q := u.Query()
+		for k, v := range a.extraQuery {
+			q.Set(k, v)
+		}
+		u.RawQuery = q.Encode()
+		endpoint = u.String()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	for k, v := range a.extraHeaders {
+		if v == "" {
+			req.Header.Del(k)
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// ParseKeyValues parses "k1=v1,k2=v2" or a JSON object into a map.
+// Used for OPENCLAW_EXTRA_HEADERS / OPENCLAW_EXTRA_QUERY.
+func ParseKeyValues(s string) (map[string]string, error) {
+	s = strings.TrimSpace(s)
+	out := make(map[string]string)
+	if s == "" {
+		return out, nil
+	}
+	if strings.HasPrefix(s, "{") {
+		if err := json.Unmarshal([]byte(s), &out); err != nil {
+			return nil, fmt.Errorf("invalid JSON object: %v", err)
+		}
+		return out, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid entry %q (want key=value)", pair)
+		}
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out, nil
+}
+
+// Empty upstream response policies (Config.EmptyResponse)
+const (
+	// EmptyResponseError fails the turn with ErrEmptyUpstream (the gateway answers 502)
+	EmptyResponseError = "error"
+	// EmptyResponseRetry resends the request once, then fails like EmptyResponseError
+	EmptyResponseRetry = "retry"
+	// EmptyResponseMessage replies with the text "no response" (previous behavior)
+	EmptyResponseMessage = "message"
+)
+
+// ErrEmptyUpstream is the turn error when the provider returned no choices
+var ErrEmptyUpstream = errors.New("upstream returned no choices")
+
+// ParseEmptyResponse validates a policy name ("" = error)
+func ParseEmptyResponse(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "":
+		return EmptyResponseError, nil
+	case EmptyResponseError, EmptyResponseRetry, EmptyResponseMessage:
+		return s, nil
+	}
+	return "", fmt.Errorf("unknown empty response policy %q (want error, retry or message)", s)
+}
+
+// emptyUpstream handles a response without choices according to the configured policy
+func (a *Agent) emptyUpstream(ctx context.Context, turn *chatTurn, messages []Message, depth int) string {
+	switch a.emptyResponse {
+	case EmptyResponseMessage:
+		return "no response"
+	case EmptyResponseRetry:
+		if !turn.emptyRetried {
+			turn.emptyRetried = true
+			log.Printf("⚠️ Upstream returned no choices, retrying once (model=%s)", a.model)
+			return a.callAPIWithDepth(ctx, turn, messages, depth)
+		}
+	}
+	log.Printf("⚠️ Upstream returned no choices (model=%s)", a.model)
+	turn.err = ErrEmptyUpstream
+	return ""
+}
@@ -0,0 +1,72 @@
+// Warmup - pay the cold-start costs (tool specs, upstream TLS, embedding connection) before the first chat
+
+package agent
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+// Warmup builds the tool specs cache, opens a keep-alive connection to the LLM
+// provider and primes the embedding provider. Upstream and embedding run in
+// parallel; a failing step is reported in the reply, not as an error, because
+// the agent still serves chats without it.
+func (a *Agent) Warmup() rpcproto.WarmupReply {
+	start := time.Now()
+	reply := rpcproto.WarmupReply{Tools: len(a.toolSpecs())}
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		reply.Upstream = a.warmUpstream()
+	}()
+	go func() {
+		defer wg.Done()
+		reply.Embedding = a.warmEmbedding()
+	}()
+	wg.Wait()
+
+	reply.DurationMs = time.Since(start).Milliseconds()
+	log.Printf("🔥 Warmup done in %dms: tools=%d upstream=%s embedding=%s", reply.DurationMs, reply.Tools, reply.Upstream, reply.Embedding)
+	return reply
+}
+
+// warmUpstream lists the provider's models; any HTTP answer leaves a pooled
+// connection behind, so only transport errors count as failures
+func (a *Agent) warmUpstream() string {
+	if a.apiKey == "" || a.baseURL == "" {
+		return "skipped (not configured)"
+	}
+	ctx, cancel := context.WithTimeout(a.ctx, a.apiTimeout)
+	defer cancel()
+	req, err := a.newUpstreamRequest(ctx, "GET", strings.TrimRight(a.baseURL, "/")+"/models", nil)
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+	// Drain the body so the connection goes back to the pool
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return fmt.Sprintf("ok (%d)", resp.StatusCode)
+}
+
+func (a *Agent) warmEmbedding() string {
+	if a.memoryStore == nil {
+		return "skipped (no memory store)"
+	}
+	if err := a.memoryStore.Warmup(); err != nil {
+		return fmt.Sprintf("error: %v", err)
+	}
+	return "ok"
+}
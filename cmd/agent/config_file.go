@@ -0,0 +1,224 @@
+// Agent config file - OPENCLAW_CONFIG_FILE (JSON or YAML) mapped onto the
+// same keys as env.config and the environment
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type valueKind int
+
+const (
+	kindScalar valueKind = iota
+	kindList             // string or list, joined with ","
+	kindMap              // string or object, written as "k=v,k2=v2"
+	kindJSON             // string or object, written as a JSON object
+)
+
+type fileKey struct {
+	env  string
+	kind valueKind
+}
+
+// configFileKeys maps each "section.field" of the config file to its env key.
+// Lists whose order matters (first matching glob wins) take no object form.
+var configFileKeys = map[string]fileKey{
+	"llm.apiKey":             {"OPENCLAW_API_KEY", kindScalar},
+	"llm.baseUrl":            {"OPENCLAW_BASE_URL", kindScalar},
+	"llm.model":              {"OPENCLAW_MODEL", kindScalar},
+	"llm.timeoutSeconds":     {"OPENCLAW_API_TIMEOUT", kindScalar},
+	"llm.maxRetries":         {"OPENCLAW_API_MAX_RETRIES", kindScalar},
+	"llm.emptyResponse":      {"OPENCLAW_EMPTY_RESPONSE", kindScalar},
+	"llm.extraHeaders":       {"OPENCLAW_EXTRA_HEADERS", kindJSON},
+	"llm.extraQuery":         {"OPENCLAW_EXTRA_QUERY", kindJSON},
+	"llm.noSystemRoleModels": {"OPENCLAW_NO_SYSTEM_ROLE_MODELS", kindList},
+	"llm.tokenizers":         {"OPENCLAW_TOKENIZERS", kindList},
+
+	"agent.socket": {"OPENCLAW_AGENT_SOCK", kindScalar},
+
+	"storage.dbPath":        {"OPENCLAW_DB_PATH", kindScalar},
+	"storage.sqliteCacheMb": {"OPENCLAW_SQLITE_CACHE_MB", kindScalar},
+	"storage.sqliteMmapMb":  {"OPENCLAW_SQLITE_MMAP_MB", kindScalar},
+
+	"embedding.serverUrl":      {"EMBEDDING_SERVER_URL", kindScalar},
+	"embedding.model":          {"EMBEDDING_MODEL", kindScalar},
+	"embedding.apiKey":         {"OPENAI_API_KEY", kindScalar},
+	"embedding.timeoutSeconds": {"EMBEDDING_TIMEOUT_SECONDS", kindScalar},
+	"embedding.cacheSize":      {"EMBEDDING_CACHE_SIZE", kindScalar},
+
+	"hnsw.path":         {"HNSW_PATH", kindScalar},
+	"hnsw.partitions":   {"HNSW_PARTITIONS", kindScalar},
+	"hnsw.m":            {"HNSW_M", kindScalar},
+	"hnsw.efSearch":     {"HNSW_EF_SEARCH", kindScalar},
+	"hnsw.efConstruct":  {"HNSW_EF_CONSTRUCT", kindScalar},
+	"hnsw.distance":     {"HNSW_DISTANCE", kindScalar},
+	"hnsw.compactAfter": {"HNSW_COMPACT_AFTER", kindScalar},
+
+	"memory.hybrid":          {"MEMORY_HYBRID_ENABLED", kindScalar},
+	"memory.vectorWeight":    {"MEMORY_VECTOR_WEIGHT", kindScalar},
+	"memory.textWeight":      {"MEMORY_TEXT_WEIGHT", kindScalar},
+	"memory.hybridNorm":      {"MEMORY_HYBRID_NORM", kindScalar},
+	"memory.ftsMode":         {"MEMORY_FTS_MODE", kindScalar},
+	"memory.scoreFloor":      {"MEMORY_SCORE_FLOOR", kindScalar},
+	"memory.dedupResults":    {"MEMORY_DEDUP_RESULTS", kindScalar},
+	"memory.dedupSimilarity": {"MEMORY_DEDUP_SIMILARITY", kindScalar},
+	"memory.asyncWrites":     {"MEMORY_ASYNC_WRITES", kindScalar},
+	"memory.asyncQueue":      {"MEMORY_ASYNC_QUEUE", kindScalar},
+
+	"recall.auto":            {"OPENCLAW_AUTO_RECALL", kindScalar},
+	"recall.limit":           {"OPENCLAW_RECALL_LIMIT", kindScalar},
+	"recall.minScore":        {"OPENCLAW_RECALL_MINSCORE", kindScalar},
+	"recall.decay":           {"OPENCLAW_RECALL_DECAY", kindScalar},
+	"recall.categoryBoost":   {"OPENCLAW_RECALL_BOOST", kindMap},
+	"recall.template":        {"OPENCLAW_RECALL_TEMPLATE", kindScalar},
+	"recall.keywordFallback": {"OPENCLAW_RECALL_KEYWORD_FALLBACK", kindScalar},
+	"recall.cacheTtlSeconds": {"OPENCLAW_RECALL_CACHE_TTL_SECONDS", kindScalar},
+
+	"capture.importance": {"OPENCLAW_CAPTURE_IMPORTANCE", kindMap},
+	"capture.assistant":  {"OPENCLAW_CAPTURE_ASSISTANT", kindScalar},
+
+	"tools.max":         {"OPENCLAW_MAX_TOOLS", kindScalar},
+	"tools.select":      {"OPENCLAW_TOOL_SELECT", kindScalar},
+	"tools.priority":    {"OPENCLAW_TOOL_PRIORITY", kindList},
+	"tools.onDemand":    {"OPENCLAW_TOOLS_ON_DEMAND", kindScalar},
+	"tools.schema":      {"OPENCLAW_TOOL_SCHEMA", kindList},
+	"tools.repeatLimit": {"OPENCLAW_TOOL_REPEAT_LIMIT", kindScalar},
+
+	"output.maxChars":  {"OPENCLAW_MAX_OUTPUT_CHARS", kindScalar},
+	"output.redactPii": {"OPENCLAW_REDACT_PII", kindScalar},
+	"output.blocklist": {"OPENCLAW_OUTPUT_BLOCKLIST", kindList},
+
+	"retention.maxMessages":     {"OPENCLAW_RETENTION_MAX_MESSAGES", kindScalar},
+	"retention.maxAgeHours":     {"OPENCLAW_RETENTION_MAX_AGE_HOURS", kindScalar},
+	"retention.intervalMinutes": {"OPENCLAW_RETENTION_INTERVAL_MINUTES", kindScalar},
+
+	"pulse.enabled":         {"OPENCLAW_PULSE_ENABLED", kindScalar},
+	"pulse.llm":             {"OPENCLAW_PULSE_LLM", kindScalar},
+	"pulse.intervalSeconds": {"OPENCLAW_PULSE_INTERVAL_SECONDS", kindScalar},
+	"pulse.maxQueue":        {"OPENCLAW_PULSE_MAX_QUEUE", kindScalar},
+	"pulse.cleanupHours":    {"OPENCLAW_PULSE_CLEANUP_HOURS", kindScalar},
+
+	"log.level": {"OPENCLAW_LOG_LEVEL", kindScalar},
+}
+
+// loadConfigFile reads a JSON or YAML (.yaml/.yml) config file and returns its
+// settings keyed like env.config. Unknown fields are an error so a misspelt key
+// does not fall back to a default unnoticed. The "env" section passes raw
+// KEY: value pairs through for settings without a structured field.
+func loadConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &doc)
+	default:
+		err = json.Unmarshal(data, &doc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	out := make(map[string]string)
+	for section, v := range doc {
+		fields, ok := v.(map[string]any)
+		if v != nil && !ok {
+			return nil, fmt.Errorf("%s: %s: want an object", path, section)
+		}
+		for field, fv := range fields {
+			if fv == nil {
+				continue
+			}
+			if section == "env" {
+				s, err := configValue(fv, kindScalar)
+				if err != nil {
+					return nil, fmt.Errorf("%s: env.%s: %v", path, field, err)
+				}
+				out[field] = s
+				continue
+			}
+			name := section + "." + field
+			key, ok := configFileKeys[name]
+			if !ok {
+				return nil, fmt.Errorf("%s: unknown setting %q", path, name)
+			}
+			s, err := configValue(fv, key.kind)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %s: %v", path, name, err)
+			}
+			out[key.env] = s
+		}
+	}
+	return out, nil
+}
+
+// configValue renders one file value in the env.config syntax of its key
+func configValue(v any, kind valueKind) (string, error) {
+	switch val := v.(type) {
+	case []any:
+		if kind != kindList {
+			return "", fmt.Errorf("a list is not allowed here")
+		}
+		items := make([]string, 0, len(val))
+		for _, item := range val {
+			s, err := scalarValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, ","), nil
+	case map[string]any:
+		switch kind {
+		case kindJSON:
+			b, err := json.Marshal(val)
+			return string(b), err
+		case kindMap:
+			keys := make([]string, 0, len(val))
+			for k := range val {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			pairs := make([]string, 0, len(keys))
+			for _, k := range keys {
+				s, err := scalarValue(val[k])
+				if err != nil {
+					return "", err
+				}
+				pairs = append(pairs, k+"="+s)
+			}
+			return strings.Join(pairs, ","), nil
+		case kindList:
+			return "", fmt.Errorf("want a string or a list; entries are matched in order")
+		}
+		return "", fmt.Errorf("an object is not allowed here")
+	}
+	return scalarValue(v)
+}
+
+func scalarValue(v any) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case int:
+		return strconv.Itoa(val), nil
+	case int64:
+		return strconv.FormatInt(val, 10), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("unsupported value %v", v)
+}
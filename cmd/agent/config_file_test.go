@@ -0,0 +1,117 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadConfigFileFormats(t *testing.T) {
+	want := map[string]string{
+		"OPENCLAW_MODEL":                 "gpt-4o-mini",
+		"OPENCLAW_API_TIMEOUT":           "45",
+		"OPENCLAW_AUTO_RECALL":           "true",
+		"OPENCLAW_RECALL_MINSCORE":       "0.35",
+		"OPENCLAW_TOOL_PRIORITY":         "memory_*,exec",
+		"OPENCLAW_CAPTURE_IMPORTANCE":    "decision=0.9,fact=0.6",
+		"OPENCLAW_EXTRA_HEADERS":         `{"X-Title":"OCG"}`,
+		"OPENCLAW_NO_SYSTEM_ROLE_MODELS": "gemma-*",
+		"OPENCLAW_FORCE_ENV_CONFIG":      "true",
+	}
+	files := map[string]string{
+		"agent.yaml": `
+llm:
+  model: gpt-4o-mini
+  timeoutSeconds: 45
+  extraHeaders: {X-Title: OCG}
+  noSystemRoleModels: gemma-*
+recall: {auto: true, minScore: 0.35}
+tools:
+  priority: [memory_*, exec]
+capture:
+  importance: {fact: 0.6, decision: 0.9}
+env:
+  OPENCLAW_FORCE_ENV_CONFIG: true
+`,
+		"agent.json": `{
+  "llm": {"model": "gpt-4o-mini", "timeoutSeconds": 45, "extraHeaders": {"X-Title": "OCG"}, "noSystemRoleModels": "gemma-*"},
+  "recall": {"auto": true, "minScore": 0.35},
+  "tools": {"priority": ["memory_*", "exec"]},
+  "capture": {"importance": {"fact": 0.6, "decision": 0.9}},
+  "env": {"OPENCLAW_FORCE_ENV_CONFIG": true}
+}`,
+	}
+	for name, content := range files {
+		got, err := loadConfigFile(writeConfigFile(t, name, content))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(got) != len(want) {
+			t.Errorf("%s: expected %d settings, got %d: %v", name, len(want), len(got), got)
+		}
+		for k, v := range want {
+			if got[k] != v {
+				t.Errorf("%s: %s = %q, want %q", name, k, got[k], v)
+			}
+		}
+	}
+}
+
+func TestLoadConfigFileErrors(t *testing.T) {
+	cases := map[string]struct {
+		content string
+		err     string
+	}{
+		"unknown.yaml": {"llm:\n  modle: x\n", `unknown setting "llm.modle"`},
+		"section.json": {`{"llm": "gpt-4o"}`, "llm: want an object"},
+		"list.yaml":    {"llm:\n  model: [a, b]\n", "a list is not allowed here"},
+		"object.json":  {`{"llm": {"model": {"name": "x"}}}`, "an object is not allowed here"},
+		"ordered.yaml": {"tools:\n  schema: {gpt-4o*: strict}\n", "entries are matched in order"},
+		"nested.json":  {`{"capture": {"importance": {"fact": [1]}}}`, "unsupported value"},
+		"broken.json":  {`{"llm": `, "broken.json"},
+		"broken.yml":   {"llm: [", "broken.yml"},
+	}
+	for name, c := range cases {
+		_, err := loadConfigFile(writeConfigFile(t, name, c.content))
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: expected error containing %q, got %v", name, c.err, err)
+		}
+	}
+	if _, err := loadConfigFile(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestConfigValueKinds(t *testing.T) {
+	cases := []struct {
+		v    any
+		kind valueKind
+		want string
+	}{
+		{"plain", kindScalar, "plain"},
+		{false, kindScalar, "false"},
+		{7, kindScalar, "7"},
+		{int64(8), kindScalar, "8"},
+		{0.25, kindScalar, "0.25"},
+		{[]any{"a", 2, true}, kindList, "a,2,true"},
+		{"a,b", kindList, "a,b"},
+		{map[string]any{"b": 1, "a": 0.5}, kindMap, "a=0.5,b=1"},
+		{map[string]any{"k": "v"}, kindJSON, `{"k":"v"}`},
+	}
+	for _, c := range cases {
+		got, err := configValue(c.v, c.kind)
+		if err != nil || got != c.want {
+			t.Errorf("configValue(%v, %d) = %q, %v; want %q", c.v, c.kind, got, err, c.want)
+		}
+	}
+}
@@ -12,10 +12,13 @@ import (
 	"sort"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/gliderlab/cogate/agent"
+	"github.com/gliderlab/cogate/logging"
 	"github.com/gliderlab/cogate/memory"
 	"github.com/gliderlab/cogate/storage"
+	"github.com/gliderlab/cogate/tokens"
 	"github.com/gliderlab/cogate/tools"
 )
 
@@ -43,48 +46,147 @@ func main() {
 		"EMBEDDING_MODEL",
 	})
 
+	// 1.1 OPENCLAW_CONFIG_FILE overrides env.config; environment variables override both.
+	// Merged after the sync above so file settings (keys included) never land in env.config.
+	configFile := envValue(envConfig, "OPENCLAW_CONFIG_FILE")
+	if configFile != "" {
+		fileConfig, err := loadConfigFile(configFile)
+		if err != nil {
+			log.Fatalf("Config file: %v", err)
+		}
+		for k, v := range fileConfig {
+			envConfig[k] = v
+		}
+		log.Printf("Loaded %d settings from %s", len(fileConfig), configFile)
+	}
+
+	// Leveled logs: debug adds tool/upstream dumps, other levels redact arguments
+	logLevel := logging.Setup(envValue(envConfig, "OPENCLAW_LOG_LEVEL"))
+	log.Printf("Log level: %s", logLevel)
+	// Exact token counts per model from tiktoken ranks files (default: heuristic)
+	if v := envValue(envConfig, "OPENCLAW_TOKENIZERS"); v != "" {
+		if err := tokens.RegisterFiles(v); err != nil {
+			log.Printf("⚠️ OPENCLAW_TOKENIZERS ignored: %v", err)
+		}
+	}
+
 	// 2. Init SQLite storage
 	dbPath := "ocg.db"
-	if v, ok := envConfig["OPENCLAW_DB_PATH"]; ok && v != "" {
+	if v := envValue(envConfig, "OPENCLAW_DB_PATH"); v != "" {
 		dbPath = v
 	}
-	if v := os.Getenv("OPENCLAW_DB_PATH"); v != "" {
-		dbPath = v
+
+	// SQLite page cache / mmap per connection, shared by storage and the memory store
+	var sqliteTuning storage.SQLiteTuning
+	if v := envValue(envConfig, "OPENCLAW_SQLITE_CACHE_MB"); v != "" {
+		fmt.Sscanf(v, "%d", &sqliteTuning.CacheSizeMB)
+	}
+	if v := envValue(envConfig, "OPENCLAW_SQLITE_MMAP_MB"); v != "" {
+		fmt.Sscanf(v, "%d", &sqliteTuning.MmapSizeMB)
 	}
 
-	store, err := storage.New(dbPath)
+	store, err := storage.NewWithTuning(dbPath, sqliteTuning)
 	if err != nil {
 		log.Fatalf("Storage init failed: %v", err)
 	}
 	defer store.Close()
 
 	// Init vector memory store (FAISS + local embedding)
-	embeddingServer := envConfig["EMBEDDING_SERVER_URL"]
-	if v := os.Getenv("EMBEDDING_SERVER_URL"); v != "" {
-		embeddingServer = v
+	embeddingServer := envValue(envConfig, "EMBEDDING_SERVER_URL")
+	embeddingModel := envValue(envConfig, "EMBEDDING_MODEL")
+	openaiKey := envValue(envConfig, "OPENAI_API_KEY")
+
+	hnswPath := envValue(envConfig, "HNSW_PATH")
+	if hnswPath == "" {
+		hnswPath = "vector.index"
 	}
-	embeddingModel := envConfig["EMBEDDING_MODEL"]
-	if v := os.Getenv("EMBEDDING_MODEL"); v != "" {
-		embeddingModel = v
+
+	// Per-request embedding timeout in seconds (0 = memory.DefaultEmbeddingTimeout)
+	var embeddingTimeout int
+	if v := envValue(envConfig, "EMBEDDING_TIMEOUT_SECONDS"); v != "" {
+		fmt.Sscanf(v, "%d", &embeddingTimeout)
 	}
-	openaiKey := envConfig["OPENAI_API_KEY"]
-	if v := os.Getenv("OPENAI_API_KEY"); v != "" {
-		openaiKey = v
+
+	// Recently embedded texts kept in the LRU embedding cache (0 = no cache)
+	var embeddingCache int
+	if v := envValue(envConfig, "EMBEDDING_CACHE_SIZE"); v != "" {
+		fmt.Sscanf(v, "%d", &embeddingCache)
 	}
 
-	hnswPath := envConfig["HNSW_PATH"]
-	if v := os.Getenv("HNSW_PATH"); v != "" {
-		hnswPath = v
+	// Tombstones before a delete/update compacts the HNSW index (0 = memory.DefaultHNSWCompactAfter)
+	var compactAfter int
+	if v := envValue(envConfig, "HNSW_COMPACT_AFTER"); v != "" {
+		fmt.Sscanf(v, "%d", &compactAfter)
 	}
-	if hnswPath == "" {
-		hnswPath = "vector.index"
+
+	// HNSW graph parameters (0 / empty = memory.DefaultHNSW*)
+	var hnswM, hnswEfSearch, hnswEfConstruct int
+	if v := envValue(envConfig, "HNSW_M"); v != "" {
+		fmt.Sscanf(v, "%d", &hnswM)
+	}
+	if v := envValue(envConfig, "HNSW_EF_SEARCH"); v != "" {
+		fmt.Sscanf(v, "%d", &hnswEfSearch)
+	}
+	if v := envValue(envConfig, "HNSW_EF_CONSTRUCT"); v != "" {
+		fmt.Sscanf(v, "%d", &hnswEfConstruct)
+	}
+
+	// Cosine similarity at which search results count as copies (0 = memory.DefaultDedupSimilarity)
+	var dedupSimilarity float32
+	if v := envValue(envConfig, "MEMORY_DEDUP_SIMILARITY"); v != "" {
+		fmt.Sscanf(v, "%f", &dedupSimilarity)
+	}
+
+	// Lowest value a hybrid score component counts as (default 0)
+	var scoreFloor float32
+	if v := envValue(envConfig, "MEMORY_SCORE_FLOOR"); v != "" {
+		fmt.Sscanf(v, "%f", &scoreFloor)
+	}
+
+	// Hybrid search weights; setting either one uses both as given, so a weight
+	// of 0 turns its side off
+	vectorWeight, textWeight := float32(memory.DefaultVectorWeight), float32(memory.DefaultTextWeight)
+	weightsSet := false
+	if v := envValue(envConfig, "MEMORY_VECTOR_WEIGHT"); v != "" {
+		fmt.Sscanf(v, "%f", &vectorWeight)
+		weightsSet = true
+	}
+	if v := envValue(envConfig, "MEMORY_TEXT_WEIGHT"); v != "" {
+		fmt.Sscanf(v, "%f", &textWeight)
+		weightsSet = true
+	}
+
+	// Queued memory writes before Store fails (0 = memory.DefaultAsyncQueueSize)
+	var asyncQueue int
+	if v := envValue(envConfig, "MEMORY_ASYNC_QUEUE"); v != "" {
+		fmt.Sscanf(v, "%d", &asyncQueue)
 	}
 
 	memoryStore, err := memory.NewVectorMemoryStore(dbPath, memory.Config{
-		EmbeddingServer: embeddingServer,
-		EmbeddingModel:  embeddingModel,
-		ApiKey:          openaiKey,
-		HNSWPath:        hnswPath,
+		EmbeddingServer:    embeddingServer,
+		EmbeddingModel:     embeddingModel,
+		ApiKey:             openaiKey,
+		HNSWPath:           hnswPath,
+		HNSWPartitioned:    strings.ToLower(envValue(envConfig, "HNSW_PARTITIONS")) == "true",
+		FTSMode:            strings.ToLower(envValue(envConfig, "MEMORY_FTS_MODE")),
+		HybridEnabled:      strings.ToLower(envValue(envConfig, "MEMORY_HYBRID_ENABLED")) == "true",
+		VectorWeight:       vectorWeight,
+		TextWeight:         textWeight,
+		WeightsSet:         weightsSet,
+		HybridNorm:         envValue(envConfig, "MEMORY_HYBRID_NORM"),
+		ScoreFloor:         scoreFloor,
+		HNSWCompactAfter:   compactAfter,
+		HNSWM:              hnswM,
+		HNSWEfSearch:       hnswEfSearch,
+		HNSWEfConstruct:    hnswEfConstruct,
+		HNSWDistance:       envValue(envConfig, "HNSW_DISTANCE"),
+		DedupResults:       strings.ToLower(envValue(envConfig, "MEMORY_DEDUP_RESULTS")) == "true",
+		DedupSimilarity:    dedupSimilarity,
+		EmbeddingTimeout:   time.Duration(embeddingTimeout) * time.Second,
+		SQLite:             sqliteTuning,
+		AsyncWrites:        strings.ToLower(envValue(envConfig, "MEMORY_ASYNC_WRITES")) == "true",
+		AsyncQueueSize:     asyncQueue,
+		EmbeddingCacheSize: embeddingCache,
 	})
 	if err != nil {
 		log.Printf("Vector memory init failed: %v", err)
@@ -93,51 +195,29 @@ func main() {
 		defer memoryStore.Close()
 	}
 
-	// Graceful shutdown: single signal handler
+	// Graceful shutdown (step 7); a signal during startup waits in the channel
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		s := <-sigCh
-		log.Printf("Agent received signal %v, shutting down...", s)
-		os.Exit(0)
-	}()
 
 	// 3. Load config (skip file if DB already has config)
 	var cfg agent.Config
 	configExists, _ := store.ConfigExists("llm")
 
-	forceEnvConfig := strings.ToLower(os.Getenv("OPENCLAW_FORCE_ENV_CONFIG")) == "true"
+	forceEnvConfig := strings.ToLower(envValue(envConfig, "OPENCLAW_FORCE_ENV_CONFIG")) == "true"
 	if forceEnvConfig {
 		configExists = false
 		log.Printf("[Config] Force loading from env.config (OPENCLAW_FORCE_ENV_CONFIG=true)")
 	}
 
 	if !configExists {
-		// 3.1 env.config
-		if v, ok := envConfig["OPENCLAW_API_KEY"]; ok && v != "" {
-			cfg.APIKey = v
-		}
-		if v, ok := envConfig["OPENCLAW_BASE_URL"]; ok && v != "" {
-			cfg.BaseURL = v
-		}
-		if v, ok := envConfig["OPENCLAW_MODEL"]; ok && v != "" {
-			cfg.Model = v
-		}
-
-		// 3.2 environment overrides
-		if v := os.Getenv("OPENCLAW_API_KEY"); v != "" {
-			cfg.APIKey = v
-		}
-		if v := os.Getenv("OPENCLAW_BASE_URL"); v != "" {
-			cfg.BaseURL = v
-		}
-		if v := os.Getenv("OPENCLAW_MODEL"); v != "" {
-			cfg.Model = v
-		}
+		// 3.1 environment, then config file / env.config
+		cfg.APIKey = envValue(envConfig, "OPENCLAW_API_KEY")
+		cfg.BaseURL = envValue(envConfig, "OPENCLAW_BASE_URL")
+		cfg.Model = envValue(envConfig, "OPENCLAW_MODEL")
 
-		// 3.3 optional config.json
+		// 3.2 optional config.json (legacy; ignored when OPENCLAW_CONFIG_FILE is set)
 		cfgFile := "config.json"
-		if _, err := os.Stat(cfgFile); err == nil {
+		if _, err := os.Stat(cfgFile); err == nil && configFile == "" {
 			data, _ := os.ReadFile(cfgFile)
 			var c Config
 			if err := json.Unmarshal(data, &c); err == nil {
@@ -160,10 +240,7 @@ func main() {
 		log.Printf("Config found in database, skipping file load")
 	}
 
-	autoRecall := strings.ToLower(os.Getenv("OPENCLAW_AUTO_RECALL"))
-	if autoRecall == "" {
-		autoRecall = strings.ToLower(envConfig["OPENCLAW_AUTO_RECALL"])
-	}
+	autoRecall := strings.ToLower(envValue(envConfig, "OPENCLAW_AUTO_RECALL"))
 	log.Printf("Config: API Key=%s, BaseURL=%s, Model=%s, DB=%s, AutoRecall=%v",
 		maskKey(cfg.APIKey), cfg.BaseURL, cfg.Model, dbPath, autoRecall == "true")
 
@@ -176,38 +253,210 @@ func main() {
 	}
 
 	recallLimit := 3
-	if v := os.Getenv("OPENCLAW_RECALL_LIMIT"); v != "" {
+	if v := envValue(envConfig, "OPENCLAW_RECALL_LIMIT"); v != "" {
 		fmt.Sscanf(v, "%d", &recallLimit)
 	}
 	if recallLimit <= 0 {
 		recallLimit = 3
 	}
 	recallMinScore := 0.3
-	if v := os.Getenv("OPENCLAW_RECALL_MINSCORE"); v != "" {
+	if v := envValue(envConfig, "OPENCLAW_RECALL_MINSCORE"); v != "" {
 		fmt.Sscanf(v, "%f", &recallMinScore)
 	}
 	if recallMinScore <= 0 {
 		recallMinScore = 0.3
 	}
 
+	// Recall recency decay per day (0 = age ignored)
+	var recallDecay float64
+	if v := envValue(envConfig, "OPENCLAW_RECALL_DECAY"); v != "" {
+		fmt.Sscanf(v, "%f", &recallDecay)
+	}
+
+	// Recall re-rank boost per category, e.g. "fact=0.3,preference=0.05"
+	var recallBoost map[string]float64
+	if v := envValue(envConfig, "OPENCLAW_RECALL_BOOST"); v != "" {
+		parsed, err := agent.ParseRecallCategoryBoost(v)
+		if err != nil {
+			log.Printf("⚠️ OPENCLAW_RECALL_BOOST ignored: %v", err)
+		} else {
+			recallBoost = parsed
+		}
+	}
+
+	// Recall injection template; "\n" escapes allowed so it fits on one env line
+	recallTemplate := strings.ReplaceAll(envValue(envConfig, "OPENCLAW_RECALL_TEMPLATE"), `\n`, "\n")
+
+	// Provider-specific upstream headers/query params (OpenRouter, Azure)
+	extraHeaders, err := agent.ParseKeyValues(envValue(envConfig, "OPENCLAW_EXTRA_HEADERS"))
+	if err != nil {
+		log.Printf("⚠️ OPENCLAW_EXTRA_HEADERS ignored: %v", err)
+	}
+	extraQuery, err := agent.ParseKeyValues(envValue(envConfig, "OPENCLAW_EXTRA_QUERY"))
+	if err != nil {
+		log.Printf("⚠️ OPENCLAW_EXTRA_QUERY ignored: %v", err)
+	}
+
+	// Models that need system messages merged into user messages, e.g. "gemma-*,mistral-7b*"
+	noSystemRole := agent.ParseModelPatterns(envValue(envConfig, "OPENCLAW_NO_SYSTEM_ROLE_MODELS"))
+
+	// Tool schema shims for strict providers, e.g. "strict" or "gpt-4o*=strict,gemini-*=gemini"
+	toolSchemaRules, err := agent.ParseToolSchemaRules(envValue(envConfig, "OPENCLAW_TOOL_SCHEMA"))
+	if err != nil {
+		log.Printf("⚠️ OPENCLAW_TOOL_SCHEMA ignored: %v", err)
+	}
+
+	// Tool list cap for models that degrade with many functions
+	var toolSelection agent.ToolSelection
+	if v := envValue(envConfig, "OPENCLAW_MAX_TOOLS"); v != "" {
+		fmt.Sscanf(v, "%d", &toolSelection.MaxTools)
+	}
+	toolSelection.Strategy, err = agent.ParseToolSelectStrategy(envValue(envConfig, "OPENCLAW_TOOL_SELECT"))
+	if err != nil {
+		log.Printf("⚠️ OPENCLAW_TOOL_SELECT ignored: %v", err)
+		toolSelection.Strategy = agent.ToolSelectAll
+	}
+	toolSelection.Priority = agent.ParseModelPatterns(envValue(envConfig, "OPENCLAW_TOOL_PRIORITY"))
+	toolSelection.OnDemand = strings.ToLower(envValue(envConfig, "OPENCLAW_TOOLS_ON_DEMAND")) == "true"
+
+	// Identical tool calls allowed per turn (0 = agent.DefaultToolRepeatLimit, negative = off)
+	var toolRepeatLimit int
+	if v := envValue(envConfig, "OPENCLAW_TOOL_REPEAT_LIMIT"); v != "" {
+		fmt.Sscanf(v, "%d", &toolRepeatLimit)
+	}
+
+	// Upstream responses without choices: error (502 at the gateway), retry once, or "no response" text
+	emptyResponse, err := agent.ParseEmptyResponse(envValue(envConfig, "OPENCLAW_EMPTY_RESPONSE"))
+	if err != nil {
+		log.Printf("⚠️ OPENCLAW_EMPTY_RESPONSE ignored: %v", err)
+		emptyResponse = agent.EmptyResponseError
+	}
+
+	// Lifetime of Agent.PrecomputeRecall results
+	var recallCacheTTL time.Duration
+	if v := envValue(envConfig, "OPENCLAW_RECALL_CACHE_TTL_SECONDS"); v != "" {
+		var secs int
+		fmt.Sscanf(v, "%d", &secs)
+		recallCacheTTL = time.Duration(secs) * time.Second
+	}
+
+	// Budget per upstream request in seconds (0 = agent.DefaultAPITimeout)
+	var apiTimeout time.Duration
+	if v := envValue(envConfig, "OPENCLAW_API_TIMEOUT"); v != "" {
+		var secs int
+		fmt.Sscanf(v, "%d", &secs)
+		apiTimeout = time.Duration(secs) * time.Second
+	}
+
+	// Resends per turn of upstream requests answered with 429/5xx (unset = default).
+	// An explicit 0 means no retries, so it maps to the Config's negative "off".
+	var apiMaxRetries int
+	if v := envValue(envConfig, "OPENCLAW_API_MAX_RETRIES"); v != "" {
+		fmt.Sscanf(v, "%d", &apiMaxRetries)
+		if apiMaxRetries == 0 {
+			apiMaxRetries = -1
+		}
+	}
+
+	// Retention policy: archive messages beyond N per session or older than N hours
+	var retention agent.RetentionPolicy
+	if v := envValue(envConfig, "OPENCLAW_RETENTION_MAX_MESSAGES"); v != "" {
+		fmt.Sscanf(v, "%d", &retention.MaxMessages)
+	}
+	if v := envValue(envConfig, "OPENCLAW_RETENTION_MAX_AGE_HOURS"); v != "" {
+		var hours int
+		fmt.Sscanf(v, "%d", &hours)
+		retention.MaxAge = time.Duration(hours) * time.Hour
+	}
+	if v := envValue(envConfig, "OPENCLAW_RETENTION_INTERVAL_MINUTES"); v != "" {
+		var minutes int
+		fmt.Sscanf(v, "%d", &minutes)
+		retention.Interval = time.Duration(minutes) * time.Minute
+	}
+
+	// Auto-capture importance per category, e.g. "decision=0.9,preference=0.8"
+	var captureImportance map[string]float64
+	if v := envValue(envConfig, "OPENCLAW_CAPTURE_IMPORTANCE"); v != "" {
+		parsed, err := agent.ParseCaptureImportance(v)
+		if err != nil {
+			log.Printf("⚠️ OPENCLAW_CAPTURE_IMPORTANCE ignored: %v", err)
+		} else {
+			captureImportance = parsed
+		}
+	}
+
+	captureAssistant := strings.ToLower(envValue(envConfig, "OPENCLAW_CAPTURE_ASSISTANT")) == "true"
+
+	// Outbound reply policy: length cap (0 = none), PII redaction, word blocklist
+	var output agent.OutputPolicy
+	if v := envValue(envConfig, "OPENCLAW_MAX_OUTPUT_CHARS"); v != "" {
+		fmt.Sscanf(v, "%d", &output.MaxChars)
+	}
+	if strings.ToLower(envValue(envConfig, "OPENCLAW_REDACT_PII")) == "true" {
+		output.Filters = append(output.Filters, agent.RedactPII)
+	}
+	if v := envValue(envConfig, "OPENCLAW_OUTPUT_BLOCKLIST"); v != "" {
+		if f := agent.NewBlocklistFilter(strings.Split(v, ",")); f != nil {
+			output.Filters = append(output.Filters, f)
+		}
+	}
+
+	// Pulse event loop (agent.DefaultPulseConfig for unset fields)
+	pulse := agent.DefaultPulseConfig()
+	if v := envValue(envConfig, "OPENCLAW_PULSE_ENABLED"); v != "" {
+		pulse.Enabled = strings.ToLower(v) == "true"
+	}
+	if v := envValue(envConfig, "OPENCLAW_PULSE_LLM"); v != "" {
+		pulse.LLMEnabled = strings.ToLower(v) == "true"
+	}
+	if v := envValue(envConfig, "OPENCLAW_PULSE_INTERVAL_SECONDS"); v != "" {
+		var secs int
+		fmt.Sscanf(v, "%d", &secs)
+		if secs > 0 {
+			pulse.Interval = time.Duration(secs) * time.Second
+		}
+	}
+	if v := envValue(envConfig, "OPENCLAW_PULSE_MAX_QUEUE"); v != "" {
+		fmt.Sscanf(v, "%d", &pulse.MaxQueueSize)
+	}
+	if v := envValue(envConfig, "OPENCLAW_PULSE_CLEANUP_HOURS"); v != "" {
+		fmt.Sscanf(v, "%d", &pulse.CleanupHours)
+	}
+
 	ai := agent.New(agent.Config{
-		APIKey:         cfg.APIKey,
-		BaseURL:        cfg.BaseURL,
-		Model:          cfg.Model,
-		Storage:        store,
-		MemoryStore:    memoryStore,
-		Registry:       registry,
-		AutoRecall:     strings.ToLower(autoRecall) == "true",
-		RecallLimit:    recallLimit,
-		RecallMinScore: recallMinScore,
-		PulseEnabled:   true,
+		APIKey:                cfg.APIKey,
+		BaseURL:               cfg.BaseURL,
+		Model:                 cfg.Model,
+		Storage:               store,
+		MemoryStore:           memoryStore,
+		Registry:              registry,
+		AutoRecall:            strings.ToLower(autoRecall) == "true",
+		RecallLimit:           recallLimit,
+		RecallMinScore:        recallMinScore,
+		RecallTemplate:        recallTemplate,
+		RecallDecay:           recallDecay,
+		RecallCategoryBoost:   recallBoost,
+		RecallKeywordFallback: strings.ToLower(envValue(envConfig, "OPENCLAW_RECALL_KEYWORD_FALLBACK")) == "true",
+		RecallCacheTTL:        recallCacheTTL,
+		ExtraHeaders:          extraHeaders,
+		ExtraQuery:            extraQuery,
+		NoSystemRoleModels:    noSystemRole,
+		ToolSchemaRules:       toolSchemaRules,
+		ToolSelection:         toolSelection,
+		EmptyResponse:         emptyResponse,
+		ToolRepeatLimit:       toolRepeatLimit,
+		APIMaxRetries:         apiMaxRetries,
+		APITimeout:            apiTimeout,
+		Output:                output,
+		PulseEnabled:          pulse.Enabled,
+		PulseConfig:           pulse,
+		Retention:             retention,
+		CaptureImportance:     captureImportance,
+		CaptureAssistant:      captureAssistant,
 	})
 
 	// 5. Start RPC service (Unix socket, no port)
-	sockPath := os.Getenv("OPENCLAW_AGENT_SOCK")
-	if sockPath == "" {
-		sockPath = envConfig["OPENCLAW_AGENT_SOCK"]
-	}
+	sockPath := envValue(envConfig, "OPENCLAW_AGENT_SOCK")
 	if sockPath == "" {
 		sockPath = "/tmp/ocg-agent.sock"
 	}
@@ -244,12 +493,30 @@ func main() {
 		log.Printf("Storage stats: %+v", stats)
 	}
 
-	// 7. Graceful shutdown
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
-	<-c
+	// 7. Graceful shutdown: cancel the turns in flight, give them a bounded time
+	// to return, then store the queued memory writes. The deferred Close calls
+	// run when main returns.
+	sig := <-sigCh
+	log.Printf("Agent received signal %v, shutting down...", sig)
+	ai.Shutdown()
+	if !ai.WaitIdle(shutdownGrace) {
+		log.Printf("⚠️ Chat turns still running after %v, exiting anyway", shutdownGrace)
+	}
+	if memoryStore != nil {
+		memoryStore.Flush()
+	}
+}
+
+// shutdownGrace bounds how long shutdown waits for cancelled turns to return;
+// it leaves room for the flush inside the 3s ocg stop allows before SIGINT
+const shutdownGrace = 2 * time.Second
 
-	log.Println("Agent shutting down...")
+// envValue returns the environment override, falling back to env.config
+func envValue(envConfig map[string]string, key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return envConfig[key]
 }
 
 func maskKey(key string) string {
@@ -0,0 +1,127 @@
+// Health - liveness vs readiness of the embedding service and its llama-server child
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// readyCacheTTL spaces out real embedding probes when load balancers poll /health
+	readyCacheTTL = 5 * time.Second
+	probeTimeout  = 5 * time.Second
+	probeText     = "ready"
+)
+
+// backendState caches the result of the last backend probe
+type backendState struct {
+	mu        sync.Mutex
+	ready     bool
+	lastErr   string
+	lastProbe time.Time
+	dim       int
+}
+
+var backend backendState
+
+// llamaRunning reports whether the llama-server child was started and has not exited
+func llamaRunning() bool {
+	if llamaCmd == nil || llamaDone == nil {
+		return false
+	}
+	select {
+	case <-llamaDone:
+		return false
+	default:
+		return true
+	}
+}
+
+// probeBackend asks llama-server for a real embedding and records the outcome.
+// Results younger than readyCacheTTL are reused unless force is set.
+func probeBackend(force bool) (bool, string) {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+
+	if !llamaRunning() {
+		backend.ready = false
+		backend.lastErr = "llama-server not running"
+		backend.lastProbe = time.Now()
+		return false, backend.lastErr
+	}
+	if !force && time.Since(backend.lastProbe) < readyCacheTTL {
+		return backend.ready, backend.lastErr
+	}
+
+	emb, err := getEmbeddingTimeout(probeText, probeTimeout)
+	backend.lastProbe = time.Now()
+	if err != nil {
+		backend.ready = false
+		backend.lastErr = err.Error()
+		return false, backend.lastErr
+	}
+	mismatch := config.ExpectedDim > 0 && len(emb) != config.ExpectedDim
+	if len(emb) != backend.dim {
+		log.Printf("📐 Embedding dimension: %d", len(emb))
+		if mismatch {
+			log.Printf("❌ Model dimension %d does not match EMBEDDING_DIM=%d; /health stays 503", len(emb), config.ExpectedDim)
+		}
+	}
+	backend.dim = len(emb)
+	config.Dim = len(emb)
+	if mismatch {
+		backend.ready = false
+		backend.lastErr = fmt.Sprintf("dimension mismatch: model returns %d, EMBEDDING_DIM is %d", len(emb), config.ExpectedDim)
+		return false, backend.lastErr
+	}
+	backend.ready = true
+	backend.lastErr = ""
+	return true, ""
+}
+
+// modelDim is the dimension the model last returned (0 before the first probe).
+// Read it here rather than from config.Dim, which the probe updates concurrently.
+func modelDim() int {
+	backend.mu.Lock()
+	defer backend.mu.Unlock()
+	return backend.dim
+}
+
+// Readiness: 200 only once the backend has answered a real embedding
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	ready, reason := probeBackend(false)
+
+	w.Header().Set("Content-Type", "application/json")
+	status := "ok"
+	if !ready {
+		status = "unavailable"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	resp := map[string]interface{}{
+		"status":     status,
+		"ready":      ready,
+		"backend":    llamaRunning(),
+		"dim":        modelDim(),
+		"serverPort": config.ServerPort,
+		"llmServer":  config.LLMServer,
+		"model":      config.ModelPath,
+		"timestamp":  time.Now().Unix(),
+	}
+	if reason != "" {
+		resp["error"] = reason
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// Liveness: the HTTP server is up, regardless of the backend
+func liveHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":    "alive",
+		"timestamp": time.Now().Unix(),
+	})
+}
@@ -23,16 +23,17 @@ import (
 
 // Config
 type Config struct {
-	Host       string `json:"host"`
-	ModelPath  string `json:"modelPath"`
-	ServerPort int    `json:"serverPort"`
-	LLMHost    string `json:"llmHost"`
-	LLMPort    int    `json:"llmPort"`
-	LLMServer  string `json:"llmServer"`
-	LlamaBin   string `json:"llamaBin"`
-	Dim        int    `json:"dim"`
-	MaxTokens  int    `json:"maxTokens"`
-	Verbose    bool   `json:"verbose"`
+	Host        string `json:"host"`
+	ModelPath   string `json:"modelPath"`
+	ServerPort  int    `json:"serverPort"`
+	LLMHost     string `json:"llmHost"`
+	LLMPort     int    `json:"llmPort"`
+	LLMServer   string `json:"llmServer"`
+	LlamaBin    string `json:"llamaBin"`
+	Dim         int    `json:"dim"`                   // Probed from the model at startup
+	ExpectedDim int    `json:"expectedDim,omitempty"` // EMBEDDING_DIM: other sizes are not ready
+	MaxTokens   int    `json:"maxTokens"`
+	Verbose     bool   `json:"verbose"`
 }
 
 var (
@@ -148,6 +149,15 @@ func main() {
 	}
 	config.Verbose = strings.ToLower(strings.TrimSpace(verb)) == "true"
 
+	// Optional expected dimension; the real one is always probed from the model
+	expectedDim := os.Getenv("EMBEDDING_DIM")
+	if expectedDim == "" {
+		expectedDim = existingConfig["EMBEDDING_DIM"]
+	}
+	if expectedDim != "" {
+		fmt.Sscanf(expectedDim, "%d", &config.ExpectedDim)
+	}
+
 	// Ensure model file exists
 	if _, err := os.Stat(config.ModelPath); os.IsNotExist(err) {
 		log.Fatalf("❌ model file not found: %s", config.ModelPath)
@@ -194,16 +204,30 @@ func main() {
 		waitForLlamaReady()
 	}
 
+	// Optional embed-and-store through the co-located agent
+	storeAgentSock = existingConfig["EMBEDDING_STORE_AGENT"]
+	if v := os.Getenv("EMBEDDING_STORE_AGENT"); v != "" {
+		storeAgentSock = v
+	}
+	if storeAgentSock != "" {
+		log.Printf("Embed-store enabled: agent %s", storeAgentSock)
+	} else if existingConfig["EMBEDDING_STORE_DB"] != "" || os.Getenv("EMBEDDING_STORE_DB") != "" {
+		// Writing the agent's DB from here left its live index without the rows
+		log.Printf("⚠️ EMBEDDING_STORE_DB is no longer used; set EMBEDDING_STORE_AGENT to the agent socket (OPENCLAW_AGENT_SOCK)")
+	}
+
 	// Start HTTP server
 	mux := http.NewServeMux()
 	mux.HandleFunc("/health", healthHandler)
+	mux.HandleFunc("/live", liveHandler)
 	mux.HandleFunc("/embed", embedHandler)
 	mux.HandleFunc("/embed-batch", embedBatchHandler)
+	mux.HandleFunc("/embed-store", embedStoreHandler)
 	mux.HandleFunc("/info", infoHandler)
 
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%d", config.Host, config.ServerPort),
-		Handler:      mux,
+		Handler:      withGzip(mux),
 		ReadTimeout:  30 * time.Second,
 		WriteTimeout: 60 * time.Second,
 		IdleTimeout:  90 * time.Second,
@@ -302,9 +326,14 @@ func stopLlamaServer() {
 	}
 }
 
-// Wait for llama server readiness
+// Wait for llama server readiness: /health answering is not enough while the
+// model loads, so a real embedding must succeed too
 func waitForLlamaReady() {
 	for i := 0; i < 30; i++ {
+		if !llamaRunning() {
+			log.Printf("Llama server exited during startup")
+			return
+		}
 		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, config.LLMServer+"/health", nil)
 		resp, err := http.DefaultClient.Do(req)
@@ -312,24 +341,20 @@ func waitForLlamaReady() {
 		if err == nil {
 			resp.Body.Close()
 			if resp.StatusCode == 200 {
-				log.Printf("Llama server is ready")
-				return
+				ready, reason := probeBackend(true)
+				if ready {
+					log.Printf("Llama server is ready")
+					return
+				}
+				if strings.HasPrefix(reason, "dimension mismatch") {
+					// Retrying cannot fix the wrong model
+					return
+				}
 			}
 		}
 		time.Sleep(time.Second)
 	}
-	log.Printf("Llama server start timeout, continuing...")
-}
-
-// Health check handler
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":     "ok",
-		"serverPort": config.ServerPort,
-		"llmServer":  config.LLMServer,
-		"model":      config.ModelPath,
-		"timestamp":  time.Now().Unix(),
-	})
+	log.Printf("Llama server start timeout, continuing (/health reports 503 until ready)...")
 }
 
 // Embed a single text
@@ -365,7 +390,16 @@ func embedHandler(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// Embed a batch of texts
+// embedBatchItem is one entry of an /embed-batch response: the vector, or why
+// that text could not be embedded
+type embedBatchItem struct {
+	Embedding []float32 `json:"embedding,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// Embed a batch of texts. Each text succeeds or fails on its own: the response
+// lists one result per text, in order, and is 207 Multi-Status when any failed.
+// A fully successful batch also carries the plain "embeddings" list.
 func embedBatchHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -384,29 +418,44 @@ func embedBatchHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	embeddings := make([][]float32, 0, len(req.Texts))
-	for _, text := range req.Texts {
+	results := make([]embedBatchItem, len(req.Texts))
+	failed, dim := 0, 0
+	for i, text := range req.Texts {
 		if strings.TrimSpace(text) == "" {
-			http.Error(w, "empty text in batch", http.StatusBadRequest)
-			return
+			results[i].Error = "empty text"
+			failed++
+			continue
 		}
 		emb, err := getEmbedding(text)
 		if err != nil {
-			short := text
-			if len(short) > 50 {
-				short = short[:50]
-			}
-			http.Error(w, fmt.Sprintf("Embedding failed for: %s", short), http.StatusInternalServerError)
-			return
+			results[i].Error = fmt.Sprintf("embedding failed: %v", err)
+			failed++
+			continue
 		}
-		embeddings = append(embeddings, emb)
+		results[i].Embedding = emb
+		dim = len(emb)
+	}
+	if failed > 0 {
+		log.Printf("⚠️ embed-batch: %d of %d texts failed", failed, len(req.Texts))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMultiStatus)
+	}
+
+	resp := map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+		"failed":  failed,
+		"dim":     dim,
+	}
+	if failed == 0 {
+		// Batch clients from before per-item results read this list
+		embeddings := make([][]float32, len(results))
+		for i, r := range results {
+			embeddings[i] = r.Embedding
+		}
+		resp["embeddings"] = embeddings
 	}
-
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"embeddings": embeddings,
-		"count":      len(embeddings),
-		"dim":        len(embeddings[0]),
-	})
+	json.NewEncoder(w).Encode(resp)
 }
 
 // Get model info
@@ -415,26 +464,35 @@ func infoHandler(w http.ResponseWriter, r *http.Request) {
 		"modelPath":  config.ModelPath,
 		"serverPort": config.ServerPort,
 		"llmServer":  config.LLMServer,
-		"dim":        config.Dim,
+		"dim":        modelDim(),
 		"maxTokens":  config.MaxTokens,
 		"endpoints": map[string]string{
-			"/health":      "Health check",
+			"/health":      "Readiness: 503 until the backend returns an embedding",
+			"/live":        "Liveness",
 			"/embed":       "Embed single text (POST)",
 			"/embed-batch": "Embed batch (POST)",
+			"/embed-store": "Embed batch and store it through the agent (POST, needs EMBEDDING_STORE_AGENT)",
 			"/info":        "Model info",
 		},
+		// Request bodies may be gzip (Content-Encoding); responses honor Accept-Encoding
+		"compression": "gzip",
+		"embedStore":  storeAgentSock != "",
 	})
 }
 
 // Call llama.cpp server to get embeddings
 func getEmbedding(text string) ([]float32, error) {
+	return getEmbeddingTimeout(text, 60*time.Second)
+}
+
+func getEmbeddingTimeout(text string, timeout time.Duration) ([]float32, error) {
 	url := fmt.Sprintf("%s/embedding", strings.TrimSuffix(config.LLMServer, "/"))
 
 	reqBody, _ := json.Marshal(map[string]interface{}{
 		"content": text,
 	})
 
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, strings.NewReader(string(reqBody)))
@@ -0,0 +1,120 @@
+// Embed-and-store: embed a batch and hand it to the co-located agent in one call
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/rpc"
+	"strings"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+// maxEmbedStoreItems caps one /embed-store request
+const maxEmbedStoreItems = 512
+
+// storeAgentSock is the agent socket from EMBEDDING_STORE_AGENT ("" = /embed-store disabled).
+// Rows go through Agent.MemoryStoreBatch rather than into the DB directly, so the
+// agent's live HNSW index, partitions and label mapping include them at once.
+var storeAgentSock string
+
+type embedStoreItem struct {
+	Text       string  `json:"text"`
+	Category   string  `json:"category,omitempty"`
+	Importance float64 `json:"importance,omitempty"`
+	Source     string  `json:"source,omitempty"`
+}
+
+// storeBatch sends pre-embedded entries to the agent and returns their new IDs
+func storeBatch(entries []rpcproto.MemoryBatchEntry) ([]string, error) {
+	client, err := rpc.Dial("unix", storeAgentSock)
+	if err != nil {
+		return nil, fmt.Errorf("agent unreachable: %v", err)
+	}
+	defer client.Close()
+	var reply rpcproto.MemoryStoreBatchReply
+	if err := client.Call("Agent.MemoryStoreBatch", rpcproto.MemoryStoreBatchArgs{Entries: entries}, &reply); err != nil {
+		return nil, err
+	}
+	return reply.IDs, nil
+}
+
+// Embed a batch of texts and persist them as memories in one transaction
+func embedStoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if storeAgentSock == "" {
+		http.Error(w, "embed-store disabled (set EMBEDDING_STORE_AGENT)", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Items []embedStoreItem `json:"items"`
+		// Defaults applied to items that leave them empty
+		Category   string  `json:"category"`
+		Importance float64 `json:"importance"`
+		Source     string  `json:"source"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) == 0 {
+		http.Error(w, "items is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Items) > maxEmbedStoreItems {
+		http.Error(w, fmt.Sprintf("too many items (max %d)", maxEmbedStoreItems), http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	entries := make([]rpcproto.MemoryBatchEntry, 0, len(req.Items))
+	for i, item := range req.Items {
+		if strings.TrimSpace(item.Text) == "" {
+			http.Error(w, fmt.Sprintf("empty text at item %d", i), http.StatusBadRequest)
+			return
+		}
+		emb, err := getEmbedding(item.Text)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Embedding failed at item %d: %v", i, err), http.StatusInternalServerError)
+			return
+		}
+		entry := rpcproto.MemoryBatchEntry{
+			Text:       item.Text,
+			Vector:     emb,
+			Category:   item.Category,
+			Importance: item.Importance,
+			Source:     item.Source,
+		}
+		if entry.Category == "" {
+			entry.Category = req.Category
+		}
+		if entry.Importance == 0 {
+			entry.Importance = req.Importance
+		}
+		if entry.Source == "" {
+			entry.Source = req.Source
+		}
+		if entry.Source == "" {
+			entry.Source = "embed-store"
+		}
+		entries = append(entries, entry)
+	}
+
+	ids, err := storeBatch(entries)
+	if err != nil {
+		log.Printf("⚠️ embed-store: %v", err)
+		http.Error(w, fmt.Sprintf("Store failed: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ids":   ids,
+		"count": len(ids),
+		"dim":   len(entries[0].Vector),
+	})
+}
@@ -15,6 +15,8 @@ import (
 	"time"
 
 	"github.com/gliderlab/cogate/gateway"
+	"github.com/gliderlab/cogate/logging"
+	"github.com/gliderlab/cogate/tokens"
 )
 
 type Config struct {
@@ -29,6 +31,17 @@ func main() {
 	log.Println("Starting OpenClaw Gateway...")
 
 	envConfig := readEnvConfig("env.config")
+	level := os.Getenv("OPENCLAW_LOG_LEVEL")
+	if level == "" {
+		level = envConfig["OPENCLAW_LOG_LEVEL"]
+	}
+	logging.Setup(level)
+	// Same tokenizers as the agent, so Usage matches its session totals
+	if v := envOr(envConfig, "OPENCLAW_TOKENIZERS"); v != "" {
+		if err := tokens.RegisterFiles(v); err != nil {
+			log.Printf("⚠️ OPENCLAW_TOKENIZERS ignored: %v", err)
+		}
+	}
 
 	// Parse bind host
 	host := os.Getenv("OPENCLAW_HOST")
@@ -87,13 +100,87 @@ func main() {
 		uiToken = envConfig["OPENCLAW_UI_TOKEN"]
 	}
 
+	eventsSecret := os.Getenv("OPENCLAW_EVENTS_SECRET")
+	if eventsSecret == "" {
+		eventsSecret = envConfig["OPENCLAW_EVENTS_SECRET"]
+	}
+
+	// Agent RPC connections calls are spread over
+	poolSize := gateway.DefaultAgentPoolSize
+	poolEnv := os.Getenv("OPENCLAW_AGENT_POOL_SIZE")
+	if poolEnv == "" {
+		poolEnv = envConfig["OPENCLAW_AGENT_POOL_SIZE"]
+	}
+	if n, err := strconv.Atoi(poolEnv); err == nil && n > 0 {
+		poolSize = n
+	}
+
+	dataDir := os.Getenv("OPENCLAW_DATA_DIR")
+	if dataDir == "" {
+		dataDir = envConfig["OPENCLAW_DATA_DIR"]
+	}
+	cronStore := os.Getenv("OPENCLAW_CRON_STORE")
+	if cronStore == "" {
+		cronStore = envConfig["OPENCLAW_CRON_STORE"]
+	}
+
+	basePath := os.Getenv("OPENCLAW_BASE_PATH")
+	if basePath == "" {
+		basePath = envConfig["OPENCLAW_BASE_PATH"]
+	}
+
+	// OPENCLAW_REQUIRE_EMBEDDING=true makes an unreachable EMBEDDING_SERVER_URL a
+	// startup error; by default the agent falls back to placeholder vectors
+	var embeddingURL string
+	if strings.ToLower(envOr(envConfig, "OPENCLAW_REQUIRE_EMBEDDING")) == "true" {
+		embeddingURL = envOr(envConfig, "EMBEDDING_SERVER_URL")
+		if embeddingURL == "" {
+			log.Fatalf("OPENCLAW_REQUIRE_EMBEDDING is set but EMBEDDING_SERVER_URL is empty")
+		}
+	}
+
+	// Inbound channel messages processed at once, and how many may wait
+	channelWorkers, _ := strconv.Atoi(envOr(envConfig, "OPENCLAW_CHANNEL_WORKERS"))
+	channelQueue, _ := strconv.Atoi(envOr(envConfig, "OPENCLAW_CHANNEL_QUEUE"))
+
+	// Cron jobs running at once, and whether a run due during the previous one
+	// is skipped or queued
+	cronMaxConcurrent, _ := strconv.Atoi(envOr(envConfig, "OPENCLAW_CRON_MAX_CONCURRENT"))
+	cronOverlap := strings.ToLower(strings.TrimSpace(envOr(envConfig, "OPENCLAW_CRON_OVERLAP")))
+
+	// Agent.Ping period in seconds (unset = gateway.DefaultHeartbeatInterval,
+	// negative = off) and the misses in a row that mark the agent unhealthy
+	heartbeatSecs, _ := strconv.Atoi(strings.TrimSpace(envOr(envConfig, "OPENCLAW_HEARTBEAT_SECONDS")))
+	heartbeatFailures, _ := strconv.Atoi(envOr(envConfig, "OPENCLAW_HEARTBEAT_FAILURES"))
+
+	// Per-route budgets in seconds (unset = gateway.Default*Timeout)
+	timeouts := gateway.RouteTimeouts{
+		Chat:    envSeconds(envConfig, "OPENCLAW_CHAT_TIMEOUT_SECONDS"),
+		Memory:  envSeconds(envConfig, "OPENCLAW_MEMORY_TIMEOUT_SECONDS"),
+		Process: envSeconds(envConfig, "OPENCLAW_PROCESS_TIMEOUT_SECONDS"),
+		Default: envSeconds(envConfig, "OPENCLAW_WRITE_TIMEOUT_SECONDS"),
+	}
+
 	srv := gateway.New(gateway.Config{
-		Host:        host,
-		Port:        p,
-		AgentAddr:   agentSock,
-		UIAuthToken: uiToken,
+		Host:              host,
+		Port:              p,
+		AgentAddr:         agentSock,
+		UIAuthToken:       uiToken,
+		EventsSecret:      eventsSecret,
+		DataDir:           dataDir,
+		CronStorePath:     cronStore,
+		Timeouts:          timeouts,
+		BasePath:          basePath,
+		EmbeddingURL:      embeddingURL,
+		ChannelWorkers:    channelWorkers,
+		ChannelQueueSize:  channelQueue,
+		HeartbeatInterval: time.Duration(heartbeatSecs) * time.Second,
+		HeartbeatFailures: heartbeatFailures,
+		ShutdownTimeout:   envSeconds(envConfig, "OPENCLAW_SHUTDOWN_TIMEOUT_SECONDS"),
+		CronMaxConcurrent: cronMaxConcurrent,
+		CronOverlap:       cronOverlap,
 	})
-	srv.SetClient(client)
+	srv.SetClientPool(dialAgentPool(client, agentSock, poolSize))
 
 	go func() {
 		if err := srv.Start(); err != nil {
@@ -101,9 +188,6 @@ func main() {
 		}
 	}()
 
-	log.Printf("Gateway listening on http://%s:%d", host, p)
-	log.Println("Waiting for messages...")
-
 	// Graceful shutdown
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGINT, syscall.SIGTERM)
@@ -114,6 +198,27 @@ func main() {
 	os.Exit(0)
 }
 
+// envOr reads key from the environment, then env.config
+func envOr(envConfig map[string]string, key string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return envConfig[key]
+}
+
+// envSeconds reads a whole number of seconds from the environment or env.config (0 if unset)
+func envSeconds(envConfig map[string]string, key string) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		v = envConfig[key]
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || n <= 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
+
 func waitForAgent(addr string, timeout time.Duration) (*rpc.Client, error) {
 	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
@@ -126,6 +231,22 @@ func waitForAgent(addr string, timeout time.Duration) (*rpc.Client, error) {
 	return nil, fmt.Errorf("timeout waiting for agent at %s", addr)
 }
 
+// dialAgentPool opens size-1 more connections next to first; a failed dial
+// shrinks the pool rather than blocking startup
+func dialAgentPool(first *rpc.Client, addr string, size int) *gateway.ClientPool {
+	clients := []*rpc.Client{first}
+	for len(clients) < size {
+		c, err := rpc.Dial("unix", addr)
+		if err != nil {
+			log.Printf("⚠️ Agent pool: dial %d/%d failed: %v", len(clients)+1, size, err)
+			break
+		}
+		clients = append(clients, c)
+	}
+	log.Printf("Agent RPC pool: %d connections", len(clients))
+	return gateway.NewClientPool(clients...)
+}
+
 // writeEnvConfig writes env.config (KEY=VALUE)
 func writeEnvConfig(path string, updates map[string]string) {
 	config := readEnvConfig(path)
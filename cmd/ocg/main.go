@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"net/rpc"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -15,6 +16,8 @@ import (
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/gliderlab/cogate/rpcproto"
 )
 
 type ProcessSpec struct {
@@ -50,6 +53,10 @@ func main() {
 		statusCmd(args)
 	case "restart":
 		restartCmd(args)
+	case "backup":
+		snapshotCmd("backup", "Agent.MemorySnapshot", args)
+	case "restore":
+		snapshotCmd("restore", "Agent.MemoryRestore", args)
 	case "help", "-h", "--help":
 		printUsage()
 	default:
@@ -117,6 +124,10 @@ func startCmd(args []string) {
 	if err := waitForAgentReady(cfgPath, 20*time.Second); err != nil {
 		fatalf("Agent not ready: %v", err)
 	}
+	// Warmup is best effort: a cold first chat is slow, not broken
+	if err := warmupAgent(cfgPath, 30*time.Second); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Agent warmup failed: %v\n", err)
+	}
 
 	if err := waitForGatewayReady(cfgPath, 20*time.Second); err != nil {
 		fatalf("Gateway not ready: %v", err)
@@ -182,6 +193,38 @@ func restartCmd(args []string) {
 	startCmd(args)
 }
 
+// snapshotCmd asks the running agent to snapshot or restore the memory store
+func snapshotCmd(name, method string, args []string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	configPath := fs.String("config", "", "Path to env.config")
+	dir := fs.String("dir", "", "Snapshot directory")
+	fs.Parse(args)
+	if *dir == "" {
+		fatalf("%s: --dir is required", name)
+	}
+	absDir, err := filepath.Abs(*dir)
+	if err != nil {
+		fatalf("%s: %v", name, err)
+	}
+
+	cfgPath, _ := resolveConfigPath(*configPath)
+	agentSock := readEnvConfig(cfgPath)["OPENCLAW_AGENT_SOCK"]
+	if agentSock == "" {
+		agentSock = "/tmp/ocg-agent.sock"
+	}
+	client, err := rpc.Dial("unix", agentSock)
+	if err != nil {
+		fatalf("%s: agent not reachable at %s: %v", name, agentSock, err)
+	}
+	defer client.Close()
+
+	var reply rpcproto.ToolResultReply
+	if err := client.Call(method, rpcproto.SnapshotArgs{Dir: absDir}, &reply); err != nil {
+		fatalf("%s failed: %v", name, err)
+	}
+	fmt.Printf("✅ Memory %s complete: %s\n", name, absDir)
+}
+
 func startProcess(binDir, cfgDir string, envConfig map[string]string, spec ProcessSpec) error {
 	binPath := filepath.Join(binDir, spec.BinName)
 	if runtime.GOOS == "windows" {
@@ -221,6 +264,10 @@ func startProcess(binDir, cfgDir string, envConfig map[string]string, spec Proce
 	return nil
 }
 
+// gatewayTermWait lets the gateway drain on SIGTERM: gateway.DefaultShutdownTimeout
+// (8s) plus time to exit
+const gatewayTermWait = 10 * time.Second
+
 func stopProcess(spec ProcessSpec) error {
 	pid, running := readPid(spec.PidFile)
 	if !running {
@@ -232,11 +279,15 @@ func stopProcess(spec ProcessSpec) error {
 		return err
 	}
 
+	termWait := 3 * time.Second
+	if spec.Name == "gateway" {
+		termWait = gatewayTermWait
+	}
 	steps := []struct {
 		sig  syscall.Signal
 		wait time.Duration
 	}{
-		{syscall.SIGTERM, 3 * time.Second},
+		{syscall.SIGTERM, termWait},
 		{syscall.SIGINT, 3 * time.Second},
 		{syscall.SIGKILL, 2 * time.Second},
 	}
@@ -302,6 +353,32 @@ func waitForAgentReady(cfgPath string, timeout time.Duration) error {
 	return fmt.Errorf("agent socket not ready: %s", agentSock)
 }
 
+// warmupAgent calls Agent.Warmup so the first chat does not pay the cold-start costs
+func warmupAgent(cfgPath string, timeout time.Duration) error {
+	agentSock := readEnvConfig(cfgPath)["OPENCLAW_AGENT_SOCK"]
+	if agentSock == "" {
+		agentSock = "/tmp/ocg-agent.sock"
+	}
+	client, err := rpc.Dial("unix", agentSock)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	var reply rpcproto.WarmupReply
+	call := client.Go("Agent.Warmup", struct{}{}, &reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		if call.Error != nil {
+			return call.Error
+		}
+	case <-time.After(timeout):
+		return fmt.Errorf("timeout after %s", timeout)
+	}
+	fmt.Printf("🔥 Agent warmed up in %dms (tools=%d, upstream=%s, embedding=%s)\n", reply.DurationMs, reply.Tools, reply.Upstream, reply.Embedding)
+	return nil
+}
+
 func waitForGatewayReady(cfgPath string, timeout time.Duration) error {
 	cfg := readEnvConfig(cfgPath)
 	port := 55003
@@ -314,7 +391,7 @@ func waitForGatewayReady(cfgPath string, timeout time.Duration) error {
 	if host == "" {
 		host = "127.0.0.1"
 	}
-	url := fmt.Sprintf("http://%s:%d/health", host, port)
+	url := fmt.Sprintf("http://%s:%d%s/health", host, port, gatewayBasePath(cfg))
 	token := cfg["OPENCLAW_UI_TOKEN"]
 
 	deadline := time.Now().Add(timeout)
@@ -344,11 +421,19 @@ func printHealth(cfgPath string) error {
 		host = "127.0.0.1"
 	}
 	token := cfg["OPENCLAW_UI_TOKEN"]
-	url := fmt.Sprintf("http://%s:%d/health", host, port)
+	url := fmt.Sprintf("http://%s:%d%s/health", host, port, gatewayBasePath(cfg))
 	fmt.Printf("gateway health: %v\n", httpAuthOK(url, token))
 	return nil
 }
 
+// gatewayBasePath is the gateway's OPENCLAW_BASE_PATH as a "/prefix" ("" at the root)
+func gatewayBasePath(cfg map[string]string) string {
+	if p := strings.Trim(strings.TrimSpace(cfg["OPENCLAW_BASE_PATH"]), "/"); p != "" {
+		return "/" + p
+	}
+	return ""
+}
+
 func httpOK(url string) bool {
 	client := &http.Client{Timeout: 800 * time.Millisecond}
 	resp, err := client.Get(url)
@@ -493,6 +578,8 @@ func printUsage() {
 	fmt.Println("  stop    Stop all OCG processes (escalating signals)")
 	fmt.Println("  status  Show running state and health")
 	fmt.Println("  restart Stop then start")
+	fmt.Println("  backup  Snapshot memory DB + index (--dir <dir>, agent keeps running)")
+	fmt.Println("  restore Restore memories from a snapshot (--dir <dir>)")
 	fmt.Println("")
 	fmt.Println("Options:")
 	fmt.Println("  --config <path>   Path to env.config")
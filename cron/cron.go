@@ -4,12 +4,15 @@
 package cron
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -44,6 +47,22 @@ const (
 	PayloadKindAgentTurn  = "agentTurn"
 )
 
+// Overlap policies: what a run that comes due while the previous one is still
+// in flight does
+const (
+	OverlapSkip  = "skip"  // dropped; the job waits for its next slot
+	OverlapQueue = "queue" // runs once when the current run ends (further ones drop)
+)
+
+// DefaultMaxConcurrentJobs bounds the jobs running at once when none is set
+const DefaultMaxConcurrentJobs = 4
+
+// Errors returned by RunJob for a job that is still running
+var (
+	ErrJobRunning = errors.New("job is still running")
+	ErrRunQueued  = errors.New("job is still running; run queued")
+)
+
 // Schedule defines when a job should run
 type Schedule struct {
 	Kind     string `json:"kind"`     // "at", "every", "cron"
@@ -84,6 +103,7 @@ type Job struct {
 	Payload     Payload   `json:"payload"`
 	Delivery    *Delivery `json:"delivery,omitempty"`
 	DeleteAfterRun bool   `json:"deleteAfterRun"`
+	DependsOn   []string  `json:"dependsOn,omitempty"` // job IDs or names that must succeed before each run
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt"`
 	// State
@@ -91,9 +111,68 @@ type Job struct {
 		NextRunAtMs     int64  `json:"nextRunAtMs"`
 		LastRunAtMs     int64  `json:"lastRunAtMs"`
 		LastStatus      string `json:"lastStatus"` // "ok", "error", "skipped"
+		LastSuccessAtMs int64  `json:"lastSuccessAtMs,omitempty"` // completion time of the last "ok" run
 		LastDurationMs  int64  `json:"lastDurationMs"`
 		ConsecutiveErrors int `json:"consecutiveErrors"`
+		Running         bool   `json:"running,omitempty"` // a run is in flight (or waiting for a slot)
+		Queued          bool   `json:"queued,omitempty"`  // one more run follows the current one (OverlapQueue)
 	} `json:"state"`
+	// History holds the last RunHistoryLimit runs, oldest first
+	History []RunRecord `json:"history,omitempty"`
+}
+
+// SessionKey is the agent session the job's turns run in: "cron:<id>" for an
+// isolated job, so its turns stay out of the main conversation; empty (the
+// agent's default session) for the main target
+func (j *Job) SessionKey() string {
+	if j.SessionTarget == SessionTargetIsolated {
+		return "cron:" + j.ID
+	}
+	return ""
+}
+
+// Run history bounds: runs kept per job and characters kept of each output/error
+const (
+	RunHistoryLimit = 20
+	RunOutputLimit  = 500
+)
+
+// RunRecord is one entry of a job's run history
+type RunRecord struct {
+	StartedAtMs int64  `json:"startedAtMs"`
+	Status      string `json:"status"` // "ok", "error", "skipped"
+	DurationMs  int64  `json:"durationMs"`
+	Output      string `json:"output,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// recordRunLocked appends rec to the job's history and drops the oldest runs
+// beyond RunHistoryLimit; the caller holds the store lock
+func recordRunLocked(job *Job, rec RunRecord) {
+	rec.Output, rec.Error = truncateOutput(rec.Output), truncateOutput(rec.Error)
+	job.History = append(job.History, rec)
+	trimHistory(job)
+}
+
+func trimHistory(job *Job) {
+	if over := len(job.History) - RunHistoryLimit; over > 0 {
+		job.History = append([]RunRecord(nil), job.History[over:]...)
+	}
+}
+
+// truncateOutput cuts s to RunOutputLimit characters
+func truncateOutput(s string) string {
+	if len(s) <= RunOutputLimit {
+		return s
+	}
+	n := 0
+	for i := range s {
+		if n == RunOutputLimit {
+			return s[:i] + "…"
+		}
+		n++
+	}
+	return s
 }
 
 // JobStore manages cron jobs
@@ -132,6 +211,7 @@ func (js *JobStore) load() {
 	js.mu.Lock()
 	defer js.mu.Unlock()
 	for _, job := range jobs {
+		trimHistory(job)
 		js.jobs[job.ID] = job
 	}
 	log.Printf("[Cron] Loaded %d jobs", len(jobs))
@@ -141,7 +221,11 @@ func (js *JobStore) load() {
 func (js *JobStore) save() error {
 	js.mu.RLock()
 	defer js.mu.RUnlock()
+	return js.saveLocked()
+}
 
+// saveLocked writes jobs to file; the caller holds js.mu
+func (js *JobStore) saveLocked() error {
 	jobs := make([]*Job, 0, len(js.jobs))
 	for _, job := range js.jobs {
 		jobs = append(jobs, job)
@@ -165,8 +249,11 @@ func (js *JobStore) Add(job *Job) error {
 	js.mu.Lock()
 	defer js.mu.Unlock()
 
+	if err := js.checkDependenciesLocked(job.ID, job.DependsOn); err != nil {
+		return err
+	}
 	js.jobs[job.ID] = job
-	return js.save()
+	return js.saveLocked()
 }
 
 // Get returns a job by ID
@@ -177,6 +264,21 @@ func (js *JobStore) Get(id string) (*Job, bool) {
 	return job, ok
 }
 
+// History returns a copy of the job's run history, newest first
+func (js *JobStore) History(id string) ([]RunRecord, error) {
+	js.mu.RLock()
+	defer js.mu.RUnlock()
+	job, ok := js.jobs[id]
+	if !ok {
+		return nil, fmt.Errorf("job not found: %s", id)
+	}
+	out := make([]RunRecord, len(job.History))
+	for i, rec := range job.History {
+		out[len(out)-1-i] = rec
+	}
+	return out, nil
+}
+
 // List returns all jobs
 func (js *JobStore) List() []*Job {
 	js.mu.RLock()
@@ -189,7 +291,10 @@ func (js *JobStore) List() []*Job {
 	return jobs
 }
 
-// Update updates a job
+// Update applies a partial job definition: fields present in updates replace the
+// job's (schedule, payload and delivery merge field by field; "delivery": null
+// clears it). The result is validated like CreateJobFromMap; on error the job is
+// left unchanged.
 func (js *JobStore) Update(id string, updates map[string]interface{}) (*Job, error) {
 	js.mu.Lock()
 	defer js.mu.Unlock()
@@ -199,55 +304,35 @@ func (js *JobStore) Update(id string, updates map[string]interface{}) (*Job, err
 		return nil, fmt.Errorf("job not found: %s", id)
 	}
 
-	// Apply updates
-	if v, ok := updates["name"].(string); ok {
-		job.Name = v
-	}
-	if v, ok := updates["description"].(string); ok {
-		job.Description = v
+	// Edit a copy so a rejected update leaves the job as it was
+	updated := *job
+	if job.Delivery != nil {
+		delivery := *job.Delivery
+		updated.Delivery = &delivery
 	}
+	applyJobFields(&updated, updates)
 	if v, ok := updates["enabled"].(bool); ok {
-		job.Enabled = v
+		updated.Enabled = v
 	}
-	if v, ok := updates["schedule"].(map[string]interface{}); ok {
-		if kind, ok := v["kind"].(string); ok {
-			job.Schedule.Kind = kind
-		}
-		if at, ok := v["at"].(string); ok {
-			job.Schedule.At = at
-		}
-		if everyMs, ok := v["everyMs"].(float64); ok {
-			job.Schedule.EveryMs = int64(everyMs)
-		}
-		if expr, ok := v["expr"].(string); ok {
-			job.Schedule.Expr = expr
+	if v, ok := updates["dependsOn"]; ok {
+		deps, err := parseDependsOn(v)
+		if err != nil {
+			return nil, err
 		}
-		if tz, ok := v["tz"].(string); ok {
-			job.Schedule.Tz = tz
+		if err := js.checkDependenciesLocked(id, deps); err != nil {
+			return nil, err
 		}
+		updated.DependsOn = deps
 	}
-	if v, ok := updates["payload"].(map[string]interface{}); ok {
-		if kind, ok := v["kind"].(string); ok {
-			job.Payload.Kind = kind
-		}
-		if text, ok := v["text"].(string); ok {
-			job.Payload.Text = text
-		}
-		if message, ok := v["message"].(string); ok {
-			job.Payload.Message = message
-		}
-		if model, ok := v["model"].(string); ok {
-			job.Payload.Model = model
-		}
-		if thinking, ok := v["thinking"].(string); ok {
-			job.Payload.Thinking = thinking
-		}
+	if err := validateJob(&updated); err != nil {
+		return nil, err
 	}
 
-	job.UpdatedAt = time.Now()
-	js.jobs[id] = job
+	updated.UpdatedAt = time.Now()
+	// Keep the pointer: running executions and callers hold it
+	*job = updated
 
-	if err := js.save(); err != nil {
+	if err := js.saveLocked(); err != nil {
 		return nil, err
 	}
 
@@ -263,8 +348,19 @@ func (js *JobStore) Remove(id string) error {
 		return fmt.Errorf("job not found: %s", id)
 	}
 
+	for _, other := range js.jobs {
+		if other.ID == id {
+			continue
+		}
+		for _, ref := range other.DependsOn {
+			if dep, _ := js.findLocked(ref); dep != nil && dep.ID == id {
+				return fmt.Errorf("job %s is a dependency of %s", id, other.ID)
+			}
+		}
+	}
+
 	delete(js.jobs, id)
-	return js.save()
+	return js.saveLocked()
 }
 
 // GetDueJobs returns jobs that are due to run
@@ -279,7 +375,7 @@ func (js *JobStore) GetDueJobs() []*Job {
 		if !job.Enabled {
 			continue
 		}
-		if job.State.NextRunAtMs > 0 && job.State.NextRunAtMs <= now {
+		if job.State.NextRunAtMs > 0 && job.State.NextRunAtMs <= now && js.dependenciesMetLocked(job) {
 			due = append(due, job)
 		}
 	}
@@ -287,6 +383,74 @@ func (js *JobStore) GetDueJobs() []*Job {
 	return due
 }
 
+// findLocked resolves a dependency reference by job ID, then by unique name
+func (js *JobStore) findLocked(ref string) (*Job, error) {
+	if job, ok := js.jobs[ref]; ok {
+		return job, nil
+	}
+	var found *Job
+	for _, job := range js.jobs {
+		if job.Name != ref {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("dependency %q matches more than one job name, use the job ID", ref)
+		}
+		found = job
+	}
+	if found == nil {
+		return nil, fmt.Errorf("dependency not found: %s", ref)
+	}
+	return found, nil
+}
+
+// dependenciesMetLocked reports whether every dependency has succeeded since the job last ran.
+// A job that stays due keeps waiting here and runs on the first tick after the upstream succeeds.
+func (js *JobStore) dependenciesMetLocked(job *Job) bool {
+	for _, ref := range job.DependsOn {
+		dep, err := js.findLocked(ref)
+		if err != nil || dep.State.LastSuccessAtMs <= job.State.LastRunAtMs {
+			return false
+		}
+	}
+	return true
+}
+
+// checkDependenciesLocked rejects unknown, self and circular dependencies for job id
+func (js *JobStore) checkDependenciesLocked(id string, deps []string) error {
+	for _, ref := range deps {
+		dep, err := js.findLocked(ref)
+		if err != nil {
+			return err
+		}
+		if dep.ID == id {
+			return fmt.Errorf("job cannot depend on itself")
+		}
+		if js.reachesLocked(dep, id, map[string]bool{}) {
+			return fmt.Errorf("dependency cycle: %s depends on %s", dep.ID, id)
+		}
+	}
+	return nil
+}
+
+// reachesLocked reports whether target is among the transitive dependencies of job
+func (js *JobStore) reachesLocked(job *Job, target string, seen map[string]bool) bool {
+	if seen[job.ID] {
+		return false
+	}
+	seen[job.ID] = true
+	for _, ref := range job.DependsOn {
+		dep, err := js.findLocked(ref)
+		if err != nil {
+			continue
+		}
+		if dep.ID == target || js.reachesLocked(dep, target, seen) {
+			return true
+		}
+	}
+	return false
+}
+
 // CalculateNextRun calculates the next run time for a job
 func (js *JobStore) CalculateNextRun(job *Job) int64 {
 	now := time.Now()
@@ -296,8 +460,14 @@ func (js *JobStore) CalculateNextRun(job *Job) int64 {
 		if job.Schedule.At == "" {
 			return 0
 		}
-		t, err := time.Parse(time.RFC3339, job.Schedule.At)
+		loc, err := scheduleLocation(job.Schedule.Tz)
+		if err != nil {
+			log.Printf("[Cron] Job %s: %v", job.Name, err)
+			return 0
+		}
+		t, err := parseAt(job.Schedule.At, loc)
 		if err != nil {
+			log.Printf("[Cron] Job %s: %v", job.Name, err)
 			return 0
 		}
 		return t.UnixMilli()
@@ -309,9 +479,20 @@ func (js *JobStore) CalculateNextRun(job *Job) int64 {
 		return now.Add(time.Duration(job.Schedule.EveryMs) * time.Millisecond).UnixMilli()
 
 	case ScheduleKindCron:
-		// Simple cron calculation (for production, use a proper cron library)
-		// For now, return a simple estimate
-		return now.Add(1 * time.Hour).UnixMilli()
+		if job.Schedule.Expr == "" {
+			return 0
+		}
+		loc, err := scheduleLocation(job.Schedule.Tz)
+		if err != nil {
+			log.Printf("[Cron] Job %s: %v", job.Name, err)
+			return 0
+		}
+		next, err := nextCronRun(job.Schedule.Expr, loc, now)
+		if err != nil {
+			log.Printf("[Cron] Job %s: %v", job.Name, err)
+			return 0
+		}
+		return next.UnixMilli()
 
 	default:
 		return 0
@@ -327,8 +508,13 @@ type CronHandler struct {
 	interval  time.Duration
 	// Callbacks
 	onSystemEvent func(string) // (message)
-	onAgentTurn   func(string, string, string) (string, error) // (message, model, thinking)
+	onAgentTurn   func(string, string, string, string) (string, error) // (sessionKey, message, model, thinking)
 	onBroadcast  func(string, string, string) error // (message, channel, target)
+	// Job executions Shutdown waits for (scheduled ticks and RunJob)
+	runs sync.WaitGroup
+	// slots holds one token per running job (SetMaxConcurrent); overlap is OverlapSkip or OverlapQueue
+	slots   chan struct{}
+	overlap string
 }
 
 // NewCronHandler creates a new cron handler
@@ -337,7 +523,36 @@ func NewCronHandler(storePath string) *CronHandler {
 		store:    NewJobStore(storePath),
 		stopCh:   make(chan struct{}),
 		interval: 1 * time.Second,
+		slots:    make(chan struct{}, DefaultMaxConcurrentJobs),
+		overlap:  OverlapSkip,
+	}
+}
+
+// SetMaxConcurrent bounds how many jobs run at once (n <= 0 = DefaultMaxConcurrentJobs).
+// Call it before Start.
+func (c *CronHandler) SetMaxConcurrent(n int) {
+	if n <= 0 {
+		n = DefaultMaxConcurrentJobs
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.slots = make(chan struct{}, n)
+}
+
+// SetOverlapPolicy sets what happens to a run that comes due while the job is
+// still running: OverlapSkip (default) or OverlapQueue
+func (c *CronHandler) SetOverlapPolicy(policy string) error {
+	switch policy {
+	case "":
+		policy = OverlapSkip
+	case OverlapSkip, OverlapQueue:
+	default:
+		return fmt.Errorf("invalid overlap policy %q (want skip or queue)", policy)
 	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.overlap = policy
+	return nil
 }
 
 // SetSystemEventCallback sets the callback for system events
@@ -347,8 +562,9 @@ func (c *CronHandler) SetSystemEventCallback(cb func(string)) {
 	c.onSystemEvent = cb
 }
 
-// SetAgentTurnCallback sets the callback for agent turns
-func (c *CronHandler) SetAgentTurnCallback(cb func(string, string, string) (string, error)) {
+// SetAgentTurnCallback sets the callback for agent turns. The session key is
+// the one SessionKey returns for the job.
+func (c *CronHandler) SetAgentTurnCallback(cb func(string, string, string, string) (string, error)) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	c.onAgentTurn = cb
@@ -374,16 +590,42 @@ func (c *CronHandler) Start() {
 
 	log.Printf("[Cron] Starting cron scheduler")
 
-	// Calculate initial next run times
-	for _, job := range c.store.List() {
-		nextRun := c.store.CalculateNextRun(job)
-		job.State.NextRunAtMs = nextRun
+	// Recurring jobs keep their saved next run, so a restart does not push an
+	// interval job back by a full interval. A time that passed while the gateway
+	// was down stays due: the job runs once, then reschedules from now.
+	now := time.Now().UnixMilli()
+	missed := 0
+	c.store.mu.Lock()
+	for _, job := range c.store.jobs {
+		// Left over from a run the previous process did not finish
+		job.State.Running, job.State.Queued = false, false
+		if keepsNextRun(job) {
+			if job.Enabled && job.State.NextRunAtMs <= now {
+				missed++
+			}
+			continue
+		}
+		job.State.NextRunAtMs = c.store.CalculateNextRun(job)
+	}
+	c.store.mu.Unlock()
+	if missed > 0 {
+		log.Printf("[Cron] %d jobs missed their run while stopped; running each once", missed)
 	}
 	c.store.save()
 
 	go c.runLoop()
 }
 
+// keepsNextRun reports whether Start keeps the job's persisted next run:
+// "every" and "cron" jobs that have one. "at" jobs are fixed and recomputed.
+func keepsNextRun(job *Job) bool {
+	switch job.Schedule.Kind {
+	case ScheduleKindEvery, ScheduleKindCron:
+		return job.State.NextRunAtMs > 0
+	}
+	return false
+}
+
 // Stop stops the cron scheduler
 func (c *CronHandler) Stop() {
 	c.mu.Lock()
@@ -398,6 +640,27 @@ func (c *CronHandler) Stop() {
 	log.Printf("[Cron] Stopped cron scheduler")
 }
 
+// Shutdown stops the scheduler and waits for running jobs until ctx ends, then
+// writes the job store so next-run and last-run state survive a restart
+func (c *CronHandler) Shutdown(ctx context.Context) error {
+	c.Stop()
+	idle := make(chan struct{})
+	go func() {
+		c.runs.Wait()
+		close(idle)
+	}()
+	var err error
+	select {
+	case <-idle:
+	case <-ctx.Done():
+		err = fmt.Errorf("cron jobs still running: %v", ctx.Err())
+	}
+	if saveErr := c.store.save(); saveErr != nil && err == nil {
+		err = fmt.Errorf("save cron jobs: %v", saveErr)
+	}
+	return err
+}
+
 // IsRunning returns whether the cron is running
 func (c *CronHandler) IsRunning() bool {
 	c.mu.RLock()
@@ -415,17 +678,99 @@ func (c *CronHandler) runLoop() {
 		case <-c.stopCh:
 			return
 		case <-ticker.C:
+			// Registered under mu, so no run starts after Stop returns
+			c.mu.RLock()
+			running := c.running
+			if running {
+				c.runs.Add(1)
+			}
+			c.mu.RUnlock()
+			if !running {
+				return
+			}
 			c.tick()
+			c.runs.Done()
 		}
 	}
 }
 
-// tick performs one cron check
+// tick starts every due job in its own goroutine, so a slow agent turn does
+// not hold up the others; the caller has registered the tick with c.runs
 func (c *CronHandler) tick() {
-	dueJobs := c.store.GetDueJobs()
+	for _, job := range c.store.GetDueJobs() {
+		if err := c.claim(job, true); err != nil {
+			continue
+		}
+		c.runs.Add(1)
+		go func() {
+			defer c.runs.Done()
+			c.runClaimed(job)
+		}()
+	}
+}
+
+// claim marks job running. A scheduled run also moves NextRunAtMs on, so the
+// same slot is not due again on the next tick. A job that is already running
+// gets the overlap policy instead.
+func (c *CronHandler) claim(job *Job, scheduled bool) error {
+	c.mu.RLock()
+	overlap := c.overlap
+	c.mu.RUnlock()
+
+	c.store.mu.Lock()
+	defer c.store.mu.Unlock()
+	if scheduled {
+		if job.Schedule.Kind == ScheduleKindAt {
+			// The one slot is taken; executeJob sets the state after the run
+			job.State.NextRunAtMs = 0
+		} else {
+			job.State.NextRunAtMs = c.store.CalculateNextRun(job)
+		}
+	}
+	if !job.State.Running {
+		job.State.Running = true
+		return nil
+	}
+	if overlap == OverlapQueue {
+		if !job.State.Queued {
+			log.Printf("[Cron] Job still running, run queued: %s", job.Name)
+		}
+		job.State.Queued = true
+		return ErrRunQueued
+	}
+	log.Printf("[Cron] Job still running, run skipped: %s", job.Name)
+	if scheduled {
+		recordRunLocked(job, RunRecord{StartedAtMs: time.Now().UnixMilli(), Status: "skipped", Error: ErrJobRunning.Error()})
+	}
+	return ErrJobRunning
+}
 
-	for _, job := range dueJobs {
+// runClaimed waits for a free slot, runs the claimed job, then its queued run if any
+func (c *CronHandler) runClaimed(job *Job) {
+	c.mu.RLock()
+	slots, stopCh := c.slots, c.stopCh
+	c.mu.RUnlock()
+	for {
+		select {
+		case slots <- struct{}{}:
+		case <-stopCh:
+			// Stopped while waiting for a slot: the run never started
+			c.store.mu.Lock()
+			job.State.Running, job.State.Queued = false, false
+			c.store.mu.Unlock()
+			return
+		}
 		c.executeJob(job)
+		<-slots
+
+		c.store.mu.Lock()
+		again := job.State.Queued
+		job.State.Queued = false
+		job.State.Running = again
+		c.store.mu.Unlock()
+		if !again {
+			return
+		}
 	}
 }
 
@@ -434,7 +779,11 @@ func (c *CronHandler) executeJob(job *Job) {
 	log.Printf("[Cron] Executing job: %s (%s)", job.Name, job.ID)
 
 	startTime := time.Now()
+	// State changes happen under the store lock: save and GetDueJobs read the
+	// job while it runs
+	c.store.mu.Lock()
 	job.State.LastRunAtMs = startTime.UnixMilli()
+	c.store.mu.Unlock()
 
 	var err error
 	var result string
@@ -461,12 +810,14 @@ func (c *CronHandler) executeJob(job *Job) {
 		c.mu.RUnlock()
 
 		if cb != nil {
-			result, err = cb(job.Payload.Message, job.Payload.Model, job.Payload.Thinking)
+			result, err = cb(job.SessionKey(), job.Payload.Message, job.Payload.Model, job.Payload.Thinking)
+			c.store.mu.Lock()
 			if err != nil {
 				job.State.ConsecutiveErrors++
 			} else {
 				job.State.ConsecutiveErrors = 0
 			}
+			c.store.mu.Unlock()
 		} else {
 			err = fmt.Errorf("no callback configured")
 		}
@@ -487,6 +838,7 @@ func (c *CronHandler) executeJob(job *Job) {
 	}
 
 	// Update job state
+	c.store.mu.Lock()
 	job.State.LastDurationMs = time.Since(startTime).Milliseconds()
 
 	if err != nil {
@@ -494,8 +846,14 @@ func (c *CronHandler) executeJob(job *Job) {
 		log.Printf("[Cron] Job error: %s - %v", job.Name, err)
 	} else {
 		job.State.LastStatus = "ok"
+		job.State.LastSuccessAtMs = time.Now().UnixMilli()
 		log.Printf("[Cron] Job completed: %s", job.Name)
 	}
+	rec := RunRecord{StartedAtMs: startTime.UnixMilli(), Status: job.State.LastStatus, DurationMs: job.State.LastDurationMs, Output: result}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+	recordRunLocked(job, rec)
 
 	// Calculate next run
 	job.State.NextRunAtMs = c.store.CalculateNextRun(job)
@@ -506,6 +864,7 @@ func (c *CronHandler) executeJob(job *Job) {
 			job.Enabled = false
 		}
 	}
+	c.store.mu.Unlock()
 
 	c.store.save()
 }
@@ -553,10 +912,25 @@ func (c *CronHandler) RunJob(id string) error {
 		return fmt.Errorf("job not found: %s", id)
 	}
 
-	go c.executeJob(job)
+	if err := c.claim(job, false); err != nil {
+		if errors.Is(err, ErrRunQueued) {
+			return nil
+		}
+		return err
+	}
+	c.runs.Add(1)
+	go func() {
+		defer c.runs.Done()
+		c.runClaimed(job)
+	}()
 	return nil
 }
 
+// JobHistory returns the job's recent runs, newest first
+func (c *CronHandler) JobHistory(id string) ([]RunRecord, error) {
+	return c.store.History(id)
+}
+
 // GetStatus returns the cron status
 func (c *CronHandler) GetStatus() map[string]interface{} {
 	jobs := c.store.List()
@@ -564,8 +938,16 @@ func (c *CronHandler) GetStatus() map[string]interface{} {
 	enabled := 0
 	disabled := 0
 	dueNow := 0
+	running := 0
+
+	c.mu.RLock()
+	maxConcurrent, overlap := cap(c.slots), c.overlap
+	c.mu.RUnlock()
 
 	for _, job := range jobs {
+		if job.State.Running {
+			running++
+		}
 		if job.Enabled {
 			enabled++
 		} else {
@@ -582,21 +964,90 @@ func (c *CronHandler) GetStatus() map[string]interface{} {
 		"enabled":      enabled,
 		"disabled":     disabled,
 		"due_now":     dueNow,
+		"running_jobs": running,
+		"max_concurrent": maxConcurrent,
+		"overlap":     overlap,
 		"next_check":  time.Now().Add(c.interval).UnixMilli(),
 	}
 }
 
+// lastJobID keeps IDs unique when several jobs are added within the same millisecond
+var lastJobID atomic.Int64
+
 // generateJobID generates a unique job ID
 func generateJobID() string {
-	return fmt.Sprintf("job-%d", time.Now().UnixMilli())
+	for {
+		prev := lastJobID.Load()
+		next := time.Now().UnixMilli()
+		if next <= prev {
+			next = prev + 1
+		}
+		if lastJobID.CompareAndSwap(prev, next) {
+			return fmt.Sprintf("job-%d", next)
+		}
+	}
+}
+
+// parseDependsOn reads a dependsOn value (array of job IDs or names, or a single string)
+func parseDependsOn(v interface{}) ([]string, error) {
+	switch deps := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		if deps == "" {
+			return nil, nil
+		}
+		return []string{deps}, nil
+	case []string:
+		return deps, nil
+	case []interface{}:
+		out := make([]string, 0, len(deps))
+		for _, d := range deps {
+			s, ok := d.(string)
+			if !ok || s == "" {
+				return nil, fmt.Errorf("dependsOn must be a list of job IDs or names")
+			}
+			out = append(out, s)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("dependsOn must be a list of job IDs or names")
+	}
 }
 
 // CreateJobFromMap creates a Job from a map (for API calls)
 func CreateJobFromMap(data map[string]interface{}) (*Job, error) {
 	job := &Job{
-		Enabled: true,
+		Enabled:       true,
+		SessionTarget: SessionTargetMain,
+		WakeMode:      WakeModeNow,
+	}
+	applyJobFields(job, data)
+
+	// Dependencies
+	if v, ok := data["dependsOn"]; ok {
+		deps, err := parseDependsOn(v)
+		if err != nil {
+			return nil, err
+		}
+		job.DependsOn = deps
+	}
+
+	// One-shot jobs delete themselves unless told otherwise
+	if _, ok := data["deleteAfterRun"].(bool); !ok && job.Schedule.Kind == ScheduleKindAt {
+		job.DeleteAfterRun = true
+	}
+
+	if err := validateJob(job); err != nil {
+		return nil, err
 	}
+	return job, nil
+}
 
+// applyJobFields copies the job definition fields present in data onto job.
+// enabled and dependsOn are left to the callers (create ignores enabled, and
+// dependencies are checked against the store).
+func applyJobFields(job *Job, data map[string]interface{}) {
 	// Basic fields
 	if v, ok := data["name"].(string); ok {
 		job.Name = v
@@ -627,18 +1078,12 @@ func CreateJobFromMap(data map[string]interface{}) (*Job, error) {
 		}
 	}
 
-	// Session target
+	// Session target and wake mode
 	if v, ok := data["sessionTarget"].(string); ok {
 		job.SessionTarget = v
-	} else {
-		job.SessionTarget = SessionTargetMain
 	}
-
-	// Wake mode
 	if v, ok := data["wakeMode"].(string); ok {
 		job.WakeMode = v
-	} else {
-		job.WakeMode = WakeModeNow
 	}
 
 	// Payload
@@ -663,9 +1108,14 @@ func CreateJobFromMap(data map[string]interface{}) (*Job, error) {
 		}
 	}
 
-	// Delivery
+	// Delivery (explicit null removes it)
+	if raw, ok := data["delivery"]; ok && raw == nil {
+		job.Delivery = nil
+	}
 	if delivery, ok := data["delivery"].(map[string]interface{}); ok {
-		job.Delivery = &Delivery{}
+		if job.Delivery == nil {
+			job.Delivery = &Delivery{}
+		}
 		if v, ok := delivery["mode"].(string); ok {
 			job.Delivery.Mode = v
 		}
@@ -683,16 +1133,36 @@ func CreateJobFromMap(data map[string]interface{}) (*Job, error) {
 	// Delete after run
 	if v, ok := data["deleteAfterRun"].(bool); ok {
 		job.DeleteAfterRun = v
-	} else if job.Schedule.Kind == ScheduleKindAt {
-		job.DeleteAfterRun = true
 	}
+}
 
-	// Validate
+// validateJob checks a complete job definition and aligns the payload kind
+// with the session target (main runs system events, isolated runs agent turns)
+func validateJob(job *Job) error {
 	if job.Name == "" {
-		return nil, fmt.Errorf("name is required")
+		return fmt.Errorf("name is required")
 	}
 	if job.Schedule.Kind == "" {
-		return nil, fmt.Errorf("schedule.kind is required")
+		return fmt.Errorf("schedule.kind is required")
+	}
+	loc, err := scheduleLocation(job.Schedule.Tz)
+	if err != nil {
+		return err
+	}
+	switch job.Schedule.Kind {
+	case ScheduleKindAt:
+		if job.Schedule.At != "" {
+			if _, err := parseAt(job.Schedule.At, loc); err != nil {
+				return err
+			}
+		}
+	case ScheduleKindCron:
+		if job.Schedule.Expr == "" {
+			return fmt.Errorf("schedule.expr is required for cron schedules")
+		}
+		if _, err := parseCronExpr(job.Schedule.Expr); err != nil {
+			return err
+		}
 	}
 	if job.SessionTarget == SessionTargetMain && job.Payload.Kind != PayloadKindSystemEvent {
 		job.Payload.Kind = PayloadKindSystemEvent
@@ -700,6 +1170,5 @@ func CreateJobFromMap(data map[string]interface{}) (*Job, error) {
 	if job.SessionTarget == SessionTargetIsolated && job.Payload.Kind != PayloadKindAgentTurn {
 		job.Payload.Kind = PayloadKindAgentTurn
 	}
-
-	return job, nil
+	return nil
 }
@@ -0,0 +1,633 @@
+package cron
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func newTestHandler(t *testing.T) *CronHandler {
+	t.Helper()
+	return NewCronHandler(filepath.Join(t.TempDir(), "jobs.json"))
+}
+
+func addEveryJob(t *testing.T, c *CronHandler, name string, deps ...string) *Job {
+	t.Helper()
+	data := map[string]interface{}{
+		"name":          name,
+		"schedule":      map[string]interface{}{"kind": "every", "everyMs": float64(60000)},
+		"sessionTarget": "main",
+		"payload":       map[string]interface{}{"kind": "systemEvent", "text": name},
+	}
+	if len(deps) > 0 {
+		list := make([]interface{}, len(deps))
+		for i, d := range deps {
+			list[i] = d
+		}
+		data["dependsOn"] = list
+	}
+	job, err := CreateJobFromMap(data)
+	if err != nil {
+		t.Fatalf("create %s: %v", name, err)
+	}
+	if err := c.AddJob(job); err != nil {
+		t.Fatalf("add %s: %v", name, err)
+	}
+	return job
+}
+
+func dueNames(c *CronHandler) map[string]bool {
+	names := map[string]bool{}
+	for _, job := range c.store.GetDueJobs() {
+		names[job.Name] = true
+	}
+	return names
+}
+
+func makeDue(jobs ...*Job) {
+	past := time.Now().Add(-time.Second).UnixMilli()
+	for _, job := range jobs {
+		job.State.NextRunAtMs = past
+	}
+}
+
+func TestDependentJobWaitsForUpstreamSuccess(t *testing.T) {
+	c := newTestHandler(t)
+	var ran []string
+	c.SetSystemEventCallback(func(text string) { ran = append(ran, text) })
+
+	a := addEveryJob(t, c, "extract")
+	b := addEveryJob(t, c, "report", a.ID)
+
+	makeDue(a, b)
+	due := dueNames(c)
+	if !due["extract"] || due["report"] {
+		t.Fatalf("before upstream run: due = %v, want only extract", due)
+	}
+
+	c.executeJob(a)
+	due = dueNames(c)
+	if !due["report"] {
+		t.Fatalf("after upstream success: due = %v, want report", due)
+	}
+	c.executeJob(b)
+
+	// Due again by schedule, but the upstream has not succeeded since
+	makeDue(b)
+	if due := dueNames(c); due["report"] {
+		t.Fatalf("report ran again without a new upstream success")
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	c.executeJob(a)
+	if due := dueNames(c); !due["report"] {
+		t.Fatalf("report not released by second upstream success")
+	}
+
+	if want := []string{"extract", "report", "extract"}; fmt.Sprint(ran) != fmt.Sprint(want) {
+		t.Fatalf("ran = %v, want %v", ran, want)
+	}
+}
+
+func TestDependentJobBlockedByUpstreamError(t *testing.T) {
+	c := newTestHandler(t)
+	c.SetAgentTurnCallback(func(sessionKey, message, model, thinking string) (string, error) {
+		return "", fmt.Errorf("upstream failed")
+	})
+
+	a, err := CreateJobFromMap(map[string]interface{}{
+		"name":          "fetch",
+		"schedule":      map[string]interface{}{"kind": "every", "everyMs": float64(60000)},
+		"sessionTarget": "isolated",
+		"payload":       map[string]interface{}{"kind": "agentTurn", "message": "fetch"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddJob(a); err != nil {
+		t.Fatal(err)
+	}
+	// Reference by name
+	b := addEveryJob(t, c, "summarize", "fetch")
+
+	c.executeJob(a)
+	if a.State.LastStatus != "error" {
+		t.Fatalf("upstream status = %q, want error", a.State.LastStatus)
+	}
+	makeDue(b)
+	if due := dueNames(c); due["summarize"] {
+		t.Fatalf("dependent released by a failed upstream run")
+	}
+}
+
+func TestDependencyValidation(t *testing.T) {
+	c := newTestHandler(t)
+	a := addEveryJob(t, c, "a")
+	b := addEveryJob(t, c, "b", a.ID)
+
+	job, err := CreateJobFromMap(map[string]interface{}{
+		"name":      "orphan",
+		"schedule":  map[string]interface{}{"kind": "every", "everyMs": float64(1000)},
+		"dependsOn": []interface{}{"missing"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddJob(job); err == nil {
+		t.Fatalf("unknown dependency accepted")
+	}
+
+	if _, err := c.UpdateJob(a.ID, map[string]interface{}{"dependsOn": []interface{}{a.ID}}); err == nil {
+		t.Fatalf("self dependency accepted")
+	}
+	if _, err := c.UpdateJob(a.ID, map[string]interface{}{"dependsOn": []interface{}{b.ID}}); err == nil {
+		t.Fatalf("dependency cycle accepted")
+	}
+	if len(a.DependsOn) != 0 {
+		t.Fatalf("rejected update changed dependsOn: %v", a.DependsOn)
+	}
+
+	if err := c.RemoveJob(a.ID); err == nil {
+		t.Fatalf("removed a job that another job depends on")
+	}
+	if _, err := c.UpdateJob(b.ID, map[string]interface{}{"dependsOn": []interface{}{}}); err != nil {
+		t.Fatalf("clear dependsOn: %v", err)
+	}
+	if err := c.RemoveJob(a.ID); err != nil {
+		t.Fatalf("remove after clearing dependency: %v", err)
+	}
+}
+
+func TestUpdateFullDefinition(t *testing.T) {
+	c := newTestHandler(t)
+	job := addEveryJob(t, c, "report")
+
+	updated, err := c.UpdateJob(job.ID, map[string]interface{}{
+		"sessionTarget":  "isolated",
+		"wakeMode":       "next-heartbeat",
+		"deleteAfterRun": true,
+		"payload":        map[string]interface{}{"message": "summarize", "timeoutSeconds": float64(30)},
+		"delivery":       map[string]interface{}{"mode": "announce", "channel": "telegram", "to": "42"},
+	})
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if updated.SessionTarget != SessionTargetIsolated || updated.WakeMode != WakeModeNextHeartbeat || !updated.DeleteAfterRun {
+		t.Fatalf("session fields not applied: %+v", updated)
+	}
+	if updated.Payload.Kind != PayloadKindAgentTurn || updated.Payload.Message != "summarize" || updated.Payload.TimeoutSeconds != 30 {
+		t.Fatalf("payload not applied: %+v", updated.Payload)
+	}
+	if updated.Delivery == nil || updated.Delivery.Channel != "telegram" || updated.Delivery.To != "42" {
+		t.Fatalf("delivery not applied: %+v", updated.Delivery)
+	}
+
+	if _, err := c.UpdateJob(job.ID, map[string]interface{}{"name": "", "delivery": nil}); err == nil {
+		t.Fatalf("empty name accepted")
+	}
+	if job.Name != "report" || job.Delivery == nil {
+		t.Fatalf("rejected update changed the job: %+v", job)
+	}
+
+	if _, err := c.UpdateJob(job.ID, map[string]interface{}{"delivery": nil}); err != nil {
+		t.Fatalf("clear delivery: %v", err)
+	}
+	if job.Delivery != nil {
+		t.Fatalf("delivery not cleared: %+v", job.Delivery)
+	}
+}
+
+func TestNextCronRun(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("tzdata: %v", err)
+	}
+	cases := []struct {
+		name  string
+		expr  string
+		after time.Time
+		want  time.Time
+	}{
+		{"quarter hour", "*/15 * * * *", time.Date(2026, 10, 14, 10, 7, 30, 0, ny), time.Date(2026, 10, 14, 10, 15, 0, 0, ny)},
+		{"quarter hour on the boundary", "*/15 * * * *", time.Date(2026, 10, 14, 10, 45, 0, 0, ny), time.Date(2026, 10, 14, 11, 0, 0, 0, ny)},
+		{"weekday morning", "0 9 * * 1-5", time.Date(2026, 10, 14, 8, 0, 0, 0, ny), time.Date(2026, 10, 14, 9, 0, 0, 0, ny)},
+		{"weekday morning over the weekend", "0 9 * * 1-5", time.Date(2026, 10, 16, 9, 0, 0, 0, ny), time.Date(2026, 10, 19, 9, 0, 0, 0, ny)},
+		// 2026-03-08 02:00 EST jumps to 03:00 EDT: 02:30 does not exist that day
+		{"spring forward gap", "30 2 * * *", time.Date(2026, 3, 7, 12, 0, 0, 0, ny), time.Date(2026, 3, 9, 2, 30, 0, 0, ny)},
+		{"spring forward keeps 9:00", "0 9 * * *", time.Date(2026, 3, 7, 12, 0, 0, 0, ny), time.Date(2026, 3, 8, 9, 0, 0, 0, ny)},
+		// 2026-11-01 02:00 EDT falls back to 01:00 EST: 01:30 happens twice
+		{"fall back first 01:30", "30 1 * * *", time.Date(2026, 10, 31, 12, 0, 0, 0, ny), time.Date(2026, 11, 1, 1, 30, 0, 0, ny)},
+		{"fall back no second 01:30", "30 1 * * *", time.Date(2026, 11, 1, 1, 30, 0, 0, ny), time.Date(2026, 11, 2, 1, 30, 0, 0, ny)},
+	}
+	for _, c := range cases {
+		got, err := nextCronRun(c.expr, ny, c.after)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if !got.Equal(c.want) {
+			t.Errorf("%s: next(%q, %v) = %v, want %v", c.name, c.expr, c.after, got, c.want)
+		}
+	}
+
+	// Hourly jobs fire on elapsed time, so the repeated hour runs twice
+	first := time.Date(2026, 11, 1, 1, 0, 0, 0, ny)
+	got, err := nextCronRun("0 * * * *", ny, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := got.Sub(first); d != time.Hour {
+		t.Errorf("hourly across fall back: next after %v = %v (%v later), want 1h", first, got, d)
+	}
+
+	// The zone applies to the fields, not to the input time
+	utc := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC) // 08:00 EDT
+	got, _ = nextCronRun("0 9 * * 1-5", ny, utc)
+	if want := time.Date(2026, 10, 14, 13, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("tz: got %v, want %v", got.UTC(), want)
+	}
+}
+
+func TestCronScheduleValidation(t *testing.T) {
+	for _, expr := range []string{"", "* * *", "61 * * * *", "0 9 * * mon-fri-sat"} {
+		_, err := CreateJobFromMap(map[string]interface{}{
+			"name":     "bad",
+			"schedule": map[string]interface{}{"kind": "cron", "expr": expr},
+		})
+		if err == nil {
+			t.Errorf("expr %q accepted", expr)
+		}
+	}
+
+	job, err := CreateJobFromMap(map[string]interface{}{
+		"name":     "daily",
+		"schedule": map[string]interface{}{"kind": "cron", "expr": "0 9 * * *", "tz": "UTC"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	next := time.UnixMilli(newTestHandler(t).store.CalculateNextRun(job)).UTC()
+	if next.Hour() != 9 || next.Minute() != 0 || time.Until(next) > 24*time.Hour {
+		t.Fatalf("next run = %v, want the next 09:00 UTC", next)
+	}
+}
+
+func TestScheduleTimeZone(t *testing.T) {
+	store := newTestHandler(t).store
+	at := func(at, tz string) int64 {
+		t.Helper()
+		job, err := CreateJobFromMap(map[string]interface{}{
+			"name":     "reminder",
+			"schedule": map[string]interface{}{"kind": "at", "at": at, "tz": tz},
+		})
+		if err != nil {
+			t.Fatalf("at %q tz %q: %v", at, tz, err)
+		}
+		return store.CalculateNextRun(job)
+	}
+
+	// Wall-clock time in the job's zone: 10:00 in Shanghai is 02:00 UTC
+	if got, want := at("2026-02-15T10:00:00", "Asia/Shanghai"), time.Date(2026, 2, 15, 2, 0, 0, 0, time.UTC).UnixMilli(); got != want {
+		t.Errorf("at in Asia/Shanghai = %v, want %v", time.UnixMilli(got).UTC(), time.UnixMilli(want).UTC())
+	}
+	// An explicit offset wins over tz
+	if got, want := at("2026-02-15T10:00:00Z", "Asia/Shanghai"), time.Date(2026, 2, 15, 10, 0, 0, 0, time.UTC).UnixMilli(); got != want {
+		t.Errorf("at with Z = %v, want %v", time.UnixMilli(got).UTC(), time.UnixMilli(want).UTC())
+	}
+	// No tz: the server's local zone
+	if got, want := at("2026-02-15 10:00", ""), time.Date(2026, 2, 15, 10, 0, 0, 0, time.Local).UnixMilli(); got != want {
+		t.Errorf("at without tz = %v, want %v", time.UnixMilli(got), time.UnixMilli(want))
+	}
+
+	for _, kind := range []map[string]interface{}{
+		{"kind": "at", "at": "2026-02-15T10:00:00", "tz": "Mars/Olympus"},
+		{"kind": "cron", "expr": "0 9 * * *", "tz": "Mars/Olympus"},
+	} {
+		_, err := CreateJobFromMap(map[string]interface{}{"name": "bad", "schedule": kind})
+		if err == nil || !strings.Contains(err.Error(), "Mars/Olympus") {
+			t.Errorf("unknown zone for %v: err = %v", kind["kind"], err)
+		}
+	}
+	if _, err := CreateJobFromMap(map[string]interface{}{
+		"name":     "bad",
+		"schedule": map[string]interface{}{"kind": "at", "at": "tomorrow"},
+	}); err == nil {
+		t.Errorf("unparseable at accepted")
+	}
+}
+
+func TestShutdownWaitsForRunningJob(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	c := NewCronHandler(path)
+	started, release := make(chan struct{}), make(chan struct{})
+	c.SetAgentTurnCallback(func(sessionKey, message, model, thinking string) (string, error) {
+		close(started)
+		<-release
+		return "done", nil
+	})
+	job, err := CreateJobFromMap(map[string]interface{}{
+		"name":          "slow",
+		"schedule":      map[string]interface{}{"kind": "every", "everyMs": float64(60000)},
+		"sessionTarget": "isolated",
+		"payload":       map[string]interface{}{"kind": "agentTurn", "message": "work"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddJob(job); err != nil {
+		t.Fatal(err)
+	}
+	c.Start()
+	if err := c.RunJob(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	<-started
+
+	// Bounded: gives up while the job is still running
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.Shutdown(ctx); err == nil {
+		t.Fatal("Shutdown returned before the running job finished")
+	}
+
+	close(release)
+	if err := c.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	saved := NewJobStore(path)
+	got, ok := saved.Get(job.ID)
+	if !ok || got.State.LastStatus != "ok" || got.State.NextRunAtMs == 0 {
+		t.Fatalf("store not flushed after the run: %+v", got)
+	}
+}
+
+func TestStartKeepsPersistedNextRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	c := NewCronHandler(path)
+	upcoming := addEveryJob(t, c, "upcoming")
+	missed := addEveryJob(t, c, "missed")
+	future := time.Now().Add(5 * time.Minute).UnixMilli()
+	upcoming.State.NextRunAtMs = future
+	// Due two intervals ago: the gateway was down (or asleep) through both
+	missed.State.NextRunAtMs = time.Now().Add(-2 * time.Minute).UnixMilli()
+	if err := c.store.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := NewCronHandler(path)
+	restarted.interval = time.Hour // no ticks during the test
+	restarted.Start()
+	defer restarted.Stop()
+
+	if got, _ := restarted.GetJob(upcoming.ID); got.State.NextRunAtMs != future {
+		t.Fatalf("next run reset on restart: %d, want %d", got.State.NextRunAtMs, future)
+	}
+	due := restarted.store.GetDueJobs()
+	if len(due) != 1 || due[0].Name != "missed" {
+		t.Fatalf("due after restart = %v, want only the missed job", due)
+	}
+	restarted.executeJob(due[0])
+	if due := dueNames(restarted); len(due) != 0 {
+		t.Fatalf("missed job caught up more than once: due = %v", due)
+	}
+	if next := due[0].State.NextRunAtMs; next < time.Now().Add(50*time.Second).UnixMilli() {
+		t.Fatalf("caught-up job not rescheduled from now: %v", time.UnixMilli(next))
+	}
+}
+
+// blockingTurns makes agent turns wait on release and counts them
+type blockingTurns struct {
+	mu      sync.Mutex
+	calls   int
+	active  int
+	peak    int
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newBlockingTurns(c *CronHandler) *blockingTurns {
+	b := &blockingTurns{entered: make(chan struct{}, 16), release: make(chan struct{})}
+	c.SetAgentTurnCallback(func(sessionKey, message, model, thinking string) (string, error) {
+		b.mu.Lock()
+		b.calls++
+		b.active++
+		b.peak = max(b.peak, b.active)
+		b.mu.Unlock()
+		b.entered <- struct{}{}
+		<-b.release
+		b.mu.Lock()
+		b.active--
+		b.mu.Unlock()
+		return "ok", nil
+	})
+	return b
+}
+
+func addAgentJob(t *testing.T, c *CronHandler, name string) *Job {
+	t.Helper()
+	job, err := CreateJobFromMap(map[string]interface{}{
+		"name":          name,
+		"schedule":      map[string]interface{}{"kind": "every", "everyMs": float64(60000)},
+		"sessionTarget": "isolated",
+		"payload":       map[string]interface{}{"kind": "agentTurn", "message": name},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AddJob(job); err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestMaxConcurrentJobs(t *testing.T) {
+	c := newTestHandler(t)
+	c.SetMaxConcurrent(2)
+	turns := newBlockingTurns(c)
+	var jobs []*Job
+	for _, name := range []string{"a", "b", "c"} {
+		jobs = append(jobs, addAgentJob(t, c, name))
+	}
+	makeDue(jobs...)
+	c.tick()
+
+	<-turns.entered
+	<-turns.entered
+	select {
+	case <-turns.entered:
+		t.Fatal("third job started past the limit of 2")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if due := dueNames(c); len(due) != 0 {
+		t.Fatalf("claimed jobs still due: %v", due)
+	}
+	close(turns.release)
+	c.runs.Wait()
+	if turns.calls != 3 || turns.peak != 2 {
+		t.Fatalf("calls = %d, peak = %d; want 3 and 2", turns.calls, turns.peak)
+	}
+	for _, job := range jobs {
+		if job.State.Running || job.State.LastStatus != "ok" {
+			t.Fatalf("%s: state after run %+v", job.Name, job.State)
+		}
+	}
+}
+
+func TestOverlapPolicy(t *testing.T) {
+	if err := newTestHandler(t).SetOverlapPolicy("parallel"); err == nil {
+		t.Fatal("unknown overlap policy accepted")
+	}
+	for policy, wantCalls := range map[string]int{OverlapSkip: 1, OverlapQueue: 2} {
+		c := newTestHandler(t)
+		if err := c.SetOverlapPolicy(policy); err != nil {
+			t.Fatal(err)
+		}
+		turns := newBlockingTurns(c)
+		job := addAgentJob(t, c, "slow")
+		makeDue(job)
+		c.tick()
+		<-turns.entered
+
+		// Due again while the first run is in flight, twice
+		for i := 0; i < 2; i++ {
+			makeDue(job)
+			c.tick()
+		}
+		err := c.RunJob(job.ID)
+		if policy == OverlapSkip && !errors.Is(err, ErrJobRunning) {
+			t.Fatalf("%s: RunJob while running = %v, want ErrJobRunning", policy, err)
+		}
+		if policy == OverlapQueue && err != nil {
+			t.Fatalf("%s: RunJob while running = %v, want queued", policy, err)
+		}
+
+		close(turns.release)
+		c.runs.Wait()
+		if turns.calls != wantCalls {
+			t.Errorf("%s: %d runs, want %d", policy, turns.calls, wantCalls)
+		}
+		if job.State.Running || job.State.Queued {
+			t.Errorf("%s: flags left set: %+v", policy, job.State)
+		}
+	}
+}
+
+func TestIsolatedJobSession(t *testing.T) {
+	c := newTestHandler(t)
+	var session string
+	c.SetAgentTurnCallback(func(sessionKey, message, model, thinking string) (string, error) {
+		session = sessionKey
+		return "ok", nil
+	})
+	job := addAgentJob(t, c, "digest")
+	c.executeJob(job)
+	if want := "cron:" + job.ID; session != want {
+		t.Errorf("isolated job ran in session %q, want %q", session, want)
+	}
+	if key := addEveryJob(t, c, "ping").SessionKey(); key != "" {
+		t.Errorf("main job session = %q, want the default session", key)
+	}
+}
+
+func TestRunHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jobs.json")
+	c := NewCronHandler(path)
+	runs := 0
+	c.SetAgentTurnCallback(func(sessionKey, message, model, thinking string) (string, error) {
+		runs++
+		if runs == RunHistoryLimit+5 {
+			return "", errors.New("upstream failed")
+		}
+		return strings.Repeat("é", RunOutputLimit+100), nil
+	})
+	job := addAgentJob(t, c, "report")
+	for i := 0; i < RunHistoryLimit+5; i++ {
+		c.executeJob(job)
+	}
+
+	history, err := NewCronHandler(path).JobHistory(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != RunHistoryLimit {
+		t.Fatalf("history has %d runs, want %d", len(history), RunHistoryLimit)
+	}
+	if last := history[0]; last.Status != "error" || last.Error != "upstream failed" {
+		t.Errorf("newest run = %+v, want the failed one", last)
+	}
+	if out := history[1].Output; utf8.RuneCountInString(out) != RunOutputLimit+1 || !strings.HasSuffix(out, "…") {
+		t.Errorf("output not truncated to %d characters: %d", RunOutputLimit, utf8.RuneCountInString(out))
+	}
+	for i := 1; i < len(history); i++ {
+		if history[i].StartedAtMs > history[i-1].StartedAtMs {
+			t.Fatalf("history not newest first at %d", i)
+		}
+	}
+	if _, err := c.JobHistory("missing"); err == nil {
+		t.Error("history of an unknown job returned no error")
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	c := newTestHandler(t)
+	a := addEveryJob(t, c, "a")
+	addEveryJob(t, c, "b", "a")
+	c.executeJob(a)
+
+	exported, err := c.ExportJobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(exported) != 2 || exported[0]["name"] != "a" || exported[0]["state"] != nil {
+		t.Fatalf("export = %v, want a and b without state", exported)
+	}
+
+	// A fresh store takes the export as-is
+	fresh := newTestHandler(t)
+	if res, err := fresh.ImportJobs(exported, ImportReplace); err != nil || res.Created != 2 {
+		t.Fatalf("import into empty store = %+v, %v", res, err)
+	}
+	if got, ok := fresh.GetJob(a.ID); !ok || got.State.NextRunAtMs == 0 {
+		t.Fatalf("imported job %s missing or unscheduled", a.ID)
+	}
+
+	// Merge by name: the definition changes, run history stays
+	edit := map[string]interface{}{
+		"name":     "a",
+		"enabled":  false,
+		"schedule": map[string]interface{}{"kind": "every", "everyMs": float64(120000)},
+		"payload":  map[string]interface{}{"kind": "systemEvent", "text": "edited"},
+	}
+	jobC := map[string]interface{}{
+		"name":     "c",
+		"schedule": map[string]interface{}{"kind": "every", "everyMs": float64(60000)},
+		"payload":  map[string]interface{}{"kind": "systemEvent", "text": "c"},
+	}
+	res, err := c.ImportJobs([]map[string]interface{}{edit, jobC}, "")
+	if err != nil || res != (ImportResult{Created: 1, Updated: 1}) {
+		t.Fatalf("merge = %+v, %v", res, err)
+	}
+	if got, _ := c.GetJob(a.ID); got != a || got.Enabled || got.Payload.Text != "edited" || len(got.History) != 1 {
+		t.Fatalf("merged job = %+v", got)
+	}
+
+	// All or nothing: one bad entry or a dangling dependency changes nothing
+	bad := map[string]interface{}{"name": "broken", "schedule": map[string]interface{}{"kind": "cron", "expr": "nope"}}
+	if _, err := c.ImportJobs([]map[string]interface{}{jobC, bad}, ImportMerge); err == nil {
+		t.Fatal("invalid job imported")
+	}
+	if _, err := c.ImportJobs([]map[string]interface{}{exported[1]}, ImportReplace); err == nil {
+		t.Fatal("replace kept b but dropped a, which b depends on")
+	}
+	if _, err := c.ImportJobs(nil, "append"); err == nil {
+		t.Fatal("unknown mode accepted")
+	}
+	if n := len(c.ListJobs()); n != 3 {
+		t.Fatalf("%d jobs after rejected imports, want 3", n)
+	}
+}
@@ -0,0 +1,95 @@
+// Schedule times - time zones, "at" timestamps and cron expressions
+
+package cron
+
+import (
+	"fmt"
+	"time"
+
+	robfig "github.com/robfig/cron/v3"
+)
+
+// scheduleLocation loads the IANA zone named by tz; empty means the server's
+// local zone. An unknown name is an error rather than a silent fallback to UTC.
+func scheduleLocation(tz string) (*time.Location, error) {
+	if tz == "" {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil {
+		return nil, fmt.Errorf("schedule.tz %q: unknown time zone", tz)
+	}
+	return loc, nil
+}
+
+// atLayouts are the accepted "at" formats without a UTC offset
+var atLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// parseAt reads an "at" timestamp. RFC 3339 with an offset ("Z", "+08:00") is
+// an absolute time; without one the wall-clock time is read in loc.
+func parseAt(at string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, at); err == nil {
+		return t, nil
+	}
+	for _, layout := range atLayouts {
+		if t, err := time.ParseInLocation(layout, at, loc); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("schedule.at %q: want RFC 3339, e.g. 2026-02-15T10:00:00 or 2026-02-15T10:00:00Z", at)
+}
+
+// parseCronExpr parses a standard 5-field expression (minute hour day-of-month
+// month day-of-week) or a descriptor such as @daily.
+func parseCronExpr(expr string) (robfig.Schedule, error) {
+	sched, err := robfig.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("schedule.expr %q: %v", expr, err)
+	}
+	return sched, nil
+}
+
+// allHours is the hour field of a schedule that fires every hour
+const allHours = 1<<24 - 1
+
+// nextCronRun returns the first fire time of expr after after, with the fields
+// read as wall-clock time in loc.
+// Around DST changes: a time that falls in the skipped hour does not fire that
+// day, and a job with fixed hours fires once in the repeated hour, at the first
+// occurrence. Jobs that run every hour keep firing on elapsed time.
+func nextCronRun(expr string, loc *time.Location, after time.Time) (time.Time, error) {
+	sched, err := parseCronExpr(expr)
+	if err != nil {
+		return time.Time{}, err
+	}
+	spec, isSpec := sched.(*robfig.SpecSchedule)
+	if isSpec {
+		spec.Location = loc
+	}
+	next := sched.Next(after.In(loc))
+	if isSpec && spec.Hour&allHours != allHours && !next.IsZero() && secondOccurrence(next) {
+		next = sched.Next(next)
+	}
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("schedule.expr %q never fires", expr)
+	}
+	return next, nil
+}
+
+// secondOccurrence reports whether t's wall-clock time already happened once,
+// before a fall-back transition moved the clock back
+func secondOccurrence(t time.Time) bool {
+	_, offset := t.Zone()
+	_, before := t.Add(-2 * time.Hour).Zone()
+	if before <= offset {
+		return false
+	}
+	earlier := t.Add(-time.Duration(before-offset) * time.Second)
+	_, earlierOffset := earlier.Zone()
+	return earlierOffset == before
+}
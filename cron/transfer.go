@@ -0,0 +1,169 @@
+// Bulk job transfer - export the job definitions and import them as one change
+
+package cron
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// Import modes
+const (
+	ImportMerge   = "merge"   // imported jobs are added or replace the matching job; others stay
+	ImportReplace = "replace" // the store holds exactly the imported jobs afterwards
+)
+
+// ImportResult counts what an import changed
+type ImportResult struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Removed int `json:"removed"`
+}
+
+// exportOmit are the Job fields that describe runs rather than the definition
+var exportOmit = []string{"state", "history", "createdAt", "updatedAt"}
+
+// ExportJobs returns every job definition, sorted by name, in the form
+// ImportJobs and CreateJobFromMap read. Run state and history are left out so
+// an export stays stable under version control.
+func (c *CronHandler) ExportJobs() ([]map[string]interface{}, error) {
+	jobs := c.store.List()
+	sort.Slice(jobs, func(i, j int) bool {
+		if jobs[i].Name != jobs[j].Name {
+			return jobs[i].Name < jobs[j].Name
+		}
+		return jobs[i].ID < jobs[j].ID
+	})
+
+	c.store.mu.RLock()
+	data, err := json.Marshal(jobs)
+	c.store.mu.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	var out []map[string]interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	for _, m := range out {
+		for _, k := range exportOmit {
+			delete(m, k)
+		}
+	}
+	return out, nil
+}
+
+// ImportJobs validates every definition with CreateJobFromMap and applies them
+// all or none. A definition matches an existing job by "id", or by name when it
+// has no id; a match keeps the job's run state and history. "enabled" is
+// honoured. Dependencies are checked against the store as it will be after the
+// import.
+func (c *CronHandler) ImportJobs(defs []map[string]interface{}, mode string) (ImportResult, error) {
+	var res ImportResult
+	if mode == "" {
+		mode = ImportMerge
+	}
+	if mode != ImportMerge && mode != ImportReplace {
+		return res, fmt.Errorf("unknown import mode %q (want %s or %s)", mode, ImportMerge, ImportReplace)
+	}
+
+	js := c.store
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	// The store after the import, built aside so a bad entry changes nothing
+	next := make(map[string]*Job)
+	if mode == ImportMerge {
+		for id, job := range js.jobs {
+			next[id] = job
+		}
+	}
+	byName := make(map[string]*Job)
+	for _, job := range js.jobs {
+		if _, dup := byName[job.Name]; dup {
+			byName[job.Name] = nil // ambiguous: match by id only
+			continue
+		}
+		byName[job.Name] = job
+	}
+
+	imported := make(map[string]*Job, len(defs))
+	seen := make(map[string]bool, len(defs))
+	now := time.Now()
+	for i, def := range defs {
+		job, err := CreateJobFromMap(def)
+		if err != nil {
+			return res, fmt.Errorf("jobs[%d] (%v): %v", i, def["name"], err)
+		}
+		if v, ok := def["enabled"].(bool); ok {
+			job.Enabled = v
+		}
+		var existing *Job
+		if id, _ := def["id"].(string); id != "" {
+			job.ID = id
+			existing = js.jobs[id]
+		} else if existing = byName[job.Name]; existing != nil {
+			job.ID = existing.ID
+		} else {
+			job.ID = generateJobID()
+		}
+		if seen[job.ID] {
+			return res, fmt.Errorf("jobs[%d] (%s): job %s imported more than once", i, job.Name, job.ID)
+		}
+		seen[job.ID] = true
+
+		if existing != nil {
+			job.CreatedAt, job.State, job.History = existing.CreatedAt, existing.State, existing.History
+		} else {
+			job.CreatedAt = now
+		}
+		job.UpdatedAt = now
+		imported[job.ID] = job
+		next[job.ID] = job
+	}
+
+	check := &JobStore{jobs: next}
+	for _, job := range imported {
+		if err := check.checkDependenciesLocked(job.ID, job.DependsOn); err != nil {
+			return res, fmt.Errorf("job %s (%s): %v", job.ID, job.Name, err)
+		}
+	}
+	for _, job := range next {
+		for _, ref := range job.DependsOn {
+			if _, err := check.findLocked(ref); err != nil {
+				return res, fmt.Errorf("job %s (%s): %v", job.ID, job.Name, err)
+			}
+		}
+	}
+
+	jobs := make(map[string]*Job, len(next))
+	for id, job := range next {
+		old, ok := js.jobs[id]
+		if !ok {
+			job.State.NextRunAtMs = js.CalculateNextRun(job)
+			jobs[id] = job
+			res.Created++
+			continue
+		}
+		if job != old {
+			// Keep the pointer: running executions and callers hold it
+			running, queued := old.State.Running, old.State.Queued
+			*old = *job
+			old.State.Running, old.State.Queued = running, queued
+			old.State.NextRunAtMs = js.CalculateNextRun(old)
+			res.Updated++
+		}
+		jobs[id] = old
+	}
+	res.Removed = len(js.jobs) + res.Created - len(jobs)
+	js.jobs = jobs
+
+	if err := js.saveLocked(); err != nil {
+		return res, err
+	}
+	log.Printf("[Cron] Imported jobs (%s): %d created, %d updated, %d removed", mode, res.Created, res.Updated, res.Removed)
+	return res, nil
+}
@@ -0,0 +1,178 @@
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+const (
+	// actorHeader lets operators sharing the UI token label their requests.
+	// It is recorded as the claimed actor only; the actor comes from the credential.
+	actorHeader   = "X-OCG-Actor"
+	maxActorLabel = 64
+	// maxAuditBody bounds how much of a request body is inspected for the target
+	maxAuditBody = 1 << 20
+)
+
+// auditActor is the caller resolved by requireAuth
+type auditActor struct {
+	Name    string
+	KeyID   string
+	Claimed string // unverified X-OCG-Actor label
+}
+
+type actorCtxKey struct{}
+
+// resolveActor names the authenticated caller from the credential it used.
+// The key ID is a short fingerprint of the token, so entries can be
+// attributed without storing it. The free-form actor header is kept apart
+// as the claimed actor, since anyone holding the token can send any label.
+func resolveActor(r *http.Request, token string) auditActor {
+	sum := sha256.Sum256([]byte(token))
+	actor := auditActor{Name: "ui-token", KeyID: "ui:" + hex.EncodeToString(sum[:4])}
+	if label := strings.TrimSpace(r.Header.Get(actorHeader)); label != "" {
+		if len(label) > maxActorLabel {
+			label = label[:maxActorLabel]
+		}
+		actor.Claimed = label
+	}
+	return actor
+}
+
+func withActor(r *http.Request, actor auditActor) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), actorCtxKey{}, actor))
+}
+
+func actorFrom(r *http.Request) auditActor {
+	if actor, ok := r.Context().Value(actorCtxKey{}).(auditActor); ok {
+		return actor
+	}
+	return auditActor{Name: "anonymous"}
+}
+
+// statusRecorder captures the status code written by a handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	s.status = code
+	s.ResponseWriter.WriteHeader(code)
+}
+
+// audited records every call of next in the audit log, including failed ones.
+func (g *Gateway) audited(action string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(r.Body, maxAuditBody))
+			r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		}
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		g.recordAudit(r, action, auditTarget(r, body), rec.status)
+	}
+}
+
+// auditedWrites is audited for routes that also serve reads: GET passes through unrecorded.
+func (g *Gateway) auditedWrites(action string, next http.HandlerFunc) http.HandlerFunc {
+	record := g.audited(action, next)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			next(w, r)
+			return
+		}
+		record(w, r)
+	}
+}
+
+func (g *Gateway) recordAudit(r *http.Request, action, target string, status int) {
+	actor := actorFrom(r)
+	args := rpcproto.AuditAppendArgs{
+		Actor:        actor.Name,
+		KeyID:        actor.KeyID,
+		ClaimedActor: actor.Claimed,
+		Action:       action,
+		Target:       target,
+		Status:       status,
+		RemoteAddr:   r.RemoteAddr,
+	}
+	client, err := g.clientOrError()
+	if err == nil {
+		var reply rpcproto.ToolResultReply
+		err = client.Call("Agent.AuditAppend", args, &reply)
+	}
+	if err != nil {
+		// Never lose the record entirely: fall back to the process log
+		log.Printf("⚠️ audit write failed (%v): actor=%s action=%s target=%s status=%d", err, args.Actor, action, target, status)
+	}
+}
+
+// auditTarget picks what an operation acted on: an ID-like body field or query
+// param, otherwise the names (never the values) of the submitted fields.
+func auditTarget(r *http.Request, body []byte) string {
+	var fields map[string]interface{}
+	json.Unmarshal(body, &fields)
+	for _, key := range []string{"jobId", "id", "name", "url"} {
+		if v, ok := fields[key].(string); ok && v != "" {
+			return key + "=" + v
+		}
+	}
+	if id := r.URL.Query().Get("id"); id != "" {
+		return "id=" + id
+	}
+	if len(fields) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return "fields=" + strings.Join(names, ",")
+}
+
+// handleAdminAudit lists audit entries, newest first.
+// Query: actor, action (exact or "prefix*"), before (entry ID), limit.
+func (g *Gateway) handleAdminAudit(w http.ResponseWriter, r *http.Request) {
+	client, err := g.clientOrError()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	q := r.URL.Query()
+	args := rpcproto.AuditListArgs{Actor: q.Get("actor"), Action: q.Get("action")}
+	if v := q.Get("limit"); v != "" {
+		args.Limit, _ = strconv.Atoi(v)
+	}
+	if v := q.Get("before"); v != "" {
+		args.BeforeID, _ = strconv.ParseInt(v, 10, 64)
+	}
+
+	var reply rpcproto.ToolResultReply
+	if err := client.Call("Agent.AuditList", args, &reply); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(reply.Result))
+}
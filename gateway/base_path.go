@@ -0,0 +1,69 @@
+package gateway
+
+import (
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// basePathPlaceholder in static/index.html is replaced with Config.BasePath when served
+const basePathPlaceholder = "__OCG_BASE_PATH__"
+
+// basePathChars keeps the base path safe to splice into the page's script
+var basePathChars = regexp.MustCompile(`^[A-Za-z0-9/._~-]*$`)
+
+// normalizeBasePath turns "ai", "/ai/" or "/ai" into "/ai"; "" and "/" mean the root
+func normalizeBasePath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	p = "/" + p
+	if !basePathChars.MatchString(p) {
+		log.Printf("[WARN] BasePath %q has unsupported characters; serving at /", p)
+		return ""
+	}
+	return p
+}
+
+// withBasePath strips base from request paths before next sees them, so routes stay
+// registered at /; paths outside base are 404 and base itself redirects to base + "/"
+func withBasePath(base string, next http.Handler) http.Handler {
+	if base == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == base {
+			target := base + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+			return
+		}
+		rest, ok := strings.CutPrefix(r.URL.Path, base+"/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		r2 := r.Clone(r.Context())
+		r2.URL.Path = "/" + rest
+		if r.URL.RawPath != "" {
+			r2.URL.RawPath = "/" + strings.TrimPrefix(r.URL.RawPath, base+"/")
+		}
+		next.ServeHTTP(w, r2)
+	})
+}
+
+// serveIndex serves the web UI with its API and WebSocket URLs under the base path
+func (g *Gateway) serveIndex(w http.ResponseWriter, r *http.Request) {
+	page, err := embeddedStaticFS.ReadFile("static/index.html")
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	page = []byte(strings.ReplaceAll(string(page), basePathPlaceholder, g.cfg.BasePath))
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(page)
+}
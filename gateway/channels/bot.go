@@ -10,6 +10,7 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gliderlab/cogate/rpcproto"
@@ -21,11 +22,13 @@ type TelegramBot struct {
 	baseURL     string
 	client      *http.Client
 	agentRPC    AgentRPCInterface
+	dispatch    Dispatcher // set by ChannelAdapter.RegisterChannel
 	running     bool
 	stopCh      chan struct{}
 	// Greeting configuration
 	greetingEnabled bool
 	greetingText   string
+	greetedMu      sync.Mutex     // workers process messages concurrently
 	greetedUsers   map[int64]bool // Track users who have received greeting
 }
 
@@ -49,6 +52,11 @@ func (b *TelegramBot) SetGreeting(enabled bool, text string) {
 	b.greetingText = text
 }
 
+// SetDispatcher routes inbound messages through the adapter's worker pool
+func (b *TelegramBot) SetDispatcher(d Dispatcher) {
+	b.dispatch = d
+}
+
 // ChannelInfo returns metadata about this channel
 func (b *TelegramBot) ChannelInfo() ChannelInfo {
 	return ChannelInfo{
@@ -224,7 +232,16 @@ func (b *TelegramBot) HandleWebhook(w http.ResponseWriter, r *http.Request) {
 
 	// Process message if present
 	if update.Message.Text != "" {
-		go b.processMessage(update.Message)
+		msg := update.Message
+		if b.dispatch == nil {
+			go b.processMessage(msg)
+		} else if err := b.dispatch(func() { b.processMessage(msg) }); err != nil {
+			// Non-2xx makes Telegram redeliver the update later
+			log.Printf("⚠️ Telegram update %d not queued: %v", update.UpdateID, err)
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+			return
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -246,8 +263,7 @@ func (b *TelegramBot) processMessage(TgMessage IncomingMessage) {
 
 	// Send greeting to new users (not /start command)
 	if b.greetingEnabled && !strings.HasPrefix(TgMessage.Text, "/") {
-		if !b.greetedUsers[userID] {
-			b.greetedUsers[userID] = true
+		if b.markGreeted(userID) {
 			// Send greeting after a short delay
 			go func() {
 				time.Sleep(500 * time.Millisecond)
@@ -258,7 +274,7 @@ func (b *TelegramBot) processMessage(TgMessage IncomingMessage) {
 
 	// Handle commands
 	if strings.HasPrefix(TgMessage.Text, "/start") {
-		b.greetedUsers[userID] = true // Mark as greeted
+		b.markGreeted(userID) // Mark as greeted
 		b.sendSimpleMessage(chatID, fmt.Sprintf("Hello %s! I'm OpenClaw-Go Telegram Bot. Send me a message!", TgMessage.From.FirstName))
 		return
 	}
@@ -270,7 +286,9 @@ func (b *TelegramBot) processMessage(TgMessage IncomingMessage) {
 
 	if strings.HasPrefix(TgMessage.Text, "/reset") {
 		// Reset greeting status for this user
+		b.greetedMu.Lock()
 		delete(b.greetedUsers, userID)
+		b.greetedMu.Unlock()
 		b.sendSimpleMessage(chatID, "Greeting status reset! You'll receive a greeting on your next message.")
 		return
 	}
@@ -298,7 +316,7 @@ func (b *TelegramBot) processMessage(TgMessage IncomingMessage) {
 		},
 	}
 
-	response, err := b.agentRPC.Chat(messages)
+	response, err := b.agentRPC.Chat(SessionKey(ChannelTelegram, chatID), messages)
 	if err != nil {
 		log.Printf("Agent error: %v", err)
 		b.sendSimpleMessage(chatID, "Sorry, I encountered an error.")
@@ -308,6 +326,17 @@ func (b *TelegramBot) processMessage(TgMessage IncomingMessage) {
 	b.sendSimpleMessage(chatID, response)
 }
 
+// markGreeted records userID as greeted; false when it already was
+func (b *TelegramBot) markGreeted(userID int64) bool {
+	b.greetedMu.Lock()
+	defer b.greetedMu.Unlock()
+	if b.greetedUsers[userID] {
+		return false
+	}
+	b.greetedUsers[userID] = true
+	return true
+}
+
 // sendSimpleMessage sends a text message to a chat
 func (b *TelegramBot) sendSimpleMessage(chatID int64, text string) {
 	if len(text) > 4096 {
@@ -403,7 +432,7 @@ func NewDefaultRPCClient(client *rpc.Client) *DefaultRPCClient {
 }
 
 // Chat sends a chat request to the agent
-func (c *DefaultRPCClient) Chat(messages []Message) (string, error) {
+func (c *DefaultRPCClient) Chat(sessionKey string, messages []Message) (string, error) {
 	// Convert to rpcproto format
 	rpcMessages := make([]rpcproto.Message, 0, len(messages))
 	for _, m := range messages {
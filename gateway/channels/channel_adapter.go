@@ -3,6 +3,7 @@
 package channels
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
@@ -139,14 +140,22 @@ type ChannelAdapter struct {
 	registry  *ChannelRegistry
 	config    ChannelAdapterConfig
 	agentRPC  AgentRPCInterface
+	pool      *workerPool // inbound message processing
 }
 
 // AgentRPCInterface defines the interface for agent communication
 type AgentRPCInterface interface {
-	Chat(messages []Message) (string, error)
+	// Chat runs a turn in the agent session sessionKey (empty = the default session)
+	Chat(sessionKey string, messages []Message) (string, error)
 	GetStats() (map[string]int, error)
 }
 
+// SessionKey is the agent session of one chat on a channel, e.g. "telegram:12345",
+// so every chat keeps its own history
+func SessionKey(channel ChannelType, chatID int64) string {
+	return fmt.Sprintf("%s:%d", channel, chatID)
+}
+
 // Message represents a chat message
 type Message struct {
 	Role    string `json:"role"`
@@ -164,6 +173,10 @@ type ChannelAdapterConfig struct {
 	PollingLimit int               `json:"pollingLimit"`
 	MaxRetries   int               `json:"maxRetries"`
 	Timeout      int               `json:"defaultTimeoutSeconds"`
+	// Workers bounds concurrent inbound message processing; QueueSize is how many
+	// messages may wait for a worker before channels see ErrQueueFull (0 = defaults)
+	Workers      int               `json:"workers"`
+	QueueSize    int               `json:"queueSize"`
 }
 
 // DefaultChannelAdapterConfig returns default configuration
@@ -178,6 +191,8 @@ func DefaultChannelAdapterConfig() ChannelAdapterConfig {
 		PollingLimit: 100,
 		MaxRetries:   3,
 		Timeout:      30,
+		Workers:      DefaultChannelWorkers,
+		QueueSize:    DefaultChannelQueueSize,
 	}
 }
 
@@ -188,6 +203,7 @@ func NewChannelAdapter(cfg ChannelAdapterConfig, agentRPC AgentRPCInterface) *Ch
 		registry:  NewChannelRegistry(),
 		config:   cfg,
 		agentRPC: agentRPC,
+		pool:     newWorkerPool(cfg.Workers, cfg.QueueSize),
 	}
 }
 
@@ -209,6 +225,11 @@ func (a *ChannelAdapter) RegisterChannel(channel ChannelLoader) error {
 		return fmt.Errorf("failed to initialize channel %s: %w", channelType, err)
 	}
 
+	// Inbound messages of pool-aware channels share the adapter's workers
+	if d, ok := channel.(dispatchAware); ok {
+		d.SetDispatcher(a.Submit)
+	}
+
 	a.channels[channelType] = channel
 	a.registry.Add(info)
 
@@ -281,6 +302,32 @@ func (a *ChannelAdapter) StopAllChannels() error {
 	return nil
 }
 
+// Submit runs job on the inbound worker pool. It never blocks: when every worker
+// is busy and the queue is full it returns ErrQueueFull.
+func (a *ChannelAdapter) Submit(job func()) error {
+	return a.pool.submit(job)
+}
+
+// PoolStats reports the inbound worker pool's load
+func (a *ChannelAdapter) PoolStats() WorkerPoolStats {
+	return a.pool.stats()
+}
+
+// Close stops all channels, then waits for queued messages to be processed
+func (a *ChannelAdapter) Close() {
+	a.Shutdown(context.Background())
+}
+
+// Shutdown stops all channels and drains the inbound queue until ctx ends.
+// The error counts the messages that had not been answered by then.
+func (a *ChannelAdapter) Shutdown(ctx context.Context) error {
+	a.StopAllChannels()
+	if n := a.pool.stop(ctx); n > 0 {
+		return fmt.Errorf("%d channel messages not finished: %v", n, ctx.Err())
+	}
+	return nil
+}
+
 // SendMessage sends a message through a channel
 func (a *ChannelAdapter) SendMessage(channelType ChannelType, req *SendMessageRequest) (*SendMessageResponse, error) {
 	a.mu.RLock()
@@ -328,7 +375,7 @@ func (a *ChannelAdapter) ProcessMessage(msg *ChannelMessage) (*ChannelResult, er
 	}
 
 	// Call agent
-	response, err := a.agentRPC.Chat(messages)
+	response, err := a.agentRPC.Chat(SessionKey(msg.Channel, msg.ChatID), messages)
 	if err != nil {
 		return &ChannelResult{
 			Success:   false,
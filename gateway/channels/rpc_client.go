@@ -1,6 +1,7 @@
 package channels
 
 import (
+	"fmt"
 	"net/rpc"
 
 	"github.com/gliderlab/cogate/rpcproto"
@@ -27,6 +28,9 @@ func (r *RPCClient) Chat(messages []rpcproto.Message) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if reply.Error != "" {
+		return "", fmt.Errorf("agent: %s", reply.Error)
+	}
 	
 	return reply.Content, nil
 }
@@ -0,0 +1,141 @@
+// Inbound worker pool - bounds how many channel messages reach the agent at once
+package channels
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+)
+
+// Defaults for ChannelAdapterConfig.Workers and QueueSize
+const (
+	DefaultChannelWorkers   = 4
+	DefaultChannelQueueSize = 100
+)
+
+// ErrQueueFull is returned by Submit when every worker is busy and the queue is
+// full; channels should ask the platform to redeliver later (HTTP 429)
+var ErrQueueFull = errors.New("channel message queue full")
+
+// ErrPoolStopped is returned by Submit after the adapter was stopped
+var ErrPoolStopped = errors.New("channel worker pool stopped")
+
+// Dispatcher hands an inbound message job to the adapter's worker pool
+type Dispatcher func(job func()) error
+
+// dispatchAware is implemented by channels that process inbound messages through
+// the adapter's pool; RegisterChannel wires them up
+type dispatchAware interface {
+	SetDispatcher(d Dispatcher)
+}
+
+// WorkerPoolStats is a snapshot of the inbound pool
+type WorkerPoolStats struct {
+	Workers   int   `json:"workers"`
+	QueueSize int   `json:"queueSize"`
+	Queued    int   `json:"queued"`
+	Active    int64 `json:"active"`
+	Processed int64 `json:"processed"`
+	Rejected  int64 `json:"rejected"`
+}
+
+// workerPool runs submitted jobs on a fixed number of goroutines
+type workerPool struct {
+	jobs    chan func()
+	workers int
+	mu      sync.RWMutex // guards closed against a send on a closed channel
+	closed  bool
+	wg      sync.WaitGroup
+
+	active    atomic.Int64
+	processed atomic.Int64
+	rejected  atomic.Int64
+}
+
+func newWorkerPool(workers, queueSize int) *workerPool {
+	if workers <= 0 {
+		workers = DefaultChannelWorkers
+	}
+	if queueSize <= 0 {
+		queueSize = DefaultChannelQueueSize
+	}
+	p := &workerPool{jobs: make(chan func(), queueSize), workers: workers}
+	p.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go p.run()
+	}
+	return p
+}
+
+func (p *workerPool) run() {
+	defer p.wg.Done()
+	for job := range p.jobs {
+		p.active.Add(1)
+		p.exec(job)
+		p.active.Add(-1)
+		p.processed.Add(1)
+	}
+}
+
+// exec keeps a panicking handler from taking a worker down with it
+func (p *workerPool) exec(job func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("⚠️ channel message handler panic: %v", r)
+		}
+	}()
+	job()
+}
+
+// submit queues job without blocking the caller
+func (p *workerPool) submit(job func()) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.closed {
+		return ErrPoolStopped
+	}
+	select {
+	case p.jobs <- job:
+		return nil
+	default:
+		p.rejected.Add(1)
+		return ErrQueueFull
+	}
+}
+
+// stop refuses new jobs and waits for the queued ones to finish, or for ctx to
+// end. It returns how many jobs were still queued or running at that point;
+// they keep going until the process exits.
+func (p *workerPool) stop(ctx context.Context) int {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return 0
+	case <-ctx.Done():
+		return len(p.jobs) + int(p.active.Load())
+	}
+}
+
+func (p *workerPool) stats() WorkerPoolStats {
+	return WorkerPoolStats{
+		Workers:   p.workers,
+		QueueSize: cap(p.jobs),
+		Queued:    len(p.jobs),
+		Active:    p.active.Load(),
+		Processed: p.processed.Load(),
+		Rejected:  p.rejected.Load(),
+	}
+}
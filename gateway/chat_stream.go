@@ -0,0 +1,241 @@
+// Chat streaming - Server-Sent Events for clients that send "stream": true
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/rpc"
+	"strings"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+// streamChunkRunes is the approximate content size of one delta frame when a
+// finished reply is replayed as a stream
+const streamChunkRunes = 48
+
+// chatChunk is an OpenAI "chat.completion.chunk" frame
+type chatChunk struct {
+	ID      string        `json:"id"`
+	Object  string        `json:"object"`
+	Created int64         `json:"created"`
+	Model   string        `json:"model"`
+	Choices []chunkChoice `json:"choices"`
+	// Set on the final frame only, as in the non-streamed response
+	Clarification *rpcproto.Clarification `json:"clarification,omitempty"`
+	ToolTrace     []rpcproto.ToolTrace    `json:"tool_trace,omitempty"`
+}
+
+type chunkChoice struct {
+	Index        int        `json:"index"`
+	Delta        chunkDelta `json:"delta"`
+	FinishReason *string    `json:"finish_reason"` // null until the last frame
+}
+
+type chunkDelta struct {
+	Role    string `json:"role,omitempty"`
+	Content string `json:"content,omitempty"`
+}
+
+// sseWriter sends JSON frames as Server-Sent Events. The headers go out with
+// the first frame, so that errors before it can still be plain HTTP errors.
+type sseWriter struct {
+	w       http.ResponseWriter
+	rc      *http.ResponseController
+	started bool
+	failed  bool
+}
+
+func newSSEWriter(w http.ResponseWriter) *sseWriter {
+	return &sseWriter{w: w, rc: http.NewResponseController(w)}
+}
+
+// send writes one frame; false once the client is gone
+func (s *sseWriter) send(v interface{}) bool {
+	if s.failed {
+		return false
+	}
+	if !s.started {
+		s.started = true
+		s.w.Header().Set("Content-Type", "text/event-stream")
+		s.w.Header().Set("Cache-Control", "no-cache")
+		s.w.Header().Set("Connection", "keep-alive")
+		s.w.Header().Set("X-Accel-Buffering", "no") // nginx would otherwise buffer the stream
+	}
+	payload, _ := json.Marshal(v)
+	if _, err := fmt.Fprintf(s.w, "data: %s\n\n", payload); err != nil {
+		log.Printf("⚠️ chat stream aborted: %v", err)
+		s.failed = true
+		return false
+	}
+	s.rc.Flush()
+	return true
+}
+
+// done ends the stream with "data: [DONE]"
+func (s *sseWriter) done() {
+	if s.failed {
+		return
+	}
+	fmt.Fprint(s.w, "data: [DONE]\n\n")
+	s.rc.Flush()
+}
+
+// chunkFrame is a frame of the response with id and created of resp
+func chunkFrame(resp *ChatResponse, choice chunkChoice) chatChunk {
+	return chatChunk{ID: resp.ID, Object: "chat.completion.chunk", Created: resp.Created, Model: resp.Model, Choices: []chunkChoice{choice}}
+}
+
+// writeChatStream sends an encoded ChatResponse as SSE: a role frame, content
+// deltas and a finish frame per choice, then "data: [DONE]". It serves replies
+// that are already complete - idempotent replays and n > 1 or logprobs turns,
+// which the agent does not stream.
+func writeChatStream(w http.ResponseWriter, data []byte) {
+	var resp ChatResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		http.Error(w, "Encode error", http.StatusInternalServerError)
+		return
+	}
+	sse := newSSEWriter(w)
+	for i, c := range resp.Choices {
+		if !sse.send(chunkFrame(&resp, chunkChoice{Index: c.Index, Delta: chunkDelta{Role: "assistant"}})) {
+			return
+		}
+		for _, piece := range splitStreamContent(c.Message.Content, streamChunkRunes) {
+			if !sse.send(chunkFrame(&resp, chunkChoice{Index: c.Index, Delta: chunkDelta{Content: piece}})) {
+				return
+			}
+		}
+		if !sse.send(finishFrame(&resp, c, i == len(resp.Choices)-1)) {
+			return
+		}
+	}
+	sse.done()
+}
+
+// finishFrame is the closing frame of choice c; the last one carries the
+// clarification and tool trace
+func finishFrame(resp *ChatResponse, c Choice, last bool) chatChunk {
+	finish := c.FinishReason
+	frame := chunkFrame(resp, chunkChoice{Index: c.Index, FinishReason: &finish})
+	if last {
+		frame.Clarification = resp.Clarification
+		frame.ToolTrace = resp.ToolTrace
+	}
+	return frame
+}
+
+// streamChat runs a single-choice "stream": true request through
+// Agent.ChatStream, sending each piece of text as the model writes it. It
+// returns the encoded ChatResponse for idempotent replay, or nil when the turn
+// failed. Until the first frame a failure is a plain HTTP error; after it the
+// stream just ends without [DONE].
+func (g *Gateway) streamChat(ctx context.Context, w http.ResponseWriter, client *rpc.Client, req ChatRequest) []byte {
+	if req.N > 1 || req.Logprobs {
+		// Several choices or logprobs come from one finished upstream answer
+		data, status, errMsg := g.runChat(ctx, client, req)
+		if errMsg != "" {
+			http.Error(w, errMsg, status)
+			return nil
+		}
+		writeChatStream(w, data)
+		return data
+	}
+
+	var start rpcproto.ChatStreamReply
+	if err := callAgent(ctx, client, "Agent.ChatStream", chatArgs(req), &start); err != nil {
+		http.Error(w, err.Error(), agentErrorStatus(err))
+		return nil
+	}
+
+	// The frames carry the response's id, known before the reply is
+	resp := ChatResponse{ID: "chatcmpl-" + randomID(), Created: nowUnix(), Model: req.Model}
+	sse := newSSEWriter(w)
+	var streamed strings.Builder
+	var reply rpcproto.ChatReply
+	for {
+		var next rpcproto.ChatStreamNextReply
+		if err := callAgent(ctx, client, "Agent.ChatStreamNext", rpcproto.ChatStreamNextArgs{StreamID: start.StreamID}, &next); err != nil {
+			if !sse.started {
+				http.Error(w, err.Error(), agentErrorStatus(err))
+			} else {
+				log.Printf("⚠️ chat stream %s lost: %v", start.StreamID, err)
+			}
+			return nil
+		}
+		if next.Delta != "" {
+			if !sse.started {
+				sse.send(chunkFrame(&resp, chunkChoice{Delta: chunkDelta{Role: "assistant"}}))
+			}
+			// A client that went away stops getting frames, but the turn is
+			// still read to the end so that its reply can be saved for a retry
+			sse.send(chunkFrame(&resp, chunkChoice{Delta: chunkDelta{Content: next.Delta}}))
+			streamed.WriteString(next.Delta)
+		}
+		if next.Done {
+			reply = next.Reply
+			break
+		}
+	}
+	if reply.Error != "" {
+		if !sse.started {
+			// The agent ran, but the upstream model gave nothing usable
+			http.Error(w, reply.Error, http.StatusBadGateway)
+		} else {
+			log.Printf("⚠️ chat stream %s failed: %s", start.StreamID, reply.Error)
+		}
+		return nil
+	}
+
+	full := chatResponse(req, reply)
+	full.ID, full.Created = resp.ID, resp.Created
+	choice := full.Choices[0]
+	// The agent's reply starts with the streamed text; anything after it, or
+	// all of it when the upstream did not stream, goes out now
+	rest := choice.Message.Content
+	if sent := streamed.String(); strings.HasPrefix(rest, sent) {
+		rest = rest[len(sent):]
+	} else if sent != "" {
+		log.Printf("⚠️ chat stream %s: reply does not continue the streamed text", start.StreamID)
+		rest = ""
+	}
+	if !sse.started {
+		sse.send(chunkFrame(&full, chunkChoice{Delta: chunkDelta{Role: "assistant"}}))
+	}
+	for _, piece := range splitStreamContent(rest, streamChunkRunes) {
+		sse.send(chunkFrame(&full, chunkChoice{Delta: chunkDelta{Content: piece}}))
+	}
+	sse.send(finishFrame(&full, choice, true))
+	sse.done()
+
+	data, err := json.Marshal(full)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// splitStreamContent cuts content into pieces of about n runes, ending each at a
+// space when there is one, so that joining the pieces gives content back unchanged
+func splitStreamContent(content string, n int) []string {
+	var pieces []string
+	runes := []rune(content)
+	for len(runes) > n {
+		cut := n
+		for i := n - 1; i > 0; i-- {
+			if runes[i] == ' ' {
+				cut = i + 1
+				break
+			}
+		}
+		pieces = append(pieces, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	if len(runes) > 0 {
+		pieces = append(pieces, string(runes))
+	}
+	return pieces
+}
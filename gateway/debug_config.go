@@ -0,0 +1,64 @@
+package gateway
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+// debugGatewayConfig is the gateway half of /debug/config; secrets only say whether they are set
+type debugGatewayConfig struct {
+	Host           string            `json:"host"`
+	Port           int               `json:"port"`
+	BasePath       string            `json:"basePath"`
+	AgentAddr      string            `json:"agentAddr"`
+	AgentPoolSize  int               `json:"agentPoolSize"`
+	UIAuthTokenSet bool              `json:"uiAuthTokenSet"`
+	EventsSecret   bool              `json:"eventsSecretSet"`
+	DataDir        string            `json:"dataDir"`
+	CronStorePath  string            `json:"cronStorePath,omitempty"`
+	Timeouts       map[string]string `json:"timeouts"`
+	Maintenance    maintenanceStatus `json:"maintenance"`
+}
+
+// handleDebugConfig returns the configuration gateway and agent are running with,
+// after env.config, env vars and the agent's DB config were merged. The agent
+// half is replaced by an error message when the agent cannot be reached.
+func (g *Gateway) handleDebugConfig(w http.ResponseWriter, r *http.Request) {
+	timeouts := g.cfg.Timeouts.withDefaults()
+	out := map[string]interface{}{
+		"gateway": debugGatewayConfig{
+			Host:           g.cfg.Host,
+			Port:           g.cfg.Port,
+			BasePath:       g.cfg.BasePath,
+			AgentAddr:      g.cfg.AgentAddr,
+			AgentPoolSize:  g.agentPool().Size(),
+			UIAuthTokenSet: g.cfg.UIAuthToken != "",
+			EventsSecret:   g.cfg.EventsSecret != "",
+			DataDir:        g.dataDir(),
+			CronStorePath:  g.cfg.CronStorePath,
+			Timeouts: map[string]string{
+				"chat":    timeouts.Chat.String(),
+				"memory":  timeouts.Memory.String(),
+				"process": timeouts.Process.String(),
+				"default": timeouts.Default.String(),
+			},
+			Maintenance: g.maintenance.status(),
+		},
+	}
+
+	if client, err := g.clientOrError(); err != nil {
+		out["agent"] = map[string]string{"error": err.Error()}
+	} else {
+		var reply rpcproto.ToolResultReply
+		if err := client.Call("Agent.EffectiveConfig", struct{}{}, &reply); err != nil {
+			out["agent"] = map[string]string{"error": err.Error()}
+		} else {
+			out["agent"] = json.RawMessage(reply.Result)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
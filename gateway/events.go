@@ -0,0 +1,120 @@
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+// Inbound event limits
+const (
+	maxEventBody    = 64 << 10
+	maxEventTitle   = 200
+	defaultPriority = 2 // normal: processed when the agent is idle
+)
+
+// Signature headers checked when Config.EventsSecret is set (GitHub-style "sha256=<hex>")
+const (
+	eventSignatureHeader = "X-OCG-Signature"
+	hubSignatureHeader   = "X-Hub-Signature-256"
+)
+
+type eventIngestRequest struct {
+	Title    string `json:"title"`
+	Content  string `json:"content"`
+	Priority *int   `json:"priority"`
+	Channel  string `json:"channel"`
+}
+
+// handleEventsIngest turns an external event (CI, monitoring) into a pulse event
+// ({"title", "content", "priority": 0-3, "channel"}).
+func (g *Gateway) handleEventsIngest(w http.ResponseWriter, r *http.Request) {
+	client, err := g.clientOrError()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxEventBody))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("body too large (max %d bytes)", maxEventBody), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if secret := strings.TrimSpace(g.cfg.EventsSecret); secret != "" {
+		sig := r.Header.Get(eventSignatureHeader)
+		if sig == "" {
+			sig = r.Header.Get(hubSignatureHeader)
+		}
+		if !validEventSignature(secret, body, sig) {
+			log.Printf("⚠️ Event rejected: bad or missing signature from %s", r.RemoteAddr)
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	var req eventIngestRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "Parse error", http.StatusBadRequest)
+		return
+	}
+	req.Title = strings.TrimSpace(req.Title)
+	if req.Title == "" {
+		http.Error(w, "title is required", http.StatusBadRequest)
+		return
+	}
+	if len(req.Title) > maxEventTitle {
+		http.Error(w, fmt.Sprintf("title too long (max %d chars)", maxEventTitle), http.StatusBadRequest)
+		return
+	}
+	priority := defaultPriority
+	if req.Priority != nil {
+		priority = *req.Priority
+	}
+	if priority < 0 || priority > 3 {
+		http.Error(w, "priority must be between 0 and 3", http.StatusBadRequest)
+		return
+	}
+
+	var reply rpcproto.PulseReply
+	args := rpcproto.PulseArgs{
+		Action:   "add",
+		Title:    req.Title,
+		Content:  req.Content,
+		Priority: priority,
+		Channel:  strings.TrimSpace(req.Channel),
+	}
+	if err := client.Call("Agent.PulseAdd", args, &reply); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("📥 Event ingested: %q (priority=%d, id=%d)", req.Title, priority, reply.EventID)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"eventId":  reply.EventID,
+		"status":   reply.Status,
+		"priority": priority,
+	})
+}
+
+// validEventSignature checks sig ("sha256=<hex>" or bare hex) against HMAC-SHA256(secret, body)
+func validEventSignature(secret string, body []byte, sig string) bool {
+	sig = strings.TrimSpace(sig)
+	sig = strings.TrimPrefix(sig, "sha256=")
+	got, err := hex.DecodeString(sig)
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
@@ -3,11 +3,14 @@
 package gateway
 
 import (
+	"context"
 	"encoding/gob"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"net/rpc"
 	"os"
@@ -22,6 +25,7 @@ import (
 	"github.com/gliderlab/cogate/gateway/channels"
 	"github.com/gliderlab/cogate/processtool"
 	"github.com/gliderlab/cogate/rpcproto"
+	"github.com/gliderlab/cogate/tokens"
 )
 
 func init() {
@@ -36,22 +40,87 @@ type Config struct {
 	Port        int    `json:"port"`
 	AgentAddr   string `json:"agentAddr"`
 	UIAuthToken string `json:"uiAuthToken"`
+	// EventsSecret, when set, requires an HMAC-SHA256 body signature on /events/ingest
+	EventsSecret string `json:"eventsSecret,omitempty"`
+	// DataDir holds on-disk gateway state (cron jobs, ...); see resolveDataDir for the default
+	DataDir string `json:"dataDir,omitempty"`
+	// CronStorePath overrides <DataDir>/cron/jobs.json
+	CronStorePath string `json:"cronStorePath,omitempty"`
+	// Timeouts are per-route budgets; zero fields use the Default*Timeout values
+	Timeouts RouteTimeouts `json:"timeouts,omitempty"`
+	// BasePath mounts the gateway under a path prefix (e.g. "/ai" behind a shared domain)
+	BasePath string `json:"basePath,omitempty"`
+	// EmbeddingURL, when set, must be reachable for Start to succeed (see checkDependencies)
+	EmbeddingURL string `json:"embeddingUrl,omitempty"`
+	// ChannelWorkers and ChannelQueueSize size the inbound channel worker pool
+	// (0 = channels.DefaultChannelWorkers / DefaultChannelQueueSize)
+	ChannelWorkers   int `json:"channelWorkers,omitempty"`
+	ChannelQueueSize int `json:"channelQueueSize,omitempty"`
+	// HeartbeatInterval is how often Agent.Ping is called (0 = DefaultHeartbeatInterval,
+	// negative = off); HeartbeatFailures misses in a row mark the agent unhealthy
+	// (0 = DefaultHeartbeatFailures)
+	HeartbeatInterval time.Duration `json:"heartbeatInterval,omitempty"`
+	HeartbeatFailures int           `json:"heartbeatFailures,omitempty"`
+	// ShutdownTimeout bounds how long Stop drains requests, channel messages and
+	// cron jobs (0 = DefaultShutdownTimeout)
+	ShutdownTimeout time.Duration `json:"shutdownTimeout,omitempty"`
+	// CronMaxConcurrent bounds the cron jobs running at once (0 = cron.DefaultMaxConcurrentJobs);
+	// CronOverlap is cron.OverlapSkip (default) or cron.OverlapQueue
+	CronMaxConcurrent int    `json:"cronMaxConcurrent,omitempty"`
+	CronOverlap       string `json:"cronOverlap,omitempty"`
 }
 
+// DefaultShutdownTimeout stays inside the 10s grace period of docker stop
+const DefaultShutdownTimeout = 8 * time.Second
+
 type Gateway struct {
 	cfg            Config
-	client         *rpc.Client
+	pool           *ClientPool
 	server         *http.Server
 	channelAdapter *channels.ChannelAdapter
 	cronHandler    *cron.CronHandler
 	mu             sync.RWMutex
+	// Chat requests currently running per Idempotency-Key
+	idemMu       sync.Mutex
+	idemInflight map[string]bool
+	// Replies buffered for WebSocket clients that dropped mid-chat
+	wsSessions wsSessionStore
+	// Identical chat requests currently running
+	chatFlights chatFlightGroup
+	// Maintenance mode: chat/API routes answer 503 + Retry-After
+	maintenance maintenanceState
+	// Agent liveness from the periodic Agent.Ping
+	heartbeat heartbeatState
+	// Resolved once from cfg.DataDir
+	dataDirOnce sync.Once
 }
 
 type ChatRequest struct {
-	Model    string             `json:"model"`
-	Messages []rpcproto.Message `json:"messages"`
+	Model       string             `json:"model"`
+	Messages    []rpcproto.Message `json:"messages"`
+	Seed        *int64             `json:"seed,omitempty"`
+	Temperature *float64           `json:"temperature,omitempty"`
+	N           int                `json:"n,omitempty"`
+	Logprobs    bool               `json:"logprobs,omitempty"`
+	TopLogprobs int                `json:"top_logprobs,omitempty"`
+	// Trace returns tool_trace in the response (also set by the X-OCG-Debug: trace header)
+	Trace bool `json:"trace,omitempty"`
+	// Stream sends the reply as Server-Sent Events (see writeChatStream)
+	Stream bool `json:"stream,omitempty"`
+	// Session is the web UI's session key (the one its WebSocket was issued); the turn is
+	// stored under "webchat:<session>" instead of the default session
+	Session string `json:"session,omitempty"`
 }
 
+// debugHeader turns on debugging output for a chat request ("trace")
+const debugHeader = "X-OCG-Debug"
+
+// Sampling limits for eval-style requests
+const (
+	maxChoices     = 16
+	maxTopLogprobs = 20
+)
+
 type ChatResponse struct {
 	ID      string   `json:"id"`
 	Object  string   `json:"object"`
@@ -59,12 +128,17 @@ type ChatResponse struct {
 	Model   string   `json:"model"`
 	Choices []Choice `json:"choices"`
 	Usage   Usage    `json:"usage"`
+	// Set when the agent asks a clarifying question (finish_reason "ask_user")
+	Clarification *rpcproto.Clarification `json:"clarification,omitempty"`
+	// ToolTrace lists the tool calls behind the answer when tracing was requested
+	ToolTrace []rpcproto.ToolTrace `json:"tool_trace,omitempty"`
 }
 
 type Choice struct {
 	Index        int              `json:"index"`
 	Message      rpcproto.Message `json:"message"`
 	FinishReason string           `json:"finish_reason"`
+	Logprobs     json.RawMessage  `json:"logprobs,omitempty"`
 }
 
 type Usage struct {
@@ -83,6 +157,7 @@ func New(cfg Config) *Gateway {
 	if cfg.UIAuthToken == "" {
 		log.Printf("[WARN] UIAuthToken is empty; API will reject all requests")
 	}
+	cfg.BasePath = normalizeBasePath(cfg.BasePath)
 	return &Gateway{cfg: cfg}
 }
 
@@ -90,20 +165,32 @@ func (g *Gateway) Config() Config {
 	return g.cfg
 }
 
-func (g *Gateway) SetClient(c *rpc.Client) {
+// SetClientPool sets the agent RPC connections calls are spread over
+func (g *Gateway) SetClientPool(p *ClientPool) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
-	g.client = c
+	g.pool = p
+}
+
+// agentPool returns the current agent connection pool (nil before SetClientPool)
+func (g *Gateway) agentPool() *ClientPool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.pool
 }
 
 func (g *Gateway) Start() error {
+	if err := g.checkDependencies(); err != nil {
+		return fmt.Errorf("startup check failed: %v", err)
+	}
+	g.startHeartbeat()
 	mux := http.NewServeMux()
 
 	// Static files (web chat UI) embedded in binary
 	log.Printf("Static assets: embedded")
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/" {
-			http.ServeFileFS(w, r, embeddedStaticFS, "static/index.html")
+			g.serveIndex(w, r)
 			return
 		}
 		if strings.HasSuffix(r.URL.Path, "/") {
@@ -114,7 +201,7 @@ func (g *Gateway) Start() error {
 	})
 
 	// WebSocket endpoint for real-time chat
-	mux.HandleFunc("/ws/chat", g.HandleWebSocket)
+	mux.HandleFunc("/ws/chat", g.serving(g.HandleWebSocket))
 
 	// Auth middleware for API routes (header Authorization: Bearer <token> or X-OCG-UI-Token)
 	requireAuth := func(next http.HandlerFunc) http.HandlerFunc {
@@ -130,77 +217,120 @@ func (g *Gateway) Start() error {
 			}
 			alt := r.Header.Get("X-OCG-UI-Token")
 			if header == token || alt == token {
-				next(w, r)
+				next(w, withActor(r, resolveActor(r, token)))
 				return
 			}
 			http.Error(w, "unauthorized", http.StatusUnauthorized)
 		}
 	}
 
-	// API routes (protected)
-	mux.HandleFunc("/v1/chat/completions", requireAuth(g.handleChat))
-	mux.HandleFunc("/health", requireAuth(g.handleHealth))
-	mux.HandleFunc("/storage/stats", requireAuth(g.handleStorageStats))
+	// API routes (protected); method checks run before auth so OPTIONS probes work
+	rt := &router{mux: mux}
+	timeouts := g.cfg.Timeouts.withDefaults()
+	chat := func(h http.HandlerFunc) http.HandlerFunc { return withTimeout(timeouts.Chat, h) }
+	memory := func(h http.HandlerFunc) http.HandlerFunc { return withTimeout(timeouts.Memory, h) }
+	process := func(h http.HandlerFunc) http.HandlerFunc { return withTimeout(timeouts.Process, h) }
+	rt.post("/v1/chat/completions", chat(requireAuth(g.serving(g.handleChat))))
+	rt.get("/health", requireAuth(g.handleHealth))
+	rt.get("/storage/stats", requireAuth(g.handleStorageStats))
+	// Onboarding (first-run LLM setup)
+	rt.get("/setup/status", requireAuth(g.handleSetupStatus))
+	rt.post("/setup/config", requireAuth(g.audited("setup.config", g.handleSetupConfig)))
 	// Process tool endpoints
-	mux.HandleFunc("/process/start", requireAuth(g.handleProcessStart))
-	mux.HandleFunc("/process/list", requireAuth(g.handleProcessList))
-	mux.HandleFunc("/process/log", requireAuth(g.handleProcessLog))
-	mux.HandleFunc("/process/write", requireAuth(g.handleProcessWrite))
-	mux.HandleFunc("/process/kill", requireAuth(g.handleProcessKill))
+	rt.post("/process/start", process(requireAuth(g.audited("process.start", g.handleProcessStart))))
+	rt.get("/process/list", process(requireAuth(g.handleProcessList)))
+	rt.get("/process/log", process(requireAuth(g.handleProcessLog)))
+	rt.post("/process/write", process(requireAuth(g.audited("process.write", g.handleProcessWrite))))
+	rt.handle("/process/kill", process(requireAuth(g.audited("process.kill", g.handleProcessKill))), http.MethodGet, http.MethodPost)
 	// Memory tool endpoints
-	mux.HandleFunc("/memory/search", requireAuth(g.handleMemorySearch))
-	mux.HandleFunc("/memory/get", requireAuth(g.handleMemoryGet))
-	mux.HandleFunc("/memory/store", requireAuth(g.handleMemoryStore))
+	rt.get("/memory/search", memory(requireAuth(g.handleMemorySearch)))
+	rt.get("/memory/get", memory(requireAuth(g.handleMemoryGet)))
+	rt.post("/memory/store", memory(requireAuth(g.audited("memory.store", g.handleMemoryStore))))
+	rt.post("/memory/delete", memory(requireAuth(g.audited("memory.delete", g.handleMemoryDelete))))
+	// Admin: HNSW parameters (GET shows, POST {"efSearch": N} tunes)
+	rt.handle("/admin/hnsw", requireAuth(g.auditedWrites("admin.hnsw", g.handleAdminHNSW)), http.MethodGet, http.MethodPost)
+	// Admin: embedding provider performance (calls, latency, errors)
+	rt.get("/admin/embedding-stats", requireAuth(g.handleAdminEmbeddingStats))
+	// Admin: audit log of mutating operations (?actor=&action=&before=&limit=)
+	rt.get("/admin/audit", requireAuth(g.handleAdminAudit))
+	// Admin: maintenance mode (GET shows, POST {"enabled": bool, "reason", "retryAfter"})
+	rt.handle("/admin/maintenance-mode", requireAuth(g.auditedWrites("admin.maintenance", g.handleAdminMaintenance)), http.MethodGet, http.MethodPost)
+	// Debug: effective gateway + agent configuration, secrets masked
+	rt.get("/debug/config", requireAuth(g.handleDebugConfig))
 
 	// Cron endpoints
-	mux.HandleFunc("/cron/status", requireAuth(g.handleCronStatus))
-	mux.HandleFunc("/cron/list", requireAuth(g.handleCronList))
-	mux.HandleFunc("/cron/add", requireAuth(g.handleCronAdd))
-	mux.HandleFunc("/cron/update", requireAuth(g.handleCronUpdate))
-	mux.HandleFunc("/cron/remove", requireAuth(g.handleCronRemove))
-	mux.HandleFunc("/cron/run", requireAuth(g.handleCronRun))
+	rt.get("/cron/status", requireAuth(g.handleCronStatus))
+	rt.get("/cron/list", requireAuth(g.handleCronList))
+	rt.post("/cron/add", requireAuth(g.audited("cron.add", g.handleCronAdd)))
+	rt.post("/cron/update", requireAuth(g.audited("cron.update", g.handleCronUpdate)))
+	rt.post("/cron/remove", requireAuth(g.audited("cron.remove", g.handleCronRemove)))
+	rt.post("/cron/run", requireAuth(g.audited("cron.run", g.handleCronRun)))
+	rt.get("/cron/history", requireAuth(g.handleCronHistory))
+	rt.get("/cron/export", requireAuth(g.handleCronExport))
+	rt.post("/cron/import", requireAuth(g.audited("cron.import", g.handleCronImport)))
+
+	// Inbound events (CI, monitoring) become pulse events
+	rt.post("/events/ingest", requireAuth(g.audited("events.ingest", g.handleEventsIngest)))
 
 	// Telegram Bot webhook endpoint (public, no auth)
-	mux.HandleFunc("/telegram/webhook", g.handleTelegramWebhook)
+	rt.post("/telegram/webhook", g.serving(g.handleTelegramWebhook))
 
 	// Telegram Bot configuration endpoints (protected)
-	mux.HandleFunc("/telegram/setWebhook", requireAuth(g.handleTelegramSetWebhook))
-	mux.HandleFunc("/telegram/status", requireAuth(g.handleTelegramStatus))
+	rt.post("/telegram/setWebhook", requireAuth(g.audited("telegram.set_webhook", g.handleTelegramSetWebhook)))
+	rt.get("/telegram/status", requireAuth(g.handleTelegramStatus))
 
 	addr := fmt.Sprintf("%s:%d", g.cfg.Host, g.cfg.Port)
 	g.server = &http.Server{
 		Addr:         addr,
-		Handler:      mux,
+		Handler:      withBasePath(g.cfg.BasePath, mux),
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 60 * time.Second,
+		WriteTimeout: timeouts.Default, // chat, memory and process routes set their own
 		IdleTimeout:  90 * time.Second,
 	}
-	log.Printf("Gateway listening on %s", addr)
+	// Bind before announcing, so a taken port fails here rather than after setup
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("listen %s: %v", addr, err)
+	}
+	log.Printf("Gateway listening on %s%s", addr, g.cfg.BasePath)
 
 	// Initialize Channel Adapter with Telegram support
-	g.channelAdapter = channels.NewChannelAdapter(
-		channels.DefaultChannelAdapterConfig(),
-		&GatewayAgentRPC{client: g.client},
-	)
+	channelCfg := channels.DefaultChannelAdapterConfig()
+	if g.cfg.ChannelWorkers > 0 {
+		channelCfg.Workers = g.cfg.ChannelWorkers
+	}
+	if g.cfg.ChannelQueueSize > 0 {
+		channelCfg.QueueSize = g.cfg.ChannelQueueSize
+	}
+	g.channelAdapter = channels.NewChannelAdapter(channelCfg, &GatewayAgentRPC{pool: g.agentPool()})
+	log.Printf("Channel workers: %d (queue=%d)", channelCfg.Workers, channelCfg.QueueSize)
 
 	// Initialize Cron handler
-	cronStore := filepath.Join(getGatewayDir(), "data", "cron", "jobs.json")
+	cronStore := g.cfg.CronStorePath
+	if cronStore == "" {
+		cronStore = filepath.Join(g.dataDir(), "cron", "jobs.json")
+	}
+	log.Printf("Cron store: %s", cronStore)
 	g.cronHandler = cron.NewCronHandler(cronStore)
+	g.cronHandler.SetMaxConcurrent(g.cfg.CronMaxConcurrent)
+	if err := g.cronHandler.SetOverlapPolicy(g.cfg.CronOverlap); err != nil {
+		log.Printf("⚠️ Cron: %v; skipping overlapping runs", err)
+	}
 	g.cronHandler.SetSystemEventCallback(func(text string) {
-		if g.client == nil {
+		if g.agentPool().Size() == 0 {
 			log.Printf("[Cron] agent not connected")
 			return
 		}
-		_, err := (&GatewayAgentRPC{client: g.client}).Chat([]channels.Message{{Role: "system", Content: text}})
+		_, err := (&GatewayAgentRPC{pool: g.agentPool()}).Chat("", []channels.Message{{Role: "system", Content: text}})
 		if err != nil {
 			log.Printf("[Cron] system event error: %v", err)
 		}
 	})
-	g.cronHandler.SetAgentTurnCallback(func(message, model, thinking string) (string, error) {
-		if g.client == nil {
+	g.cronHandler.SetAgentTurnCallback(func(sessionKey, message, model, thinking string) (string, error) {
+		if g.agentPool().Size() == 0 {
 			return "", fmt.Errorf("agent not connected")
 		}
-		return (&GatewayAgentRPC{client: g.client}).Chat([]channels.Message{{Role: "user", Content: message}})
+		return (&GatewayAgentRPC{pool: g.agentPool()}).Chat(sessionKey, []channels.Message{{Role: "user", Content: message}})
 	})
 	g.cronHandler.SetBroadcastCallback(func(message, channel, target string) error {
 		if g.channelAdapter == nil {
@@ -221,9 +351,9 @@ func (g *Gateway) Start() error {
 
 	// Register Telegram channel if token is provided
 	if telegramToken := os.Getenv("TELEGRAM_BOT_TOKEN"); telegramToken != "" {
-		if g.client != nil {
+		if g.agentPool().Size() > 0 {
 			// Create Telegram bot as a channel plugin
-			bot := channels.NewTelegramBot(telegramToken, &GatewayAgentRPC{client: g.client})
+			bot := channels.NewTelegramBot(telegramToken, &GatewayAgentRPC{pool: g.agentPool()})
 			if err := g.channelAdapter.RegisterChannel(bot); err != nil {
 				log.Printf("⚠️ Failed to register Telegram channel: %v", err)
 			} else {
@@ -240,22 +370,54 @@ func (g *Gateway) Start() error {
 		log.Printf("ℹ️ No TELEGRAM_BOT_TOKEN environment variable found")
 	}
 
-	return g.server.ListenAndServe()
+	// Stop ends Serve with ErrServerClosed while it is still draining; that is not a failure
+	if err := g.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
 }
 
+// Stop shuts the gateway down within cfg.ShutdownTimeout: in-flight HTTP
+// requests and queued channel messages get answered, running cron jobs finish
+// and the cron store is written. The agent connections close last, since the
+// draining work still talks to the agent.
 func (g *Gateway) Stop() {
+	g.stopHeartbeat()
+	// No new cron runs from here; the running ones are awaited below
 	if g.cronHandler != nil {
 		g.cronHandler.Stop()
 	}
+	ctx, cancel := context.WithTimeout(context.Background(), g.shutdownTimeout())
+	defer cancel()
 	if g.server != nil {
-		g.server.Close()
+		if err := g.server.Shutdown(ctx); err != nil {
+			log.Printf("⚠️ Shutdown: HTTP requests still running, closing: %v", err)
+			g.server.Close()
+		}
+	}
+	if g.channelAdapter != nil {
+		if err := g.channelAdapter.Shutdown(ctx); err != nil {
+			log.Printf("⚠️ Shutdown: %v", err)
+		}
+	}
+	if g.cronHandler != nil {
+		if err := g.cronHandler.Shutdown(ctx); err != nil {
+			log.Printf("⚠️ Shutdown: %v", err)
+		}
+	}
+	g.agentPool().Close()
+}
+
+// shutdownTimeout resolves cfg.ShutdownTimeout
+func (g *Gateway) shutdownTimeout() time.Duration {
+	if g.cfg.ShutdownTimeout > 0 {
+		return g.cfg.ShutdownTimeout
 	}
+	return DefaultShutdownTimeout
 }
 
 func (g *Gateway) clientOrError() (*rpc.Client, error) {
-	g.mu.RLock()
-	client := g.client
-	g.mu.RUnlock()
+	client := g.agentPool().Get()
 	if client == nil {
 		return nil, fmt.Errorf("agent not connected")
 	}
@@ -263,11 +425,6 @@ func (g *Gateway) clientOrError() (*rpc.Client, error) {
 }
 
 func (g *Gateway) handleChat(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	client, err := g.clientOrError()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusServiceUnavailable)
@@ -286,20 +443,117 @@ func (g *Gateway) handleChat(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Parse error", http.StatusBadRequest)
 		return
 	}
+	if req.N < 0 || req.N > maxChoices {
+		http.Error(w, fmt.Sprintf("n must be between 1 and %d", maxChoices), http.StatusBadRequest)
+		return
+	}
+	if req.TopLogprobs < 0 || req.TopLogprobs > maxTopLogprobs {
+		http.Error(w, fmt.Sprintf("top_logprobs must be between 0 and %d", maxTopLogprobs), http.StatusBadRequest)
+		return
+	}
+	if req.Session != "" && !g.issuedWSSessionKey(req.Session) {
+		http.Error(w, "session must be a key issued by /ws/chat", http.StatusBadRequest)
+		return
+	}
+	if strings.EqualFold(strings.TrimSpace(r.Header.Get(debugHeader)), "trace") {
+		req.Trace = true
+	}
+
+	idemKey := idempotencyKey(r)
+	if len(idemKey) > maxIdempotencyKey {
+		http.Error(w, "Idempotency-Key too long", http.StatusBadRequest)
+		return
+	}
+	if idemKey != "" {
+		if g.replayIdempotent(w, client, idemKey, body, req.Stream) {
+			return
+		}
+		if !g.beginIdempotent(idemKey) {
+			http.Error(w, "A request with this Idempotency-Key is already in progress", http.StatusConflict)
+			return
+		}
+		defer g.endIdempotent(idemKey)
+	}
 
 	if len(req.Messages) > 0 {
 		last := req.Messages[len(req.Messages)-1]
 		log.Printf("Received message: role=%s len=%d", last.Role, len(last.Content))
 	}
 
-	var reply rpcproto.ChatReply
-	args := rpcproto.ChatArgs{Messages: req.Messages}
-	if err := client.Call("Agent.Chat", args, &reply); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	ctx, cancel := detachedDeadline(r.Context())
+	defer cancel()
+	if req.Stream {
+		// Frames follow the model as it writes; a live stream is not shared
+		if data := g.streamChat(ctx, w, client, req); data != nil && idemKey != "" {
+			g.saveIdempotent(client, idemKey, body, data)
+		}
 		return
 	}
 
-	// Return OpenAI-compatible response
+	// Identical concurrent requests from one caller share one agent call and one response
+	var (
+		data   []byte
+		status int
+		errMsg string
+		shared bool
+	)
+	if chatShareable(req) {
+		data, status, errMsg, shared = g.chatFlights.do(chatFingerprint(actorFrom(r), req), func() ([]byte, int, string) {
+			return g.runChat(ctx, client, req)
+		})
+	} else {
+		data, status, errMsg = g.runChat(ctx, client, req)
+	}
+	if errMsg != "" {
+		http.Error(w, errMsg, status)
+		return
+	}
+	if shared {
+		log.Printf("Duplicate in-flight chat request collapsed")
+		w.Header().Set("X-OCG-Shared-Response", "true")
+	}
+	if idemKey != "" {
+		g.saveIdempotent(client, idemKey, body, data)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// runChat calls the agent and encodes the OpenAI-compatible response.
+// On failure it returns the HTTP status and message instead.
+func (g *Gateway) runChat(ctx context.Context, client *rpc.Client, req ChatRequest) ([]byte, int, string) {
+	var reply rpcproto.ChatReply
+	if err := callAgent(ctx, client, "Agent.Chat", chatArgs(req), &reply); err != nil {
+		return nil, agentErrorStatus(err), err.Error()
+	}
+	if reply.Error != "" {
+		// The agent ran, but the upstream model gave nothing usable
+		return nil, http.StatusBadGateway, reply.Error
+	}
+	data, err := json.Marshal(chatResponse(req, reply))
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Encode error"
+	}
+	return data, http.StatusOK, ""
+}
+
+// chatArgs maps a chat request to the agent's arguments
+func chatArgs(req ChatRequest) rpcproto.ChatArgs {
+	return rpcproto.ChatArgs{
+		Messages:    req.Messages,
+		Seed:        req.Seed,
+		Temperature: req.Temperature,
+		N:           req.N,
+		Logprobs:    req.Logprobs,
+		TopLogprobs: req.TopLogprobs,
+		Trace:       req.Trace,
+		SessionKey:  webchatSession(req.Session),
+	}
+}
+
+// chatResponse builds the OpenAI-compatible response to a successful reply
+func chatResponse(req ChatRequest, reply rpcproto.ChatReply) ChatResponse {
 	resp := ChatResponse{
 		ID:      "chatcmpl-" + randomID(),
 		Object:  "chat.completion",
@@ -316,18 +570,68 @@ func (g *Gateway) handleChat(w http.ResponseWriter, r *http.Request) {
 			},
 		},
 		Usage: Usage{
-			PromptTokens:     countTokens(body),
-			CompletionTokens: countTokens([]byte(reply.Content)),
-			TotalTokens:      countTokens(body) + countTokens([]byte(reply.Content)),
+			PromptTokens:     promptTokens(req.Model, req.Messages),
+			CompletionTokens: countTokens(req.Model, reply.Content),
 		},
 	}
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+	if reply.Type == rpcproto.ReplyTypeAskUser && reply.Clarification != nil {
+		resp.Choices[0].FinishReason = rpcproto.ReplyTypeAskUser
+		resp.Clarification = reply.Clarification
+	} else if len(reply.Choices) > 0 {
+		resp.Choices = replyChoices(reply.Choices)
+		completion := 0
+		for _, c := range reply.Choices {
+			completion += countTokens(req.Model, c.Content)
+		}
+		resp.Usage.CompletionTokens = completion
+		resp.Usage.TotalTokens = resp.Usage.PromptTokens + completion
+	}
+	if req.Trace {
+		resp.ToolTrace = reply.Trace
+	}
+	return resp
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+// replyChoices maps the agent's upstream choices to OpenAI-style choices
+func replyChoices(choices []rpcproto.ChatChoice) []Choice {
+	out := make([]Choice, len(choices))
+	for i, c := range choices {
+		out[i] = Choice{
+			Index:        c.Index,
+			Message:      rpcproto.Message{Role: "assistant", Content: c.Content},
+			FinishReason: c.FinishReason,
+		}
+		if out[i].FinishReason == "" {
+			out[i].FinishReason = "stop"
+		}
+		if c.Logprobs != "" {
+			out[i].Logprobs = json.RawMessage(c.Logprobs)
+		}
+	}
+	return out
 }
 
 func (g *Gateway) handleHealth(w http.ResponseWriter, r *http.Request) {
-	w.Write([]byte(`{"status":"ok"}`))
+	if st := g.maintenance.status(); st.Enabled {
+		// Still 200: the process is healthy, only the API is held back
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "maintenance", "maintenance": st})
+		return
+	}
+	if r.URL.Query().Get("deep") == "" {
+		w.Write([]byte(`{"status":"ok"}`))
+		return
+	}
+	// Deep check: 503 while the agent misses heartbeats, so a load balancer
+	// drops this instance before chat requests fail
+	agent := g.deepHealth()
+	if !agent.Healthy {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		json.NewEncoder(w).Encode(map[string]interface{}{"status": "unhealthy", "agent": agent})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"status": "ok", "agent": agent})
 }
 
 func (g *Gateway) handleStorageStats(w http.ResponseWriter, r *http.Request) {
@@ -351,6 +655,92 @@ func (g *Gateway) handleStorageStats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(StatsResponse{Status: "ok", Stats: reply.Stats})
 }
 
+func (g *Gateway) handleSetupStatus(w http.ResponseWriter, r *http.Request) {
+	client, err := g.clientOrError()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var reply rpcproto.SetupStatusReply
+	if err := client.Call("Agent.SetupStatus", struct{}{}, &reply); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+func (g *Gateway) handleSetupConfig(w http.ResponseWriter, r *http.Request) {
+	client, err := g.clientOrError()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var args rpcproto.UpdateConfigArgs
+	if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+		http.Error(w, "Parse error", http.StatusBadRequest)
+		return
+	}
+
+	var reply rpcproto.SetupStatusReply
+	if err := client.Call("Agent.UpdateConfig", args, &reply); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+// handleAdminHNSW shows the HNSW index parameters and tunes efSearch on POST
+func (g *Gateway) handleAdminHNSW(w http.ResponseWriter, r *http.Request) {
+	client, err := g.clientOrError()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var reply rpcproto.HNSWStatusReply
+	if r.Method == http.MethodPost {
+		var args rpcproto.SetEfSearchArgs
+		if err := json.NewDecoder(r.Body).Decode(&args); err != nil {
+			http.Error(w, "Parse error", http.StatusBadRequest)
+			return
+		}
+		if args.EfSearch <= 0 {
+			http.Error(w, "efSearch must be > 0", http.StatusBadRequest)
+			return
+		}
+		err = client.Call("Agent.SetEfSearch", args, &reply)
+	} else {
+		err = client.Call("Agent.HNSWStatus", struct{}{}, &reply)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+// handleAdminEmbeddingStats shows embedding provider, call count, latency histogram and errors
+func (g *Gateway) handleAdminEmbeddingStats(w http.ResponseWriter, r *http.Request) {
+	client, err := g.clientOrError()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var reply rpcproto.ToolResultReply
+	if err := client.Call("Agent.EmbeddingStats", struct{}{}, &reply); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(reply.Result))
+}
+
 // Utility functions
 func randomID() string {
 	// Simple ID for demo purposes
@@ -361,8 +751,18 @@ func nowUnix() int64 {
 	return time.Now().Unix()
 }
 
-func countTokens(data []byte) int {
-	return len(data) / 4 // Simple estimate
+// countTokens estimates text with the counter registered for model (tokens.Register)
+func countTokens(model, text string) int {
+	return tokens.ForModel(model).Count(text)
+}
+
+// promptTokens counts the request messages the way the agent sizes a session
+func promptTokens(model string, messages []rpcproto.Message) int {
+	contents := make([]string, len(messages))
+	for i, m := range messages {
+		contents[i] = m.Content
+	}
+	return tokens.CountMessages(tokens.ForModel(model), contents...)
 }
 
 func channelTypeFromString(s string) channels.ChannelType {
@@ -382,6 +782,35 @@ func channelTypeFromString(s string) channels.ChannelType {
 	}
 }
 
+// dataDir returns the directory for on-disk gateway state, resolving and creating it once
+func (g *Gateway) dataDir() string {
+	g.dataDirOnce.Do(func() {
+		dir := g.cfg.DataDir
+		if dir == "" {
+			dir = resolveDataDir()
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			log.Printf("⚠️ Data dir %s not usable: %v", dir, err)
+		}
+		g.cfg.DataDir = dir
+		log.Printf("Data dir: %s", dir)
+	})
+	return g.cfg.DataDir
+}
+
+// resolveDataDir picks the default data directory: <gatewayDir>/data when it already
+// exists (installs from before OPENCLAW_DATA_DIR), otherwise ~/.openclaw
+func resolveDataDir() string {
+	legacy := filepath.Join(getGatewayDir(), "data")
+	if info, err := os.Stat(legacy); err == nil && info.IsDir() {
+		return legacy
+	}
+	if home, err := os.UserHomeDir(); err == nil && home != "" {
+		return filepath.Join(home, ".openclaw")
+	}
+	return legacy
+}
+
 // Locate gateway directory
 func getGatewayDir() string {
 	if env := os.Getenv("OPENCLAW_GATEWAY_DIR"); env != "" {
@@ -420,11 +849,6 @@ func getGatewayDir() string {
 
 // Process handlers
 func (g *Gateway) handleProcessStart(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	body, _ := io.ReadAll(r.Body)
 	var req struct {
 		Command string `json:"command"`
@@ -499,11 +923,6 @@ func (g *Gateway) handleProcessKill(w http.ResponseWriter, r *http.Request) {
 }
 
 func (g *Gateway) handleProcessWrite(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	body, _ := io.ReadAll(r.Body)
 	var req struct {
 		SessionID string `json:"sessionId"`
@@ -542,15 +961,24 @@ func (g *Gateway) handleMemorySearch(w http.ResponseWriter, r *http.Request) {
 	fmt.Sscanf(r.URL.Query().Get("limit"), "%d", &limit)
 	minScore := 0.7
 	fmt.Sscanf(r.URL.Query().Get("minScore"), "%f", &minScore)
+	var ef int
+	fmt.Sscanf(r.URL.Query().Get("ef"), "%d", &ef)
+	// Projection for list views: ?fields=id,category,score,snippet&snippet=80
+	fields := r.URL.Query().Get("fields")
+	var snippet int
+	fmt.Sscanf(r.URL.Query().Get("snippet"), "%d", &snippet)
 
 	var reply rpcproto.ToolResultReply
-	if err := client.Call("Agent.MemorySearch", rpcproto.MemorySearchArgs{
+	if err := callAgent(r.Context(), client, "Agent.MemorySearch", rpcproto.MemorySearchArgs{
 		Query:    query,
 		Category: category,
 		Limit:    limit,
 		MinScore: minScore,
+		Ef:       ef,
+		Fields:   fields,
+		Snippet:  snippet,
 	}, &reply); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), agentErrorStatus(err))
 		return
 	}
 
@@ -570,8 +998,8 @@ func (g *Gateway) handleMemoryGet(w http.ResponseWriter, r *http.Request) {
 	path := r.URL.Query().Get("path")
 
 	var reply rpcproto.ToolResultReply
-	if err := client.Call("Agent.MemoryGet", rpcproto.MemoryGetArgs{Path: path}, &reply); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := callAgent(r.Context(), client, "Agent.MemoryGet", rpcproto.MemoryGetArgs{Path: path}, &reply); err != nil {
+		http.Error(w, err.Error(), agentErrorStatus(err))
 		return
 	}
 
@@ -588,26 +1016,23 @@ func (g *Gateway) handleMemoryStore(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	body, _ := io.ReadAll(r.Body)
 	var req struct {
 		Text       string  `json:"text"`
 		Category   string  `json:"category,omitempty"`
 		Importance float64 `json:"importance,omitempty"`
+		ExternalID string  `json:"externalId,omitempty"`
 	}
 	json.Unmarshal(body, &req)
 
 	var reply rpcproto.ToolResultReply
-	if err := client.Call("Agent.MemoryStore", rpcproto.MemoryStoreArgs{
+	if err := callAgent(r.Context(), client, "Agent.MemoryStore", rpcproto.MemoryStoreArgs{
 		Text:       req.Text,
 		Category:   req.Category,
 		Importance: req.Importance,
+		ExternalID: req.ExternalID,
 	}, &reply); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), agentErrorStatus(err))
 		return
 	}
 
@@ -617,6 +1042,41 @@ func (g *Gateway) handleMemoryStore(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(result)
 }
 
+// handleMemoryDelete deletes the memory {"id"}; 404 when no memory has that ID
+func (g *Gateway) handleMemoryDelete(w http.ResponseWriter, r *http.Request) {
+	client, err := g.clientOrError()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if strings.TrimSpace(req.ID) == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+
+	var reply rpcproto.ToolResultReply
+	if err := callAgent(r.Context(), client, "Agent.MemoryDelete", rpcproto.MemoryDeleteArgs{ID: req.ID}, &reply); err != nil {
+		http.Error(w, err.Error(), agentErrorStatus(err))
+		return
+	}
+
+	var result struct {
+		Deleted bool   `json:"deleted"`
+		ID      string `json:"id"`
+	}
+	json.Unmarshal([]byte(reply.Result), &result)
+	w.Header().Set("Content-Type", "application/json")
+	if !result.Deleted {
+		w.WriteHeader(http.StatusNotFound)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
 // Cron handlers
 func (g *Gateway) handleCronStatus(w http.ResponseWriter, r *http.Request) {
 	if g.cronHandler == nil {
@@ -639,10 +1099,6 @@ func (g *Gateway) handleCronAdd(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "cron not initialized", http.StatusServiceUnavailable)
 		return
 	}
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
 	body, _ := io.ReadAll(r.Body)
 	var req map[string]interface{}
 	json.Unmarshal(body, &req)
@@ -673,10 +1129,6 @@ func (g *Gateway) handleCronUpdate(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "cron not initialized", http.StatusServiceUnavailable)
 		return
 	}
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
 	body, _ := io.ReadAll(r.Body)
 	var req map[string]interface{}
 	json.Unmarshal(body, &req)
@@ -705,10 +1157,6 @@ func (g *Gateway) handleCronRemove(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "cron not initialized", http.StatusServiceUnavailable)
 		return
 	}
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
 	body, _ := io.ReadAll(r.Body)
 	var req map[string]interface{}
 	json.Unmarshal(body, &req)
@@ -732,10 +1180,6 @@ func (g *Gateway) handleCronRun(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "cron not initialized", http.StatusServiceUnavailable)
 		return
 	}
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
 	body, _ := io.ReadAll(r.Body)
 	var req map[string]interface{}
 	json.Unmarshal(body, &req)
@@ -748,20 +1192,93 @@ func (g *Gateway) handleCronRun(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if err := g.cronHandler.RunJob(jobID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		status := http.StatusInternalServerError
+		if errors.Is(err, cron.ErrJobRunning) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
 		return
 	}
 	json.NewEncoder(w).Encode(map[string]interface{}{"ok": true})
 }
 
+// handleCronHistory returns a job's recent runs, newest first (?limit=N for fewer)
+func (g *Gateway) handleCronHistory(w http.ResponseWriter, r *http.Request) {
+	if g.cronHandler == nil {
+		http.Error(w, "cron not initialized", http.StatusServiceUnavailable)
+		return
+	}
+	jobID := r.URL.Query().Get("jobId")
+	if jobID == "" {
+		jobID = r.URL.Query().Get("id")
+	}
+	if jobID == "" {
+		http.Error(w, "jobId is required", http.StatusBadRequest)
+		return
+	}
+	history, err := g.cronHandler.JobHistory(jobID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	limit := 0
+	fmt.Sscanf(r.URL.Query().Get("limit"), "%d", &limit)
+	if limit > 0 && limit < len(history) {
+		history = history[:limit]
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"jobId": jobID, "history": history})
+}
+
+// handleCronExport returns every job definition, in the body /cron/import takes
+func (g *Gateway) handleCronExport(w http.ResponseWriter, r *http.Request) {
+	if g.cronHandler == nil {
+		http.Error(w, "cron not initialized", http.StatusServiceUnavailable)
+		return
+	}
+	jobs, err := g.cronHandler.ExportJobs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"jobs": jobs})
+}
+
+// handleCronImport applies {"mode": "merge"|"replace", "jobs": [...]} as one change
+func (g *Gateway) handleCronImport(w http.ResponseWriter, r *http.Request) {
+	if g.cronHandler == nil {
+		http.Error(w, "cron not initialized", http.StatusServiceUnavailable)
+		return
+	}
+	var req struct {
+		Mode string                   `json:"mode"`
+		Jobs []map[string]interface{} `json:"jobs"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if req.Jobs == nil {
+		http.Error(w, "jobs is required", http.StatusBadRequest)
+		return
+	}
+	res, err := g.cronHandler.ImportJobs(req.Jobs, req.Mode)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
 // GatewayAgentRPC implements channels.AgentRPCInterface for gateway-agent communication
 type GatewayAgentRPC struct {
-	client *rpc.Client
+	pool *ClientPool
 }
 
-// Chat sends a chat request to the agent via RPC
-func (r *GatewayAgentRPC) Chat(messages []channels.Message) (string, error) {
-	if r.client == nil {
+// Chat sends a chat request to the agent via RPC, in the agent session sessionKey
+// (empty = the default session)
+func (r *GatewayAgentRPC) Chat(sessionKey string, messages []channels.Message) (string, error) {
+	client := r.pool.Get()
+	if client == nil {
 		return "", fmt.Errorf("agent RPC client not connected")
 	}
 
@@ -775,24 +1292,41 @@ func (r *GatewayAgentRPC) Chat(messages []channels.Message) (string, error) {
 	}
 
 	var reply rpcproto.ChatReply
-	args := rpcproto.ChatArgs{Messages: rpcMessages}
+	args := rpcproto.ChatArgs{Messages: rpcMessages, SessionKey: sessionKey}
 
-	err := r.client.Call("Agent.Chat", args, &reply)
+	err := client.Call("Agent.Chat", args, &reply)
 	if err != nil {
 		return "", err
 	}
+	if reply.Error != "" {
+		return "", fmt.Errorf("agent: %s", reply.Error)
+	}
 
+	// Channels render clarifications as a plain-text prompt
+	if reply.Type == rpcproto.ReplyTypeAskUser && reply.Clarification != nil {
+		return formatClarification(reply.Clarification), nil
+	}
 	return reply.Content, nil
 }
 
+func formatClarification(q *rpcproto.Clarification) string {
+	var b strings.Builder
+	b.WriteString("❓ " + q.Question)
+	for i, opt := range q.Options {
+		fmt.Fprintf(&b, "\n%d. %s", i+1, opt)
+	}
+	return b.String()
+}
+
 // GetStats gets statistics from the agent via RPC
 func (r *GatewayAgentRPC) GetStats() (map[string]int, error) {
-	if r.client == nil {
+	client := r.pool.Get()
+	if client == nil {
 		return nil, fmt.Errorf("agent RPC client not connected")
 	}
 
 	var reply rpcproto.StatsReply
-	if err := r.client.Call("Agent.Stats", struct{}{}, &reply); err != nil {
+	if err := client.Call("Agent.Stats", struct{}{}, &reply); err != nil {
 		return nil, err
 	}
 
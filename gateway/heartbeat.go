@@ -0,0 +1,209 @@
+// Agent heartbeat - ping the agent on a timer, report it in deep health and
+// redial the socket once the agent stops answering
+
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+// Heartbeat defaults (Config fields left at 0)
+const (
+	DefaultHeartbeatInterval = 10 * time.Second
+	DefaultHeartbeatFailures = 3
+)
+
+// heartbeatTimeout bounds one Agent.Ping round; a live agent answers at once
+const heartbeatTimeout = 3 * time.Second
+
+type heartbeatState struct {
+	mu         sync.RWMutex
+	unhealthy  bool
+	failures   int
+	lastOK     time.Time
+	lastErr    string
+	reconnects int
+	// poolSize is the connection count to redial, taken when the heartbeat starts
+	poolSize int
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+type heartbeatStatus struct {
+	Healthy    bool   `json:"healthy"`
+	Failures   int    `json:"failures"`
+	LastOK     int64  `json:"lastOk,omitempty"`
+	LastError  string `json:"lastError,omitempty"`
+	Reconnects int    `json:"reconnects"`
+}
+
+func (h *heartbeatState) status() heartbeatStatus {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	st := heartbeatStatus{Healthy: !h.unhealthy, Failures: h.failures, LastError: h.lastErr, Reconnects: h.reconnects}
+	if !h.lastOK.IsZero() {
+		st.LastOK = h.lastOK.Unix()
+	}
+	return st
+}
+
+// ok records an answered ping and reports whether the agent was unhealthy before
+func (h *heartbeatState) ok() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	was := h.unhealthy
+	h.unhealthy, h.failures, h.lastErr, h.lastOK = false, 0, "", time.Now()
+	return was
+}
+
+// fail records a missed ping and returns the failures in a row
+func (h *heartbeatState) fail(err error, threshold int) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.failures++
+	h.lastErr = err.Error()
+	if h.failures >= threshold {
+		h.unhealthy = true
+	}
+	return h.failures
+}
+
+// heartbeatSettings resolves the interval (0 = off) and failure threshold
+func (g *Gateway) heartbeatSettings() (time.Duration, int) {
+	interval, failures := g.cfg.HeartbeatInterval, g.cfg.HeartbeatFailures
+	if interval == 0 {
+		interval = DefaultHeartbeatInterval
+	}
+	if interval < 0 {
+		interval = 0
+	}
+	if failures <= 0 {
+		failures = DefaultHeartbeatFailures
+	}
+	return interval, failures
+}
+
+// startHeartbeat pings the agent every interval until Stop
+func (g *Gateway) startHeartbeat() {
+	interval, failures := g.heartbeatSettings()
+	if interval == 0 {
+		log.Printf("ℹ️ Agent heartbeat disabled")
+		return
+	}
+	g.heartbeat.poolSize = max(g.agentPool().Size(), 1)
+	g.heartbeat.stop = make(chan struct{})
+	stop := g.heartbeat.stop
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-t.C:
+				g.heartbeatOnce(failures)
+			}
+		}
+	}()
+	log.Printf("💓 Agent heartbeat every %v (unhealthy after %d misses)", interval, failures)
+}
+
+func (g *Gateway) stopHeartbeat() {
+	if g.heartbeat.stop != nil {
+		g.heartbeat.stopOnce.Do(func() { close(g.heartbeat.stop) })
+	}
+}
+
+// heartbeatOnce pings every pooled connection, so one broken connection is
+// noticed even though calls are spread round-robin. From the threshold on,
+// each missed round redials the socket.
+func (g *Gateway) heartbeatOnce(threshold int) {
+	err := pingPool(g.agentPool())
+	if err == nil {
+		if g.heartbeat.ok() {
+			log.Printf("✅ Agent answers heartbeat again")
+		}
+		return
+	}
+	n := g.heartbeat.fail(err, threshold)
+	if n < threshold {
+		log.Printf("⚠️ Agent heartbeat missed (%d/%d): %v", n, threshold, err)
+		return
+	}
+	if n == threshold {
+		log.Printf("❌ Agent missed %d heartbeats, marking unhealthy and reconnecting: %v", n, err)
+	}
+	g.reconnectAgent()
+}
+
+// pingPool calls Agent.Ping on each connection of p
+func pingPool(p *ClientPool) error {
+	clients := p.all()
+	if len(clients) == 0 {
+		return fmt.Errorf("agent not connected")
+	}
+	for _, c := range clients {
+		if err := pingAgent(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pingAgent(c *rpc.Client) error {
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatTimeout)
+	defer cancel()
+	var reply rpcproto.PingReply
+	return callAgent(ctx, c, "Agent.Ping", struct{}{}, &reply)
+}
+
+// reconnectAgent dials a fresh set of connections and swaps them into the pool
+// once the first one answers a ping; the gateway stays unhealthy until then
+func (g *Gateway) reconnectAgent() {
+	pool := g.agentPool()
+	if pool == nil {
+		return
+	}
+	var clients []*rpc.Client
+	for len(clients) < g.heartbeat.poolSize {
+		c, err := rpc.Dial("unix", g.cfg.AgentAddr)
+		if err != nil {
+			break
+		}
+		clients = append(clients, c)
+	}
+	if len(clients) == 0 || pingAgent(clients[0]) != nil {
+		for _, c := range clients {
+			c.Close()
+		}
+		return
+	}
+	pool.Reset(clients...)
+	g.heartbeat.mu.Lock()
+	g.heartbeat.reconnects++
+	g.heartbeat.mu.Unlock()
+	g.heartbeat.ok()
+	log.Printf("🔌 Reconnected to agent at %s (%d connections)", g.cfg.AgentAddr, len(clients))
+}
+
+// deepHealth is the agent part of /health?deep=1. Without a running heartbeat
+// it pings once so the answer is never stale.
+func (g *Gateway) deepHealth() heartbeatStatus {
+	if interval, _ := g.heartbeatSettings(); interval == 0 {
+		st := heartbeatStatus{Healthy: true}
+		if err := pingPool(g.agentPool()); err != nil {
+			st.Healthy, st.LastError = false, err.Error()
+		} else {
+			st.LastOK = time.Now().Unix()
+		}
+		return st
+	}
+	return g.heartbeat.status()
+}
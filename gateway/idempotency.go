@@ -0,0 +1,107 @@
+package gateway
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/rpc"
+	"strings"
+	"time"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+const (
+	idempotencyHeader    = "Idempotency-Key"
+	idempotencyNamespace = "idempotency"
+	// Replies are kept long enough to cover client retry loops
+	idempotencyTTL    = 10 * time.Minute
+	maxIdempotencyKey = 255
+)
+
+// idempotentRecord is what gets cached in kv_cache for one Idempotency-Key
+type idempotentRecord struct {
+	BodyHash string          `json:"bodyHash"`
+	Response json.RawMessage `json:"response"`
+}
+
+func idempotencyKey(r *http.Request) string {
+	return strings.TrimSpace(r.Header.Get(idempotencyHeader))
+}
+
+func hashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// replayIdempotent writes the cached reply for key if there is one, as SSE for stream.
+// It returns true when the request has been fully answered.
+func (g *Gateway) replayIdempotent(w http.ResponseWriter, client *rpc.Client, key string, body []byte, stream bool) bool {
+	var reply rpcproto.KVGetReply
+	if err := client.Call("Agent.KVGet", rpcproto.KVGetArgs{Namespace: idempotencyNamespace, Key: key}, &reply); err != nil {
+		// Cache unavailable: process the request normally
+		log.Printf("⚠️ idempotency lookup failed: %v", err)
+		return false
+	}
+	if !reply.Found {
+		return false
+	}
+
+	var rec idempotentRecord
+	if err := json.Unmarshal([]byte(reply.Value), &rec); err != nil {
+		return false
+	}
+	if rec.BodyHash != hashBody(body) {
+		http.Error(w, "Idempotency-Key was already used with a different request body", http.StatusUnprocessableEntity)
+		return true
+	}
+
+	w.Header().Set("Idempotent-Replayed", "true")
+	if stream {
+		writeChatStream(w, rec.Response)
+		return true
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(rec.Response)
+	return true
+}
+
+// saveIdempotent caches a successful reply for key
+func (g *Gateway) saveIdempotent(client *rpc.Client, key string, body, response []byte) {
+	value, err := json.Marshal(idempotentRecord{BodyHash: hashBody(body), Response: response})
+	if err != nil {
+		return
+	}
+	args := rpcproto.KVSetArgs{
+		Namespace:  idempotencyNamespace,
+		Key:        key,
+		Value:      string(value),
+		TTLSeconds: int(idempotencyTTL / time.Second),
+	}
+	var reply rpcproto.ToolResultReply
+	if err := client.Call("Agent.KVSet", args, &reply); err != nil {
+		log.Printf("⚠️ idempotency save failed: %v", err)
+	}
+}
+
+// beginIdempotent marks key as in flight; false means a request with the same key is still running
+func (g *Gateway) beginIdempotent(key string) bool {
+	g.idemMu.Lock()
+	defer g.idemMu.Unlock()
+	if g.idemInflight == nil {
+		g.idemInflight = make(map[string]bool)
+	}
+	if g.idemInflight[key] {
+		return false
+	}
+	g.idemInflight[key] = true
+	return true
+}
+
+func (g *Gateway) endIdempotent(key string) {
+	g.idemMu.Lock()
+	delete(g.idemInflight, key)
+	g.idemMu.Unlock()
+}
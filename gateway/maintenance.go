@@ -0,0 +1,117 @@
+package gateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Retry-After (seconds) sent while maintenance is on
+const (
+	defaultRetryAfter = 30
+	maxRetryAfter     = 24 * 60 * 60
+)
+
+// maintenanceState takes the chat API out of rotation without stopping the gateway
+type maintenanceState struct {
+	mu         sync.RWMutex
+	enabled    bool
+	reason     string
+	retryAfter int
+	since      time.Time
+}
+
+type maintenanceStatus struct {
+	Enabled    bool   `json:"enabled"`
+	Reason     string `json:"reason,omitempty"`
+	RetryAfter int    `json:"retryAfter,omitempty"`
+	Since      int64  `json:"since,omitempty"`
+}
+
+func (m *maintenanceState) status() maintenanceStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.enabled {
+		return maintenanceStatus{}
+	}
+	return maintenanceStatus{Enabled: true, Reason: m.reason, RetryAfter: m.retryAfter, Since: m.since.Unix()}
+}
+
+func (m *maintenanceState) set(enabled bool, reason string, retryAfter int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if retryAfter <= 0 {
+		retryAfter = defaultRetryAfter
+	}
+	if enabled && !m.enabled {
+		m.since = time.Now()
+	}
+	m.enabled = enabled
+	m.reason = reason
+	m.retryAfter = retryAfter
+	if !enabled {
+		m.reason = ""
+	}
+}
+
+// serving rejects traffic with a retryable 503 while maintenance mode is on.
+// Only chat/API routes are wrapped; /health and /admin/* keep answering.
+func (g *Gateway) serving(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		st := g.maintenance.status()
+		if !st.Enabled {
+			next(w, r)
+			return
+		}
+		w.Header().Set("Retry-After", strconv.Itoa(st.RetryAfter))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		msg := "service in maintenance mode"
+		if st.Reason != "" {
+			msg += ": " + st.Reason
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"error": map[string]interface{}{
+				"message": msg,
+				"type":    "maintenance",
+			},
+			"retryAfter": st.RetryAfter,
+		})
+	}
+}
+
+// handleAdminMaintenance shows maintenance mode on GET and toggles it on POST
+// ({"enabled": true, "reason": "db migration", "retryAfter": 60})
+func (g *Gateway) handleAdminMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		var req struct {
+			Enabled    *bool  `json:"enabled"`
+			Reason     string `json:"reason"`
+			RetryAfter int    `json:"retryAfter"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Parse error", http.StatusBadRequest)
+			return
+		}
+		if req.Enabled == nil {
+			http.Error(w, "enabled is required", http.StatusBadRequest)
+			return
+		}
+		if req.RetryAfter < 0 || req.RetryAfter > maxRetryAfter {
+			http.Error(w, fmt.Sprintf("retryAfter must be between 0 and %d", maxRetryAfter), http.StatusBadRequest)
+			return
+		}
+		g.maintenance.set(*req.Enabled, req.Reason, req.RetryAfter)
+		if *req.Enabled {
+			log.Printf("🔧 Maintenance mode ON (reason=%q, retryAfter=%ds)", req.Reason, g.maintenance.status().RetryAfter)
+		} else {
+			log.Printf("🔧 Maintenance mode OFF")
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(g.maintenance.status())
+}
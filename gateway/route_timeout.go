@@ -0,0 +1,93 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/rpc"
+	"time"
+)
+
+// Default per-route budgets (RouteTimeouts fields left at 0)
+const (
+	DefaultChatTimeout    = 5 * time.Minute
+	DefaultMemoryTimeout  = 10 * time.Second
+	DefaultProcessTimeout = 10 * time.Second
+	DefaultRouteTimeout   = 60 * time.Second
+)
+
+// routeWriteGrace keeps the connection writable past a route's deadline,
+// so the handler can still send its 504
+const routeWriteGrace = 5 * time.Second
+
+// RouteTimeouts bounds how long each group of routes may run
+type RouteTimeouts struct {
+	// Chat covers /v1/chat/completions; tool-using turns can take minutes
+	Chat time.Duration `json:"chat,omitempty"`
+	// Memory covers /memory/*
+	Memory time.Duration `json:"memory,omitempty"`
+	// Process covers /process/*
+	Process time.Duration `json:"process,omitempty"`
+	// Default is the server-wide write timeout for every other route
+	Default time.Duration `json:"default,omitempty"`
+}
+
+// withDefaults fills zero fields with the Default* budgets
+func (t RouteTimeouts) withDefaults() RouteTimeouts {
+	if t.Chat <= 0 {
+		t.Chat = DefaultChatTimeout
+	}
+	if t.Memory <= 0 {
+		t.Memory = DefaultMemoryTimeout
+	}
+	if t.Process <= 0 {
+		t.Process = DefaultProcessTimeout
+	}
+	if t.Default <= 0 {
+		t.Default = DefaultRouteTimeout
+	}
+	return t
+}
+
+// withTimeout gives the handler a context deadline of d and moves the connection's
+// write deadline to match, overriding the server-wide WriteTimeout for this route
+func withTimeout(d time.Duration, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), d)
+		defer cancel()
+		// Not every ResponseWriter supports it (e.g. in tests); the context still applies
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(d + routeWriteGrace))
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// callAgent is client.Call bounded by ctx. On timeout the agent keeps working;
+// its reply is discarded.
+func callAgent(ctx context.Context, client *rpc.Client, method string, args, reply interface{}) error {
+	call := client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		return call.Error
+	case <-ctx.Done():
+		return fmt.Errorf("%s: %w", method, ctx.Err())
+	}
+}
+
+// agentErrorStatus is 504 for a call cut off by its route deadline, 500 otherwise
+func agentErrorStatus(err error) int {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return http.StatusGatewayTimeout
+	}
+	return http.StatusInternalServerError
+}
+
+// detachedDeadline keeps ctx's deadline but not its cancellation, for work shared
+// between requests (singleflight) that one client disconnecting must not abort
+func detachedDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := context.WithoutCancel(ctx)
+	if deadline, ok := ctx.Deadline(); ok {
+		return context.WithDeadline(detached, deadline)
+	}
+	return detached, func() {}
+}
@@ -0,0 +1,60 @@
+package gateway
+
+import (
+	"net/http"
+	"strings"
+)
+
+// allowMethods restricts a route to the given methods.
+// GET routes also answer HEAD, OPTIONS replies with the Allow header,
+// and anything else gets a 405 carrying the same Allow header.
+func allowMethods(next http.HandlerFunc, methods ...string) http.HandlerFunc {
+	allowed := make(map[string]bool, len(methods)+2)
+	list := make([]string, 0, len(methods)+2)
+	add := func(m string) {
+		if !allowed[m] {
+			allowed[m] = true
+			list = append(list, m)
+		}
+	}
+	for _, m := range methods {
+		add(strings.ToUpper(m))
+	}
+	if allowed[http.MethodGet] {
+		add(http.MethodHead)
+	}
+	add(http.MethodOptions)
+	allow := strings.Join(list, ", ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Allow", allow)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		if !allowed[r.Method] {
+			w.Header().Set("Allow", allow)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		// HEAD runs the GET handler; net/http drops the body
+		next(w, r)
+	}
+}
+
+// router registers routes with their allowed methods on a ServeMux.
+type router struct {
+	mux *http.ServeMux
+}
+
+func (rt *router) get(path string, h http.HandlerFunc) {
+	rt.mux.HandleFunc(path, allowMethods(h, http.MethodGet))
+}
+
+func (rt *router) post(path string, h http.HandlerFunc) {
+	rt.mux.HandleFunc(path, allowMethods(h, http.MethodPost))
+}
+
+func (rt *router) handle(path string, h http.HandlerFunc, methods ...string) {
+	rt.mux.HandleFunc(path, allowMethods(h, methods...))
+}
@@ -0,0 +1,95 @@
+package gateway
+
+import (
+	"net/rpc"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultAgentPoolSize is the number of agent RPC connections when none is configured
+const DefaultAgentPoolSize = 4
+
+// ClientPool round-robins agent RPC calls over several connections, so large
+// requests and replies on one connection do not hold up the others
+type ClientPool struct {
+	mu      sync.RWMutex
+	clients []*rpc.Client
+	next    atomic.Uint64
+}
+
+// NewClientPool wraps already connected clients (nil entries are skipped)
+func NewClientPool(clients ...*rpc.Client) *ClientPool {
+	return &ClientPool{clients: nonNil(clients)}
+}
+
+func nonNil(clients []*rpc.Client) []*rpc.Client {
+	var out []*rpc.Client
+	for _, c := range clients {
+		if c != nil {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// Get returns the next client in turn, nil for an empty pool
+func (p *ClientPool) Get() *rpc.Client {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if len(p.clients) == 0 {
+		return nil
+	}
+	n := p.next.Add(1) - 1
+	return p.clients[n%uint64(len(p.clients))]
+}
+
+// Size is the number of connections in the pool
+func (p *ClientPool) Size() int {
+	if p == nil {
+		return 0
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return len(p.clients)
+}
+
+// all returns a copy of the current connections
+func (p *ClientPool) all() []*rpc.Client {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return append([]*rpc.Client(nil), p.clients...)
+}
+
+// Reset swaps in new connections and closes the old ones. Holders of the pool
+// (channel adapters, cron callbacks) use the new connections from their next call.
+func (p *ClientPool) Reset(clients ...*rpc.Client) {
+	p.mu.Lock()
+	old := p.clients
+	p.clients = nonNil(clients)
+	p.mu.Unlock()
+	for _, c := range old {
+		c.Close()
+	}
+}
+
+// Close closes every connection
+func (p *ClientPool) Close() error {
+	if p == nil {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var first error
+	for _, c := range p.clients {
+		if err := c.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
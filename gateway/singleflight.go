@@ -0,0 +1,63 @@
+package gateway
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// chatFlight is one agent call shared by identical concurrent requests
+type chatFlight struct {
+	done   chan struct{}
+	data   []byte
+	status int
+	errMsg string
+}
+
+// chatFlightGroup collapses identical in-flight chat requests (e.g. a double-clicked
+// send) into one agent call. Unlike Idempotency-Key it only covers requests that
+// overlap in time; nothing is cached once the call returns.
+type chatFlightGroup struct {
+	mu      sync.Mutex
+	flights map[string]*chatFlight
+}
+
+// chatFingerprint identifies a chat request by its caller, its session and its
+// canonical JSON, so formatting differences between two submissions of the same
+// request do not matter but two callers never share a reply
+func chatFingerprint(actor auditActor, req ChatRequest) string {
+	data, _ := json.Marshal(req)
+	return actor.KeyID + "\x00" + actor.Claimed + "\x00" + req.Session + "\x00" + hashBody(data)
+}
+
+// chatShareable reports whether identical requests may share one reply. A
+// request that asks for sampling (temperature > 0) without a seed expects a
+// fresh answer on every call.
+func chatShareable(req ChatRequest) bool {
+	return req.Temperature == nil || *req.Temperature <= 0 || req.Seed != nil
+}
+
+// do runs fn for key unless an identical call is already running, in which case
+// it waits for that call and returns its result. shared reports the latter.
+func (f *chatFlightGroup) do(key string, fn func() ([]byte, int, string)) (data []byte, status int, errMsg string, shared bool) {
+	f.mu.Lock()
+	if f.flights == nil {
+		f.flights = make(map[string]*chatFlight)
+	}
+	if fl, ok := f.flights[key]; ok {
+		f.mu.Unlock()
+		<-fl.done
+		return fl.data, fl.status, fl.errMsg, true
+	}
+	fl := &chatFlight{done: make(chan struct{})}
+	f.flights[key] = fl
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.flights, key)
+		f.mu.Unlock()
+		close(fl.done)
+	}()
+	fl.data, fl.status, fl.errMsg = fn()
+	return fl.data, fl.status, fl.errMsg, false
+}
@@ -0,0 +1,59 @@
+// Startup checks - a missing dependency fails Start instead of every later request
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gliderlab/cogate/rpcproto"
+)
+
+// startupCheckTimeout bounds each dependency probe in Start
+const startupCheckTimeout = 5 * time.Second
+
+// checkDependencies runs before Start listens: the agent must answer over RPC and,
+// when Config.EmbeddingURL is set, the embedding server must accept connections
+func (g *Gateway) checkDependencies() error {
+	pool := g.agentPool()
+	if pool.Size() == 0 {
+		return fmt.Errorf("agent not connected (socket %s): start the agent first or check OPENCLAW_AGENT_SOCK", g.cfg.AgentAddr)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), startupCheckTimeout)
+	defer cancel()
+	var status rpcproto.SetupStatusReply
+	if err := callAgent(ctx, pool.Get(), "Agent.SetupStatus", struct{}{}, &status); err != nil {
+		return fmt.Errorf("agent at %s does not answer RPC: %v", g.cfg.AgentAddr, err)
+	}
+	log.Printf("✅ Agent reachable (%d connections)", pool.Size())
+
+	if g.cfg.EmbeddingURL == "" {
+		return nil
+	}
+	return checkEmbeddingURL(g.cfg.EmbeddingURL)
+}
+
+// checkEmbeddingURL fails on a malformed URL or a server that cannot be reached.
+// Any HTTP answer counts as reachable: /health is 503 while the model loads.
+func checkEmbeddingURL(raw string) error {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid embedding URL %q: want http(s)://host:port", raw)
+	}
+	client := &http.Client{Timeout: startupCheckTimeout}
+	resp, err := client.Get(strings.TrimRight(u.String(), "/") + "/health")
+	if err != nil {
+		return fmt.Errorf("embedding server %s unreachable: %v", u.Host, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("⚠️ Embedding server %s reachable but not ready yet (health %d)", u.Host, resp.StatusCode)
+		return nil
+	}
+	log.Printf("✅ Embedding server reachable: %s", u.Host)
+	return nil
+}
@@ -23,11 +23,6 @@ func (g *Gateway) handleTelegramWebhook(w http.ResponseWriter, r *http.Request)
 
 // handleTelegramSetWebhook configures the Telegram bot webhook URL
 func (g *Gateway) handleTelegramSetWebhook(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Get webhook URL from request
 	body, _ := io.ReadAll(r.Body)
 	var req struct {
@@ -49,7 +44,7 @@ func (g *Gateway) handleTelegramSetWebhook(w http.ResponseWriter, r *http.Reques
 			return
 		}
 
-		bot := channels.NewTelegramBot(telegramToken, &GatewayAgentRPC{client: g.client})
+		bot := channels.NewTelegramBot(telegramToken, &GatewayAgentRPC{pool: g.agentPool()})
 		if err := g.channelAdapter.RegisterChannel(bot); err != nil {
 			http.Error(w, fmt.Sprintf("Failed to register Telegram channel: %v", err), http.StatusInternalServerError)
 			return
@@ -91,6 +86,7 @@ func (g *Gateway) handleTelegramStatus(w http.ResponseWriter, r *http.Request) {
 			status["version"] = info.Version
 			status["capabilities"] = info.Capabilities
 		}
+		status["workers"] = g.channelAdapter.PoolStats()
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -24,8 +24,23 @@ const (
 	MsgTypePing    = "ping"
 	MsgTypePong    = "pong"
 	MsgTypeHistory = "history"
+	MsgTypeAskUser = "ask_user" // agent needs a clarification; reply with a normal chat message
+	MsgTypeSession = "session"  // sent first on every connection: session key + keepalive settings
 )
 
+// Keepalive: protocol ping frames well under common 60s proxy idle timeouts
+const (
+	wsPingInterval = 25 * time.Second
+	wsPongTimeout  = 10 * time.Second
+)
+
+// WSSessionInfo is the content of the session message
+type WSSessionInfo struct {
+	SessionKey   string `json:"sessionKey"`
+	Resumed      bool   `json:"resumed"`
+	PingInterval int    `json:"pingInterval"` // seconds
+}
+
 // WSMessage represents a WebSocket message
 type WSMessage struct {
 	Type    string          `json:"type"`
@@ -34,8 +49,10 @@ type WSMessage struct {
 
 // WSChatRequest represents a chat request via WebSocket
 type WSChatRequest struct {
-	Model    string             `json:"model"`
-	Messages []rpcproto.Message `json:"messages"`
+	Model       string             `json:"model"`
+	Messages    []rpcproto.Message `json:"messages"`
+	Seed        *int64             `json:"seed,omitempty"`
+	Temperature *float64           `json:"temperature,omitempty"`
 }
 
 // WSChatResponse represents a chat response via WebSocket
@@ -44,6 +61,7 @@ type WSChatResponse struct {
 	Finish    bool   `json:"finish"`
 	Error     string `json:"error,omitempty"`
 	TotalTokens int   `json:"totalTokens,omitempty"`
+	Clarification *rpcproto.Clarification `json:"clarification,omitempty"`
 }
 
 // WebSocketHub manages WebSocket connections
@@ -147,17 +165,46 @@ func (g *Gateway) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Create context with timeout for ping/pong handling
+	// Resume the client's session when it reconnects with ?session=<key>;
+	// a key this gateway did not issue gets a new one
+	sessionKey := r.URL.Query().Get("session")
+	resumed := g.issuedWSSessionKey(sessionKey)
+	if !resumed {
+		sessionKey = g.newWSSessionKey()
+	}
+
+	// Cancelled on disconnect or when the keepalive gives up
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Handle the connection
-	g.handleWSConnection(ctx, conn)
+	g.handleWSConnection(ctx, cancel, conn, sessionKey, resumed)
 }
 
-func (g *Gateway) handleWSConnection(ctx context.Context, conn *websocket.Conn) {
+func (g *Gateway) handleWSConnection(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, sessionKey string, resumed bool) {
 	defer conn.Close(websocket.StatusNormalClosure, "")
 
+	go g.wsKeepalive(ctx, cancel, conn)
+
+	info, _ := json.Marshal(WSSessionInfo{
+		SessionKey:   sessionKey,
+		Resumed:      resumed,
+		PingInterval: int(wsPingInterval / time.Second),
+	})
+	if data, err := json.Marshal(WSMessage{Type: MsgTypeSession, Content: info}); err == nil {
+		conn.Write(ctx, websocket.MessageText, data)
+	}
+	// Deliver replies that completed while the client was away
+	for _, data := range g.wsSessions.take(sessionKey) {
+		if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
+			g.wsSessions.add(sessionKey, data)
+		}
+	}
+
+	// Chats run off the read loop so pongs are still read during long model calls;
+	// chatMu keeps one connection's chats in order
+	var chatMu sync.Mutex
+
 	// Message loop
 	for {
 		_, msgBytes, err := conn.Read(ctx)
@@ -174,7 +221,11 @@ func (g *Gateway) handleWSConnection(ctx context.Context, conn *websocket.Conn)
 
 		switch msg.Type {
 		case MsgTypeChat:
-			g.handleWSChat(ctx, conn, msg.Content)
+			go func(content json.RawMessage) {
+				chatMu.Lock()
+				defer chatMu.Unlock()
+				g.handleWSChat(ctx, conn, sessionKey, content)
+			}(msg.Content)
 		case MsgTypePing:
 			// Respond with pong
 			pong := WSMessage{Type: MsgTypePong}
@@ -187,7 +238,32 @@ func (g *Gateway) handleWSConnection(ctx context.Context, conn *websocket.Conn)
 	}
 }
 
-func (g *Gateway) handleWSChat(ctx context.Context, conn *websocket.Conn, content json.RawMessage) {
+// wsKeepalive pings the client every wsPingInterval and closes the connection
+// when a pong does not arrive within wsPongTimeout
+func (g *Gateway) wsKeepalive(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn) {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pingCtx, done := context.WithTimeout(ctx, wsPongTimeout)
+			err := conn.Ping(pingCtx)
+			done()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("[WS] Ping failed, closing dead connection: %v", err)
+					conn.Close(websocket.StatusGoingAway, "ping timeout")
+				}
+				cancel()
+				return
+			}
+		}
+	}
+}
+
+func (g *Gateway) handleWSChat(ctx context.Context, conn *websocket.Conn, sessionKey string, content json.RawMessage) {
 	// Content can be either a JSON object or a stringified JSON object
 	// Try to parse as WSChatRequest first
 	var req WSChatRequest
@@ -219,23 +295,36 @@ func (g *Gateway) handleWSChat(ctx context.Context, conn *websocket.Conn, conten
 
 	// Send request to agent via RPC
 	var reply rpcproto.ChatReply
-	args := rpcproto.ChatArgs{Messages: req.Messages}
+	args := rpcproto.ChatArgs{
+		Messages:    req.Messages,
+		Seed:        req.Seed,
+		Temperature: req.Temperature,
+		SessionKey:  webchatSession(sessionKey),
+	}
 	if err := client.Call("Agent.Chat", args, &reply); err != nil {
 		g.sendWSError(conn, "chat error: "+err.Error())
 		return
 	}
+	if reply.Error != "" {
+		g.sendWSError(conn, "upstream error: "+reply.Error)
+		return
+	}
 
 	// Send the response as a single message (since agent doesn't stream)
 	resp := WSChatResponse{
 		Content:     reply.Content,
 		Finish:      true,
-		TotalTokens: countTokens([]byte(reply.Content)),
+		TotalTokens: countTokens(req.Model, reply.Content),
 	}
 
 	msg := WSMessage{
 		Type:    MsgTypeDone,
 		Content: json.RawMessage{},
 	}
+	if reply.Type == rpcproto.ReplyTypeAskUser && reply.Clarification != nil {
+		msg.Type = MsgTypeAskUser
+		resp.Clarification = reply.Clarification
+	}
 
 	// Marshal with the response data
 	respBytes, _ := json.Marshal(resp)
@@ -248,7 +337,9 @@ func (g *Gateway) handleWSChat(ctx context.Context, conn *websocket.Conn, conten
 	}
 
 	if err := conn.Write(ctx, websocket.MessageText, data); err != nil {
-		log.Printf("[WS] Write error: %v", err)
+		// Client dropped mid-chat: keep the reply for its reconnect
+		log.Printf("[WS] Write error, buffering reply for session %s: %v", sessionKey, err)
+		g.wsSessions.add(sessionKey, data)
 	}
 }
 
@@ -0,0 +1,121 @@
+// WebSocket sessions - keys for reconnecting clients and replies buffered across drops
+
+package gateway
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// wsPendingTTL is how long an undelivered reply waits for the client to reconnect
+	wsPendingTTL = 5 * time.Minute
+	// wsMaxPending caps buffered replies per session
+	wsMaxPending = 16
+	// Session keys are "ws-<id>-<mac>": a random ID and its truncated HMAC
+	wsSessionIDBytes  = 16
+	wsSessionMACBytes = 12
+)
+
+type wsPending struct {
+	data []byte
+	at   time.Time
+}
+
+// wsSessionStore buffers replies that finished after their connection dropped,
+// so a client reconnecting with the same session key still receives them.
+type wsSessionStore struct {
+	mu      sync.Mutex
+	pending map[string][]wsPending
+}
+
+// add buffers an undelivered message for key
+func (s *wsSessionStore) add(key string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[string][]wsPending)
+	}
+	s.pruneLocked()
+	list := append(s.pending[key], wsPending{data: data, at: time.Now()})
+	if len(list) > wsMaxPending {
+		list = list[len(list)-wsMaxPending:]
+	}
+	s.pending[key] = list
+}
+
+// take returns and clears the buffered messages for key
+func (s *wsSessionStore) take(key string) [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pruneLocked()
+	list := s.pending[key]
+	delete(s.pending, key)
+	out := make([][]byte, 0, len(list))
+	for _, p := range list {
+		out = append(out, p.data)
+	}
+	return out
+}
+
+func (s *wsSessionStore) pruneLocked() {
+	cutoff := time.Now().Add(-wsPendingTTL)
+	for key, list := range s.pending {
+		i := 0
+		for i < len(list) && list[i].at.Before(cutoff) {
+			i++
+		}
+		if i == len(list) {
+			delete(s.pending, key)
+		} else if i > 0 {
+			s.pending[key] = list[i:]
+		}
+	}
+}
+
+// webchatSession is the agent session of a web UI session key; empty keeps the
+// agent's default session
+func webchatSession(key string) string {
+	if key == "" {
+		return ""
+	}
+	return "webchat:" + key
+}
+
+// newWSSessionKey issues a session key. The ID is random and the key carries
+// its HMAC, so only keys this gateway issued are accepted back.
+func (g *Gateway) newWSSessionKey() string {
+	b := make([]byte, wsSessionIDBytes)
+	rand.Read(b)
+	id := hex.EncodeToString(b)
+	return "ws-" + id + "-" + g.wsSessionMAC(id)
+}
+
+// issuedWSSessionKey reports whether key was issued by newWSSessionKey. A
+// well-formed key the gateway did not sign is rejected, so nobody can pick
+// another client's key and read its replies or history.
+func (g *Gateway) issuedWSSessionKey(key string) bool {
+	rest, ok := strings.CutPrefix(key, "ws-")
+	if !ok {
+		return false
+	}
+	id, mac, ok := strings.Cut(rest, "-")
+	if !ok || len(id) != 2*wsSessionIDBytes {
+		return false
+	}
+	return hmac.Equal([]byte(mac), []byte(g.wsSessionMAC(id)))
+}
+
+// wsSessionMAC signs a session ID with a secret derived from the UI token, so
+// keys survive a restart and are revoked when the token changes
+func (g *Gateway) wsSessionMAC(id string) string {
+	secret := sha256.Sum256([]byte("ocg-ws-session\x00" + strings.TrimSpace(g.cfg.UIAuthToken)))
+	m := hmac.New(sha256.New, secret[:])
+	m.Write([]byte(id))
+	return hex.EncodeToString(m.Sum(nil)[:wsSessionMACBytes])
+}
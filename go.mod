@@ -8,4 +8,8 @@ require (
 	github.com/sashabaranov/go-openai v1.41.2
 )
 
-require nhooyr.io/websocket v1.8.17 // indirect
+require nhooyr.io/websocket v1.8.17
+
+require github.com/robfig/cron/v3 v3.0.1
+
+require gopkg.in/yaml.v3 v3.0.1
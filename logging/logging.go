@@ -0,0 +1,76 @@
+// Package logging configures leveled, structured logs (log/slog) for the OCG processes.
+//
+// Setup installs a slog handler as the default for slog records, such as the
+// verbose dumps at slog.Debug. The level gates those records only: log.Printf,
+// log.Fatalf and friends keep writing to stderr as before, so their warnings,
+// errors and fatal messages show at every level. Below debug, slog attributes
+// that may carry user data or credentials are redacted.
+package logging
+
+import (
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// Redacted replaces sensitive attribute values when not running at debug level
+const Redacted = "[redacted]"
+
+// sensitiveKeys are attribute keys whose values are hidden at info and above
+var sensitiveKeys = map[string]bool{
+	"args":          true,
+	"arguments":     true,
+	"params":        true,
+	"param":         true,
+	"content":       true,
+	"text":          true,
+	"value":         true,
+	"apikey":        true,
+	"api_key":       true,
+	"token":         true,
+	"authorization": true,
+	"password":      true,
+	"secret":        true,
+}
+
+// ParseLevel reads debug, info, warn(ing) or error; ok is false for anything else
+func ParseLevel(s string) (slog.Level, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug, true
+	case "", "info":
+		return slog.LevelInfo, true
+	case "warn", "warning":
+		return slog.LevelWarn, true
+	case "error":
+		return slog.LevelError, true
+	}
+	return slog.LevelInfo, false
+}
+
+// Setup makes a text handler at the given level the default slog logger and returns the level.
+// An unknown level falls back to info with a warning.
+func Setup(level string) slog.Level {
+	lvl, ok := ParseLevel(level)
+	debug := lvl <= slog.LevelDebug
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+		Level: lvl,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if !debug && sensitiveKeys[strings.ToLower(a.Key)] {
+				return slog.String(a.Key, Redacted)
+			}
+			return a
+		},
+	})
+	// SetDefault would also route the log package through the handler at info,
+	// where a warn or error level drops every log.Printf warning and fatal message
+	flags := log.Flags()
+	slog.SetDefault(slog.New(handler))
+	log.SetOutput(os.Stderr)
+	log.SetFlags(flags)
+	if !ok {
+		slog.Warn("unknown log level, using info", "level", level)
+	}
+	return lvl
+}
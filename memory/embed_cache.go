@@ -0,0 +1,78 @@
+// Embedding cache - recall and capture often embed the same text within one turn
+package memory
+
+import (
+	"container/list"
+	"sync"
+)
+
+// EmbeddingCacheStats counts lookups of the embedding cache since the store opened
+type EmbeddingCacheStats struct {
+	Size     int   `json:"size"`
+	Capacity int   `json:"capacity"`
+	Hits     int64 `json:"hits"`
+	Misses   int64 `json:"misses"`
+}
+
+// embedCache is an LRU of provider vectors keyed by the exact text
+type embedCache struct {
+	mu           sync.Mutex
+	capacity     int
+	ll           *list.List // front = most recently used
+	items        map[string]*list.Element
+	hits, misses int64
+}
+
+type embedCacheEntry struct {
+	text   string
+	vector []float32
+}
+
+func newEmbedCache(capacity int) *embedCache {
+	return &embedCache{capacity: capacity, ll: list.New(), items: make(map[string]*list.Element)}
+}
+
+// get returns a copy of the cached vector; callers normalize vectors in place.
+// A nil cache always misses.
+func (c *embedCache) get(text string) ([]float32, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.items[text]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+	c.hits++
+	c.ll.MoveToFront(el)
+	return append([]float32(nil), el.Value.(*embedCacheEntry).vector...), true
+}
+
+// put stores a copy of vector, evicting the least recently used entry when full
+func (c *embedCache) put(text string, vector []float32) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	vector = append([]float32(nil), vector...)
+	if el, ok := c.items[text]; ok {
+		el.Value.(*embedCacheEntry).vector = vector
+		c.ll.MoveToFront(el)
+		return
+	}
+	c.items[text] = c.ll.PushFront(&embedCacheEntry{text: text, vector: vector})
+	if c.ll.Len() > c.capacity {
+		oldest := c.ll.Back()
+		c.ll.Remove(oldest)
+		delete(c.items, oldest.Value.(*embedCacheEntry).text)
+	}
+}
+
+func (c *embedCache) stats() EmbeddingCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return EmbeddingCacheStats{Size: c.ll.Len(), Capacity: c.capacity, Hits: c.hits, Misses: c.misses}
+}
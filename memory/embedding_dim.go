@@ -0,0 +1,77 @@
+// Embedding dimension guard - vectors from different models cannot share one store
+package memory
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// checkStoredDims compares the embedding_dim of the stored rows with the provider's.
+// Rows of another dimension come from a previous model: the index skips them and
+// searches cannot match them. Instead of running half-broken the store keeps
+// serving reads but refuses writes (see DimMismatch) until Reembed migrates the rows.
+func (s *VectorMemoryStore) checkStoredDims() {
+	if s.embedding == nil {
+		return
+	}
+	rows, err := s.db.Query("SELECT embedding_dim, COUNT(*) FROM vector_memories WHERE embedding_dim > 0 GROUP BY embedding_dim")
+	if err != nil {
+		log.Printf("embedding_dim check skipped: %v", err)
+		return
+	}
+	defer rows.Close()
+
+	stale := make(map[int]int)
+	for rows.Next() {
+		var dim, n int
+		if err := rows.Scan(&dim, &n); err != nil {
+			log.Printf("embedding_dim check scan err: %v", err)
+			return
+		}
+		if dim != s.cfg.EmbeddingDim {
+			stale[dim] = n
+		}
+	}
+	if len(stale) == 0 {
+		return
+	}
+
+	dims := make([]int, 0, len(stale))
+	for dim := range stale {
+		dims = append(dims, dim)
+	}
+	sort.Ints(dims)
+	parts := make([]string, len(dims))
+	total := 0
+	for i, dim := range dims {
+		parts[i] = fmt.Sprintf("%d at dim %d", stale[dim], dim)
+		total += stale[dim]
+	}
+	s.dimErr = fmt.Errorf("embedding dimension mismatch: %d memories were embedded with another model (%s), provider %s returns dim %d; run Reembed or switch back to the previous model",
+		total, strings.Join(parts, ", "), s.embedding.Name(), s.cfg.EmbeddingDim)
+	log.Printf("⚠️ %v", s.dimErr)
+	log.Printf("⚠️ Memory store is read-only until the embedding dimensions agree")
+}
+
+// DimMismatch reports why writes are refused: stored memories whose embedding
+// dimension differs from the provider's. nil when the store is consistent.
+func (s *VectorMemoryStore) DimMismatch() error {
+	return s.dimErr
+}
+
+// checkVectorDim rejects a vector that cannot be stored next to the existing ones
+func (s *VectorMemoryStore) checkVectorDim(vector []float32) error {
+	if s.dimErr != nil {
+		return s.dimErr
+	}
+	// Placeholder vectors (no provider) have no model to disagree with
+	if s.embedding == nil {
+		return nil
+	}
+	if dim := s.embedding.Dim(); dim > 0 && len(vector) != dim {
+		return fmt.Errorf("embedding dimension mismatch: got %d, provider %s declares %d (embedding model changed?)", len(vector), s.embedding.Name(), dim)
+	}
+	return nil
+}
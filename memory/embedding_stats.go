@@ -0,0 +1,148 @@
+// Embedding stats - call count, latency distribution and errors of the embedding provider
+package memory
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// embeddingBuckets are the upper bounds of the latency histogram; slower calls land in "+Inf"
+var embeddingBuckets = []time.Duration{
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// embeddingSamples is how many recent latencies back the percentiles
+const embeddingSamples = 512
+
+// EmbeddingStats is a snapshot of embedding performance since the store opened
+type EmbeddingStats struct {
+	Provider    string          `json:"provider"` // provider name, "placeholder" without one
+	Dim         int             `json:"dim"`
+	Calls       int64           `json:"calls"`
+	Errors      int64           `json:"errors"`
+	Timeouts    int64           `json:"timeouts"`
+	AvgMs       float64         `json:"avgMs"`
+	P50Ms       float64         `json:"p50Ms"` // over the last 512 calls
+	P95Ms       float64         `json:"p95Ms"`
+	MaxMs       float64         `json:"maxMs"`
+	LastError   string          `json:"lastError,omitempty"`
+	LastErrorAt int64           `json:"lastErrorAt,omitempty"`
+	Buckets     []LatencyBucket `json:"buckets"`
+	// Cache is set with Config.EmbeddingCacheSize; hits are not counted as Calls
+	Cache *EmbeddingCacheStats `json:"cache,omitempty"`
+}
+
+// LatencyBucket counts calls that took at most Le ("+Inf" for the rest)
+type LatencyBucket struct {
+	Le    string `json:"le"`
+	Count int64  `json:"count"`
+}
+
+type embeddingStats struct {
+	mu          sync.Mutex
+	calls       int64
+	errors      int64
+	timeouts    int64
+	total       time.Duration
+	max         time.Duration
+	buckets     []int64
+	recent      []time.Duration // ring buffer of the last embeddingSamples latencies
+	next        int
+	lastError   string
+	lastErrorAt time.Time
+}
+
+func (st *embeddingStats) record(took time.Duration, err error) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.buckets == nil {
+		st.buckets = make([]int64, len(embeddingBuckets)+1)
+	}
+	st.calls++
+	st.total += took
+	if took > st.max {
+		st.max = took
+	}
+	i := sort.Search(len(embeddingBuckets), func(i int) bool { return took <= embeddingBuckets[i] })
+	st.buckets[i]++
+	if len(st.recent) < embeddingSamples {
+		st.recent = append(st.recent, took)
+	} else {
+		st.recent[st.next] = took
+		st.next = (st.next + 1) % embeddingSamples
+	}
+	if err != nil {
+		st.errors++
+		msg := err.Error()
+		if strings.Contains(msg, "deadline exceeded") || strings.Contains(msg, "Timeout") {
+			st.timeouts++
+		}
+		st.lastError = msg
+		st.lastErrorAt = time.Now()
+	}
+}
+
+func (st *embeddingStats) snapshot() EmbeddingStats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	out := EmbeddingStats{
+		Calls:     st.calls,
+		Errors:    st.errors,
+		Timeouts:  st.timeouts,
+		MaxMs:     millis(st.max),
+		LastError: st.lastError,
+		Buckets:   make([]LatencyBucket, len(embeddingBuckets)+1),
+	}
+	if !st.lastErrorAt.IsZero() {
+		out.LastErrorAt = st.lastErrorAt.Unix()
+	}
+	if st.calls > 0 {
+		out.AvgMs = millis(st.total / time.Duration(st.calls))
+	}
+	for i := range out.Buckets {
+		out.Buckets[i].Le = "+Inf"
+		if i < len(embeddingBuckets) {
+			out.Buckets[i].Le = embeddingBuckets[i].String()
+		}
+		if st.buckets != nil {
+			out.Buckets[i].Count = st.buckets[i]
+		}
+	}
+	if len(st.recent) > 0 {
+		sorted := append([]time.Duration(nil), st.recent...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		out.P50Ms = millis(sorted[(len(sorted)-1)*50/100])
+		out.P95Ms = millis(sorted[(len(sorted)-1)*95/100])
+	}
+	return out
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// EmbeddingStats reports embedding calls, latency and errors since the store opened
+func (s *VectorMemoryStore) EmbeddingStats() EmbeddingStats {
+	out := s.embedStats.snapshot()
+	out.Provider = "placeholder"
+	out.Dim = s.cfg.EmbeddingDim
+	if s.embedding != nil {
+		out.Provider = s.embedding.Name()
+		out.Dim = s.embedding.Dim()
+	}
+	if s.embedCache != nil {
+		cache := s.embedCache.stats()
+		out.Cache = &cache
+	}
+	return out
+}
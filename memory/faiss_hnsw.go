@@ -32,12 +32,29 @@ type HNSWIndex struct {
 	dim    int
 	mu     sync.Mutex
 	loaded bool
+	// removed holds tombstoned labels; FAISS HNSW cannot delete, so Search skips them
+	removed map[int64]bool
 }
 
 func (idx *HNSWIndex) Config() HNSWConfig {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
 	return idx.cfg
 }
 
+// SetEfSearch changes the search-time ef; it applies to the next query without a rebuild
+func (idx *HNSWIndex) SetEfSearch(ef int) error {
+	if ef <= 0 {
+		return fmt.Errorf("invalid efSearch: %d", ef)
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	C.faiss_hnsw_set_ef_search(idx.ptr, C.int(ef))
+	idx.cfg.EfSearch = ef
+	return nil
+}
+
 // Create a new HNSW index
 func NewHNSWIndex(cfg HNSWConfig) (*HNSWIndex, error) {
 	if cfg.Dim <= 0 {
@@ -69,6 +86,7 @@ func NewHNSWIndex(cfg HNSWConfig) (*HNSWIndex, error) {
 	if ptr == nil {
 		return nil, fmt.Errorf("failed to create HNSW index")
 	}
+	C.faiss_hnsw_set_ef_search(ptr, C.int(cfg.EfSearch))
 
 	idx := &HNSWIndex{
 		ptr:    ptr,
@@ -90,7 +108,7 @@ func NewHNSWIndex(cfg HNSWConfig) (*HNSWIndex, error) {
 		}
 	}
 
-	log.Printf("✅ HNSW index created: dim=%d, M=%d, metric=%s", cfg.Dim, cfg.M, cfg.Distance)
+	log.Printf("✅ HNSW index created: dim=%d, M=%d, efSearch=%d, metric=%s", cfg.Dim, cfg.M, cfg.EfSearch, cfg.Distance)
 
 	return idx, nil
 }
@@ -124,6 +142,28 @@ func (idx *HNSWIndex) Add(vectors [][]float32) error {
 
 // Search nearest neighbors
 func (idx *HNSWIndex) Search(query []float32, k int) (distances []float32, labels []int64, err error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return idx.searchLocked(query, k)
+}
+
+// SearchWithEf searches with a one-off efSearch (ef <= 0 uses the configured one);
+// the index setting is restored before the lock is released
+func (idx *HNSWIndex) SearchWithEf(query []float32, k, ef int) ([]float32, []int64, error) {
+	if ef <= 0 {
+		return idx.Search(query, k)
+	}
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	C.faiss_hnsw_set_ef_search(idx.ptr, C.int(ef))
+	defer C.faiss_hnsw_set_ef_search(idx.ptr, C.int(idx.cfg.EfSearch))
+	return idx.searchLocked(query, k)
+}
+
+// searchLocked runs the k-NN query (caller holds mu)
+func (idx *HNSWIndex) searchLocked(query []float32, k int) (distances []float32, labels []int64, err error) {
 	if k <= 0 {
 		k = 5
 	}
@@ -132,33 +172,60 @@ func (idx *HNSWIndex) Search(query []float32, k int) (distances []float32, label
 		return nil, nil, fmt.Errorf("query dimension mismatch: got %d, expected %d", len(query), idx.dim)
 	}
 
-	idx.mu.Lock()
-	defer idx.mu.Unlock()
-
 	// Prepare query vector
 	queryData := make([]C.float, idx.dim)
 	for i, f := range query {
 		queryData[i] = C.float(f)
 	}
 
+	// Over-fetch by the tombstone count so k live results remain after filtering
+	n := k + len(idx.removed)
+
 	// Allocate result buffers
-	distBuf := make([]C.float, k)
-	labelBuf := make([]C.long, k)
+	distBuf := make([]C.float, n)
+	labelBuf := make([]C.long, n)
 
 	// Search
-	C.faiss_hnsw_search(idx.ptr, &queryData[0], C.int(k), &distBuf[0], &labelBuf[0])
+	C.faiss_hnsw_search(idx.ptr, &queryData[0], C.int(n), &distBuf[0], &labelBuf[0])
 
 	// Convert results
-	distances = make([]float32, k)
-	labels = make([]int64, k)
-	for i := 0; i < k; i++ {
-		distances[i] = float32(distBuf[i])
-		labels[i] = int64(labelBuf[i])
+	distances = make([]float32, 0, k)
+	labels = make([]int64, 0, k)
+	for i := 0; i < n && len(labels) < k; i++ {
+		label := int64(labelBuf[i])
+		if idx.removed[label] {
+			continue
+		}
+		distances = append(distances, float32(distBuf[i]))
+		labels = append(labels, label)
 	}
 
 	return distances, labels, nil
 }
 
+// Remove tombstones label: the vector stays in the graph but is no longer returned
+func (idx *HNSWIndex) Remove(label int) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if label < 0 || int64(label) >= int64(C.faiss_hnsw_count(idx.ptr)) {
+		return fmt.Errorf("label %d out of range", label)
+	}
+	if idx.removed == nil {
+		idx.removed = make(map[int64]bool)
+	}
+	idx.removed[int64(label)] = true
+	return nil
+}
+
+// Removed returns the number of tombstoned labels
+func (idx *HNSWIndex) Removed() int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	return len(idx.removed)
+}
+
 // Search and return scores (already converted)
 func (idx *HNSWIndex) SearchWithScores(query []float32, k int) ([]float32, []int64, error) {
 	distances, labels, err := idx.Search(query, k)
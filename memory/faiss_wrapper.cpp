@@ -79,6 +79,11 @@ struct HNSWIndexWrapper {
         return index->ntotal;
     }
     
+    void set_ef_search(int efSearch) {
+        // Search-time parameter: takes effect on the next query, no rebuild
+        index->hnsw.efSearch = efSearch;
+    }
+    
     void save(const char* path) {
         // Save index
         std::ofstream out(path, std::ios::binary);
@@ -163,6 +168,13 @@ long faiss_hnsw_count(void* ptr) {
     return idx->count();
 }
 
+// Set search-time ef
+void faiss_hnsw_set_ef_search(void* ptr, int efSearch) {
+    if (!ptr || efSearch <= 0) return;
+    HNSWIndexWrapper* idx = static_cast<HNSWIndexWrapper*>(ptr);
+    idx->set_ef_search(efSearch);
+}
+
 // Save index
 void faiss_hnsw_save(void* ptr, const char* path) {
     if (!ptr || !path) return;
@@ -9,6 +9,7 @@ void  faiss_hnsw_train(void* ptr, int n, float* data);
 void  faiss_hnsw_add(void* ptr, int n, float* data);
 void  faiss_hnsw_search(void* ptr, float* query, int k, float* distances, long* labels);
 long  faiss_hnsw_count(void* ptr);
+void  faiss_hnsw_set_ef_search(void* ptr, int efSearch);
 void  faiss_hnsw_save(void* ptr, const char* path);
 void  faiss_hnsw_load(void* ptr, const char* path);
 void  faiss_hnsw_delete(void* ptr);
@@ -0,0 +1,85 @@
+// FTS query building - turn free text into a forgiving FTS5 expression
+package memory
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// FTS query modes (Config.FTSMode)
+const (
+	// FTSFuzzy rewrites the query: terms get prefix matching and are OR-combined,
+	// with a NEAR group ranking passages that mention them close together
+	FTSFuzzy = "fuzzy"
+	// FTSRaw passes the query to MATCH unchanged (FTS5 syntax, for power users)
+	FTSRaw = "raw"
+)
+
+// Fuzzy query limits
+const (
+	ftsMaxTerms     = 8  // terms beyond this are dropped
+	ftsNearDistance = 10 // max tokens between terms of the NEAR group
+	ftsMinPrefix    = 3  // shorter terms match exactly
+)
+
+// ftsExpression returns the MATCH expression for query under the store's FTS mode
+func (s *VectorMemoryStore) ftsExpression(query string) string {
+	if s.cfg.FTSMode == FTSRaw {
+		return query
+	}
+	return fuzzyFTSQuery(query)
+}
+
+// fuzzyFTSQuery builds `{text category} : (NEAR(a* b*, 10) OR a* OR b*)` from free text.
+// Long terms keep about two thirds before the prefix star so a typo in the tail
+// still matches ("deploymnet" -> "deploym"*). Returns "" when the query has no searchable terms.
+func fuzzyFTSQuery(query string) string {
+	terms := ftsTerms(query)
+	if len(terms) == 0 {
+		return ""
+	}
+	phrases := make([]string, len(terms))
+	for i, t := range terms {
+		phrases[i] = ftsPhrase(t)
+	}
+	var parts []string
+	if len(phrases) > 1 {
+		parts = append(parts, "NEAR("+strings.Join(phrases, " ")+", "+strconv.Itoa(ftsNearDistance)+")")
+	}
+	parts = append(parts, phrases...)
+	return "{text category} : (" + strings.Join(parts, " OR ") + ")"
+}
+
+// ftsTerms splits query into lower-case word tokens (2+ chars), deduplicated, in order
+func ftsTerms(query string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := make(map[string]bool, len(fields))
+	terms := make([]string, 0, len(fields))
+	for _, f := range fields {
+		if seen[f] || len([]rune(f)) < 2 {
+			continue
+		}
+		seen[f] = true
+		terms = append(terms, f)
+		if len(terms) == ftsMaxTerms {
+			break
+		}
+	}
+	return terms
+}
+
+// ftsPhrase quotes a term (tokens are letters/digits only, so no escaping is needed)
+// and adds a prefix star to terms long enough for it to be selective
+func ftsPhrase(term string) string {
+	runes := []rune(term)
+	if len(runes) < ftsMinPrefix {
+		return `"` + term + `"`
+	}
+	if len(runes) >= 6 {
+		runes = runes[:(2*len(runes)+2)/3]
+	}
+	return `"` + string(runes) + `"*`
+}
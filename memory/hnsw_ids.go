@@ -0,0 +1,109 @@
+// HNSW label mapping - the memory ID of every index label, saved next to the index file
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+)
+
+// hnswIDsPath is the sidecar of an index file: <index>.ids.json
+func hnswIDsPath(indexPath string) string {
+	return indexPath + ".ids.json"
+}
+
+// saveHNSWIDs writes ids (label order, "" = tombstoned) via a temp file and rename
+func saveHNSWIDs(path string, ids []string) error {
+	if ids == nil {
+		ids = []string{}
+	}
+	data, err := json.Marshal(ids)
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func loadHNSWIDs(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	if err := json.Unmarshal(data, &ids); err != nil {
+		return nil, fmt.Errorf("invalid id mapping: %v", err)
+	}
+	return ids, nil
+}
+
+// mapLoadedIndex lines a loaded index up with the current rows using the saved
+// mapping at idsPath: labels of memories that no longer exist are tombstoned and
+// rows the index does not know yet are added. It reports false when the mapping is
+// missing or does not match the index, so the caller rebuilds.
+func (s *VectorMemoryStore) mapLoadedIndex(idsPath string, ids []string, vectors [][]float32) bool {
+	saved, err := loadHNSWIDs(idsPath)
+	if err != nil {
+		log.Printf("⚠️ HNSW id mapping unavailable (%v), rebuilding index", err)
+		return false
+	}
+	if n := s.hnsw.Count(); int64(len(saved)) != n {
+		log.Printf("⚠️ HNSW id mapping has %d labels, index has %d; rebuilding index", len(saved), n)
+		return false
+	}
+
+	rows := make(map[string]int, len(ids))
+	for i, id := range ids {
+		rows[id] = i
+	}
+	tombstoned := 0
+	for label, id := range saved {
+		if _, ok := rows[id]; ok {
+			delete(rows, id) // a later duplicate label is dropped too
+			continue
+		}
+		if id != "" {
+			tombstoned++
+		}
+		saved[label] = ""
+		s.hnsw.Remove(label)
+	}
+
+	// Rows written after the index was saved (another process, or a crash before the save)
+	var missing [][]float32
+	for i, id := range ids {
+		if _, ok := rows[id]; ok {
+			missing = append(missing, vectors[i])
+			saved = append(saved, id)
+		}
+	}
+	if len(missing) > 0 {
+		if err := s.hnsw.Add(missing); err != nil {
+			log.Printf("⚠️ HNSW catch-up add failed (%v), rebuilding index", err)
+			return false
+		}
+	}
+	s.hnswIDs = saved
+	log.Printf("HNSW loaded from disk: %d labels, %d added, %d newly tombstoned", len(saved), len(missing), tombstoned)
+	return true
+}
+
+// freshIndex replaces the loaded index with an empty one of the same config
+func (s *VectorMemoryStore) freshIndex() error {
+	cfg := s.hnsw.Config()
+	storage := cfg.StoragePath
+	cfg.StoragePath = "" // do not load the file again
+	s.hnsw.Close()
+	idx, err := NewHNSWIndex(cfg)
+	if err != nil {
+		s.hnsw = nil
+		return err
+	}
+	idx.cfg.StoragePath = storage
+	s.hnsw = idx
+	return nil
+}
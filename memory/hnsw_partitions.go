@@ -0,0 +1,131 @@
+// Per-category HNSW partitions - category-scoped search only walks that category's graph
+package memory
+
+import "log"
+
+// hnswPartition is an in-memory HNSW index over the vectors of one category
+type hnswPartition struct {
+	index *HNSWIndex
+	ids   []string // partition label -> memory ID
+}
+
+// partitionsEnabled reports whether category searches are routed to partitions.
+// Partitions sit next to the main index, which still serves unscoped searches.
+func (s *VectorMemoryStore) partitionsEnabled() bool {
+	return s.cfg.HNSWPartitioned && s.hnsw != nil
+}
+
+// buildPartitions replaces all partitions with the given rows (same order as the main index)
+func (s *VectorMemoryStore) buildPartitions(ids, categories []string, vectors [][]float32) {
+	s.resetPartitions()
+	if !s.partitionsEnabled() {
+		return
+	}
+	s.addToPartitions(ids, categories, vectors)
+	s.partMu.RLock()
+	n := len(s.partitions)
+	s.partMu.RUnlock()
+	if n > 0 {
+		log.Printf("HNSW partitions built: %d categories, %d vectors", n, len(ids))
+	}
+}
+
+// addToPartitions routes each vector to its category's partition, creating it on first use.
+// A partition that fails to take a vector is dropped; that category falls back to the main index.
+func (s *VectorMemoryStore) addToPartitions(ids, categories []string, vectors [][]float32) {
+	if !s.partitionsEnabled() || len(ids) == 0 {
+		return
+	}
+	byCat := make(map[string][]int)
+	for i := range ids {
+		byCat[categories[i]] = append(byCat[categories[i]], i)
+	}
+
+	s.partMu.Lock()
+	defer s.partMu.Unlock()
+	if s.partitions == nil {
+		s.partitions = make(map[string]*hnswPartition)
+	}
+	for cat, idxs := range byCat {
+		p := s.partitions[cat]
+		if p == nil {
+			cfg := s.hnsw.Config()
+			cfg.StoragePath = "" // rebuilt from SQLite on load, never persisted
+			idx, err := NewHNSWIndex(cfg)
+			if err != nil {
+				log.Printf("HNSW partition %q init failed: %v", cat, err)
+				continue
+			}
+			p = &hnswPartition{index: idx}
+			s.partitions[cat] = p
+		}
+		batch := make([][]float32, len(idxs))
+		for j, i := range idxs {
+			batch[j] = vectors[i]
+		}
+		if err := p.index.Add(batch); err != nil {
+			log.Printf("HNSW partition %q add failed, dropping it: %v", cat, err)
+			p.index.Close()
+			delete(s.partitions, cat)
+			continue
+		}
+		for _, i := range idxs {
+			p.ids = append(p.ids, ids[i])
+		}
+	}
+}
+
+// resetPartitions closes and forgets every partition
+func (s *VectorMemoryStore) resetPartitions() {
+	s.partMu.Lock()
+	defer s.partMu.Unlock()
+	for _, p := range s.partitions {
+		p.index.Close()
+	}
+	s.partitions = nil
+}
+
+// partitionSearch searches the category's partition. ok is false when the
+// category has to be served another way (partitions off, or the partition was dropped).
+func (s *VectorMemoryStore) partitionSearch(category string, queryVec []float32, limit int, minScore float32, ef int) (results []MemoryResult, ok bool, err error) {
+	if !s.partitionsEnabled() {
+		return nil, false, nil
+	}
+	s.partMu.RLock()
+	p := s.partitions[category]
+	if p == nil {
+		s.partMu.RUnlock()
+		if s.hasCategory(category) {
+			return nil, false, nil
+		}
+		return []MemoryResult{}, true, nil
+	}
+	// Held while labels map to IDs, since addToPartitions appends to p.ids
+	defer s.partMu.RUnlock()
+	if p.index.Count() == 0 {
+		return []MemoryResult{}, true, nil
+	}
+	results, err = s.searchIndex(p.index, p.ids, queryVec, limit, minScore, ef)
+	return results, true, err
+}
+
+// hasCategory reports whether any memory is stored under category
+func (s *VectorMemoryStore) hasCategory(category string) bool {
+	var one int
+	err := s.db.QueryRow("SELECT 1 FROM vector_memories WHERE category = ? LIMIT 1", category).Scan(&one)
+	return err == nil
+}
+
+// PartitionCounts returns the live vector count per category partition (nil when partitions are off)
+func (s *VectorMemoryStore) PartitionCounts() map[string]int64 {
+	if !s.partitionsEnabled() {
+		return nil
+	}
+	s.partMu.RLock()
+	defer s.partMu.RUnlock()
+	out := make(map[string]int64, len(s.partitions))
+	for cat, p := range s.partitions {
+		out[cat] = p.index.Count() - int64(p.index.Removed())
+	}
+	return out
+}
@@ -23,6 +23,14 @@ type HNSWIndex struct{ cfg HNSWConfig }
 
 func (idx *HNSWIndex) Config() HNSWConfig { return idx.cfg }
 
+func (idx *HNSWIndex) SetEfSearch(ef int) error {
+	if ef <= 0 {
+		return fmt.Errorf("invalid efSearch: %d", ef)
+	}
+	idx.cfg.EfSearch = ef
+	return nil
+}
+
 func NewHNSWIndex(cfg HNSWConfig) (*HNSWIndex, error) {
 	return nil, fmt.Errorf("FAISS not enabled (build without -tags faiss)")
 }
@@ -40,10 +48,18 @@ func (idx *HNSWIndex) Search(query []float32, k int) ([]float32, []int64, error)
 	return nil, nil, fmt.Errorf("FAISS not enabled (build without -tags faiss)")
 }
 
+func (idx *HNSWIndex) SearchWithEf(query []float32, k, ef int) ([]float32, []int64, error) {
+	return nil, nil, fmt.Errorf("FAISS not enabled (build without -tags faiss)")
+}
+
 func (idx *HNSWIndex) SearchWithScores(query []float32, k int) ([]float32, []int64, error) {
 	return nil, nil, fmt.Errorf("FAISS not enabled (build without -tags faiss)")
 }
 
+func (idx *HNSWIndex) Remove(label int) error { return nil }
+
+func (idx *HNSWIndex) Removed() int { return 0 }
+
 func (idx *HNSWIndex) Metric() string { return "" }
 
 func (idx *HNSWIndex) Dim() int { return idx.cfg.Dim }
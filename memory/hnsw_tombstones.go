@@ -0,0 +1,113 @@
+// HNSW tombstones - deletes and re-embeds retire single labels instead of rebuilding the index
+package memory
+
+import "log"
+
+// DefaultHNSWCompactAfter is the tombstone count that triggers a rebuild when Config.HNSWCompactAfter is 0
+const DefaultHNSWCompactAfter = 1000
+
+// dropFromIndex retires a deleted memory's vector, rebuilding only when the label is unknown
+func (s *VectorMemoryStore) dropFromIndex(id, category string) {
+	s.lockIndex()
+	defer s.unlockIndex()
+	if !s.unindex(id, category) {
+		s.rebuildHNSWLocked()
+		return
+	}
+	s.afterUnindex()
+}
+
+// replaceInIndex swaps in a re-embedded vector for id
+func (s *VectorMemoryStore) replaceInIndex(id, oldCategory, category string, vector []float32) {
+	s.lockIndex()
+	defer s.unlockIndex()
+	if !s.reindex(id, oldCategory, category, vector) {
+		s.rebuildHNSWLocked()
+		return
+	}
+	s.afterUnindex()
+}
+
+// movePartition moves id between category partitions; the main index is unaffected
+func (s *VectorMemoryStore) movePartition(id, oldCategory, category string, vector []float32) {
+	if !s.partitionsEnabled() {
+		return
+	}
+	s.removeFromPartition(id, oldCategory)
+	s.addToPartitions([]string{id}, []string{category}, [][]float32{vector})
+}
+
+// unindex tombstones id in the main index and in its category partition, under lockIndex.
+// It reports false when id has no label, so the caller can fall back to rebuildHNSW.
+func (s *VectorMemoryStore) unindex(id, category string) bool {
+	if s.hnsw == nil {
+		return true
+	}
+	label := indexOf(s.hnswIDs, id)
+	if label < 0 {
+		return false
+	}
+	if err := s.hnsw.Remove(label); err != nil {
+		log.Printf("HNSW remove %s failed: %v", shortID(id), err)
+		return false
+	}
+	s.hnswIDs[label] = ""
+
+	s.removeFromPartition(id, category)
+	return true
+}
+
+// removeFromPartition tombstones id in its category partition (partitions are never saved)
+func (s *VectorMemoryStore) removeFromPartition(id, category string) {
+	s.partMu.Lock()
+	defer s.partMu.Unlock()
+	if p := s.partitions[category]; p != nil {
+		if i := indexOf(p.ids, id); i >= 0 && p.index.Remove(i) == nil {
+			p.ids[i] = ""
+		}
+	}
+}
+
+// reindex replaces id's vector: the old label is tombstoned and the new vector appended
+func (s *VectorMemoryStore) reindex(id, oldCategory, category string, vector []float32) bool {
+	if s.hnsw == nil {
+		return true
+	}
+	if !s.unindex(id, oldCategory) {
+		return false
+	}
+	if err := s.hnsw.Add([][]float32{vector}); err != nil {
+		log.Printf("HNSW re-add %s failed: %v", shortID(id), err)
+		return false
+	}
+	s.hnswIDs = append(s.hnswIDs, id)
+	s.addToPartitions([]string{id}, []string{category}, [][]float32{vector})
+	return true
+}
+
+// afterUnindex compacts once enough tombstones piled up, and otherwise saves the
+// index with its tombstoned labels
+func (s *VectorMemoryStore) afterUnindex() {
+	if s.hnsw == nil {
+		return
+	}
+	limit := s.cfg.HNSWCompactAfter
+	if limit <= 0 {
+		limit = DefaultHNSWCompactAfter
+	}
+	if removed := s.hnsw.Removed(); removed >= limit {
+		log.Printf("HNSW compaction: %d tombstones", removed)
+		s.rebuildHNSWLocked()
+		return
+	}
+	s.saveHNSW()
+}
+
+func indexOf(ids []string, id string) int {
+	for i, v := range ids {
+		if v == id {
+			return i
+		}
+	}
+	return -1
+}
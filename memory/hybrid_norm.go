@@ -0,0 +1,135 @@
+// Hybrid score normalization - put vector and keyword scores on one scale before weighting
+package memory
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strings"
+)
+
+// Config.HybridNorm modes
+const (
+	// HybridNormMinMax divides each component by max(best candidate, 1) (default)
+	HybridNormMinMax = "minmax"
+	// HybridNormZScore standardizes each component and squashes it with a logistic, so
+	// one outlier does not push everything else towards 0
+	HybridNormZScore = "zscore"
+	// HybridNormNone keeps raw cosine and 1/(1+max(0,bm25)) (scoring before normalization)
+	HybridNormNone = "none"
+)
+
+// Hybrid weights used when Config.WeightsSet is false and a weight is 0
+const (
+	DefaultVectorWeight = 0.7
+	DefaultTextWeight   = 0.3
+)
+
+// parseHybridNorm resolves Config.HybridNorm ("" = HybridNormMinMax)
+func parseHybridNorm(mode string) (string, error) {
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	switch mode {
+	case "":
+		return HybridNormMinMax, nil
+	case HybridNormMinMax, HybridNormZScore, HybridNormNone:
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid hybrid normalization %q (want minmax, zscore or none)", mode)
+}
+
+// fuseHybrid combines the vector scores and keyword relevances (higher = better)
+// of the candidates into VectorWeight*vector + TextWeight*text, normalizing both
+// first unless HybridNormNone. A candidate only one search returned scores 0 on the other.
+// Each component is clamped to [ScoreFloor,1], so a fused score lies in
+// [0, VectorWeight+TextWeight]; a candidate with a NaN or infinite component is
+// left out of the result.
+func (s *VectorMemoryStore) fuseHybrid(vecScores, textRel map[string]float32) map[string]float32 {
+	skip := dropNonFinite(vecScores, "vector")
+	for id := range dropNonFinite(textRel, "keyword") {
+		skip[id] = true
+	}
+	if s.cfg.HybridNorm != HybridNormNone {
+		normalizeScores(vecScores, s.cfg.HybridNorm)
+		normalizeScores(textRel, s.cfg.HybridNorm)
+	}
+	fused := make(map[string]float32, len(vecScores)+len(textRel))
+	for id, v := range vecScores {
+		fused[id] = s.cfg.VectorWeight * s.clampScore(v)
+	}
+	for id, t := range textRel {
+		fused[id] += s.cfg.TextWeight * s.clampScore(t)
+	}
+	for id := range skip {
+		delete(fused, id)
+	}
+	return fused
+}
+
+// dropNonFinite removes NaN and infinite scores before they reach normalization
+// (one NaN would turn every min-max score into NaN) and returns their ids
+func dropNonFinite(scores map[string]float32, kind string) map[string]bool {
+	bad := make(map[string]bool)
+	for id, v := range scores {
+		if f := float64(v); math.IsNaN(f) || math.IsInf(f, 0) {
+			log.Printf("⚠️ Hybrid search: %s score of %s is %v, result skipped", kind, id, v)
+			delete(scores, id)
+			bad[id] = true
+		}
+	}
+	return bad
+}
+
+// clampScore bounds one normalized component to [ScoreFloor,1]
+func (s *VectorMemoryStore) clampScore(v float32) float32 {
+	return minf(maxf(v, s.cfg.ScoreFloor), 1)
+}
+
+// normalizeScores rescales scores in place to [0,1]; higher must mean better.
+// The scale is fixed rather than taken from the candidate set alone: scores are
+// divided by max(best, 1), so cosine similarities stay as they are and a set of
+// weak matches is not stretched until its best one scores 1. Negative scores map
+// below 0 and are clamped later. Z-scores order the candidates the same way but
+// are capped at that fixed-scale best, and a set without spread keeps the
+// fixed-scale value.
+func normalizeScores(scores map[string]float32, mode string) {
+	if len(scores) == 0 {
+		return
+	}
+	hi := float32(-math.MaxFloat32)
+	for _, v := range scores {
+		hi = maxf(hi, v)
+	}
+	scale := maxf(hi, 1)
+	switch mode {
+	case HybridNormZScore:
+		var sum, sq float64
+		for _, v := range scores {
+			sum += float64(v)
+		}
+		mean := sum / float64(len(scores))
+		for _, v := range scores {
+			sq += (float64(v) - mean) * (float64(v) - mean)
+		}
+		std := math.Sqrt(sq / float64(len(scores)))
+		top := maxf(hi/scale, 0)
+		for id, v := range scores {
+			if std == 0 {
+				scores[id] = v / scale
+				continue
+			}
+			z := (float64(v) - mean) / std
+			scores[id] = top * float32(1/(1+math.Exp(-z)))
+		}
+	default:
+		for id, v := range scores {
+			scores[id] = v / scale
+		}
+	}
+}
+
+func minf(a float32, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
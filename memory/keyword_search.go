@@ -0,0 +1,120 @@
+// Keyword search - term matching without embeddings, for when vector similarity misses
+package memory
+
+import (
+	"sort"
+	"strings"
+)
+
+// keywordMinTerm is the shortest term the LIKE fallback matches on; shorter
+// words hit nearly every row
+const keywordMinTerm = 3
+
+// KeywordSearch ranks memories by the query's terms alone: FTS5 bm25 when the
+// table exists, otherwise the share of terms each text contains. Score is 0-1
+// (bm25 relative to the best hit, or that share), not a cosine similarity, so
+// vector min scores do not apply to it.
+func (s *VectorMemoryStore) KeywordSearch(query string, limit int) ([]MemoryResult, error) {
+	if limit <= 0 {
+		limit = s.cfg.MaxResults
+	}
+	if s.ftsAvailable {
+		return s.ftsKeywordSearch(query, limit)
+	}
+	return s.likeKeywordSearch(query, limit)
+}
+
+func (s *VectorMemoryStore) ftsKeywordSearch(query string, limit int) ([]MemoryResult, error) {
+	bm25, err := s.ftsSearch(query, searchFilter{}, limit)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]MemoryResult, 0, len(bm25))
+	var best float32
+	for id, v := range bm25 {
+		entry, err := s.getByID(id)
+		if err != nil || entry.Text == "" {
+			continue
+		}
+		entry.Vector = nil
+		// bm25() is negative for matches, lower is better
+		rel := -v
+		if rel > best {
+			best = rel
+		}
+		results = append(results, MemoryResult{Entry: entry, Score: rel, Matched: true})
+	}
+	for i := range results {
+		if best > 0 {
+			results[i].Score /= best
+		} else {
+			results[i].Score = 1
+		}
+	}
+	sortKeywordResults(results)
+	return results, nil
+}
+
+func (s *VectorMemoryStore) likeKeywordSearch(query string, limit int) ([]MemoryResult, error) {
+	var terms []string
+	for _, t := range ftsTerms(query) {
+		if len([]rune(t)) >= keywordMinTerm {
+			terms = append(terms, t)
+		}
+	}
+	if len(terms) == 0 {
+		return []MemoryResult{}, nil
+	}
+	conds := make([]string, len(terms))
+	args := make([]interface{}, 0, len(terms)+1)
+	for i, t := range terms {
+		conds[i] = "LOWER(text) LIKE ?"
+		args = append(args, "%"+t+"%")
+	}
+	// Candidates are over-fetched: the SQL order is by importance, the ranking by terms matched
+	rows, err := s.db.Query(`
+		SELECT id, text, importance, category, source, COALESCE(external_id, ''), created_at, updated_at
+		FROM vector_memories
+		WHERE `+strings.Join(conds, " OR ")+`
+		ORDER BY importance DESC, created_at DESC
+		LIMIT ?
+	`, append(args, limit*s.cfg.CandidateMult)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []MemoryResult
+	for rows.Next() {
+		var entry MemoryEntry
+		if err := rows.Scan(&entry.ID, &entry.Text, &entry.Importance, &entry.Category, &entry.Source, &entry.ExternalID, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
+			return nil, err
+		}
+		text := strings.ToLower(entry.Text)
+		hits := 0
+		for _, t := range terms {
+			if strings.Contains(text, t) {
+				hits++
+			}
+		}
+		results = append(results, MemoryResult{Entry: entry, Score: float32(hits) / float32(len(terms)), Matched: true})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sortKeywordResults(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// sortKeywordResults orders by score, then importance (stable for equal rows)
+func sortKeywordResults(results []MemoryResult) {
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Entry.Importance > results[j].Entry.Importance
+	})
+}
@@ -0,0 +1,135 @@
+// Result projection - lightweight search results for lists and browsing UIs
+package memory
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ResultFields are the fields a Projection can select, in output order
+var ResultFields = []string{"id", "text", "snippet", "category", "importance", "score", "matched", "source", "externalId", "createdAt", "updatedAt"}
+
+// DefaultSnippetLen is the snippet length when Projection.SnippetLen is 0
+const DefaultSnippetLen = 160
+
+// Projection selects the fields of a search result item. The zero value keeps every
+// field except snippet, which is only produced on request.
+type Projection struct {
+	Fields     []string
+	SnippetLen int // characters of text in "snippet" (0 = DefaultSnippetLen)
+}
+
+// ParseProjection reads a comma-separated field list ("" = all fields) and rejects
+// unknown names
+func ParseProjection(fields string, snippetLen int) (Projection, error) {
+	p := Projection{SnippetLen: snippetLen}
+	if snippetLen < 0 {
+		return p, fmt.Errorf("invalid snippet length %d", snippetLen)
+	}
+	for _, f := range strings.Split(fields, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !containsField(f) {
+			return p, fmt.Errorf("unknown result field %q (want %s)", f, strings.Join(ResultFields, ", "))
+		}
+		p.Fields = append(p.Fields, f)
+	}
+	return p, nil
+}
+
+func containsField(f string) bool {
+	for _, name := range ResultFields {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Has reports whether the projection includes field
+func (p Projection) Has(field string) bool {
+	if len(p.Fields) == 0 {
+		return field != "snippet"
+	}
+	for _, f := range p.Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Item renders r with the selected fields; times as "2006-01-02 15:04", score with 4 decimals
+func (p Projection) Item(r MemoryResult) map[string]interface{} {
+	item := make(map[string]interface{}, len(ResultFields))
+	for _, f := range ResultFields {
+		if !p.Has(f) {
+			continue
+		}
+		switch f {
+		case "id":
+			item[f] = r.Entry.ID
+		case "text":
+			item[f] = r.Entry.Text
+		case "snippet":
+			item[f] = Snippet(r.Entry.Text, p.SnippetLen)
+		case "category":
+			item[f] = r.Entry.Category
+		case "importance":
+			item[f] = r.Entry.Importance
+		case "score":
+			item[f] = fmt.Sprintf("%.4f", r.Score)
+		case "matched":
+			item[f] = r.Matched
+		case "source":
+			item[f] = r.Entry.Source
+		case "externalId":
+			item[f] = r.Entry.ExternalID
+		case "createdAt":
+			item[f] = time.Unix(r.Entry.CreatedAt, 0).Format("2006-01-02 15:04")
+		case "updatedAt":
+			item[f] = time.Unix(r.Entry.UpdatedAt, 0).Format("2006-01-02 15:04")
+		}
+	}
+	return item
+}
+
+// trim strips what the projection does not need from results in place (nil = keep all)
+func (p *Projection) trim(results []MemoryResult) []MemoryResult {
+	if p == nil {
+		return results
+	}
+	for i := range results {
+		e := &results[i].Entry
+		e.Vector = nil
+		switch {
+		case p.Has("text"):
+		case p.Has("snippet"):
+			e.Text = Snippet(e.Text, p.SnippetLen)
+		default:
+			e.Text = ""
+		}
+	}
+	return results
+}
+
+// Snippet shortens text to at most n characters (0 = DefaultSnippetLen), cutting on a
+// word boundary when one is near and ending with "…". Snippet of a snippet is a no-op.
+func Snippet(text string, n int) string {
+	if n <= 0 {
+		n = DefaultSnippetLen
+	}
+	text = strings.Join(strings.Fields(text), " ")
+	runes := []rune(text)
+	if len(runes) <= n {
+		return text
+	}
+	cut := string(runes[:n-1])
+	if i := strings.LastIndex(cut, " "); i > len(cut)*2/3 {
+		cut = cut[:i]
+	}
+	return cut + "…"
+}
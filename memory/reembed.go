@@ -0,0 +1,155 @@
+// Re-embedding - migrate every stored memory to the current embedding model
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"strconv"
+)
+
+// Progress of a running Reembed, kept in the config table (shared with storage)
+// under this section so an interrupted migration resumes where it stopped
+const (
+	reembedSection   = "memory_reembed"
+	reembedTargetKey = "target" // provider name and dim the rows are migrated to
+	reembedCursorKey = "cursor" // rowid of the last re-embedded row
+)
+
+// Reembed re-embeds the text of every memory with the configured provider, in
+// batches of embedBatchSize, and rewrites vector, vector_norm and embedding_dim.
+// Each batch commits together with its progress, so a cancelled or failed run
+// continues after the last finished batch when called again with the same model.
+// The HNSW index is rebuilt once at the end and a dimension mismatch is cleared.
+func (s *VectorMemoryStore) Reembed(ctx context.Context) error {
+	if s.embedding == nil {
+		return fmt.Errorf("reembed needs an embedding provider")
+	}
+	s.Flush()
+	if err := s.ensureConfigTable(); err != nil {
+		return fmt.Errorf("reembed progress table: %v", err)
+	}
+
+	dim := s.embedding.Dim()
+	target := fmt.Sprintf("%s/%d", s.embedding.Name(), dim)
+	var cursor int64
+	if prev, _ := s.reembedProgress(reembedTargetKey); prev == target {
+		if v, _ := s.reembedProgress(reembedCursorKey); v != "" {
+			cursor, _ = strconv.ParseInt(v, 10, 64)
+		}
+	}
+	if cursor > 0 {
+		log.Printf("Resuming re-embed to %s after row %d", target, cursor)
+	}
+	if _, err := s.db.Exec("INSERT OR REPLACE INTO config (section, key, value, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)", reembedSection, reembedTargetKey, target); err != nil {
+		return fmt.Errorf("save reembed progress: %v", err)
+	}
+
+	var total int
+	s.db.QueryRow("SELECT COUNT(*) FROM vector_memories WHERE rowid > ?", cursor).Scan(&total)
+	done := 0
+	for {
+		if err := ctx.Err(); err != nil {
+			log.Printf("⚠️ Re-embed stopped after %d/%d memories: %v", done, total, err)
+			return err
+		}
+		rowids, texts, err := s.reembedBatch(cursor)
+		if err != nil {
+			return err
+		}
+		if len(rowids) == 0 {
+			break
+		}
+		vectors, err := s.getEmbeddings(texts)
+		if err != nil {
+			return fmt.Errorf("embedding failed: %v", err)
+		}
+		for _, v := range vectors {
+			if dim > 0 && len(v) != dim {
+				return fmt.Errorf("embedding dimension mismatch: got %d, provider %s declares %d", len(v), s.embedding.Name(), dim)
+			}
+		}
+		if err := s.writeReembedded(rowids, vectors); err != nil {
+			return err
+		}
+		cursor = rowids[len(rowids)-1]
+		done += len(rowids)
+		log.Printf("Re-embedded %d/%d memories", done, total)
+	}
+
+	s.db.Exec("DELETE FROM config WHERE section = ?", reembedSection)
+	s.dimErr = nil
+	s.rebuildHNSW()
+	log.Printf("✅ Re-embed to %s complete: %d memories", target, done)
+	return nil
+}
+
+// reembedBatch reads the next rows after cursor in rowid order
+func (s *VectorMemoryStore) reembedBatch(cursor int64) ([]int64, []string, error) {
+	rows, err := s.db.Query("SELECT rowid, text FROM vector_memories WHERE rowid > ? ORDER BY rowid LIMIT ?", cursor, embedBatchSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	var rowids []int64
+	var texts []string
+	for rows.Next() {
+		var rowid int64
+		var text string
+		if err := rows.Scan(&rowid, &text); err != nil {
+			return nil, nil, err
+		}
+		rowids = append(rowids, rowid)
+		texts = append(texts, text)
+	}
+	return rowids, texts, rows.Err()
+}
+
+// writeReembedded replaces the vectors of one batch and advances the cursor in the same transaction
+func (s *VectorMemoryStore) writeReembedded(rowids []int64, vectors [][]float32) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare("UPDATE vector_memories SET vector = ?, vector_norm = ?, embedding_dim = ? WHERE rowid = ?")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+	for i, rowid := range rowids {
+		if _, err := stmt.Exec(serializeVector(vectors[i]), vectorNorm(vectors[i]), len(vectors[i]), rowid); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	cursor := strconv.FormatInt(rowids[len(rowids)-1], 10)
+	if _, err := tx.Exec("INSERT OR REPLACE INTO config (section, key, value, updated_at) VALUES (?, ?, ?, CURRENT_TIMESTAMP)", reembedSection, reembedCursorKey, cursor); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *VectorMemoryStore) reembedProgress(key string) (string, error) {
+	var value sql.NullString
+	err := s.db.QueryRow("SELECT value FROM config WHERE section = ? AND key = ?", reembedSection, key).Scan(&value)
+	return value.String, err
+}
+
+// ensureConfigTable creates storage's config table when the memory DB is used on
+// its own (same schema, so both packages can share one file)
+func (s *VectorMemoryStore) ensureConfigTable() error {
+	_, err := s.db.Exec(`
+		CREATE TABLE IF NOT EXISTS config (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			section TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(section, key)
+		)
+	`)
+	return err
+}
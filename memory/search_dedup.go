@@ -0,0 +1,66 @@
+// Search dedup - collapse repeated copies of the same fact in search results
+package memory
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DefaultDedupSimilarity is the cosine similarity used when Config.DedupSimilarity is 0
+const DefaultDedupSimilarity = 0.97
+
+// dedupOverfetch is how many candidates per requested result SearchCategory fetches when dedup is on
+const dedupOverfetch = 2
+
+// dedupResults keeps one copy of each fact in results, at most limit of them.
+// Two results are copies when their normalized text is equal or their vectors are at
+// least DedupSimilarity apart; the copy with the higher score (then importance) is kept.
+func (s *VectorMemoryStore) dedupResults(results []MemoryResult, limit int) []MemoryResult {
+	threshold := s.cfg.DedupSimilarity
+	if threshold <= 0 {
+		threshold = DefaultDedupSimilarity
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Entry.Importance > results[j].Entry.Importance
+	})
+
+	kept := make([]MemoryResult, 0, limit)
+	seen := make(map[string]bool, len(results))
+	for _, r := range results {
+		if len(kept) == limit {
+			break
+		}
+		key := dedupKey(r.Entry.Text)
+		if seen[key] || similarToKept(r.Entry.Vector, kept, threshold) {
+			continue
+		}
+		seen[key] = true
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// similarToKept reports whether vec is a near-duplicate of a kept result's vector
+func similarToKept(vec []float32, kept []MemoryResult, threshold float32) bool {
+	if len(vec) == 0 {
+		return false
+	}
+	for _, k := range kept {
+		if len(k.Entry.Vector) == len(vec) && cosineSimilarity(vec, k.Entry.Vector) >= threshold {
+			return true
+		}
+	}
+	return false
+}
+
+// dedupKey normalizes text for equality: lower case, punctuation dropped, whitespace collapsed
+func dedupKey(text string) string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(fields, " ")
+}
@@ -0,0 +1,169 @@
+// Search filters - restrict candidates by category, importance, source and age
+package memory
+
+import (
+	"strings"
+	"time"
+)
+
+// SearchOptions configures SearchFiltered; zero fields do not filter
+type SearchOptions struct {
+	Limit         int     // max results (default Config.MaxResults)
+	MinScore      float32 // min similarity (default Config.MinScore)
+	Categories    []string
+	MinImportance float64
+	Sources       []string
+	Since         time.Time // created at or after
+	Until         time.Time // created before
+	// EfSearch overrides the HNSW search-time ef for this query (0 = index setting);
+	// higher trades latency for recall without touching the global value
+	EfSearch int
+	// Projection, when set, returns a lightweight result set: vectors are dropped
+	// and Text is cut to a snippet or cleared when neither text nor snippet is selected
+	Projection *Projection
+}
+
+// searchFilter is the SQL- and entry-level form of SearchOptions
+type searchFilter struct {
+	categories    []string
+	minImportance float64
+	sources       []string
+	since, until  int64 // unix seconds, 0 = open
+	// ef rides along to the index searches; it is not a filter (see empty)
+	ef int
+}
+
+func (o SearchOptions) filter() searchFilter {
+	f := searchFilter{
+		categories:    nonEmpty(o.Categories),
+		minImportance: o.MinImportance,
+		sources:       nonEmpty(o.Sources),
+		ef:            o.EfSearch,
+	}
+	if !o.Since.IsZero() {
+		f.since = o.Since.Unix()
+	}
+	if !o.Until.IsZero() {
+		f.until = o.Until.Unix()
+	}
+	return f
+}
+
+func (f searchFilter) empty() bool {
+	return len(f.categories) == 0 && f.minImportance <= 0 && len(f.sources) == 0 && f.since == 0 && f.until == 0
+}
+
+// partition is the category whose HNSW partition can serve the search, and whether
+// the partition alone satisfies the filter (no other conditions)
+func (f searchFilter) partition() (category string, exact bool) {
+	if len(f.categories) != 1 {
+		return "", false
+	}
+	rest := f
+	rest.categories = nil
+	return f.categories[0], rest.empty()
+}
+
+// where is the filter as a SQL condition on vector_memories columns
+func (f searchFilter) where() (string, []interface{}) {
+	var conds []string
+	var args []interface{}
+	in := func(col string, values []string) {
+		conds = append(conds, col+" IN (?"+strings.Repeat(", ?", len(values)-1)+")")
+		for _, v := range values {
+			args = append(args, v)
+		}
+	}
+	if len(f.categories) > 0 {
+		in("category", f.categories)
+	}
+	if f.minImportance > 0 {
+		conds = append(conds, "importance >= ?")
+		args = append(args, f.minImportance)
+	}
+	if len(f.sources) > 0 {
+		in("source", f.sources)
+	}
+	if f.since > 0 {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, f.since)
+	}
+	if f.until > 0 {
+		conds = append(conds, "created_at < ?")
+		args = append(args, f.until)
+	}
+	if len(conds) == 0 {
+		return "1 = 1", nil
+	}
+	return strings.Join(conds, " AND "), args
+}
+
+// match applies the filter to an entry loaded from an index hit
+func (f searchFilter) match(e MemoryEntry) bool {
+	if len(f.categories) > 0 && !containsString(f.categories, e.Category) {
+		return false
+	}
+	if f.minImportance > 0 && e.Importance < f.minImportance {
+		return false
+	}
+	if len(f.sources) > 0 && !containsString(f.sources, e.Source) {
+		return false
+	}
+	if f.since > 0 && e.CreatedAt < f.since {
+		return false
+	}
+	if f.until > 0 && e.CreatedAt >= f.until {
+		return false
+	}
+	return true
+}
+
+// keep returns up to limit results that pass the filter, in order
+func (f searchFilter) keep(results []MemoryResult, limit int) []MemoryResult {
+	kept := make([]MemoryResult, 0, limit)
+	for _, r := range results {
+		if len(kept) == limit {
+			break
+		}
+		if f.match(r.Entry) {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+// overfetch runs an index search with a growing candidate count until limit results
+// pass the filter or the index has no more candidates
+func (s *VectorMemoryStore) overfetch(f searchFilter, limit int, search func(k int) ([]MemoryResult, error)) ([]MemoryResult, error) {
+	k := limit * s.cfg.CandidateMult
+	for {
+		results, err := search(k)
+		if err != nil {
+			return nil, err
+		}
+		kept := f.keep(results, limit)
+		if len(kept) >= limit || len(results) < k {
+			return kept, nil
+		}
+		k *= 4
+	}
+}
+
+func nonEmpty(values []string) []string {
+	var out []string
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func containsString(values []string, v string) bool {
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+// Effective settings - the resolved configuration of an open store, for diagnostics
+package memory
+
+// Settings is the configuration the store runs with after defaults and provider
+// detection; the OpenAI key is left out
+type Settings struct {
+	Provider         string  `json:"provider"`
+	Dim              int     `json:"dim"`
+	EmbeddingTimeout string  `json:"embeddingTimeout"`
+	EmbeddingCache   int     `json:"embeddingCache,omitempty"` // LRU capacity, 0 = off
+	Index            bool    `json:"index"`
+	IndexPath        string  `json:"indexPath,omitempty"`
+	HNSWM            int     `json:"hnswM"`
+	HNSWEfSearch     int     `json:"hnswEfSearch"`
+	HNSWEfConstruct  int     `json:"hnswEfConstruct"`
+	HNSWDistance     string  `json:"hnswDistance"`
+	HNSWPartitioned  bool    `json:"hnswPartitioned"`
+	HNSWCompactAfter int     `json:"hnswCompactAfter"`
+	Hybrid           bool    `json:"hybrid"`
+	HybridNorm       string  `json:"hybridNorm"`
+	VectorWeight     float32 `json:"vectorWeight"`
+	TextWeight       float32 `json:"textWeight"`
+	CandidateMult    int     `json:"candidateMult"`
+	MaxResults       int     `json:"maxResults"`
+	MinScore         float32 `json:"minScore"`
+	FTS              bool    `json:"fts"`
+	FTSMode          string  `json:"ftsMode"`
+	Dedup            bool    `json:"dedup"`
+	DedupSimilarity  float32 `json:"dedupSimilarity"`
+	AsyncWrites      bool    `json:"asyncWrites"`
+	AsyncQueueSize   int     `json:"asyncQueueSize,omitempty"`
+	DimMismatch      string  `json:"dimMismatch,omitempty"`
+}
+
+// Settings reports the store's effective configuration
+func (s *VectorMemoryStore) Settings() Settings {
+	out := Settings{
+		Provider:         "placeholder",
+		Dim:              s.cfg.EmbeddingDim,
+		EmbeddingTimeout: s.cfg.EmbeddingTimeout.String(),
+		IndexPath:        s.cfg.HNSWPath,
+		HNSWM:            s.cfg.HNSWM,
+		HNSWEfSearch:     s.cfg.HNSWEfSearch,
+		HNSWEfConstruct:  s.cfg.HNSWEfConstruct,
+		HNSWDistance:     s.cfg.HNSWDistance,
+		HNSWPartitioned:  s.cfg.HNSWPartitioned,
+		HNSWCompactAfter: s.cfg.HNSWCompactAfter,
+		Hybrid:           s.cfg.HybridEnabled,
+		HybridNorm:       s.cfg.HybridNorm,
+		VectorWeight:     s.cfg.VectorWeight,
+		TextWeight:       s.cfg.TextWeight,
+		CandidateMult:    s.cfg.CandidateMult,
+		MaxResults:       s.cfg.MaxResults,
+		MinScore:         s.cfg.MinScore,
+		FTS:              s.ftsAvailable,
+		FTSMode:          s.cfg.FTSMode,
+		Dedup:            s.cfg.DedupResults,
+		DedupSimilarity:  s.cfg.DedupSimilarity,
+		AsyncWrites:      s.queue != nil,
+	}
+	if s.embedding != nil {
+		out.Provider = s.embedding.Name()
+	}
+	// Live values: efSearch can be tuned at runtime
+	if cfg, ok := s.HNSWConfig(); ok {
+		out.Index = true
+		out.HNSWM = cfg.M
+		out.HNSWEfSearch = cfg.EfSearch
+		out.HNSWEfConstruct = cfg.EfConstruct
+		out.HNSWDistance = cfg.Distance
+	}
+	if out.HNSWCompactAfter <= 0 {
+		out.HNSWCompactAfter = DefaultHNSWCompactAfter
+	}
+	if out.FTSMode != FTSRaw {
+		out.FTSMode = FTSFuzzy
+	}
+	if out.DedupSimilarity <= 0 {
+		out.DedupSimilarity = DefaultDedupSimilarity
+	}
+	if s.queue != nil {
+		out.AsyncQueueSize = cap(s.queue.ch)
+	}
+	if s.embedCache != nil {
+		out.EmbeddingCache = s.embedCache.capacity
+	}
+	if s.dimErr != nil {
+		out.DimMismatch = s.dimErr.Error()
+	}
+	return out
+}
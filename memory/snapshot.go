@@ -0,0 +1,268 @@
+// Snapshots - online, consistent backups of the memory DB and HNSW index
+package memory
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gliderlab/cogate/storage"
+	"github.com/mattn/go-sqlite3"
+)
+
+// Snapshot file names inside the destination directory
+const (
+	SnapshotDBFile       = "memory.db"
+	SnapshotIndexFile    = "vector.index"
+	SnapshotManifestFile = "manifest.json"
+)
+
+// SnapshotManifest describes a snapshot directory
+type SnapshotManifest struct {
+	CreatedAt time.Time `json:"createdAt"`
+	Memories  int       `json:"memories"`
+	Dim       int       `json:"dim"`
+	Index     bool      `json:"index"`
+	Vectors   int64     `json:"vectors"`
+}
+
+// Snapshot writes a restorable copy of the store to destDir without stopping writes.
+// The DB is copied with SQLite's online backup API; the index is saved first, so
+// every indexed vector is present in the DB copy (rows added in between are
+// re-indexed on restore), and its label mapping is saved next to it. Each file is
+// written under a temp name and renamed.
+func (s *VectorMemoryStore) Snapshot(destDir string) error {
+	if err := os.MkdirAll(destDir, 0755); err != nil {
+		return fmt.Errorf("create snapshot dir: %v", err)
+	}
+
+	// Hold off index changes (adds, tombstones, rebuilds) so index and DB agree
+	s.snapMu.Lock()
+	defer s.snapMu.Unlock()
+
+	manifest := SnapshotManifest{CreatedAt: time.Now().UTC(), Dim: s.cfg.EmbeddingDim}
+
+	indexPath := filepath.Join(destDir, SnapshotIndexFile)
+	idsPath := hnswIDsPath(indexPath)
+	if s.hnsw != nil {
+		tmp := indexPath + ".tmp"
+		os.Remove(tmp)
+		if err := s.hnsw.Save(tmp); err != nil {
+			return fmt.Errorf("save index: %v", err)
+		}
+		// The index is a no-op without FAISS; only keep a file that was written
+		if _, err := os.Stat(tmp); err == nil {
+			if err := saveHNSWIDs(idsPath, s.hnswIDs); err != nil {
+				os.Remove(tmp)
+				return fmt.Errorf("save index id mapping: %v", err)
+			}
+			if err := os.Rename(tmp, indexPath); err != nil {
+				return fmt.Errorf("install index: %v", err)
+			}
+			manifest.Index = true
+			manifest.Vectors = s.hnsw.Count()
+		}
+	}
+	if !manifest.Index {
+		os.Remove(indexPath)
+		os.Remove(idsPath)
+	}
+
+	dbPath := filepath.Join(destDir, SnapshotDBFile)
+	tmp := dbPath + ".tmp"
+	os.Remove(tmp)
+	if err := backupDB(s.db, tmp); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("backup db: %v", err)
+	}
+	if err := os.Rename(tmp, dbPath); err != nil {
+		return fmt.Errorf("install db: %v", err)
+	}
+
+	if count, err := countRows(dbPath); err == nil {
+		manifest.Memories = count
+	}
+	data, _ := json.MarshalIndent(manifest, "", "  ")
+	manifestPath := filepath.Join(destDir, SnapshotManifestFile)
+	if err := os.WriteFile(manifestPath+".tmp", data, 0644); err != nil {
+		return fmt.Errorf("write manifest: %v", err)
+	}
+	if err := os.Rename(manifestPath+".tmp", manifestPath); err != nil {
+		return fmt.Errorf("install manifest: %v", err)
+	}
+
+	log.Printf("🗄️ Memory snapshot written: %s (memories=%d, index=%v)", destDir, manifest.Memories, manifest.Index)
+	return nil
+}
+
+// RestoreFromSnapshot replaces the live memories with those in the snapshot at srcDir.
+// Only the memory tables are restored (the snapshot DB also holds the agent's other
+// tables). A snapshot index is mapped onto the restored rows with its label mapping.
+// The index is reloaded from the snapshot, or rebuilt when the snapshot has none.
+func (s *VectorMemoryStore) RestoreFromSnapshot(srcDir string) error {
+	dbPath := filepath.Join(srcDir, SnapshotDBFile)
+	if _, err := os.Stat(dbPath); err != nil {
+		return fmt.Errorf("snapshot db missing: %v", err)
+	}
+
+	s.lockIndex()
+	defer s.unlockIndex()
+
+	if err := s.restoreRows(dbPath); err != nil {
+		return fmt.Errorf("restore db: %v", err)
+	}
+	// FTS rows were cleared with the old memories; refill from the restored rows
+	s.rebuildFTSIfEmpty()
+
+	if s.hnsw != nil {
+		cfg := s.hnsw.Config()
+		s.hnsw.Close()
+		indexPath := filepath.Join(srcDir, SnapshotIndexFile)
+		if _, err := os.Stat(indexPath); err == nil {
+			cfg.StoragePath = indexPath
+		} else {
+			cfg.StoragePath = ""
+		}
+		idx, err := NewHNSWIndex(cfg)
+		if err != nil {
+			s.hnsw = nil
+			s.hnswIDs = nil
+			s.resetPartitions()
+			return fmt.Errorf("reload index: %v", err)
+		}
+		// Keep persisting to the live index path
+		idx.cfg.StoragePath = s.cfg.HNSWPath
+		s.hnsw = idx
+		s.hnswIDs = nil
+		s.loadVectors(hnswIDsPath(indexPath))
+		s.saveHNSW()
+	}
+
+	log.Printf("🗄️ Memory restored from snapshot: %s", srcDir)
+	return nil
+}
+
+// restoreColumns are the vector_memories columns copied from a snapshot; the
+// first restoreRequired exist in every schema version
+const restoreRequired = 3
+
+var restoreColumns = []string{"id", "text", "vector", "vector_norm", "importance", "category", "source", "external_id", "embedding_dim", "created_at", "updated_at"}
+
+// restoreRows swaps vector_memories for the snapshot's rows in one transaction
+func (s *VectorMemoryStore) restoreRows(dbPath string) error {
+	ctx := context.Background()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// ATTACH is per connection, so everything below runs on conn
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS snap", "file:"+dbPath+"?mode=ro"); err != nil {
+		return fmt.Errorf("attach snapshot: %v", err)
+	}
+	defer conn.ExecContext(ctx, "DETACH DATABASE snap")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Snapshots taken before a migration lack its columns; those keep their defaults
+	var present []string
+	for i, col := range restoreColumns {
+		ok, err := storage.HasColumn(tx, "snap.vector_memories", col)
+		if err != nil {
+			return fmt.Errorf("read snapshot schema: %v", err)
+		}
+		if ok {
+			present = append(present, col)
+		} else if i < restoreRequired {
+			return fmt.Errorf("snapshot vector_memories has no %s column", col)
+		}
+	}
+	cols := strings.Join(present, ", ")
+	if _, err := tx.Exec("DELETE FROM vector_memories"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO vector_memories (rowid, " + cols + ") SELECT rowid, " + cols + " FROM snap.vector_memories ORDER BY rowid"); err != nil {
+		return fmt.Errorf("copy rows: %v", err)
+	}
+	// Rows the snapshot stored without a norm would be recomputed on every search
+	if err := backfillVectorNorms(tx); err != nil {
+		return fmt.Errorf("backfill norms: %v", err)
+	}
+	if s.ftsAvailable {
+		if _, err := tx.Exec("DELETE FROM vector_memories_fts"); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// backupDB copies db into a new SQLite file at path
+func backupDB(db *sql.DB, path string) error {
+	dest, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+	return copyDB(db, dest)
+}
+
+// copyDB runs the SQLite online backup from src to dest. A single Step(-1)
+// copies every page inside one read transaction, so the copy is consistent
+// while other connections keep writing (WAL).
+func copyDB(src, dest *sql.DB) error {
+	ctx := context.Background()
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+	destConn, err := dest.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer destConn.Close()
+
+	return destConn.Raw(func(destRaw interface{}) error {
+		return srcConn.Raw(func(srcRaw interface{}) error {
+			d, ok := destRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", destRaw)
+			}
+			sc, ok := srcRaw.(*sqlite3.SQLiteConn)
+			if !ok {
+				return fmt.Errorf("unexpected driver connection %T", srcRaw)
+			}
+			b, err := d.Backup("main", sc, "main")
+			if err != nil {
+				return err
+			}
+			if _, err := b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+			return b.Finish()
+		})
+	})
+}
+
+func countRows(dbPath string) (int, error) {
+	db, err := sql.Open("sqlite3", "file:"+dbPath+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+	var n int
+	err = db.QueryRow("SELECT COUNT(*) FROM vector_memories").Scan(&n)
+	return n, err
+}
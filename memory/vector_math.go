@@ -0,0 +1,54 @@
+// Vector math - similarity kernels for linear search and dedup
+package memory
+
+import "math"
+
+// dot is the inner product of a and b[:len(a)]. Four independent accumulators
+// break the add dependency chain, so the multiplies can overlap.
+func dot(a, b []float32) float32 {
+	b = b[:len(a)]
+	var s0, s1, s2, s3 float32
+	i := 0
+	for ; i+4 <= len(a); i += 4 {
+		// Fixed-size windows let the compiler drop the per-element bounds checks
+		aa := a[i : i+4 : i+4]
+		bb := b[i : i+4 : i+4]
+		s0 += aa[0] * bb[0]
+		s1 += aa[1] * bb[1]
+		s2 += aa[2] * bb[2]
+		s3 += aa[3] * bb[3]
+	}
+	for ; i < len(a); i++ {
+		s0 += a[i] * b[i]
+	}
+	return (s0 + s1) + (s2 + s3)
+}
+
+// vectorNorm is the Euclidean length of v
+func vectorNorm(v []float32) float32 {
+	return float32(math.Sqrt(float64(dot(v, v))))
+}
+
+// cosineSimilarity computes the dot product and both norms in one pass; use
+// cosineWithNorms when the norms are known (about a third of the work)
+func cosineSimilarity(a, b []float32) float32 {
+	var sum, normA, normB float32
+	for i := 0; i < len(a); i++ {
+		sum += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return sum / float32(math.Sqrt(float64(normA*normB)))
+}
+
+// cosineWithNorms is cosineSimilarity with both norms already known
+// (the query's is computed once per search, stored vectors' are in vector_norm)
+func cosineWithNorms(a, b []float32, normA, normB float32) float32 {
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot(a, b) / (normA * normB)
+}
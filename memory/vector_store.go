@@ -2,19 +2,26 @@
 package memory
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/rand"
 	"database/sql"
+	"encoding/binary"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"math"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/gliderlab/cogate/storage"
 	_ "github.com/mattn/go-sqlite3"
 	openai "github.com/sashabaranov/go-openai"
 )
@@ -23,25 +30,106 @@ import (
 type VectorMemoryStore struct {
 	db           *sql.DB
 	hnsw         *HNSWIndex // FAISS HNSW index
-	hnswIDs      []string   // HNSW index -> memory ID mapping
+	hnswIDs      []string   // HNSW index -> memory ID mapping ("" = tombstoned label)
 	embedding    EmbeddingProvider
 	ftsAvailable bool
 	cfg          Config
+	// snapMu keeps index changes (adds, tombstones, rebuilds) out of a running
+	// snapshot or restore; they take its write side through lockIndex
+	snapMu sync.RWMutex
+	// idxMu guards hnsw and hnswIDs together, since label i of the index is
+	// hnswIDs[i]: changes hold the write side, searches the read side while they
+	// map labels to IDs. Lock order: snapMu, idxMu, partMu.
+	idxMu sync.RWMutex
+	// partitions are per-category indices (Config.HNSWPartitioned)
+	partitions map[string]*hnswPartition
+	partMu     sync.RWMutex
+	// embedStats instruments every provider call of getEmbedding
+	embedStats embeddingStats
+	// embedCache skips the provider for recently embedded texts (nil = Config.EmbeddingCacheSize 0)
+	embedCache *embedCache
+	// dimErr is set when stored rows use another embedding dimension; writes are refused
+	dimErr error
+	// queue takes StoreWithSource writes when Config.AsyncWrites is set
+	queue *writeQueue
+	// newID is Config.NewID or generateUUID
+	newID func() string
 }
 
 // Config
 type Config struct {
-	ApiKey          string  // OpenAI API Key (or ${OPENAI_API_KEY})
-	EmbeddingModel  string  // OpenAI model: text-embedding-3-small/large
-	EmbeddingServer string  // Local embedding service URL
-	EmbeddingDim    int     // Embedding dimension (auto-detected)
-	MaxResults      int     // Max results (default 5)
-	MinScore        float32 // Minimum similarity score (default 0.7)
-	HNSWPath        string  // HNSW index file path
-	HybridEnabled   bool    // Enable hybrid search (default true)
-	VectorWeight    float32 // Vector weight (default 0.7)
-	TextWeight      float32 // Keyword weight (default 0.3)
-	CandidateMult   int     // Candidate multiplier (default 4)
+	ApiKey           string  // OpenAI API Key (or ${OPENAI_API_KEY})
+	EmbeddingModel   string  // OpenAI model: text-embedding-3-small/large
+	EmbeddingServer  string  // Local embedding service URL
+	EmbeddingDim     int     // Embedding dimension (auto-detected)
+	MaxResults       int     // Max results (default 5)
+	MinScore         float32 // Minimum similarity score (default 0.7)
+	HNSWPath         string  // HNSW index file path
+	HybridEnabled    bool    // Enable hybrid search (default false)
+	VectorWeight     float32 // Vector weight (default 0.7)
+	TextWeight       float32 // Keyword weight (default 0.3)
+	WeightsSet       bool    // Use VectorWeight and TextWeight as given, zeros included
+	CandidateMult    int     // Candidate multiplier (default 4)
+	HybridNorm       string  // Hybrid score normalization: HybridNormMinMax (default), HybridNormZScore or HybridNormNone
+	ScoreFloor       float32 // Lowest value a hybrid score component counts as, in [0,1) (default 0)
+	DedupResults     bool    // Collapse search results with the same normalized text or near-identical vectors
+	DedupSimilarity  float32 // Cosine similarity at which two results count as copies (default 0.97)
+	HNSWPartitioned  bool    // Extra per-category HNSW indices for category-scoped search
+	HNSWCompactAfter int     // Tombstones before deletes/updates rebuild the index (default 1000)
+	HNSWM            int     // HNSW graph degree (default 16)
+	HNSWEfSearch     int     // HNSW search-time candidate list (default 100)
+	HNSWEfConstruct  int     // HNSW build-time candidate list (default 200)
+	HNSWDistance     string  // HNSW metric: cosine (default), ip or l2
+	FTSMode          string  // FTSFuzzy (default) or FTSRaw
+	AsyncWrites      bool    // StoreWithSource queues the write and returns before embedding
+	AsyncQueueSize   int     // Queued writes before StoreWithSource fails (default 256)
+	// EmbeddingTimeout bounds each embedding request (default 15s), so Store and
+	// Search fail fast when the embedding service hangs
+	EmbeddingTimeout time.Duration
+	// EmbeddingCacheSize keeps the vectors of that many recently embedded texts in an
+	// LRU cache, so repeated texts skip the provider (0 = no cache)
+	EmbeddingCacheSize int
+	// NewID assigns the ID of each stored memory (nil = random UUID). Tests set a
+	// deterministic sequence; it must be safe for concurrent use.
+	NewID func() string
+	// SQLite page cache and mmap sizes per connection (zero value = storage defaults)
+	SQLite storage.SQLiteTuning
+}
+
+// DefaultEmbeddingTimeout applies when Config.EmbeddingTimeout is zero
+const DefaultEmbeddingTimeout = 15 * time.Second
+
+// HNSW parameters used when the Config fields are zero
+const (
+	DefaultHNSWM           = 16
+	DefaultHNSWEfSearch    = 100
+	DefaultHNSWEfConstruct = 200
+	DefaultHNSWDistance    = "cosine"
+)
+
+// hnswParams fills the HNSW defaults and rejects values the index cannot use
+func (cfg *Config) hnswParams() error {
+	if cfg.HNSWM < 0 || cfg.HNSWEfSearch < 0 || cfg.HNSWEfConstruct < 0 {
+		return fmt.Errorf("invalid HNSW parameters: M=%d efSearch=%d efConstruct=%d", cfg.HNSWM, cfg.HNSWEfSearch, cfg.HNSWEfConstruct)
+	}
+	if cfg.HNSWM == 0 {
+		cfg.HNSWM = DefaultHNSWM
+	}
+	if cfg.HNSWEfSearch == 0 {
+		cfg.HNSWEfSearch = DefaultHNSWEfSearch
+	}
+	if cfg.HNSWEfConstruct == 0 {
+		cfg.HNSWEfConstruct = DefaultHNSWEfConstruct
+	}
+	cfg.HNSWDistance = strings.ToLower(strings.TrimSpace(cfg.HNSWDistance))
+	switch cfg.HNSWDistance {
+	case "":
+		cfg.HNSWDistance = DefaultHNSWDistance
+	case "cosine", "ip", "l2":
+	default:
+		return fmt.Errorf("invalid HNSW distance %q (want cosine, ip or l2)", cfg.HNSWDistance)
+	}
+	return nil
 }
 
 // Embedding provider interface
@@ -51,11 +139,21 @@ type EmbeddingProvider interface {
 	Name() string
 }
 
+// BatchEmbedder is implemented by providers that embed many texts in one request
+// (LocalProvider via /embed-batch, OpenAIProvider via a multi-input request)
+type BatchEmbedder interface {
+	EmbedBatch(texts []string) ([][]float32, error)
+}
+
+// embedBatchSize caps the texts sent in one batch request
+const embedBatchSize = 64
+
 // OpenAI embedding
 type OpenAIProvider struct {
-	client *openai.Client
-	model  string
-	dim    int
+	client  *openai.Client
+	model   string
+	dim     int
+	timeout time.Duration
 }
 
 // Local embedding (llama.cpp server)
@@ -63,6 +161,7 @@ type LocalProvider struct {
 	serverURL string
 	dim       int
 	client    *http.Client
+	timeout   time.Duration // per request
 }
 
 // Memory entry
@@ -73,6 +172,7 @@ type MemoryEntry struct {
 	Importance float64
 	Category   string
 	Source     string
+	ExternalID string // Caller-assigned ID (unique when set), for idempotent upserts
 	CreatedAt  int64
 	UpdatedAt  int64
 }
@@ -111,14 +211,17 @@ func NewOpenAIProvider(apiKey, model string) (*OpenAIProvider, error) {
 	}
 
 	return &OpenAIProvider{
-		client: openai.NewClient(apiKey),
-		model:  model,
-		dim:    dim,
+		client:  openai.NewClient(apiKey),
+		model:   model,
+		dim:     dim,
+		timeout: DefaultEmbeddingTimeout,
 	}, nil
 }
 
 func (p *OpenAIProvider) Embed(text string) ([]float32, error) {
-	resp, err := p.client.CreateEmbeddings(context.Background(), openai.EmbeddingRequest{
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
 		Model: openai.EmbeddingModel(p.model),
 		Input: text,
 	})
@@ -136,6 +239,42 @@ func (p *OpenAIProvider) Embed(text string) ([]float32, error) {
 	return result, nil
 }
 
+// EmbedBatch embeds texts with one request; vectors come back in input order
+func (p *OpenAIProvider) EmbedBatch(texts []string) ([][]float32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+	resp, err := p.client.CreateEmbeddings(ctx, openai.EmbeddingRequest{
+		Model: openai.EmbeddingModel(p.model),
+		Input: texts,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %v", err)
+	}
+	if len(resp.Data) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(resp.Data))
+	}
+
+	result := make([][]float32, len(texts))
+	for _, d := range resp.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vec := make([]float32, len(d.Embedding))
+		for i, v := range d.Embedding {
+			vec[i] = float32(v)
+		}
+		result[d.Index] = vec
+	}
+	return result, nil
+}
+
+// SetTimeout changes the per-request timeout (non-positive keeps the current one)
+func (p *OpenAIProvider) SetTimeout(d time.Duration) {
+	if d > 0 {
+		p.timeout = d
+	}
+}
+
 func (p *OpenAIProvider) Dim() int     { return p.dim }
 func (p *OpenAIProvider) Name() string { return "openai:" + p.model }
 
@@ -145,10 +284,6 @@ func NewLocalProvider(serverURL string, dim int) (*LocalProvider, error) {
 	if serverURL == "" {
 		serverURL = "http://localhost:50000"
 	}
-	if dim == 0 {
-		dim = 768 // embedding-gemma default dimension
-	}
-
 	// Wait for service ready (up to 30s)
 	var lastErr error
 	for i := 0; i < 30; i++ {
@@ -162,13 +297,20 @@ func NewLocalProvider(serverURL string, dim int) (*LocalProvider, error) {
 			time.Sleep(time.Second)
 			continue
 		}
+		var health struct {
+			Dim int `json:"dim"`
+		}
+		json.NewDecoder(resp.Body).Decode(&health)
 		resp.Body.Close()
 		if resp.StatusCode == http.StatusOK {
 			log.Printf("Local embedding service connected: %s", serverURL)
+			dim = resolveServerDim(dim, health.Dim)
 			return &LocalProvider{
 				serverURL: serverURL,
 				dim:       dim,
-				client:    &http.Client{Timeout: 60 * time.Second},
+				// Deadlines come from the per-request context (see timeout)
+				client:  &http.Client{},
+				timeout: DefaultEmbeddingTimeout,
 			}, nil
 		}
 		lastErr = fmt.Errorf("server returned %d", resp.StatusCode)
@@ -178,31 +320,135 @@ func NewLocalProvider(serverURL string, dim int) (*LocalProvider, error) {
 	return nil, fmt.Errorf("local server unavailable: %v", lastErr)
 }
 
+// resolveServerDim prefers the dimension the embedding server measured from its
+// model over the configured one; 768 (embedding-gemma) is only a last resort for
+// servers that do not report it.
+func resolveServerDim(configured, reported int) int {
+	switch {
+	case reported > 0 && configured > 0 && reported != configured:
+		log.Printf("⚠️ Embedding server reports dim=%d, configured %d; using %d", reported, configured, reported)
+		return reported
+	case reported > 0:
+		return reported
+	case configured > 0:
+		return configured
+	default:
+		log.Printf("⚠️ Embedding server did not report a dimension; assuming 768")
+		return 768
+	}
+}
+
 func (p *LocalProvider) Embed(text string) ([]float32, error) {
-	reqBody, _ := json.Marshal(map[string]interface{}{"text": text})
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	var result struct {
+		Embedding []float32 `json:"embedding"`
+	}
+	if err := p.post("/embed", map[string]interface{}{"text": text}, &result); err != nil {
+		return nil, err
+	}
+	return result.Embedding, nil
+}
+
+// EmbedBatch embeds texts with one /embed-batch request. The server reports
+// failures per text; any failed text fails the batch, naming the first one.
+func (p *LocalProvider) EmbedBatch(texts []string) ([][]float32, error) {
+	var result struct {
+		Results []struct {
+			Embedding []float32 `json:"embedding"`
+			Error     string    `json:"error"`
+		} `json:"results"`
+		Failed int `json:"failed"`
+	}
+	if err := p.post("/embed-batch", map[string]interface{}{"texts": texts}, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Results) != len(texts) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(texts), len(result.Results))
+	}
+	embeddings := make([][]float32, len(texts))
+	for i, r := range result.Results {
+		if r.Error != "" {
+			return nil, fmt.Errorf("%d of %d texts failed, first at %d: %s", result.Failed, len(texts), i, r.Error)
+		}
+		embeddings[i] = r.Embedding
+	}
+	return embeddings, nil
+}
+
+// gzipMinBytes: request bodies smaller than this are sent uncompressed
+const gzipMinBytes = 1024
+
+// post sends a JSON request to the embedding server. Large bodies are gzip
+// compressed and gzip responses are accepted; if the server rejects the
+// compressed body (older server), the request is retried uncompressed.
+func (p *LocalProvider) post(path string, payload interface{}, out interface{}) error {
+	reqBody, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	compress := len(reqBody) >= gzipMinBytes
+	status, err := p.doPost(path, reqBody, compress, out)
+	if compress && (status == http.StatusBadRequest || status == http.StatusUnsupportedMediaType) {
+		_, err = p.doPost(path, reqBody, false, out)
+	}
+	return err
+}
+
+func (p *LocalProvider) doPost(path string, reqBody []byte, compress bool, out interface{}) (int, error) {
+	body := reqBody
+	if compress {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(reqBody)
+		zw.Close()
+		body = buf.Bytes()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
 	defer cancel()
 
-	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, p.serverURL+"/embed", strings.NewReader(string(reqBody)))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.serverURL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Encoding", "gzip")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 
 	resp, err := p.client.Do(req)
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("server returned %d", resp.StatusCode)
+	// 207 carries per-item results (/embed-batch); the caller inspects them
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusMultiStatus {
+		return resp.StatusCode, fmt.Errorf("server returned %d", resp.StatusCode)
 	}
 
-	var result struct {
-		Embedding []float32 `json:"embedding"`
+	// Accept-Encoding was set explicitly, so the transport does not decompress for us
+	var reader io.Reader = resp.Body
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		zr, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return resp.StatusCode, fmt.Errorf("invalid gzip response: %v", err)
+		}
+		defer zr.Close()
+		reader = zr
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, err
+	if err := json.NewDecoder(reader).Decode(out); err != nil {
+		return resp.StatusCode, err
+	}
+	return resp.StatusCode, nil
+}
+
+// SetTimeout changes the per-request timeout (non-positive keeps the current one)
+func (p *LocalProvider) SetTimeout(d time.Duration) {
+	if d > 0 {
+		p.timeout = d
 	}
-	return result.Embedding, nil
 }
 
 func (p *LocalProvider) Dim() int     { return p.dim }
@@ -230,25 +476,35 @@ func NewVectorMemoryStore(dbPath string, cfg Config) (*VectorMemoryStore, error)
 	if cfg.CandidateMult == 0 {
 		cfg.CandidateMult = 4
 	}
-	if cfg.VectorWeight == 0 {
-		cfg.VectorWeight = 0.7
+	if !cfg.WeightsSet {
+		if cfg.VectorWeight == 0 {
+			cfg.VectorWeight = DefaultVectorWeight
+		}
+		if cfg.TextWeight == 0 {
+			cfg.TextWeight = DefaultTextWeight
+		}
 	}
-	if cfg.TextWeight == 0 {
-		cfg.TextWeight = 0.3
+	if cfg.VectorWeight < 0 || cfg.TextWeight < 0 || cfg.VectorWeight+cfg.TextWeight == 0 {
+		return nil, fmt.Errorf("invalid hybrid weights %v/%v (want >= 0, not both 0)", cfg.VectorWeight, cfg.TextWeight)
 	}
-	// default true unless explicitly set to false
-	if cfg.HybridEnabled == false {
-		// keep as false
-	} else {
-		cfg.HybridEnabled = true
+	if cfg.EmbeddingTimeout <= 0 {
+		cfg.EmbeddingTimeout = DefaultEmbeddingTimeout
 	}
-
-	// Open database
-	db, err := sql.Open("sqlite3", dbPath)
+	if err := cfg.hnswParams(); err != nil {
+		return nil, err
+	}
+	hybridNorm, err := parseHybridNorm(cfg.HybridNorm)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v", err)
+		return nil, err
+	}
+	cfg.HybridNorm = hybridNorm
+	if cfg.ScoreFloor < 0 || cfg.ScoreFloor >= 1 {
+		return nil, fmt.Errorf("invalid score floor %v (want 0 <= floor < 1)", cfg.ScoreFloor)
 	}
 
+	// Open database (cache and mmap sizes apply to every pooled connection)
+	db := storage.OpenSQLite(dbPath, cfg.SQLite)
+
 	// avoid lock errors in concurrent access
 	db.Exec("PRAGMA busy_timeout=5000")
 
@@ -256,7 +512,10 @@ func NewVectorMemoryStore(dbPath string, cfg Config) (*VectorMemoryStore, error)
 		return nil, fmt.Errorf("failed to init schema: %v", err)
 	}
 
-	store := &VectorMemoryStore{db: db, cfg: cfg}
+	store := &VectorMemoryStore{db: db, cfg: cfg, newID: cfg.NewID}
+	if store.newID == nil {
+		store.newID = generateUUID
+	}
 	if err := store.ensureFTS(); err != nil {
 		log.Printf("FTS init failed: %v", err)
 	} else {
@@ -269,10 +528,11 @@ func NewVectorMemoryStore(dbPath string, cfg Config) (*VectorMemoryStore, error)
 		if err != nil {
 			log.Printf("Local embedding connection failed: %v", err)
 		} else {
+			provider.SetTimeout(cfg.EmbeddingTimeout)
 			store.embedding = provider
 			cfg.EmbeddingDim = provider.Dim()
 			store.cfg.EmbeddingDim = provider.Dim()
-			log.Printf("Local embedding: %s (dim=%d)", provider.Name(), provider.Dim())
+			log.Printf("Local embedding: %s (dim=%d, timeout=%s)", provider.Name(), provider.Dim(), cfg.EmbeddingTimeout)
 		}
 	}
 
@@ -281,13 +541,19 @@ func NewVectorMemoryStore(dbPath string, cfg Config) (*VectorMemoryStore, error)
 		if err != nil {
 			log.Printf("OpenAI embedding init failed: %v", err)
 		} else {
+			provider.SetTimeout(cfg.EmbeddingTimeout)
 			store.embedding = provider
 			cfg.EmbeddingDim = provider.Dim()
 			store.cfg.EmbeddingDim = provider.Dim()
-			log.Printf("OpenAI embedding: %s (dim=%d)", provider.Name(), provider.Dim())
+			log.Printf("OpenAI embedding: %s (dim=%d, timeout=%s)", provider.Name(), provider.Dim(), cfg.EmbeddingTimeout)
 		}
 	}
 
+	if store.embedding != nil && cfg.EmbeddingCacheSize > 0 {
+		store.embedCache = newEmbedCache(cfg.EmbeddingCacheSize)
+		log.Printf("Embedding cache: %d entries", cfg.EmbeddingCacheSize)
+	}
+
 	if store.embedding == nil {
 		log.Printf("No embedding service, using placeholder vectors")
 		if cfg.EmbeddingDim == 0 {
@@ -299,15 +565,16 @@ func NewVectorMemoryStore(dbPath string, cfg Config) (*VectorMemoryStore, error)
 
 	// Backfill embedding_dim for old rows when NULL/0
 	store.backfillEmbeddingDim()
+	store.checkStoredDims()
 
 	// Initialize FAISS HNSW when embedding is available
 	if store.embedding != nil {
 		hnswCfg := HNSWConfig{
 			Dim:         cfg.EmbeddingDim,
-			M:           16,
-			EfSearch:    100,
-			EfConstruct: 200,
-			Distance:    "cosine",
+			M:           cfg.HNSWM,
+			EfSearch:    cfg.HNSWEfSearch,
+			EfConstruct: cfg.HNSWEfConstruct,
+			Distance:    cfg.HNSWDistance,
 			StoragePath: cfg.HNSWPath,
 		}
 
@@ -327,6 +594,14 @@ func NewVectorMemoryStore(dbPath string, cfg Config) (*VectorMemoryStore, error)
 		log.Printf("No embedding service, skipping FAISS init")
 	}
 
+	if cfg.AsyncWrites {
+		size := cfg.AsyncQueueSize
+		if size <= 0 {
+			size = DefaultAsyncQueueSize
+		}
+		store.startWriteQueue(size)
+	}
+
 	log.Printf("Vector memory store initialized: faiss=%v, embedding=%v", store.hnsw != nil, store.embedding != nil)
 	return store, nil
 }
@@ -351,38 +626,8 @@ func initSchema(db *sql.DB) error {
 		return err
 	}
 
-	// Legacy table compatibility: add missing columns
-	rows, err := db.Query(`PRAGMA table_info(vector_memories)`)
-	if err == nil {
-		defer rows.Close()
-		hasDim := false
-		hasSource := false
-		hasUpdated := false
-		for rows.Next() {
-			var cid int
-			var name, ctype string
-			var notnull int
-			var dflt interface{}
-			var pk int
-			rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk)
-			switch name {
-			case "embedding_dim":
-				hasDim = true
-			case "source":
-				hasSource = true
-			case "updated_at":
-				hasUpdated = true
-			}
-		}
-		if !hasDim {
-			db.Exec(`ALTER TABLE vector_memories ADD COLUMN embedding_dim INTEGER`)
-		}
-		if !hasSource {
-			db.Exec(`ALTER TABLE vector_memories ADD COLUMN source TEXT DEFAULT 'manual'`)
-		}
-		if !hasUpdated {
-			db.Exec(`ALTER TABLE vector_memories ADD COLUMN updated_at INTEGER DEFAULT (strftime('%s','now'))`)
-		}
+	if err := storage.Migrate(db, "memory", memoryMigrations); err != nil {
+		return err
 	}
 
 	db.Exec(`CREATE INDEX IF NOT EXISTS idx_vm_category ON vector_memories(category)`)
@@ -398,19 +643,89 @@ func initSchema(db *sql.DB) error {
 	return nil
 }
 
+// memoryMigrations is the ordered vector_memories schema history.
+var memoryMigrations = []storage.Migration{
+	// Legacy tables predate these columns
+	{Version: 1, Name: "vector_memories legacy columns", Up: func(tx *sql.Tx) error {
+		if err := storage.AddColumnIfMissing(tx, "vector_memories", "embedding_dim", "INTEGER"); err != nil {
+			return err
+		}
+		if err := storage.AddColumnIfMissing(tx, "vector_memories", "source", "TEXT DEFAULT 'manual'"); err != nil {
+			return err
+		}
+		// ALTER TABLE cannot use a non-constant default; seed from created_at instead
+		hasUpdated, err := storage.HasColumn(tx, "vector_memories", "updated_at")
+		if err != nil || hasUpdated {
+			return err
+		}
+		if _, err := tx.Exec(`ALTER TABLE vector_memories ADD COLUMN updated_at INTEGER DEFAULT 0`); err != nil {
+			return err
+		}
+		_, err = tx.Exec(`UPDATE vector_memories SET updated_at = created_at`)
+		return err
+	}},
+	{Version: 2, Name: "vector_memories external_id", Up: func(tx *sql.Tx) error {
+		if err := storage.AddColumnIfMissing(tx, "vector_memories", "external_id", "TEXT"); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_vm_external_id ON vector_memories(external_id) WHERE external_id IS NOT NULL`)
+		return err
+	}},
+	// Linear search divides by both norms; storing them saves recomputing every row per query
+	{Version: 3, Name: "vector_memories vector_norm", Up: func(tx *sql.Tx) error {
+		if err := storage.AddColumnIfMissing(tx, "vector_memories", "vector_norm", "REAL"); err != nil {
+			return err
+		}
+		return backfillVectorNorms(tx)
+	}},
+}
+
 // ==================== Core Operations ====================
 
 func (s *VectorMemoryStore) Store(text string, category string, importance float64) (string, error) {
 	return s.StoreWithSource(text, category, importance, "manual")
 }
 
+// StoreWithSource stores an untagged memory. With Config.AsyncWrites it only queues
+// the write: the returned ID becomes visible once the worker has stored it (see Flush).
 func (s *VectorMemoryStore) StoreWithSource(text string, category string, importance float64, source string) (string, error) {
+	if s.queue == nil {
+		return s.StoreWithExternalID("", text, category, importance, source)
+	}
+	if s.dimErr != nil {
+		return "", s.dimErr
+	}
+	if source == "" {
+		source = "manual"
+	}
+	id := s.newID()
+	if err := s.enqueue(pendingWrite{id: id, text: text, category: category, importance: importance, source: source}); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// StoreWithExternalID stores a memory tagged with the caller's own ID.
+// An empty externalID stores an untagged memory; a taken one is an error (use Upsert).
+func (s *VectorMemoryStore) StoreWithExternalID(externalID string, text string, category string, importance float64, source string) (string, error) {
+	externalID = strings.TrimSpace(externalID)
+	if externalID != "" {
+		if existing, found, err := s.GetByExternalID(externalID); err != nil {
+			return "", err
+		} else if found {
+			return "", fmt.Errorf("external id %q already used by memory %s", externalID, shortID(existing.ID))
+		}
+	}
+
 	vector, err := s.getEmbedding(text)
 	if err != nil {
 		return "", fmt.Errorf("embedding failed: %v", err)
 	}
+	if err := s.checkVectorDim(vector); err != nil {
+		return "", err
+	}
 
-	id := generateUUID()
+	id := s.newID()
 	now := time.Now().Unix()
 	vectorBlob := serializeVector(vector)
 	if source == "" {
@@ -418,26 +733,33 @@ func (s *VectorMemoryStore) StoreWithSource(text string, category string, import
 	}
 
 	_, err = s.db.Exec(`
-		INSERT INTO vector_memories (id, text, vector, importance, category, source, embedding_dim, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, id, text, vectorBlob, importance, category, source, s.cfg.EmbeddingDim, now, now)
+		INSERT INTO vector_memories (id, text, vector, vector_norm, importance, category, source, external_id, embedding_dim, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, id, text, vectorBlob, vectorNorm(vector), importance, category, source, nullString(externalID), s.cfg.EmbeddingDim, now, now)
 	if err == nil {
 		s.upsertFTS(id, text, category)
 	}
 	if err != nil {
+		if externalID != "" && strings.Contains(err.Error(), "UNIQUE") {
+			return "", fmt.Errorf("external id %q already in use", externalID)
+		}
 		return "", err
 	}
 
 	// Add to HNSW index
+	s.lockIndex()
+	defer s.unlockIndex()
 	if s.hnsw != nil {
 		if err := s.hnsw.Add([][]float32{vector}); err != nil {
 			log.Printf("HNSW add failed, disabling index: %v", err)
 			s.hnsw.Close()
 			s.hnsw = nil
 			s.hnswIDs = nil
+			s.resetPartitions()
 		} else {
 			s.hnswIDs = append(s.hnswIDs, id)
 			s.saveHNSW()
+			s.addToPartitions([]string{id}, []string{category}, [][]float32{vector})
 		}
 	}
 
@@ -445,6 +767,137 @@ func (s *VectorMemoryStore) StoreWithSource(text string, category string, import
 	return id, nil
 }
 
+// StoreBatch embeds and stores many memories: one embedding request per embedBatchSize
+// texts (when the provider supports batches), then one transaction and one index add.
+// categories and importances may be nil; otherwise they line up with texts.
+func (s *VectorMemoryStore) StoreBatch(texts []string, categories []string, importances []float64) ([]string, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	if (categories != nil && len(categories) != len(texts)) || (importances != nil && len(importances) != len(texts)) {
+		return nil, fmt.Errorf("got %d texts, %d categories and %d importances", len(texts), len(categories), len(importances))
+	}
+	for i, text := range texts {
+		if strings.TrimSpace(text) == "" {
+			return nil, fmt.Errorf("entry %d: text required", i)
+		}
+	}
+
+	vectors, err := s.getEmbeddings(texts)
+	if err != nil {
+		return nil, fmt.Errorf("embedding failed: %v", err)
+	}
+	entries := make([]MemoryEntry, len(texts))
+	for i, text := range texts {
+		entries[i] = MemoryEntry{Text: text, Vector: vectors[i], Importance: 0.5}
+		if categories != nil {
+			entries[i].Category = categories[i]
+		}
+		if importances != nil {
+			entries[i].Importance = importances[i]
+		}
+	}
+	return s.StoreEmbedded(entries)
+}
+
+// StoreEmbedded persists entries whose vectors were computed by the caller
+// (e.g. the embedding server's /embed-store, via Agent.MemoryStoreBatch) in a single transaction.
+// Entry IDs and timestamps are assigned here; the new IDs are returned in order.
+func (s *VectorMemoryStore) StoreEmbedded(entries []MemoryEntry) ([]string, error) {
+	ids, err := s.storeEmbedded(entries, nil)
+	if err == nil && len(ids) > 0 {
+		log.Printf("✅ Memory stored: %d pre-embedded entries", len(ids))
+	}
+	return ids, err
+}
+
+// storeEmbedded is StoreEmbedded with optional preassigned IDs (the write queue's)
+func (s *VectorMemoryStore) storeEmbedded(entries []MemoryEntry, ids []string) ([]string, error) {
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	for i, e := range entries {
+		if strings.TrimSpace(e.Text) == "" {
+			return nil, fmt.Errorf("entry %d: text required", i)
+		}
+		if len(e.Vector) == 0 {
+			return nil, fmt.Errorf("entry %d: vector required", i)
+		}
+		if s.hnsw != nil && s.hnsw.Dim() > 0 && len(e.Vector) != s.hnsw.Dim() {
+			return nil, fmt.Errorf("entry %d: dim mismatch %d != %d", i, len(e.Vector), s.hnsw.Dim())
+		}
+		if err := s.checkVectorDim(e.Vector); err != nil {
+			return nil, fmt.Errorf("entry %d: %v", i, err)
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	stmt, err := tx.Prepare(`
+		INSERT INTO vector_memories (id, text, vector, vector_norm, importance, category, source, external_id, embedding_dim, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	defer stmt.Close()
+
+	now := time.Now().Unix()
+	if ids == nil {
+		ids = make([]string, len(entries))
+		for i := range ids {
+			ids[i] = s.newID()
+		}
+	}
+	vectors := make([][]float32, len(entries))
+	for i, e := range entries {
+		// Always normalized: another process may load these into a cosine HNSW index
+		vector := append([]float32(nil), e.Vector...)
+		normalizeVector(vector)
+		category := e.Category
+		if category == "" {
+			category = "other"
+		}
+		source := e.Source
+		if source == "" {
+			source = "manual"
+		}
+		vectors[i] = vector
+		if _, err := stmt.Exec(ids[i], e.Text, serializeVector(vector), vectorNorm(vector), e.Importance, category, source, nullString(strings.TrimSpace(e.ExternalID)), len(vector), now, now); err != nil {
+			tx.Rollback()
+			return nil, fmt.Errorf("entry %d: %v", i, err)
+		}
+		entries[i].Category = category
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	for i, e := range entries {
+		s.upsertFTS(ids[i], e.Text, e.Category)
+	}
+	s.lockIndex()
+	defer s.unlockIndex()
+	if s.hnsw != nil {
+		if err := s.hnsw.Add(vectors); err != nil {
+			log.Printf("HNSW batch add failed, rebuilding: %v", err)
+			s.rebuildHNSWLocked()
+		} else {
+			s.hnswIDs = append(s.hnswIDs, ids...)
+			s.saveHNSW()
+			categories := make([]string, len(entries))
+			for i, e := range entries {
+				categories[i] = e.Category
+			}
+			s.addToPartitions(ids, categories, vectors)
+		}
+	}
+	return ids, nil
+}
+
 // Update existing memory (re-embed on text change)
 func (s *VectorMemoryStore) Update(id string, text string, category string, importance float64) (bool, error) {
 	if id == "" {
@@ -474,30 +927,99 @@ func (s *VectorMemoryStore) Update(id string, text string, category string, impo
 		if err != nil {
 			return false, err
 		}
+		if err := s.checkVectorDim(vector); err != nil {
+			return false, err
+		}
 	}
 
 	now := time.Now().Unix()
 	_, err = s.db.Exec(`
 		UPDATE vector_memories
-		SET text = ?, vector = ?, importance = ?, category = ?, updated_at = ?
+		SET text = ?, vector = ?, vector_norm = ?, importance = ?, category = ?, updated_at = ?
 		WHERE id = ?
-	`, newText, serializeVector(vector), newImportance, newCategory, now, id)
+	`, newText, serializeVector(vector), vectorNorm(vector), newImportance, newCategory, now, id)
 	if err != nil {
 		return false, err
 	}
 
 	s.upsertFTS(id, newText, newCategory)
-	s.rebuildHNSW()
+	switch {
+	case strings.TrimSpace(text) != "":
+		s.replaceInIndex(id, entry.Category, newCategory, vector)
+	case newCategory != entry.Category:
+		s.movePartition(id, entry.Category, newCategory, vector)
+	}
 	return true, nil
 }
 
+// Upsert stores text under externalID, or updates the memory that already has it.
+// The text is only re-embedded when it changed, so re-ingesting the same
+// document is cheap. created reports whether a new memory was inserted.
+func (s *VectorMemoryStore) Upsert(externalID string, text string, category string, importance float64, source string) (id string, created bool, err error) {
+	externalID = strings.TrimSpace(externalID)
+	if externalID == "" {
+		return "", false, fmt.Errorf("external id required")
+	}
+	if strings.TrimSpace(text) == "" {
+		return "", false, fmt.Errorf("text required")
+	}
+
+	existing, found, err := s.GetByExternalID(externalID)
+	if err != nil {
+		return "", false, err
+	}
+	if !found {
+		id, err := s.StoreWithExternalID(externalID, text, category, importance, source)
+		return id, err == nil, err
+	}
+
+	newText := text
+	if newText == existing.Text {
+		newText = "" // unchanged: keep the stored vector
+	}
+	if _, err := s.Update(existing.ID, newText, category, importance); err != nil {
+		return "", false, err
+	}
+	return existing.ID, false, nil
+}
+
+// GetByExternalID looks up a memory by its caller-assigned ID
+func (s *VectorMemoryStore) GetByExternalID(externalID string) (MemoryEntry, bool, error) {
+	var id string
+	err := s.db.QueryRow("SELECT id FROM vector_memories WHERE external_id = ?", externalID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return MemoryEntry{}, false, nil
+	}
+	if err != nil {
+		return MemoryEntry{}, false, err
+	}
+	entry, err := s.getByID(id)
+	return entry, err == nil, err
+}
+
+// nullString maps "" to NULL so the unique external_id index ignores untagged rows
+func nullString(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
+
 func (s *VectorMemoryStore) getEmbedding(text string) ([]float32, error) {
 	var vector []float32
 	var err error
 	if s.embedding != nil {
-		vector, err = s.embedding.Embed(text)
-		if err != nil {
-			return nil, err
+		cached, ok := s.embedCache.get(text)
+		if ok {
+			vector = cached
+		} else {
+			start := time.Now()
+			vector, err = s.embedding.Embed(text)
+			s.embedStats.record(time.Since(start), err)
+			if err != nil {
+				return nil, err
+			}
+			s.embedCache.put(text, vector)
 		}
 	} else {
 		// Placeholder vector
@@ -508,30 +1030,129 @@ func (s *VectorMemoryStore) getEmbedding(text string) ([]float32, error) {
 	}
 
 	// Normalize for cosine/ip metrics
+	s.idxMu.RLock()
+	metric := ""
 	if s.hnsw != nil {
-		metric := s.hnsw.Metric()
-		if metric == "cosine" || metric == "ip" {
-			normalizeVector(vector)
-		}
+		metric = s.hnsw.Metric()
+	}
+	s.idxMu.RUnlock()
+	if metric == "cosine" || metric == "ip" {
+		normalizeVector(vector)
 	}
 	return vector, nil
 }
 
+// getEmbeddings embeds texts in order, in batches when the provider supports it
+func (s *VectorMemoryStore) getEmbeddings(texts []string) ([][]float32, error) {
+	batcher, ok := s.embedding.(BatchEmbedder)
+	if !ok {
+		vectors := make([][]float32, len(texts))
+		for i, text := range texts {
+			vec, err := s.getEmbedding(text)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: %v", i, err)
+			}
+			vectors[i] = vec
+		}
+		return vectors, nil
+	}
+
+	// Only cache misses go to the provider; missing maps them back to their slot
+	vectors := make([][]float32, len(texts))
+	var missing []int
+	for i, text := range texts {
+		if vec, ok := s.embedCache.get(text); ok {
+			vectors[i] = vec
+			continue
+		}
+		missing = append(missing, i)
+	}
+	for start := 0; start < len(missing); start += embedBatchSize {
+		end := start + embedBatchSize
+		if end > len(missing) {
+			end = len(missing)
+		}
+		batchTexts := make([]string, end-start)
+		for j, i := range missing[start:end] {
+			batchTexts[j] = texts[i]
+		}
+		t0 := time.Now()
+		batch, err := batcher.EmbedBatch(batchTexts)
+		s.embedStats.record(time.Since(t0), err)
+		if err != nil {
+			return nil, fmt.Errorf("entries %d-%d: %v", missing[start], missing[end-1], err)
+		}
+		for j, i := range missing[start:end] {
+			vectors[i] = batch[j]
+			s.embedCache.put(texts[i], batch[j])
+		}
+	}
+	return vectors, nil
+}
+
+// Embed returns the normalized provider embedding of text; it fails without a provider
+// since placeholder vectors carry no meaning outside the store
+func (s *VectorMemoryStore) Embed(text string) ([]float32, error) {
+	if s.embedding == nil {
+		return nil, fmt.Errorf("no embedding provider")
+	}
+	vec, err := s.getEmbedding(text)
+	if err != nil {
+		return nil, err
+	}
+	out := append([]float32(nil), vec...)
+	normalizeVector(out)
+	return out, nil
+}
+
+// Warmup embeds a short text so the provider's connection (and, for a local
+// server, its model) is ready before the first Store or Search
+func (s *VectorMemoryStore) Warmup() error {
+	if s.embedding == nil {
+		return nil
+	}
+	_, err := s.getEmbedding("warmup")
+	return err
+}
+
 // Search - with similarity scores
 func (s *VectorMemoryStore) Search(query string, limit int, minScore float32) ([]MemoryResult, error) {
+	return s.SearchFiltered(query, SearchOptions{Limit: limit, MinScore: minScore})
+}
+
+// SearchCategory is Search restricted to one category ("" = all). With
+// HNSWPartitioned the vector part only walks that category's index.
+func (s *VectorMemoryStore) SearchCategory(query string, category string, limit int, minScore float32) ([]MemoryResult, error) {
+	return s.SearchFiltered(query, SearchOptions{Limit: limit, MinScore: minScore, Categories: []string{category}})
+}
+
+// SearchFiltered is Search with filters applied to the candidates (SQL conditions,
+// over-fetched index hits), so up to opts.Limit results are returned after filtering.
+func (s *VectorMemoryStore) SearchFiltered(query string, opts SearchOptions) ([]MemoryResult, error) {
+	limit := opts.Limit
 	if limit <= 0 {
 		limit = s.cfg.MaxResults
 	}
+	minScore := opts.MinScore
 	if minScore == 0 {
 		minScore = s.cfg.MinScore
 	}
-
-	if s.embedding == nil {
-		return s.keywordSearch(query, limit)
+	f := opts.filter()
+	if !s.cfg.DedupResults {
+		results, err := s.searchFiltered(query, f, limit, minScore)
+		return opts.Projection.trim(results), err
 	}
+	// Over-fetch so collapsed copies do not leave the result short
+	results, err := s.searchFiltered(query, f, limit*dedupOverfetch, minScore)
+	if err != nil {
+		return nil, err
+	}
+	return opts.Projection.trim(s.dedupResults(results, limit)), nil
+}
 
+func (s *VectorMemoryStore) searchFiltered(query string, f searchFilter, limit int, minScore float32) ([]MemoryResult, error) {
 	if s.embedding == nil {
-		return s.keywordSearch(query, limit)
+		return s.keywordSearch(query, f, limit)
 	}
 
 	queryVec, err := s.getEmbedding(query)
@@ -540,37 +1161,103 @@ func (s *VectorMemoryStore) Search(query string, limit int, minScore float32) ([
 	}
 
 	if s.cfg.HybridEnabled {
-		return s.hybridSearch(query, queryVec, limit, minScore)
+		return s.hybridSearch(query, f, queryVec, limit, minScore)
 	}
 
-	var results []MemoryResult
+	results, err := s.vectorSearch(queryVec, f, limit)
+	if err != nil {
+		return nil, err
+	}
+	filtered := results[:0]
+	for _, r := range results {
+		if r.Score >= minScore {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered, nil
+}
 
-	// FAISS HNSW search (preferred)
-	if s.hnsw != nil && s.hnsw.Count() > 0 {
-		results, err = s.hnswSearch(queryVec, limit, minScore)
-	} else {
-		// Fallback to SQLite linear search
-		results, err = s.linearSearch(queryVec, limit, minScore)
+// rrfK is the Reciprocal Rank Fusion constant (standard value from the RRF paper)
+const rrfK = 60
+
+// SearchMulti runs every query and fuses the ranked lists with Reciprocal Rank Fusion.
+// Results are deduped by ID; Score is the fused score scaled to 0-1.
+func (s *VectorMemoryStore) SearchMulti(queries []string, limit int) ([]MemoryResult, error) {
+	return s.SearchMultiScored(queries, limit, 0)
+}
+
+// SearchMultiScored is SearchMulti with a per-query minimum similarity.
+func (s *VectorMemoryStore) SearchMultiScored(queries []string, limit int, minScore float32) ([]MemoryResult, error) {
+	if limit <= 0 {
+		limit = s.cfg.MaxResults
 	}
 
-	return results, err
+	fused := make(map[string]float32)
+	entries := make(map[string]MemoryResult)
+	used := 0
+	var lastErr error
+	for _, q := range queries {
+		q = strings.TrimSpace(q)
+		if q == "" {
+			continue
+		}
+		results, err := s.Search(q, limit*2, minScore)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		used++
+		for rank, r := range results {
+			fused[r.Entry.ID] += 1.0 / float32(rrfK+rank+1)
+			if _, ok := entries[r.Entry.ID]; !ok {
+				entries[r.Entry.ID] = r
+			}
+		}
+	}
+	if used == 0 {
+		return nil, lastErr
+	}
+
+	// Best possible fused score: rank 1 in every query
+	maxScore := float32(used) / float32(rrfK+1)
+	results := make([]MemoryResult, 0, len(fused))
+	for id, score := range fused {
+		r := entries[id]
+		r.Score = score / maxScore
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+	if s.cfg.DedupResults {
+		return s.dedupResults(results, limit), nil
+	}
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// HNSW search; ef <= 0 uses the index's efSearch. The caller holds idxMu.RLock.
+func (s *VectorMemoryStore) hnswSearch(queryVec []float32, limit int, minScore float32, ef int) ([]MemoryResult, error) {
+	return s.searchIndex(s.hnsw, s.hnswIDs, queryVec, limit, minScore, ef)
 }
 
-// HNSW search
-func (s *VectorMemoryStore) hnswSearch(queryVec []float32, limit int, minScore float32) ([]MemoryResult, error) {
-	distances, labels, err := s.hnsw.SearchWithScores(queryVec, limit)
+// searchIndex runs a k-NN query on idx; ids maps its labels to memory IDs
+func (s *VectorMemoryStore) searchIndex(idx *HNSWIndex, ids []string, queryVec []float32, limit int, minScore float32, ef int) ([]MemoryResult, error) {
+	distances, labels, err := idx.SearchWithEf(queryVec, limit, ef)
 	if err != nil {
 		return nil, err
 	}
 
-	metric := s.hnsw.Metric()
+	metric := idx.Metric()
 	results := make([]MemoryResult, 0, limit)
 	for i, dist := range distances {
 		label := int(labels[i])
-		if label < 0 || label >= len(s.hnswIDs) {
+		if label < 0 || label >= len(ids) {
 			continue
 		}
-		id := s.hnswIDs[label]
+		id := ids[label]
 		entry, err := s.getByID(id)
 		if err != nil {
 			continue
@@ -597,32 +1284,74 @@ func (s *VectorMemoryStore) hnswSearch(queryVec []float32, limit int, minScore f
 	return results, nil
 }
 
-// SQLite linear search (fallback)
-func (s *VectorMemoryStore) linearSearch(queryVec []float32, limit int, minScore float32) ([]MemoryResult, error) {
+// Related finds memories nearest to the stored vector of id, excluding id itself.
+func (s *VectorMemoryStore) Related(id string, limit int) ([]MemoryResult, error) {
+	if limit <= 0 {
+		limit = s.cfg.MaxResults
+	}
+	entry, err := s.getByID(id)
+	if err != nil {
+		return nil, fmt.Errorf("memory not found: %v", err)
+	}
+	if len(entry.Vector) == 0 {
+		return nil, fmt.Errorf("memory %s has no vector", shortID(id))
+	}
+
+	// One extra candidate because the entry itself is usually the top hit
+	candidates, err := s.vectorSearch(entry.Vector, searchFilter{}, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]MemoryResult, 0, limit)
+	for _, r := range candidates {
+		if r.Entry.ID == id {
+			continue
+		}
+		results = append(results, r)
+		if len(results) >= limit {
+			break
+		}
+	}
+	return results, nil
+}
+
+// SQLite linear search (fallback). Rows are scored from id, vector and stored norm
+// only; the full entry is loaded for the top hits.
+func (s *VectorMemoryStore) linearSearch(queryVec []float32, f searchFilter, limit int, minScore float32) ([]MemoryResult, error) {
+	where, args := f.where()
 	rows, err := s.db.Query(`
-		SELECT id, text, vector, importance, category, source, created_at, updated_at FROM vector_memories
-	`)
+		SELECT id, vector, COALESCE(vector_norm, 0) FROM vector_memories
+		WHERE `+where, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	type withScore struct {
-		entry MemoryEntry
+		id    string
 		score float32
 	}
 
+	queryNorm := vectorNorm(queryVec)
+	vec := make([]float32, len(queryVec)) // reused: only the winners are loaded again
 	var all []withScore
 	for rows.Next() {
 		var w withScore
-		var vectorBlob []byte
-		if err := rows.Scan(&w.entry.ID, &w.entry.Text, &vectorBlob,
-			&w.entry.Importance, &w.entry.Category, &w.entry.Source, &w.entry.CreatedAt, &w.entry.UpdatedAt); err != nil {
+		var blob sql.RawBytes
+		var norm float32
+		if err := rows.Scan(&w.id, &blob, &norm); err != nil {
 			return nil, err
 		}
-		w.entry.Vector = deserializeVector(vectorBlob)
-		if len(w.entry.Vector) == len(queryVec) {
-			w.score = cosineSimilarity(queryVec, w.entry.Vector)
+		if len(blob) == len(queryVec)*4 {
+			decodeVector(vec, blob)
+			if norm == 0 {
+				// Rows from before vector_norm (or restored from an old snapshot)
+				norm = vectorNorm(vec)
+			}
+			w.score = cosineWithNorms(queryVec, vec, queryNorm, norm)
+		}
+		if w.score < minScore {
+			continue
 		}
 		all = append(all, w)
 	}
@@ -630,38 +1359,38 @@ func (s *VectorMemoryStore) linearSearch(queryVec []float32, limit int, minScore
 		return nil, err
 	}
 
-	// Sorting
-	for i := 0; i < len(all)-1; i++ {
-		for j := i + 1; j < len(all); j++ {
-			if all[j].score > all[i].score {
-				all[i], all[j] = all[j], all[i]
-			}
-		}
+	sort.Slice(all, func(i, j int) bool {
+		return all[i].score > all[j].score
+	})
+	if len(all) > limit {
+		all = all[:limit]
 	}
 
-	// Filter and return top N
-	results := make([]MemoryResult, 0, limit)
+	results := make([]MemoryResult, 0, len(all))
 	for _, w := range all {
-		if w.score >= minScore && len(results) < limit {
-			results = append(results, MemoryResult{
-				Entry:   w.entry,
-				Score:   w.score,
-				Matched: w.score >= minScore,
-			})
+		entry, err := s.getByID(w.id)
+		if err != nil {
+			continue
 		}
+		results = append(results, MemoryResult{
+			Entry:   entry,
+			Score:   w.score,
+			Matched: true,
+		})
 	}
 	return results, nil
 }
 
 // Keyword search (fallback when no embedding service)
-func (s *VectorMemoryStore) keywordSearch(query string, limit int) ([]MemoryResult, error) {
+func (s *VectorMemoryStore) keywordSearch(query string, f searchFilter, limit int) ([]MemoryResult, error) {
+	where, args := f.where()
 	rows, err := s.db.Query(`
-		SELECT id, text, importance, category, source, created_at, updated_at
+		SELECT id, text, importance, category, source, COALESCE(external_id, ''), created_at, updated_at
 		FROM vector_memories
-		WHERE text LIKE ? OR category LIKE ?
+		WHERE (text LIKE ? OR category LIKE ?) AND `+where+`
 		ORDER BY importance DESC, created_at DESC
 		LIMIT ?
-	`, "%"+query+"%", "%"+query+"%", limit)
+	`, append(append([]interface{}{"%" + query + "%", "%" + query + "%"}, args...), limit)...)
 	if err != nil {
 		return nil, err
 	}
@@ -670,7 +1399,7 @@ func (s *VectorMemoryStore) keywordSearch(query string, limit int) ([]MemoryResu
 	results := make([]MemoryResult, 0, limit)
 	for rows.Next() {
 		var entry MemoryEntry
-		if err := rows.Scan(&entry.ID, &entry.Text, &entry.Importance, &entry.Category, &entry.Source, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
+		if err := rows.Scan(&entry.ID, &entry.Text, &entry.Importance, &entry.Category, &entry.Source, &entry.ExternalID, &entry.CreatedAt, &entry.UpdatedAt); err != nil {
 			return nil, err
 		}
 		results = append(results, MemoryResult{
@@ -689,14 +1418,26 @@ func (s *VectorMemoryStore) keywordSearch(query string, limit int) ([]MemoryResu
 }
 
 // FTS5 keyword search (returns bm25 score)
-func (s *VectorMemoryStore) ftsSearch(query string, limit int) (map[string]float32, error) {
+func (s *VectorMemoryStore) ftsSearch(query string, f searchFilter, limit int) (map[string]float32, error) {
+	expr := s.ftsExpression(query)
+	if strings.TrimSpace(expr) == "" {
+		return map[string]float32{}, nil
+	}
+	filter := ""
+	args := []interface{}{expr}
+	if !f.empty() {
+		// The FTS table only carries id/text/category; filter against the main table
+		where, whereArgs := f.where()
+		filter = "AND id IN (SELECT id FROM vector_memories WHERE " + where + ")"
+		args = append(args, whereArgs...)
+	}
 	rows, err := s.db.Query(`
 		SELECT id, bm25(vector_memories_fts) AS score
 		FROM vector_memories_fts
-		WHERE vector_memories_fts MATCH ?
+		WHERE vector_memories_fts MATCH ? `+filter+`
 		ORDER BY score ASC
 		LIMIT ?
-	`, query, limit)
+	`, append(args, limit)...)
 	if err != nil {
 		return nil, err
 	}
@@ -717,14 +1458,15 @@ func (s *VectorMemoryStore) ftsSearch(query string, limit int) (map[string]float
 	return out, nil
 }
 
-func (s *VectorMemoryStore) likeScores(query string, limit int) map[string]float32 {
+func (s *VectorMemoryStore) likeScores(query string, f searchFilter, limit int) map[string]float32 {
+	where, args := f.where()
 	rows, err := s.db.Query(`
 		SELECT id
 		FROM vector_memories
-		WHERE text LIKE ? OR category LIKE ?
+		WHERE (text LIKE ? OR category LIKE ?) AND `+where+`
 		ORDER BY importance DESC, created_at DESC
 		LIMIT ?
-	`, "%"+query+"%", "%"+query+"%", limit)
+	`, append(append([]interface{}{"%" + query + "%", "%" + query + "%"}, args...), limit)...)
 	if err != nil {
 		return map[string]float32{}
 	}
@@ -742,18 +1484,18 @@ func (s *VectorMemoryStore) likeScores(query string, limit int) map[string]float
 }
 
 // Hybrid search: vector + BM25
-func (s *VectorMemoryStore) hybridSearch(query string, queryVec []float32, limit int, minScore float32) ([]MemoryResult, error) {
+func (s *VectorMemoryStore) hybridSearch(query string, f searchFilter, queryVec []float32, limit int, minScore float32) ([]MemoryResult, error) {
 	cand := limit * s.cfg.CandidateMult
-	vecResults, err := s.vectorSearch(queryVec, cand)
+	vecResults, err := s.vectorSearch(queryVec, f, cand)
 	if err != nil {
 		return nil, err
 	}
 
 	textScores := map[string]float32{}
 	if s.ftsAvailable {
-		textScores, _ = s.ftsSearch(query, cand)
+		textScores, _ = s.ftsSearch(query, f, cand)
 	} else {
-		textScores = s.likeScores(query, cand)
+		textScores = s.likeScores(query, f, cand)
 	}
 
 	type scored struct {
@@ -762,21 +1504,39 @@ func (s *VectorMemoryStore) hybridSearch(query string, queryVec []float32, limit
 	}
 	merged := make(map[string]*scored)
 
+	// bm25() is lower-is-better (negative for matches); LIKE matches all count as 1
+	textRel := make(map[string]float32, len(textScores))
+	for id, v := range textScores {
+		switch {
+		case s.cfg.HybridNorm == HybridNormNone:
+			v = float32(1.0 / (1.0 + maxf(0, v)))
+		case s.ftsAvailable:
+			v = -v
+		}
+		textRel[id] = v
+	}
+	vecScores := make(map[string]float32, len(vecResults))
 	for _, r := range vecResults {
-		merged[r.Entry.ID] = &scored{entry: r.Entry, score: s.cfg.VectorWeight * r.Score}
+		vecScores[r.Entry.ID] = r.Score
 	}
+	fused := s.fuseHybrid(vecScores, textRel)
 
-	for id, bm25 := range textScores {
+	// Candidates fuseHybrid dropped (NaN scores) are not results
+	for _, r := range vecResults {
+		if score, ok := fused[r.Entry.ID]; ok {
+			merged[r.Entry.ID] = &scored{entry: r.Entry, score: score}
+		}
+	}
+	for id := range textRel {
+		score, ok := fused[id]
+		if _, dup := merged[id]; dup || !ok {
+			continue
+		}
 		entry, err := s.getByID(id)
 		if err != nil {
 			continue
 		}
-		textScore := float32(1.0 / (1.0 + maxf(0, bm25)))
-		if m, ok := merged[id]; ok {
-			m.score = m.score + s.cfg.TextWeight*textScore
-		} else {
-			merged[id] = &scored{entry: entry, score: s.cfg.TextWeight * textScore}
-		}
+		merged[id] = &scored{entry: entry, score: score}
 	}
 
 	// Sorting
@@ -810,12 +1570,47 @@ func (s *VectorMemoryStore) hybridSearch(query string, queryVec []float32, limit
 	return results, nil
 }
 
-// Unified vector search (for hybrid candidate pool)
-func (s *VectorMemoryStore) vectorSearch(queryVec []float32, limit int) ([]MemoryResult, error) {
-	if s.hnsw != nil && s.hnsw.Count() > 0 {
-		return s.hnswSearch(queryVec, limit, 0)
+// Unified vector search (for hybrid candidate pool); an empty filter searches everything
+func (s *VectorMemoryStore) vectorSearch(queryVec []float32, f searchFilter, limit int) ([]MemoryResult, error) {
+	if category, exact := f.partition(); category != "" {
+		if exact {
+			if results, ok, err := s.partitionSearch(category, queryVec, limit, 0, f.ef); ok {
+				return results, err
+			}
+		} else if s.partitionsEnabled() {
+			var served bool
+			results, err := s.overfetch(f, limit, func(k int) ([]MemoryResult, error) {
+				results, ok, err := s.partitionSearch(category, queryVec, k, 0, f.ef)
+				served = ok
+				return results, err
+			})
+			if served {
+				return results, err
+			}
+		}
+	}
+	if results, ok, err := s.mainIndexSearch(queryVec, f, limit); ok {
+		return results, err
+	}
+	return s.linearSearch(queryVec, f, limit, 0)
+}
+
+// mainIndexSearch searches the main HNSW index; ok is false when it is off or empty
+func (s *VectorMemoryStore) mainIndexSearch(queryVec []float32, f searchFilter, limit int) (results []MemoryResult, ok bool, err error) {
+	s.idxMu.RLock()
+	defer s.idxMu.RUnlock()
+	if s.hnsw == nil || s.hnsw.Count() == 0 {
+		return nil, false, nil
 	}
-	return s.linearSearch(queryVec, limit, 0)
+	if f.empty() {
+		results, err = s.hnswSearch(queryVec, limit, 0, f.ef)
+		return results, true, err
+	}
+	// No partition: over-fetch from the main index and keep what passes the filter
+	results, err = s.overfetch(f, limit, func(k int) ([]MemoryResult, error) {
+		return s.hnswSearch(queryVec, k, 0, f.ef)
+	})
+	return results, true, err
 }
 
 func maxf(a float32, b float32) float32 {
@@ -829,8 +1624,8 @@ func (s *VectorMemoryStore) getByID(id string) (MemoryEntry, error) {
 	var entry MemoryEntry
 	var vectorBlob []byte
 	s.db.QueryRow(`
-		SELECT text, vector, importance, category, source, created_at, updated_at FROM vector_memories WHERE id = ?
-	`, id).Scan(&entry.Text, &vectorBlob, &entry.Importance, &entry.Category, &entry.Source, &entry.CreatedAt, &entry.UpdatedAt)
+		SELECT text, vector, importance, category, source, COALESCE(external_id, ''), created_at, updated_at FROM vector_memories WHERE id = ?
+	`, id).Scan(&entry.Text, &vectorBlob, &entry.Importance, &entry.Category, &entry.Source, &entry.ExternalID, &entry.CreatedAt, &entry.UpdatedAt)
 	entry.ID = id
 	entry.Vector = deserializeVector(vectorBlob)
 	return entry, nil
@@ -842,6 +1637,8 @@ func (s *VectorMemoryStore) Get(id string) (MemoryEntry, error) {
 }
 
 func (s *VectorMemoryStore) Delete(id string) (bool, error) {
+	var category string
+	s.db.QueryRow("SELECT COALESCE(category, '') FROM vector_memories WHERE id = ?", id).Scan(&category)
 	res, err := s.db.Exec("DELETE FROM vector_memories WHERE id = ?", id)
 	if err != nil {
 		return false, err
@@ -852,40 +1649,73 @@ func (s *VectorMemoryStore) Delete(id string) (bool, error) {
 	}
 	// remove from FTS
 	s.db.Exec("DELETE FROM vector_memories_fts WHERE id = ?", id)
-	// Rebuild HNSW to keep in sync
-	s.rebuildHNSW()
+	s.dropFromIndex(id, category)
 	return true, nil
 }
 
 func (s *VectorMemoryStore) rebuildHNSW() {
+	s.lockIndex()
+	defer s.unlockIndex()
+	s.rebuildHNSWLocked()
+}
+
+// rebuildHNSWLocked is rebuildHNSW for a caller holding lockIndex
+func (s *VectorMemoryStore) rebuildHNSWLocked() {
 	if s.hnsw == nil {
 		return
 	}
-	cfg := s.hnsw.Config()
-	s.hnsw.Close()
-	idx, err := NewHNSWIndex(cfg)
-	if err != nil {
+	if err := s.freshIndex(); err != nil {
 		log.Printf("rebuild HNSW failed: %v", err)
-		s.hnsw = nil
 		s.hnswIDs = nil
+		s.resetPartitions()
 		return
 	}
-	s.hnsw = idx
 	s.hnswIDs = nil
 	s.loadExistingVectors()
 	s.saveHNSW()
 }
 
+// HNSWConfig returns the active HNSW parameters; ok is false when the index is disabled
+func (s *VectorMemoryStore) HNSWConfig() (cfg HNSWConfig, ok bool) {
+	s.idxMu.RLock()
+	defer s.idxMu.RUnlock()
+	if s.hnsw == nil {
+		return HNSWConfig{}, false
+	}
+	return s.hnsw.Config(), true
+}
+
+// SetEfSearch tunes HNSW search-time ef (higher = better recall, slower queries)
+func (s *VectorMemoryStore) SetEfSearch(ef int) error {
+	s.idxMu.RLock()
+	defer s.idxMu.RUnlock()
+	if s.hnsw == nil {
+		return fmt.Errorf("HNSW index not enabled")
+	}
+	if err := s.hnsw.SetEfSearch(ef); err != nil {
+		return err
+	}
+	s.partMu.RLock()
+	for _, p := range s.partitions {
+		p.index.SetEfSearch(ef)
+	}
+	s.partMu.RUnlock()
+	log.Printf("🔧 HNSW efSearch set to %d", ef)
+	return nil
+}
+
 func (s *VectorMemoryStore) Count() (int, error) {
 	var count int
 	return count, s.db.QueryRow("SELECT COUNT(*) FROM vector_memories").Scan(&count)
 }
 
 func (s *VectorMemoryStore) Close() error {
+	s.stopWriteQueue()
+	s.lockIndex()
+	defer s.unlockIndex()
+	s.resetPartitions()
 	if s.hnsw != nil {
-		if s.cfg.HNSWPath != "" {
-			s.hnsw.Save(s.cfg.HNSWPath)
-		}
+		s.saveHNSW()
 		s.hnsw.Close()
 	}
 	return s.db.Close()
@@ -893,8 +1723,14 @@ func (s *VectorMemoryStore) Close() error {
 
 // Load existing vectors into HNSW
 func (s *VectorMemoryStore) loadExistingVectors() {
+	s.loadVectors(hnswIDsPath(s.cfg.HNSWPath))
+}
+
+// loadVectors fills the index from SQLite. An index loaded from disk is mapped back
+// onto the rows with the label mapping saved at idsPath, and rebuilt without one.
+func (s *VectorMemoryStore) loadVectors(idsPath string) {
 	s.rebuildFTSIfEmpty()
-	rows, err := s.db.Query("SELECT id, vector, embedding_dim FROM vector_memories ORDER BY rowid")
+	rows, err := s.db.Query("SELECT id, vector, embedding_dim, COALESCE(category, '') FROM vector_memories ORDER BY rowid")
 	if err != nil {
 		return
 	}
@@ -902,11 +1738,13 @@ func (s *VectorMemoryStore) loadExistingVectors() {
 
 	var vectors [][]float32
 	var ids []string
+	var categories []string
 	for rows.Next() {
 		var id string
 		var vectorBlob []byte
 		var embeddingDim sql.NullInt64
-		if err := rows.Scan(&id, &vectorBlob, &embeddingDim); err != nil {
+		var category string
+		if err := rows.Scan(&id, &vectorBlob, &embeddingDim, &category); err != nil {
 			log.Printf("hnsw reload scan err: %v", err)
 			continue
 		}
@@ -934,32 +1772,60 @@ func (s *VectorMemoryStore) loadExistingVectors() {
 		}
 		vectors = append(vectors, vector)
 		ids = append(ids, id)
+		categories = append(categories, category)
 	}
 	if err := rows.Err(); err != nil {
 		log.Printf("hnsw reload rows err: %v", err)
 	}
 
-	if s.hnsw != nil {
+	if s.hnsw == nil {
+		return
+	}
+	if s.hnsw.Loaded() && !s.mapLoadedIndex(idsPath, ids, vectors) {
+		if err := s.freshIndex(); err != nil {
+			log.Printf("HNSW rebuild failed: %v", err)
+			s.hnswIDs = nil
+			s.resetPartitions()
+			return
+		}
+	}
+	if !s.hnsw.Loaded() {
 		s.hnswIDs = ids
 		if len(vectors) > 0 {
-			if s.hnsw.Loaded() {
-				log.Printf("HNSW loaded from disk, restored %d id mappings", len(ids))
+			if err := s.hnsw.Add(vectors); err != nil {
+				log.Printf("Load existing vectors add failed: %v", err)
 			} else {
-				if err := s.hnsw.Add(vectors); err != nil {
-					log.Printf("Load existing vectors add failed: %v", err)
-				} else {
-					log.Printf("Loaded %d vectors into HNSW", len(vectors))
-				}
+				log.Printf("Loaded %d vectors into HNSW", len(vectors))
 			}
-			s.saveHNSW()
 		}
 	}
+	if len(vectors) > 0 {
+		s.saveHNSW()
+	}
+	s.buildPartitions(ids, categories, vectors)
 }
 
+// lockIndex takes the locks for changing hnsw and hnswIDs: the write side of
+// snapMu, so that a snapshot sees them in step, and of idxMu against searches
+func (s *VectorMemoryStore) lockIndex() {
+	s.snapMu.Lock()
+	s.idxMu.Lock()
+}
+
+func (s *VectorMemoryStore) unlockIndex() {
+	s.idxMu.Unlock()
+	s.snapMu.Unlock()
+}
+
+// saveHNSW writes the index and its label mapping to HNSWPath (under lockIndex)
 func (s *VectorMemoryStore) saveHNSW() {
 	if s.hnsw != nil && s.cfg.HNSWPath != "" {
 		if err := s.hnsw.Save(s.cfg.HNSWPath); err != nil {
 			log.Printf("save hnsw failed: %v", err)
+			return
+		}
+		if err := saveHNSWIDs(hnswIDsPath(s.cfg.HNSWPath), s.hnswIDs); err != nil {
+			log.Printf("save hnsw id mapping failed: %v", err)
 		}
 	}
 }
@@ -985,6 +1851,34 @@ func serializeVector(v []float32) []byte {
 	return result
 }
 
+// backfillVectorNorms fills vector_norm for rows stored before the column existed
+func backfillVectorNorms(tx *sql.Tx) error {
+	rows, err := tx.Query("SELECT id, vector FROM vector_memories WHERE vector_norm IS NULL")
+	if err != nil {
+		return err
+	}
+	norms := make(map[string]float32)
+	for rows.Next() {
+		var id string
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			rows.Close()
+			return err
+		}
+		norms[id] = vectorNorm(deserializeVector(blob))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for id, norm := range norms {
+		if _, err := tx.Exec("UPDATE vector_memories SET vector_norm = ? WHERE id = ?", norm, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *VectorMemoryStore) backfillEmbeddingDim() {
 	rows, err := s.db.Query("SELECT id, vector, embedding_dim FROM vector_memories WHERE embedding_dim IS NULL OR embedding_dim = 0")
 	if err != nil {
@@ -1062,11 +1956,18 @@ func (s *VectorMemoryStore) rebuildFTSIfEmpty() {
 	if err != nil {
 		return
 	}
-	defer rows.Close()
+	// Read everything before writing: inserting while the cursor holds its read
+	// lock would wait out busy_timeout on each row (rollback journal mode)
+	type ftsRow struct{ id, text, category string }
+	var pending []ftsRow
 	for rows.Next() {
-		var id, text, category string
-		rows.Scan(&id, &text, &category)
-		s.upsertFTS(id, text, category)
+		var r ftsRow
+		rows.Scan(&r.id, &r.text, &r.category)
+		pending = append(pending, r)
+	}
+	rows.Close()
+	for _, r := range pending {
+		s.upsertFTS(r.id, r.text, r.category)
 	}
 }
 
@@ -1086,25 +1987,15 @@ func deserializeVector(b []byte) []float32 {
 		return nil
 	}
 	result := make([]float32, len(b)/4)
-	for i := 0; i < len(result); i++ {
-		bits := uint32(b[i*4]) | uint32(b[i*4+1])<<8 |
-			uint32(b[i*4+2])<<16 | uint32(b[i*4+3])<<24
-		result[i] = math.Float32frombits(bits)
-	}
+	decodeVector(result, b)
 	return result
 }
 
-func cosineSimilarity(a, b []float32) float32 {
-	var dot, normA, normB float32
-	for i := 0; i < len(a); i++ {
-		dot += a[i] * b[i]
-		normA += a[i] * a[i]
-		normB += b[i] * b[i]
-	}
-	if normA == 0 || normB == 0 {
-		return 0
+// decodeVector fills dst from a serializeVector blob of len(dst)*4 bytes
+func decodeVector(dst []float32, b []byte) {
+	for i := range dst {
+		dst[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
 	}
-	return dot / float32(math.Sqrt(float64(normA*normB)))
 }
 
 func normalizeVector(v []float32) {
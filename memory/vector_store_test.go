@@ -1,9 +1,22 @@
 package memory
 
 import (
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/gliderlab/cogate/storage"
 )
 
 func TestBackfillEmbeddingDim(t *testing.T) {
@@ -58,3 +71,1145 @@ func TestLoadExistingVectorsSkipsDimMismatch(t *testing.T) {
 		t.Fatalf("expected no hnsw ids due to dim mismatch, got %d", len(store.hnswIDs))
 	}
 }
+
+func TestMigrateLegacyTable(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "vec.db")
+
+	legacy, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	_, err = legacy.Exec(`CREATE TABLE vector_memories (id TEXT PRIMARY KEY, text TEXT NOT NULL, vector BLOB NOT NULL, importance REAL DEFAULT 0.5, category TEXT DEFAULT 'other', created_at INTEGER)`)
+	if err != nil {
+		t.Fatalf("create legacy: %v", err)
+	}
+	_, err = legacy.Exec(`INSERT INTO vector_memories (id, text, vector, created_at) VALUES (?, ?, ?, ?)`,
+		"id-3", "old", serializeVector([]float32{1, 0}), 42)
+	if err != nil {
+		t.Fatalf("insert legacy: %v", err)
+	}
+	legacy.Close()
+
+	store, err := NewVectorMemoryStore(dbPath, Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+
+	var source string
+	var updated int64
+	if err := store.db.QueryRow(`SELECT source, updated_at FROM vector_memories WHERE id = ?`, "id-3").Scan(&source, &updated); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if source != "manual" || updated != 42 {
+		t.Fatalf("expected migrated defaults (manual, 42), got (%s, %d)", source, updated)
+	}
+
+	var version int
+	if err := store.db.QueryRow(`SELECT MAX(version) FROM schema_version WHERE scope = 'memory'`).Scan(&version); err != nil {
+		t.Fatalf("schema_version: %v", err)
+	}
+	if version != len(memoryMigrations) {
+		t.Fatalf("expected schema version %d, got %d", len(memoryMigrations), version)
+	}
+}
+
+func TestSearchMultiFusesAndDedupes(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+
+	now := time.Now().Unix()
+	rows := []struct {
+		id, text   string
+		importance float64
+	}{
+		{"a", "likes coffee in the morning", 0.9},
+		{"b", "flying to tokyo next week", 0.8},
+		{"c", "coffee shop near the tokyo hotel", 0.1},
+	}
+	for _, r := range rows {
+		_, err := store.db.Exec(`INSERT INTO vector_memories (id, text, vector, importance, category, source, embedding_dim, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			r.id, r.text, serializeVector([]float32{1, 0}), r.importance, "fact", "manual", 2, now, now)
+		if err != nil {
+			t.Fatalf("insert %s: %v", r.id, err)
+		}
+	}
+
+	results, err := store.SearchMulti([]string{"coffee", "tokyo"}, 5)
+	if err != nil {
+		t.Fatalf("search multi: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 deduped results, got %d", len(results))
+	}
+	if results[0].Entry.ID != "c" {
+		t.Fatalf("expected entry matching both queries first, got %s", results[0].Entry.ID)
+	}
+	if results[0].Score > 1 || results[len(results)-1].Score <= 0 {
+		t.Fatalf("fused scores out of range: %v .. %v", results[0].Score, results[len(results)-1].Score)
+	}
+}
+
+func TestLocalProviderGzip(t *testing.T) {
+	var gotEncoding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		gotEncoding = r.Header.Get("Content-Encoding")
+		body := r.Body
+		if gotEncoding == "gzip" {
+			zr, err := gzip.NewReader(r.Body)
+			if err != nil {
+				http.Error(w, "bad gzip", http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		var req struct {
+			Text string `json:"text"`
+		}
+		if err := json.NewDecoder(body).Decode(&req); err != nil {
+			http.Error(w, "bad json", http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		json.NewEncoder(zw).Encode(map[string]interface{}{"embedding": []float32{float32(len(req.Text)), 1}})
+		zw.Close()
+	}))
+	defer srv.Close()
+
+	p, err := NewLocalProvider(srv.URL, 2)
+	if err != nil {
+		t.Fatalf("provider: %v", err)
+	}
+
+	long := strings.Repeat("a", 2*gzipMinBytes)
+	vec, err := p.Embed(long)
+	if err != nil {
+		t.Fatalf("embed: %v", err)
+	}
+	if gotEncoding != "gzip" {
+		t.Fatalf("expected gzip request body, got %q", gotEncoding)
+	}
+	if len(vec) != 2 || int(vec[0]) != len(long) {
+		t.Fatalf("unexpected embedding %v", vec)
+	}
+
+	if _, err := p.Embed("short"); err != nil {
+		t.Fatalf("embed short: %v", err)
+	}
+	if gotEncoding != "" {
+		t.Fatalf("expected short body uncompressed, got %q", gotEncoding)
+	}
+}
+
+func TestStoreEmbedded(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+
+	ids, err := store.StoreEmbedded([]MemoryEntry{
+		{Text: "first doc", Vector: []float32{3, 4}, Importance: 0.7, Category: "fact", Source: "ingest"},
+		{Text: "second doc", Vector: []float32{0, 2}},
+	})
+	if err != nil {
+		t.Fatalf("store embedded: %v", err)
+	}
+	if len(ids) != 2 || ids[0] == ids[1] {
+		t.Fatalf("expected 2 distinct ids, got %v", ids)
+	}
+
+	entry, err := store.Get(ids[0])
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if entry.Source != "ingest" || entry.Category != "fact" {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+	if len(entry.Vector) != 2 || entry.Vector[0] != 0.6 || entry.Vector[1] != 0.8 {
+		t.Fatalf("expected normalized vector, got %v", entry.Vector)
+	}
+	second, _ := store.Get(ids[1])
+	if second.Category != "other" || second.Source != "manual" {
+		t.Fatalf("expected default category/source, got %+v", second)
+	}
+
+	if _, err := store.StoreEmbedded([]MemoryEntry{{Text: "no vector"}}); err == nil {
+		t.Fatalf("expected error for missing vector")
+	}
+}
+
+func TestRelatedExcludesSelf(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+
+	ids, err := store.StoreEmbedded([]MemoryEntry{
+		{Text: "anchor", Vector: []float32{1, 0}},
+		{Text: "near", Vector: []float32{0.9, 0.1}},
+		{Text: "far", Vector: []float32{0, 1}},
+	})
+	if err != nil {
+		t.Fatalf("store embedded: %v", err)
+	}
+
+	results, err := store.Related(ids[0], 1)
+	if err != nil {
+		t.Fatalf("related: %v", err)
+	}
+	if len(results) != 1 || results[0].Entry.Text != "near" {
+		t.Fatalf("expected nearest neighbour 'near', got %+v", results)
+	}
+
+	results, _ = store.Related(ids[0], 10)
+	for _, r := range results {
+		if r.Entry.ID == ids[0] {
+			t.Fatalf("related results include the source memory")
+		}
+	}
+
+	if _, err := store.Related("missing", 5); err == nil {
+		t.Fatalf("expected error for unknown id")
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+
+	ids, err := store.StoreEmbedded([]MemoryEntry{
+		{Text: "kept one", Vector: []float32{1, 0}},
+		{Text: "kept two", Vector: []float32{0, 1}},
+	})
+	if err != nil {
+		t.Fatalf("store embedded: %v", err)
+	}
+
+	snapDir := filepath.Join(dir, "snap")
+	if err := store.Snapshot(snapDir); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(snapDir, SnapshotManifestFile)); err != nil {
+		t.Fatalf("manifest missing: %v", err)
+	}
+
+	snapDB, err := sql.Open("sqlite3", filepath.Join(snapDir, SnapshotDBFile))
+	if err != nil {
+		t.Fatalf("open snapshot db: %v", err)
+	}
+	if _, err := snapDB.Exec(`UPDATE vector_memories SET vector_norm = NULL WHERE id = ?`, ids[1]); err != nil {
+		t.Fatalf("clear snapshot norm: %v", err)
+	}
+	snapDB.Close()
+
+	if _, err := store.StoreEmbedded([]MemoryEntry{{Text: "after snapshot", Vector: []float32{1, 1}}}); err != nil {
+		t.Fatalf("store after snapshot: %v", err)
+	}
+	if _, err := store.Delete(ids[0]); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+
+	if err := store.RestoreFromSnapshot(snapDir); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	count, _ := store.Count()
+	if count != 2 {
+		t.Fatalf("expected 2 memories after restore, got %d", count)
+	}
+	if entry, err := store.Get(ids[0]); err != nil || entry.Text != "kept one" {
+		t.Fatalf("expected deleted memory restored, got %+v (%v)", entry, err)
+	}
+	var missingNorms int
+	store.db.QueryRow(`SELECT COUNT(*) FROM vector_memories WHERE vector_norm IS NULL`).Scan(&missingNorms)
+	if missingNorms != 0 {
+		t.Fatalf("expected restored rows to keep their norms, %d are NULL", missingNorms)
+	}
+}
+
+func TestSnapshotRestoreLegacySchema(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+
+	// A snapshot from before the source, external_id and vector_norm columns
+	snapDir := filepath.Join(dir, "snap")
+	os.MkdirAll(snapDir, 0755)
+	snapDB, err := sql.Open("sqlite3", filepath.Join(snapDir, SnapshotDBFile))
+	if err != nil {
+		t.Fatalf("open snapshot db: %v", err)
+	}
+	if _, err := snapDB.Exec(`CREATE TABLE vector_memories (id TEXT PRIMARY KEY, text TEXT NOT NULL, vector BLOB NOT NULL, importance REAL DEFAULT 0.5, category TEXT DEFAULT 'other', created_at INTEGER)`); err != nil {
+		t.Fatalf("create legacy table: %v", err)
+	}
+	if _, err := snapDB.Exec(`INSERT INTO vector_memories (id, text, vector, importance, category, created_at) VALUES (?, ?, ?, 0.7, 'fact', 1700000000)`,
+		"old-1", "legacy memory", serializeVector([]float32{3, 4})); err != nil {
+		t.Fatalf("insert legacy row: %v", err)
+	}
+	snapDB.Close()
+
+	if err := store.RestoreFromSnapshot(snapDir); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	entry, err := store.Get("old-1")
+	if err != nil || entry.Text != "legacy memory" || entry.Category != "fact" {
+		t.Fatalf("expected legacy memory restored, got %+v (%v)", entry, err)
+	}
+	var source string
+	var norm float64
+	if err := store.db.QueryRow(`SELECT source, vector_norm FROM vector_memories WHERE id = ?`, "old-1").Scan(&source, &norm); err != nil {
+		t.Fatalf("read restored row: %v", err)
+	}
+	if source != "manual" || math.Abs(norm-5) > 1e-6 {
+		t.Fatalf("expected default source and backfilled norm 5, got %q %v", source, norm)
+	}
+}
+
+type countingProvider struct{ calls int }
+
+func (p *countingProvider) Embed(text string) ([]float32, error) {
+	p.calls++
+	return []float32{float32(len(text)), 1}, nil
+}
+func (p *countingProvider) Dim() int     { return 2 }
+func (p *countingProvider) Name() string { return "counting" }
+
+func TestDeterministicIDs(t *testing.T) {
+	dir := t.TempDir()
+	n := 0
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{NewID: func() string {
+		n++
+		return fmt.Sprintf("mem-%d", n)
+	}})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+
+	first, _ := store.StoreWithSource("first note", "fact", 0.5, "")
+	second, _ := store.StoreWithSource("second note", "fact", 0.5, "")
+	batch, err := store.StoreEmbedded([]MemoryEntry{{Text: "third note", Vector: []float32{1, 0}}, {Text: "fourth note", Vector: []float32{0, 1}}})
+	if err != nil {
+		t.Fatalf("store embedded: %v", err)
+	}
+	got := append([]string{first, second}, batch...)
+	if strings.Join(got, ",") != "mem-1,mem-2,mem-3,mem-4" {
+		t.Fatalf("ids = %v, want mem-1..mem-4 in order", got)
+	}
+
+	if ok, err := store.Delete("mem-1"); err != nil || !ok {
+		t.Fatalf("delete mem-1: %v %v", ok, err)
+	}
+	if e, _ := store.Get("mem-1"); e.Text != "" {
+		t.Fatalf("mem-1 still stored: %q", e.Text)
+	}
+	if e, _ := store.Get("mem-4"); e.Text != "fourth note" {
+		t.Fatalf("mem-4 = %q", e.Text)
+	}
+	results, _ := store.KeywordSearch("second", 5)
+	if len(results) != 1 || results[0].Entry.ID != "mem-2" {
+		t.Fatalf("search results = %+v, want mem-2", results)
+	}
+}
+
+func TestUpsertByExternalID(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	provider := &countingProvider{}
+	store.embedding = provider
+
+	id, created, err := store.Upsert("doc-1", "first version", "fact", 0.5, "sync")
+	if err != nil || !created {
+		t.Fatalf("first upsert: created=%v err=%v", created, err)
+	}
+
+	again, created, err := store.Upsert("doc-1", "first version", "", 0.9, "sync")
+	if err != nil || created || again != id {
+		t.Fatalf("same-text upsert: id=%s created=%v err=%v", again, created, err)
+	}
+	if provider.calls != 1 {
+		t.Fatalf("expected unchanged text not to re-embed, got %d embed calls", provider.calls)
+	}
+
+	if _, _, err := store.Upsert("doc-1", "second version", "", 0, "sync"); err != nil {
+		t.Fatalf("changed-text upsert: %v", err)
+	}
+	entry, found, err := store.GetByExternalID("doc-1")
+	if err != nil || !found {
+		t.Fatalf("get by external id: found=%v err=%v", found, err)
+	}
+	if entry.ID != id || entry.Text != "second version" || entry.Importance != 0.9 || entry.ExternalID != "doc-1" {
+		t.Fatalf("unexpected entry after update: %+v", entry)
+	}
+	if provider.calls != 2 {
+		t.Fatalf("expected changed text to re-embed, got %d embed calls", provider.calls)
+	}
+
+	if _, err := store.StoreWithExternalID("doc-1", "duplicate", "fact", 0.5, "sync"); err == nil {
+		t.Fatalf("expected duplicate external id to be rejected")
+	}
+	// Untagged memories do not collide with each other
+	for i := 0; i < 2; i++ {
+		if _, err := store.Store("untagged", "fact", 0.5); err != nil {
+			t.Fatalf("store untagged: %v", err)
+		}
+	}
+	if n, _ := store.Count(); n != 3 {
+		t.Fatalf("expected 3 memories, got %d", n)
+	}
+}
+
+type fixedProvider struct{ vec []float32 }
+
+func (p *fixedProvider) Embed(text string) ([]float32, error) {
+	return append([]float32(nil), p.vec...), nil
+}
+func (p *fixedProvider) Dim() int     { return len(p.vec) }
+func (p *fixedProvider) Name() string { return "fixed" }
+
+func TestSearchCategory(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{HNSWPartitioned: true})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	store.embedding = &fixedProvider{vec: []float32{1, 0}}
+
+	if _, err := store.StoreEmbedded([]MemoryEntry{
+		{Text: "likes green tea", Category: "preference", Vector: []float32{0.9, 0.1}},
+		{Text: "office is in Prague", Category: "fact", Vector: []float32{1, 0}},
+		{Text: "deploys on fridays", Category: "fact", Vector: []float32{0.2, 0.8}},
+	}); err != nil {
+		t.Fatalf("store embedded: %v", err)
+	}
+
+	for _, hybrid := range []bool{true, false} {
+		store.cfg.HybridEnabled = hybrid
+		results, err := store.SearchCategory("anything", "preference", 5, 0.01)
+		if err != nil {
+			t.Fatalf("hybrid=%v: search category: %v", hybrid, err)
+		}
+		if len(results) != 1 || results[0].Entry.Text != "likes green tea" {
+			t.Fatalf("hybrid=%v: expected only the preference, got %+v", hybrid, results)
+		}
+
+		all, err := store.Search("anything", 5, 0.01)
+		if err != nil {
+			t.Fatalf("hybrid=%v: search: %v", hybrid, err)
+		}
+		if len(all) != 3 || all[0].Entry.Text != "office is in Prague" {
+			t.Fatalf("hybrid=%v: expected all 3 ranked by similarity, got %+v", hybrid, all)
+		}
+
+		none, err := store.SearchCategory("anything", "entity", 5, 0.01)
+		if err != nil || len(none) != 0 {
+			t.Fatalf("hybrid=%v: expected no entity results, got %+v (%v)", hybrid, none, err)
+		}
+	}
+}
+
+type failingProvider struct{}
+
+func (p *failingProvider) Embed(text string) ([]float32, error) {
+	return nil, fmt.Errorf("context deadline exceeded")
+}
+func (p *failingProvider) Dim() int     { return 2 }
+func (p *failingProvider) Name() string { return "failing" }
+
+func TestEmbeddingStats(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+
+	if st := store.EmbeddingStats(); st.Provider != "placeholder" || st.Calls != 0 {
+		t.Fatalf("expected idle placeholder stats, got %+v", st)
+	}
+
+	store.embedding = &countingProvider{}
+	for i := 0; i < 3; i++ {
+		if _, err := store.Store("note", "fact", 0.5); err != nil {
+			t.Fatalf("store: %v", err)
+		}
+	}
+	store.embedding = &failingProvider{}
+	if _, err := store.Store("note", "fact", 0.5); err == nil {
+		t.Fatalf("expected embedding failure")
+	}
+
+	st := store.EmbeddingStats()
+	if st.Provider != "failing" || st.Calls != 4 || st.Errors != 1 || st.Timeouts != 1 {
+		t.Fatalf("unexpected stats: %+v", st)
+	}
+	if st.LastError == "" || st.LastErrorAt == 0 {
+		t.Fatalf("expected last error recorded, got %+v", st)
+	}
+	var bucketed int64
+	for _, b := range st.Buckets {
+		bucketed += b.Count
+	}
+	if bucketed != 4 || st.Buckets[len(st.Buckets)-1].Le != "+Inf" {
+		t.Fatalf("expected 4 calls across buckets ending in +Inf, got %+v", st.Buckets)
+	}
+}
+
+func TestFuzzyFTSQuery(t *testing.T) {
+	cases := map[string]string{
+		"deploymnet":      `{text category} : ("deploym"*)`,
+		"pipeline friday": `{text category} : (NEAR("pipeli"* "frid"*, 10) OR "pipeli"* OR "frid"*)`,
+		`"ok" AND ok a`:   `{text category} : (NEAR("ok" "and"*, 10) OR "ok" OR "and"*)`,
+		"?!":              "",
+	}
+	for in, want := range cases {
+		if got := fuzzyFTSQuery(in); got != want {
+			t.Errorf("fuzzyFTSQuery(%q) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestSearchDedupResults(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{DedupResults: true})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	store.embedding = &fixedProvider{vec: []float32{1, 0}}
+
+	if _, err := store.StoreEmbedded([]MemoryEntry{
+		{Text: "Office is in Prague.", Importance: 0.3, Vector: []float32{1, 0}},
+		{Text: "office is in  prague", Importance: 0.9, Vector: []float32{0.6, 0.8}},
+		{Text: "The office is in Prague", Importance: 0.5, Vector: []float32{0.999, 0.01}},
+		{Text: "deploys on fridays", Importance: 0.5, Vector: []float32{0.2, 0.8}},
+	}); err != nil {
+		t.Fatalf("store embedded: %v", err)
+	}
+
+	results, err := store.Search("anything", 5, 0.01)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 2 || results[0].Entry.Text != "Office is in Prague." || results[1].Entry.Text != "deploys on fridays" {
+		t.Fatalf("expected one Prague copy and the deploy fact, got %+v", results)
+	}
+
+	store.cfg.DedupResults = false
+	all, err := store.Search("anything", 5, 0.01)
+	if err != nil || len(all) != 4 {
+		t.Fatalf("expected all 4 copies without dedup, got %d (%v)", len(all), err)
+	}
+}
+
+type batchProvider struct {
+	countingProvider
+	batches int
+}
+
+func (p *batchProvider) EmbedBatch(texts []string) ([][]float32, error) {
+	p.batches++
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = []float32{float32(len(text)), 1}
+	}
+	return out, nil
+}
+
+func TestStoreBatch(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	provider := &batchProvider{}
+	store.embedding = provider
+
+	texts := make([]string, embedBatchSize+1)
+	for i := range texts {
+		texts[i] = fmt.Sprintf("history line %d", i)
+	}
+	if _, err := store.StoreBatch(texts, nil, []float64{0.5}); err == nil {
+		t.Fatalf("expected error for mismatched importances")
+	}
+	ids, err := store.StoreBatch(texts, nil, nil)
+	if err != nil {
+		t.Fatalf("store batch: %v", err)
+	}
+	if len(ids) != len(texts) || provider.batches != 2 || provider.calls != 0 {
+		t.Fatalf("expected %d ids over 2 batch calls, got %d ids, %d batches, %d single calls", len(texts), len(ids), provider.batches, provider.calls)
+	}
+	entry, err := store.Get(ids[3])
+	if err != nil || entry.Text != texts[3] || entry.Category != "other" || len(entry.Vector) != 2 {
+		t.Fatalf("unexpected stored entry %+v (%v)", entry, err)
+	}
+	if st := store.EmbeddingStats(); st.Calls != 2 {
+		t.Fatalf("expected 2 recorded embedding calls, got %d", st.Calls)
+	}
+}
+
+func TestSearchFiltered(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	store.embedding = &fixedProvider{vec: []float32{1, 0}}
+
+	if _, err := store.StoreEmbedded([]MemoryEntry{
+		{Text: "likes green tea", Category: "preference", Importance: 0.9, Source: "manual", Vector: []float32{1, 0}},
+		{Text: "likes black coffee", Category: "preference", Importance: 0.2, Source: "auto", Vector: []float32{0.99, 0.1}},
+		{Text: "office is in Prague", Category: "fact", Importance: 0.8, Source: "auto", Vector: []float32{0.98, 0.2}},
+		{Text: "deploys on fridays", Category: "decision", Importance: 0.7, Source: "manual", Vector: []float32{0.97, 0.25}},
+	}); err != nil {
+		t.Fatalf("store embedded: %v", err)
+	}
+	old := time.Now().Add(-48 * time.Hour).Unix()
+	if _, err := store.db.Exec(`UPDATE vector_memories SET created_at = ? WHERE text = ?`, old, "deploys on fridays"); err != nil {
+		t.Fatalf("backdate: %v", err)
+	}
+
+	texts := func(results []MemoryResult) []string {
+		out := make([]string, len(results))
+		for i, r := range results {
+			out[i] = r.Entry.Text
+		}
+		return out
+	}
+	cases := []struct {
+		name string
+		opts SearchOptions
+		want []string
+	}{
+		{"categories", SearchOptions{Categories: []string{"fact", "decision"}}, []string{"office is in Prague", "deploys on fridays"}},
+		{"importance within category", SearchOptions{Categories: []string{"preference"}, MinImportance: 0.5}, []string{"likes green tea"}},
+		{"sources honor limit", SearchOptions{Sources: []string{"auto"}, Limit: 1}, []string{"likes black coffee"}},
+		{"since", SearchOptions{Since: time.Now().Add(-time.Hour), MinImportance: 0.7}, []string{"likes green tea", "office is in Prague"}},
+		{"until", SearchOptions{Until: time.Now().Add(-time.Hour)}, []string{"deploys on fridays"}},
+	}
+	for _, hybrid := range []bool{true, false} {
+		store.cfg.HybridEnabled = hybrid
+		for _, c := range cases {
+			c.opts.MinScore = 0.01
+			results, err := store.SearchFiltered("anything", c.opts)
+			if err != nil {
+				t.Fatalf("hybrid=%v %s: %v", hybrid, c.name, err)
+			}
+			if got := texts(results); strings.Join(got, "|") != strings.Join(c.want, "|") {
+				t.Errorf("hybrid=%v %s: got %q, want %q", hybrid, c.name, got, c.want)
+			}
+		}
+	}
+}
+func TestHNSWParams(t *testing.T) {
+	cfg := Config{HNSWDistance: " L2 "}
+	if err := cfg.hnswParams(); err != nil {
+		t.Fatalf("hnswParams: %v", err)
+	}
+	if cfg.HNSWDistance != "l2" || cfg.HNSWM != DefaultHNSWM || cfg.HNSWEfSearch != DefaultHNSWEfSearch || cfg.HNSWEfConstruct != DefaultHNSWEfConstruct {
+		t.Fatalf("unexpected params: %+v", cfg)
+	}
+
+	dir := t.TempDir()
+	if _, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{HNSWDistance: "dot"}); err == nil {
+		t.Fatal("expected unknown distance to be rejected")
+	}
+	if _, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{HNSWM: -1}); err == nil {
+		t.Fatal("expected negative M to be rejected")
+	}
+}
+
+func TestEmbeddingDimMismatch(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	if _, err := store.StoreEmbedded([]MemoryEntry{{Text: "old model", Vector: []float32{1, 0, 0}}}); err != nil {
+		t.Fatalf("store embedded: %v", err)
+	}
+
+	// Reopened with a 2-dim model: the 3-dim row makes the store refuse writes
+	store.embedding = &countingProvider{}
+	store.cfg.EmbeddingDim = 2
+	store.checkStoredDims()
+	if store.DimMismatch() == nil {
+		t.Fatal("expected a dimension mismatch")
+	}
+	if _, err := store.Store("new fact", "fact", 0.5); err == nil || !strings.Contains(err.Error(), "dimension mismatch") {
+		t.Fatalf("expected store to be refused, got %v", err)
+	}
+
+	// A provider returning vectors of another size than it declares is caught per write
+	store.dimErr = nil
+	store.db.Exec("DELETE FROM vector_memories")
+	store.embedding = &wrongDimProvider{}
+	if _, err := store.Store("new fact", "fact", 0.5); err == nil {
+		t.Fatal("expected a vector of the wrong size to be refused")
+	}
+}
+
+type wrongDimProvider struct{ countingProvider }
+
+func (p *wrongDimProvider) Dim() int { return 3 }
+
+func TestAsyncWrites(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{AsyncWrites: true, AsyncQueueSize: 8})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	provider := &batchProvider{}
+	store.embedding = provider
+
+	ids := make([]string, 5)
+	for i := range ids {
+		if ids[i], err = store.StoreWithSource(fmt.Sprintf("queued fact %d", i), "fact", 0.5, "auto"); err != nil || ids[i] == "" {
+			t.Fatalf("queue write %d: id=%q err=%v", i, ids[i], err)
+		}
+	}
+	store.Flush()
+	if n, _ := store.Count(); n != len(ids) {
+		t.Fatalf("expected %d memories after flush, got %d", len(ids), n)
+	}
+	entry, err := store.Get(ids[2])
+	if err != nil || entry.Text != "queued fact 2" || entry.Source != "auto" {
+		t.Fatalf("unexpected queued entry %+v (%v)", entry, err)
+	}
+	if provider.calls != 0 {
+		t.Fatalf("expected queued writes to be embedded in batches, got %d single calls", provider.calls)
+	}
+
+	// Writes queued before Close are stored, later ones are refused
+	last, err := store.StoreWithSource("queued before close", "fact", 0.5, "auto")
+	if err != nil {
+		t.Fatalf("queue write: %v", err)
+	}
+	store.stopWriteQueue()
+	if _, err := store.Get(last); err != nil {
+		t.Fatalf("expected write queued before close to be stored: %v", err)
+	}
+	if _, err := store.StoreWithSource("too late", "fact", 0.5, "auto"); err == nil {
+		t.Fatal("expected writes after close to be refused")
+	}
+}
+
+func TestReembed(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	entries := make([]MemoryEntry, embedBatchSize+2)
+	for i := range entries {
+		entries[i] = MemoryEntry{Text: fmt.Sprintf("old model fact %d", i), Vector: []float32{1, 0, 0}}
+	}
+	ids, err := store.StoreEmbedded(entries)
+	if err != nil {
+		t.Fatalf("store embedded: %v", err)
+	}
+
+	provider := &countingProvider{}
+	store.embedding = provider
+	store.cfg.EmbeddingDim = 2
+	store.checkStoredDims()
+	if store.DimMismatch() == nil {
+		t.Fatal("expected a dimension mismatch before reembed")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := store.Reembed(ctx); err == nil {
+		t.Fatal("expected a cancelled reembed to fail")
+	}
+
+	// Pretend an earlier run finished the first batch: only the rest is re-embedded
+	if err := store.writeReembedded([]int64{int64(embedBatchSize)}, [][]float32{{float32(len(entries[embedBatchSize-1].Text)), 1}}); err != nil {
+		t.Fatalf("seed progress: %v", err)
+	}
+	if err := store.Reembed(context.Background()); err != nil {
+		t.Fatalf("reembed: %v", err)
+	}
+	if provider.calls != 2 {
+		t.Fatalf("expected the resumed run to embed 2 rows, got %d calls", provider.calls)
+	}
+	if store.DimMismatch() != nil {
+		t.Fatalf("expected the mismatch to clear, got %v", store.DimMismatch())
+	}
+	entry, err := store.Get(ids[len(ids)-1])
+	if err != nil || len(entry.Vector) != 2 {
+		t.Fatalf("expected a re-embedded 2-dim vector, got %+v (%v)", entry, err)
+	}
+	if _, err := store.Store("new fact", "fact", 0.5); err != nil {
+		t.Fatalf("expected writes after reembed, got %v", err)
+	}
+	if v, _ := store.reembedProgress(reembedCursorKey); v != "" {
+		t.Fatalf("expected progress to be cleared, got cursor %q", v)
+	}
+}
+
+func TestHybridNormalization(t *testing.T) {
+	if _, err := parseHybridNorm("softmax"); err == nil {
+		t.Fatal("expected unknown normalization to be rejected")
+	}
+	// bm25 as FTS5 returns it (lower is better) and cosine scores that disagree on order
+	bm25 := map[string]float32{"a": -9.5, "b": -4.2, "c": -0.3}
+	cosine := map[string]float32{"a": 0.31, "b": 0.62, "c": 0.93, "d": 0.5}
+	ftsOrder := []string{"a", "b", "c", "d"} // d has no keyword match
+	vecOrder := []string{"c", "b", "d", "a"}
+
+	rank := func(fused map[string]float32) []string {
+		ids := make([]string, 0, len(fused))
+		for id := range fused {
+			ids = append(ids, id)
+		}
+		sort.Slice(ids, func(i, j int) bool { return fused[ids[i]] > fused[ids[j]] })
+		return ids
+	}
+	for _, mode := range []string{HybridNormMinMax, HybridNormZScore} {
+		for _, c := range []struct {
+			vectorWeight, textWeight float32
+			want                     []string
+		}{{0, 1, ftsOrder}, {1, 0, vecOrder}} {
+			store, err := NewVectorMemoryStore(filepath.Join(t.TempDir(), "vec.db"), Config{
+				HybridEnabled: true, HybridNorm: mode, VectorWeight: c.vectorWeight, TextWeight: c.textWeight, WeightsSet: true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer store.Close()
+			if store.cfg.VectorWeight != c.vectorWeight || store.cfg.TextWeight != c.textWeight {
+				t.Fatalf("weights %v/%v became %v/%v", c.vectorWeight, c.textWeight, store.cfg.VectorWeight, store.cfg.TextWeight)
+			}
+			vec := make(map[string]float32)
+			for id, v := range cosine {
+				vec[id] = v
+			}
+			text := make(map[string]float32)
+			for id, v := range bm25 {
+				text[id] = -v
+			}
+			fused := store.fuseHybrid(vec, text)
+			if got := rank(fused); strings.Join(got, "") != strings.Join(c.want, "") {
+				t.Errorf("%s weights %v/%v: got order %v, want %v", mode, c.vectorWeight, c.textWeight, got, c.want)
+			}
+			for id, score := range fused {
+				if score < 0 || score > 1 {
+					t.Errorf("%s: score of %s out of [0,1]: %v", mode, id, score)
+				}
+			}
+		}
+	}
+
+	// Without WeightsSet a zero weight takes the default; both zero is rejected
+	store, err := NewVectorMemoryStore(filepath.Join(t.TempDir(), "vec.db"), Config{TextWeight: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.cfg.VectorWeight != DefaultVectorWeight {
+		t.Errorf("expected default vector weight, got %v", store.cfg.VectorWeight)
+	}
+	store.Close()
+	if _, err := NewVectorMemoryStore(filepath.Join(t.TempDir(), "vec.db"), Config{WeightsSet: true}); err == nil {
+		t.Error("expected weights 0/0 to be rejected")
+	}
+
+	// Raw 1/(1+bm25) is 1 for every FTS match, so the keyword side never changes the
+	// order; normalized, the strongest keyword match can win with equal weights
+	for mode, want := range map[string]string{HybridNormNone: "c", HybridNormMinMax: "a"} {
+		store := &VectorMemoryStore{cfg: Config{HybridNorm: mode, VectorWeight: 0.5, TextWeight: 0.5}}
+		text := make(map[string]float32)
+		for id, v := range bm25 {
+			text[id] = -v
+			if mode == HybridNormNone {
+				text[id] = float32(1.0 / (1.0 + maxf(0, v)))
+			}
+		}
+		vec := map[string]float32{"a": 0.31, "b": 0.62, "c": 0.93, "d": 0.5}
+		if got := rank(store.fuseHybrid(vec, text))[0]; got != want {
+			t.Errorf("%s: expected %s first, got %s", mode, want, got)
+		}
+	}
+}
+
+func TestHybridScoreRange(t *testing.T) {
+	nan := float32(math.NaN())
+	for _, mode := range []string{HybridNormMinMax, HybridNormZScore, HybridNormNone} {
+		store := &VectorMemoryStore{cfg: Config{HybridNorm: mode, VectorWeight: 0.7, TextWeight: 0.3}}
+		// Negative cosine and bm25-derived relevance, a NaN vector score and an infinite keyword score
+		vec := map[string]float32{"a": 0.9, "b": -0.4, "c": nan, "d": 0.2}
+		text := map[string]float32{"a": 3.2, "b": -12, "c": 1, "e": float32(math.Inf(1))}
+		fused := store.fuseHybrid(vec, text)
+		if _, ok := fused["c"]; ok {
+			t.Errorf("%s: NaN candidate kept: %v", mode, fused["c"])
+		}
+		if _, ok := fused["e"]; ok {
+			t.Errorf("%s: infinite candidate kept: %v", mode, fused["e"])
+		}
+		for _, id := range []string{"a", "b", "d"} {
+			score, ok := fused[id]
+			if !ok {
+				t.Errorf("%s: finite candidate %s dropped", mode, id)
+				continue
+			}
+			if math.IsNaN(float64(score)) || score < 0 || score > 1 {
+				t.Errorf("%s: score of %s out of [0, 1]: %v", mode, id, score)
+			}
+		}
+		if fused["a"] <= fused["b"] {
+			t.Errorf("%s: a (%v) should outrank b (%v)", mode, fused["a"], fused["b"])
+		}
+	}
+
+	// Weak matches are not stretched to the top of the scale, so minScore still
+	// means a similarity: a lone 0.2 cosine hit does not score VectorWeight
+	for _, mode := range []string{HybridNormMinMax, HybridNormZScore} {
+		store := &VectorMemoryStore{cfg: Config{HybridNorm: mode, VectorWeight: 0.7, TextWeight: 0.3}}
+		for _, vec := range []map[string]float32{{"a": 0.2}, {"a": 0.2, "b": 0.1}} {
+			fused := store.fuseHybrid(vec, map[string]float32{})
+			if want := float32(0.7 * 0.2); fused["a"] > want+1e-6 {
+				t.Errorf("%s %v: weak hit scored %v, want at most %v", mode, vec, fused["a"], want)
+			}
+		}
+	}
+
+	// A raised floor lifts the weakest component, so b keeps a minimum score
+	store := &VectorMemoryStore{cfg: Config{HybridNorm: HybridNormNone, VectorWeight: 0.7, TextWeight: 0.3, ScoreFloor: 0.2}}
+	fused := store.fuseHybrid(map[string]float32{"b": -0.4}, map[string]float32{"b": 0})
+	if want := float32(0.2); math.Abs(float64(fused["b"]-want)) > 1e-6 {
+		t.Errorf("floor 0.2: expected b = %v, got %v", want, fused["b"])
+	}
+	if _, err := NewVectorMemoryStore(filepath.Join(t.TempDir(), "vec.db"), Config{ScoreFloor: 1}); err == nil {
+		t.Error("expected a floor of 1 to be rejected")
+	}
+}
+
+func TestSearchProjection(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	store.embedding = &fixedProvider{vec: []float32{1, 0}}
+
+	long := strings.Repeat("the quick brown fox jumps over the lazy dog ", 10)
+	if _, err := store.StoreEmbedded([]MemoryEntry{{Text: long, Category: "fact", Vector: []float32{1, 0}}}); err != nil {
+		t.Fatalf("store embedded: %v", err)
+	}
+
+	if _, err := ParseProjection("id,body", 0); err == nil {
+		t.Fatalf("unknown field accepted")
+	}
+	proj, err := ParseProjection("id, category,score,snippet", 40)
+	if err != nil {
+		t.Fatalf("parse projection: %v", err)
+	}
+	results, err := store.SearchFiltered("fox", SearchOptions{Projection: &proj})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("search: %v (%d results)", err, len(results))
+	}
+	if results[0].Entry.Vector != nil {
+		t.Fatalf("projected result kept its vector")
+	}
+	item := proj.Item(results[0])
+	if len(item) != 4 {
+		t.Fatalf("item fields = %v, want id/category/score/snippet", item)
+	}
+	snippet, _ := item["snippet"].(string)
+	if n := len([]rune(snippet)); n > 40 || !strings.HasSuffix(snippet, "…") {
+		t.Fatalf("snippet %q (%d chars), want <= 40 ending in …", snippet, n)
+	}
+	if _, ok := item["text"]; ok {
+		t.Fatalf("text not requested but present")
+	}
+
+	// Without a projection every field but snippet is returned
+	full, _ := store.SearchFiltered("fox", SearchOptions{})
+	item = Projection{}.Item(full[0])
+	if item["text"] != full[0].Entry.Text || item["snippet"] != nil || len(item) != len(ResultFields)-1 {
+		t.Fatalf("default projection = %v", item)
+	}
+}
+
+func TestEmbeddingCache(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	provider := &batchProvider{}
+	store.embedding = provider
+	store.embedCache = newEmbedCache(2)
+
+	first, _ := store.getEmbedding("hello")
+	first[0] = -1 // callers may normalize in place; the cached copy must not change
+	again, _ := store.getEmbedding("hello")
+	if provider.calls != 1 || again[0] == -1 {
+		t.Fatalf("calls = %d, vector %v; want one provider call and an unchanged copy", provider.calls, again)
+	}
+
+	// "hello" is the least recently used entry when "three" arrives
+	store.getEmbedding("two")
+	store.getEmbedding("three")
+	store.getEmbedding("hello")
+	if provider.calls != 4 {
+		t.Fatalf("calls = %d, want 4 after evicting hello", provider.calls)
+	}
+
+	// Batches only send the misses; "hello" and "three" are cached
+	vectors, err := store.getEmbeddings([]string{"hello", "four", "three"})
+	if err != nil || len(vectors) != 3 {
+		t.Fatalf("batch: %v (%d vectors)", err, len(vectors))
+	}
+	if provider.batches != 1 || vectors[1][0] != 4 {
+		t.Fatalf("batches = %d, vectors %v", provider.batches, vectors)
+	}
+
+	stats := store.EmbeddingStats().Cache
+	if stats == nil || stats.Hits != 3 || stats.Misses != 5 || stats.Size != 2 {
+		t.Fatalf("cache stats = %+v, want 3 hits, 5 misses, 2 entries", stats)
+	}
+}
+
+func TestKeywordSearch(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+
+	if _, err := store.StoreEmbedded([]MemoryEntry{
+		{Text: "Staging deploys run on Friday afternoons", Category: "decision", Vector: []float32{1, 0}},
+		{Text: "The staging cluster lives in Frankfurt", Category: "fact", Vector: []float32{0, 1}},
+		{Text: "Prefers tea over coffee", Category: "preference", Vector: []float32{1, 1}},
+	}); err != nil {
+		t.Fatalf("store embedded: %v", err)
+	}
+
+	results, err := store.KeywordSearch("when do we deploy to staging?", 5)
+	if err != nil {
+		t.Fatalf("keyword search: %v", err)
+	}
+	if len(results) != 2 || !strings.HasPrefix(results[0].Entry.Text, "Staging deploys") {
+		t.Fatalf("results = %+v, want the deploy decision first, then the cluster fact", results)
+	}
+	if results[0].Score <= results[1].Score || results[1].Score <= 0 {
+		t.Fatalf("scores %v, %v: more matched terms should rank higher", results[0].Score, results[1].Score)
+	}
+	if none, _ := store.KeywordSearch("is it ok", 5); len(none) != 0 {
+		t.Fatalf("short words matched %d memories", len(none))
+	}
+}
+
+func TestSQLiteTuning(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewVectorMemoryStore(filepath.Join(dir, "vec.db"), Config{
+		SQLite: storage.SQLiteTuning{CacheSizeMB: 32, MmapSizeMB: 8},
+	})
+	if err != nil {
+		t.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+
+	// Hold several connections at once so the pool has to open new ones
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		conn, err := store.db.Conn(ctx)
+		if err != nil {
+			t.Fatalf("conn: %v", err)
+		}
+		defer conn.Close()
+		var cache, mmap int64
+		conn.QueryRowContext(ctx, "PRAGMA cache_size").Scan(&cache)
+		conn.QueryRowContext(ctx, "PRAGMA mmap_size").Scan(&mmap)
+		if cache != -32*1024 || mmap != 8<<20 {
+			t.Fatalf("connection %d: cache_size=%d mmap_size=%d, want -32768 and %d", i, cache, mmap, 8<<20)
+		}
+	}
+}
+
+// benchSink keeps benchmarked results alive so the calls are not optimized away
+var benchSink float32
+
+func benchVectors(n, dim int) [][]float32 {
+	out := make([][]float32, n)
+	for i := range out {
+		v := make([]float32, dim)
+		for j := range v {
+			v[j] = float32((i*31+j*17)%97) / 97
+		}
+		out[i] = v
+	}
+	return out
+}
+
+func BenchmarkCosineSimilarity(b *testing.B) {
+	vecs := benchVectors(2, 768)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		benchSink = cosineSimilarity(vecs[0], vecs[1])
+	}
+}
+
+func BenchmarkCosineWithNorms(b *testing.B) {
+	vecs := benchVectors(2, 768)
+	na, nb := vectorNorm(vecs[0]), vectorNorm(vecs[1])
+	for i := 0; i < b.N; i++ {
+		benchSink = cosineWithNorms(vecs[0], vecs[1], na, nb)
+	}
+}
+
+func BenchmarkLinearSearch(b *testing.B) {
+	store, err := NewVectorMemoryStore(filepath.Join(b.TempDir(), "vec.db"), Config{})
+	if err != nil {
+		b.Fatalf("new store: %v", err)
+	}
+	defer store.Close()
+	vecs := benchVectors(2001, 768)
+	entries := make([]MemoryEntry, 2000)
+	for i := range entries {
+		entries[i] = MemoryEntry{Text: fmt.Sprintf("memory %d", i), Vector: vecs[i]}
+	}
+	if _, err := store.StoreEmbedded(entries); err != nil {
+		b.Fatalf("store embedded: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := store.linearSearch(vecs[2000], searchFilter{}, 5, 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
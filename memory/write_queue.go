@@ -0,0 +1,141 @@
+// Async write queue - Store returns before the embedding request and the index save
+package memory
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// DefaultAsyncQueueSize applies when Config.AsyncQueueSize is zero
+const DefaultAsyncQueueSize = 256
+
+// pendingWrite is a memory accepted by StoreWithSource but not yet embedded.
+// Its ID is assigned on enqueue so the caller gets it back immediately.
+type pendingWrite struct {
+	id         string
+	text       string
+	category   string
+	importance float64
+	source     string
+}
+
+// writeQueue feeds a single worker that embeds queued memories in batches and
+// stores each batch with one transaction and one index save
+type writeQueue struct {
+	ch      chan pendingWrite
+	mu      sync.Mutex
+	idle    *sync.Cond // signalled when pending drops to zero
+	pending int        // enqueued and not yet written (or dropped)
+	closed  bool
+	done    chan struct{}
+}
+
+func (s *VectorMemoryStore) startWriteQueue(size int) {
+	q := &writeQueue{ch: make(chan pendingWrite, size), done: make(chan struct{})}
+	q.idle = sync.NewCond(&q.mu)
+	s.queue = q
+	go s.runWriteQueue(q)
+	log.Printf("Async memory writes enabled (queue=%d)", size)
+}
+
+// enqueue hands w to the worker without blocking; a full queue is an error
+// rather than a stall of the caller
+func (s *VectorMemoryStore) enqueue(w pendingWrite) error {
+	q := s.queue
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return fmt.Errorf("memory store closed")
+	}
+	select {
+	case q.ch <- w:
+		q.pending++
+		return nil
+	default:
+		return fmt.Errorf("memory write queue full (%d pending)", q.pending)
+	}
+}
+
+func (s *VectorMemoryStore) runWriteQueue(q *writeQueue) {
+	defer close(q.done)
+	for w := range q.ch {
+		batch := []pendingWrite{w}
+	drain:
+		for len(batch) < embedBatchSize {
+			select {
+			case next, ok := <-q.ch:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+		s.writeBatch(batch)
+
+		q.mu.Lock()
+		q.pending -= len(batch)
+		if q.pending == 0 {
+			q.idle.Broadcast()
+		}
+		q.mu.Unlock()
+	}
+}
+
+// writeBatch embeds and stores queued memories; failures can no longer reach
+// the caller, so they are logged with the IDs that were handed out
+func (s *VectorMemoryStore) writeBatch(batch []pendingWrite) {
+	texts := make([]string, len(batch))
+	for i, w := range batch {
+		texts[i] = w.text
+	}
+	vectors, err := s.getEmbeddings(texts)
+	if err != nil {
+		log.Printf("⚠️ async memory write dropped %d entries (first %s): embedding failed: %v", len(batch), shortID(batch[0].id), err)
+		return
+	}
+	entries := make([]MemoryEntry, len(batch))
+	ids := make([]string, len(batch))
+	for i, w := range batch {
+		entries[i] = MemoryEntry{Text: w.text, Vector: vectors[i], Category: w.category, Importance: w.importance, Source: w.source}
+		ids[i] = w.id
+	}
+	if _, err := s.storeEmbedded(entries, ids); err != nil {
+		log.Printf("⚠️ async memory write dropped %d entries (first %s): %v", len(batch), shortID(batch[0].id), err)
+		return
+	}
+	log.Printf("✅ Memory stored: %d queued entries", len(batch))
+}
+
+// Flush blocks until every queued write has been stored. A no-op without
+// Config.AsyncWrites.
+func (s *VectorMemoryStore) Flush() {
+	q := s.queue
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	for q.pending > 0 {
+		q.idle.Wait()
+	}
+	q.mu.Unlock()
+}
+
+// stopWriteQueue refuses new writes and waits for the worker to store the rest
+func (s *VectorMemoryStore) stopWriteQueue() {
+	q := s.queue
+	if q == nil {
+		return
+	}
+	q.mu.Lock()
+	if q.closed {
+		q.mu.Unlock()
+		return
+	}
+	q.closed = true
+	close(q.ch)
+	q.mu.Unlock()
+	<-q.done
+}
@@ -0,0 +1,113 @@
+package rpcproto
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Content part types (OpenAI content-parts array)
+const (
+	ContentTypeText     = "text"
+	ContentTypeImageURL = "image_url"
+)
+
+// ContentPart is one element of a multimodal message content array.
+type ContentPart struct {
+	Type     string    `json:"type"`
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+// ImageURL points at an image (http(s) URL or data: URI).
+type ImageURL struct {
+	URL    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// TextPart builds a text content part.
+func TextPart(text string) ContentPart {
+	return ContentPart{Type: ContentTypeText, Text: text}
+}
+
+// ImagePart builds an image_url content part.
+func ImagePart(url, detail string) ContentPart {
+	return ContentPart{Type: ContentTypeImageURL, ImageURL: &ImageURL{URL: url, Detail: detail}}
+}
+
+// PartsText joins the text parts, used as the plain-string view of a multimodal message.
+func PartsText(parts []ContentPart) string {
+	texts := make([]string, 0, len(parts))
+	for _, p := range parts {
+		if p.Type == ContentTypeText && p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// MarshalContent encodes content as a parts array when parts are present, otherwise as a string.
+func MarshalContent(content string, parts []ContentPart) (json.RawMessage, error) {
+	if len(parts) > 0 {
+		return json.Marshal(parts)
+	}
+	return json.Marshal(content)
+}
+
+// UnmarshalContent accepts either a string or a parts array.
+// For arrays the returned string is the joined text of the parts.
+func UnmarshalContent(raw json.RawMessage) (string, []ContentPart, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return "", nil, nil
+	}
+	switch raw[0] {
+	case '"':
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return "", nil, err
+		}
+		return s, nil, nil
+	case '[':
+		var parts []ContentPart
+		if err := json.Unmarshal(raw, &parts); err != nil {
+			return "", nil, fmt.Errorf("invalid content parts: %v", err)
+		}
+		return PartsText(parts), parts, nil
+	default:
+		return "", nil, fmt.Errorf("content must be a string or an array of parts")
+	}
+}
+
+// MarshalJSON emits content as a parts array for multimodal messages.
+func (m Message) MarshalJSON() ([]byte, error) {
+	type alias Message
+	content, err := MarshalContent(m.Content, m.Parts)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		alias
+		Content json.RawMessage `json:"content"`
+	}{alias: alias(m), Content: content})
+}
+
+// UnmarshalJSON accepts string or parts-array content.
+func (m *Message) UnmarshalJSON(data []byte) error {
+	type alias Message
+	aux := struct {
+		*alias
+		Content json.RawMessage `json:"content"`
+	}{alias: (*alias)(m)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	content, parts, err := UnmarshalContent(aux.Content)
+	if err != nil {
+		return err
+	}
+	m.Content = content
+	m.Parts = parts
+	return nil
+}
@@ -3,10 +3,12 @@ package rpcproto
 // Shared RPC types between gateway and agent.
 
 type Message struct {
-	Role                 string       `json:"role"`
-	Content              string       `json:"content"`
-	ToolCalls            []ToolCall   `json:"tool_calls,omitempty"`
-	ToolExecutionResults []ToolResult `json:"tool_results,omitempty"`
+	Role    string `json:"role"`
+	Content string `json:"content"`
+	// Parts carries multimodal content (text + image_url); Content mirrors its text
+	Parts                []ContentPart `json:"-"`
+	ToolCalls            []ToolCall    `json:"tool_calls,omitempty"`
+	ToolExecutionResults []ToolResult  `json:"tool_results,omitempty"`
 }
 
 type ToolCall struct {
@@ -27,11 +29,90 @@ type ToolResult struct {
 type ChatArgs struct {
 	Messages []Message `json:"messages"`
 	Tools    []Tool    `json:"tools,omitempty"`
+	// Optional sampling overrides (nil = agent defaults)
+	Seed        *int64   `json:"seed,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	// N requests several completions; Logprobs/TopLogprobs are passed upstream as-is
+	N           int  `json:"n,omitempty"`
+	Logprobs    bool `json:"logprobs,omitempty"`
+	TopLogprobs int  `json:"top_logprobs,omitempty"`
+	// Trace asks for ChatReply.Trace (debugging)
+	Trace bool `json:"trace,omitempty"`
+	// SessionKey is the conversation the turn is stored and compacted under
+	// (empty = "default")
+	SessionKey string `json:"sessionKey,omitempty"`
 }
 
+// Chat reply types
+const (
+	ReplyTypeMessage = "message"
+	ReplyTypeAskUser = "ask_user"
+)
+
+// Chat reply error codes (ChatReply.ErrorCode)
+const (
+	// ReplyErrorEmptyUpstream: the provider answered without any choices
+	ReplyErrorEmptyUpstream = "empty_upstream"
+)
+
 type ChatReply struct {
 	Content string     `json:"content"`
 	Tools   []ToolCall `json:"tools,omitempty"`
+	// Type is ReplyTypeAskUser when the agent is waiting on a clarification
+	Type          string         `json:"type,omitempty"`
+	Clarification *Clarification `json:"clarification,omitempty"`
+	// Choices is set when n > 1 or logprobs were requested; Choices[0] matches Content
+	Choices []ChatChoice `json:"choices,omitempty"`
+	// Trace lists the tool calls of the turn in order, when ChatArgs.Trace is set
+	Trace []ToolTrace `json:"trace,omitempty"`
+	// Error is set when the turn failed upstream; Content is empty then
+	Error     string `json:"error,omitempty"`
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// ChatStreamReply names the stream Agent.ChatStream started
+type ChatStreamReply struct {
+	StreamID string `json:"streamId"`
+}
+
+// ChatStreamNextArgs polls a stream for its next text
+type ChatStreamNextArgs struct {
+	StreamID string `json:"streamId"`
+}
+
+// ChatStreamNextReply carries the text since the previous poll (empty when none
+// came within the poll wait). Done marks the last poll; Reply is the turn's
+// result then, and its Content begins with all the streamed text.
+type ChatStreamNextReply struct {
+	Delta string    `json:"delta,omitempty"`
+	Done  bool      `json:"done,omitempty"`
+	Reply ChatReply `json:"reply,omitempty"`
+}
+
+// ToolTrace is one executed tool call; Result is truncated
+type ToolTrace struct {
+	Name       string `json:"name"`
+	Arguments  string `json:"arguments"`
+	Result     string `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// ChatChoice is one upstream completion of the final answer
+type ChatChoice struct {
+	Index        int    `json:"index"`
+	Content      string `json:"content"`
+	FinishReason string `json:"finish_reason,omitempty"`
+	// Logprobs is the provider's raw logprobs JSON (a string so it survives gob)
+	Logprobs string `json:"logprobs,omitempty"`
+}
+
+// Clarification is a question the model asked via the ask_user tool.
+// The answer is sent back as the next user message.
+type Clarification struct {
+	Question string   `json:"question"`
+	Options  []string `json:"options,omitempty"`
+	Context  string   `json:"context,omitempty"`
 }
 
 type Tool struct {
@@ -42,7 +123,9 @@ type Tool struct {
 type ToolFunction struct {
 	Name        string                 `json:"name"`
 	Description string                 `json:"description"`
-	Parameters  map[string]interface{} `json:"parameters"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	// Strict asks OpenAI-style providers to enforce the schema (strict tool schema profile)
+	Strict bool `json:"strict,omitempty"`
 }
 
 type StatsReply struct {
@@ -54,6 +137,9 @@ type MemorySearchArgs struct {
 	Category string  `json:"category,omitempty"`
 	Limit    int     `json:"limit,omitempty"`
 	MinScore float64 `json:"minScore,omitempty"`
+	Ef       int     `json:"ef,omitempty"`      // per-query HNSW efSearch (0 = index setting)
+	Fields   string  `json:"fields,omitempty"`  // comma-separated result fields ("" = all)
+	Snippet  int     `json:"snippet,omitempty"` // snippet length in characters
 }
 
 type MemoryGetArgs struct {
@@ -64,8 +150,138 @@ type MemoryStoreArgs struct {
 	Text       string  `json:"text"`
 	Category   string  `json:"category,omitempty"`
 	Importance float64 `json:"importance,omitempty"`
+	ExternalID string  `json:"externalId,omitempty"`
+}
+
+// MemoryStoreBatchArgs are pre-embedded memories for Agent.MemoryStoreBatch
+type MemoryStoreBatchArgs struct {
+	Entries []MemoryBatchEntry `json:"entries"`
+}
+
+type MemoryBatchEntry struct {
+	Text       string    `json:"text"`
+	Vector     []float32 `json:"vector"`
+	Category   string    `json:"category,omitempty"`
+	Importance float64   `json:"importance,omitempty"`
+	Source     string    `json:"source,omitempty"`
+}
+
+// MemoryStoreBatchReply lists the new memory IDs in entry order
+type MemoryStoreBatchReply struct {
+	IDs []string `json:"ids"`
+}
+
+type MemoryDeleteArgs struct {
+	ID string `json:"id"`
 }
 
 type ToolResultReply struct {
 	Result string `json:"result"`
 }
+
+// SetupStatusReply reports whether the agent has a usable LLM configured.
+type SetupStatusReply struct {
+	Configured bool     `json:"configured"`
+	Missing    []string `json:"missing,omitempty"`
+	APIKeySet  bool     `json:"apiKeySet"`
+	BaseURL    string   `json:"baseUrl,omitempty"`
+	Model      string   `json:"model,omitempty"`
+	Message    string   `json:"message,omitempty"`
+}
+
+// WarmupReply reports what Agent.Warmup primed; Upstream and Embedding are
+// "ok ...", "skipped (...)" or "error: ..."
+type WarmupReply struct {
+	Tools      int    `json:"tools"`
+	Upstream   string `json:"upstream"`
+	Embedding  string `json:"embedding"`
+	DurationMs int64  `json:"durationMs"`
+}
+
+// PingReply answers the gateway heartbeat
+type PingReply struct {
+	UptimeMs int64 `json:"uptimeMs"`
+}
+
+// UpdateConfigArgs sets LLM config; empty fields keep the current value.
+type UpdateConfigArgs struct {
+	APIKey  string `json:"apiKey,omitempty"`
+	BaseURL string `json:"baseUrl,omitempty"`
+	Model   string `json:"model,omitempty"`
+}
+
+// KVGetArgs reads a kv_cache entry.
+type KVGetArgs struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+}
+
+// KVGetReply carries the cached value; Found is false for missing or expired keys.
+type KVGetReply struct {
+	Value string `json:"value"`
+	Found bool   `json:"found"`
+}
+
+// KVSetArgs writes a kv_cache entry that expires after TTLSeconds (0 = no expiry).
+type KVSetArgs struct {
+	Namespace  string `json:"namespace"`
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	TTLSeconds int    `json:"ttlSeconds"`
+}
+
+// SnapshotArgs names the directory a memory snapshot is written to or restored from.
+type SnapshotArgs struct {
+	Dir string `json:"dir"`
+}
+
+// AuditAppendArgs records one admin/config operation in the audit log.
+type AuditAppendArgs struct {
+	Actor        string `json:"actor"`
+	KeyID        string `json:"keyId,omitempty"`
+	ClaimedActor string `json:"claimedActor,omitempty"` // unverified X-OCG-Actor label
+	Action       string `json:"action"`
+	Target       string `json:"target,omitempty"`
+	Status       int    `json:"status"`
+	RemoteAddr   string `json:"remoteAddr,omitempty"`
+}
+
+// AuditListArgs filters the audit log (newest first); Action may end in "*" for a prefix match.
+type AuditListArgs struct {
+	Actor    string `json:"actor,omitempty"`
+	Action   string `json:"action,omitempty"`
+	BeforeID int64  `json:"beforeId,omitempty"`
+	Limit    int    `json:"limit,omitempty"`
+}
+
+// HNSWStatusReply reports the active HNSW index parameters.
+type HNSWStatusReply struct {
+	Enabled     bool   `json:"enabled"`
+	Dim         int    `json:"dim,omitempty"`
+	M           int    `json:"m,omitempty"`
+	EfSearch    int    `json:"efSearch,omitempty"`
+	EfConstruct int    `json:"efConstruct,omitempty"`
+	Distance    string `json:"distance,omitempty"`
+}
+
+// SetEfSearchArgs changes the HNSW search-time ef.
+type SetEfSearchArgs struct {
+	EfSearch int `json:"efSearch"`
+}
+
+// PulseArgs represents arguments for pulse operations (Agent.PulseAdd)
+type PulseArgs struct {
+	Action   string // "add", "status", "list"
+	Title    string
+	Content  string
+	Priority int    // 0-3
+	Channel  string
+	Limit    int
+}
+
+// PulseReply represents the result of pulse operations
+type PulseReply struct {
+	Result  string
+	EventID int64
+	Status  string
+}
@@ -0,0 +1,85 @@
+// Audit log - append-only record of who changed what and when
+
+package storage
+
+import (
+	"strings"
+	"time"
+)
+
+// AuditEntry is one recorded admin/config operation.
+type AuditEntry struct {
+	ID           int64     `json:"id"`
+	Actor        string    `json:"actor"`
+	KeyID        string    `json:"keyId,omitempty"`
+	ClaimedActor string    `json:"claimedActor,omitempty"` // label the caller sent; not verified
+	Action       string    `json:"action"`
+	Target       string    `json:"target,omitempty"`
+	Status       int       `json:"status"`
+	RemoteAddr   string    `json:"remoteAddr,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// AuditFilter narrows ListAudit; zero values match everything.
+type AuditFilter struct {
+	Actor    string
+	Action   string // exact action, or a prefix ending in "*" (e.g. "cron.*")
+	BeforeID int64  // page backwards from this ID
+	Limit    int
+}
+
+// AppendAudit records an entry. The table rejects updates and deletes.
+func (s *Storage) AppendAudit(e AuditEntry) (int64, error) {
+	res, err := s.db.Exec(
+		"INSERT INTO audit_log (actor, key_id, claimed_actor, action, target, status, remote_addr) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		e.Actor, e.KeyID, e.ClaimedActor, e.Action, e.Target, e.Status, e.RemoteAddr,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ListAudit returns entries newest first.
+func (s *Storage) ListAudit(f AuditFilter) ([]AuditEntry, error) {
+	if f.Limit <= 0 || f.Limit > 1000 {
+		f.Limit = 100
+	}
+	query := "SELECT id, actor, COALESCE(key_id, ''), COALESCE(claimed_actor, ''), action, COALESCE(target, ''), COALESCE(status, 0), COALESCE(remote_addr, ''), created_at FROM audit_log WHERE 1=1"
+	var args []interface{}
+	if f.Actor != "" {
+		query += " AND actor = ?"
+		args = append(args, f.Actor)
+	}
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, "*") {
+			query += " AND action LIKE ?"
+			args = append(args, strings.TrimSuffix(f.Action, "*")+"%")
+		} else {
+			query += " AND action = ?"
+			args = append(args, f.Action)
+		}
+	}
+	if f.BeforeID > 0 {
+		query += " AND id < ?"
+		args = append(args, f.BeforeID)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, f.Limit)
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var e AuditEntry
+		if err := rows.Scan(&e.ID, &e.Actor, &e.KeyID, &e.ClaimedActor, &e.Action, &e.Target, &e.Status, &e.RemoteAddr, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,134 @@
+// Schema migrations - ordered, versioned schema changes tracked in schema_version
+
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Migration is a single schema change. Up runs inside a transaction and must be
+// safe to apply to databases created before migrations were tracked.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+}
+
+// Migrate applies every migration of scope newer than the recorded version.
+// Each migration runs in its own transaction together with its version row,
+// so a failed migration leaves the schema at the previous version.
+func Migrate(db *sql.DB, scope string, migrations []Migration) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			scope TEXT NOT NULL,
+			version INTEGER NOT NULL,
+			name TEXT,
+			applied_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (scope, version)
+		)
+	`); err != nil {
+		return fmt.Errorf("create schema_version: %v", err)
+	}
+
+	current, err := SchemaVersion(db, scope)
+	if err != nil {
+		return err
+	}
+
+	last := 0
+	for _, m := range migrations {
+		if m.Version <= last {
+			return fmt.Errorf("migration %s/%d out of order", scope, m.Version)
+		}
+		last = m.Version
+		if m.Version <= current {
+			continue
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("migration %s/%d begin: %v", scope, m.Version, err)
+		}
+		if err := m.Up(tx); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s/%d (%s) failed: %v", scope, m.Version, m.Name, err)
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO schema_version (scope, version, name) VALUES (?, ?, ?)",
+			scope, m.Version, m.Name,
+		); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s/%d record: %v", scope, m.Version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("migration %s/%d commit: %v", scope, m.Version, err)
+		}
+		log.Printf("🔧 Migration %s/%d applied: %s", scope, m.Version, m.Name)
+	}
+	return nil
+}
+
+// SchemaVersion returns the highest applied migration version for scope (0 if none).
+func SchemaVersion(db *sql.DB, scope string) (int, error) {
+	var version int
+	err := db.QueryRow(
+		"SELECT COALESCE(MAX(version), 0) FROM schema_version WHERE scope = ?", scope,
+	).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("read schema version: %v", err)
+	}
+	return version, nil
+}
+
+// HasColumn reports whether table has the named column. A "schema.table" name
+// looks in an attached database.
+func HasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	pragma := fmt.Sprintf("PRAGMA table_info(%s)", table)
+	if schema, name, ok := strings.Cut(table, "."); ok {
+		pragma = fmt.Sprintf("PRAGMA %s.table_info(%s)", schema, name)
+	}
+	rows, err := tx.Query(pragma)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notnull, pk int
+		var name, ctype string
+		var dflt interface{}
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// AddColumnIfMissing adds column (with its type/default definition) unless it already exists.
+func AddColumnIfMissing(tx *sql.Tx, table, column, definition string) error {
+	ok, err := HasColumn(tx, table, column)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+	_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// execAll runs statements in order, stopping at the first error.
+func execAll(tx *sql.Tx, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
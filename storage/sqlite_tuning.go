@@ -0,0 +1,68 @@
+// SQLite tuning - page cache and memory-mapped I/O for every pooled connection
+
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DefaultCacheSizeMB is the page cache per connection when SQLiteTuning.CacheSizeMB
+// is 0; SQLite's own default is 2 MB
+const DefaultCacheSizeMB = 16
+
+// SQLiteTuning sizes SQLite's memory use. Both settings are per connection and
+// database/sql keeps a pool, so they are applied as each connection opens
+// (a plain db.Exec("PRAGMA ...") would reach only one of them).
+type SQLiteTuning struct {
+	// CacheSizeMB is PRAGMA cache_size (0 = DefaultCacheSizeMB)
+	CacheSizeMB int
+	// MmapSizeMB is PRAGMA mmap_size: reads of the first MmapSizeMB of the file go
+	// through the OS page cache instead of SQLite's (0 = off, SQLite's default)
+	MmapSizeMB int
+}
+
+// pragmas returns the statements run on each new connection
+func (t SQLiteTuning) pragmas() []string {
+	cache := t.CacheSizeMB
+	if cache <= 0 {
+		cache = DefaultCacheSizeMB
+	}
+	// A negative cache_size is in KiB rather than pages, so it does not depend on page_size
+	out := []string{fmt.Sprintf("PRAGMA cache_size = -%d", cache*1024)}
+	if t.MmapSizeMB > 0 {
+		out = append(out, fmt.Sprintf("PRAGMA mmap_size = %d", int64(t.MmapSizeMB)<<20))
+	}
+	return out
+}
+
+// OpenSQLite opens dsn with the sqlite3 driver and applies t to every connection
+func OpenSQLite(dsn string, t SQLiteTuning) *sql.DB {
+	pragmas := t.pragmas()
+	drv := &sqlite3.SQLiteDriver{ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+		for _, p := range pragmas {
+			if _, err := conn.Exec(p, nil); err != nil {
+				return fmt.Errorf("%s: %v", p, err)
+			}
+		}
+		return nil
+	}}
+	return sql.OpenDB(tunedConnector{dsn: dsn, drv: drv})
+}
+
+type tunedConnector struct {
+	dsn string
+	drv *sqlite3.SQLiteDriver
+}
+
+func (c tunedConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.drv.Open(c.dsn)
+}
+
+func (c tunedConnector) Driver() driver.Driver {
+	return c.drv
+}
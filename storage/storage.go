@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -86,11 +87,12 @@ type Event struct {
 }
 
 func New(dbPath string) (*Storage, error) {
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v", err)
-	}
+	return NewWithTuning(dbPath, SQLiteTuning{})
+}
 
+// NewWithTuning is New with explicit SQLite page cache and mmap sizes
+func NewWithTuning(dbPath string, tuning SQLiteTuning) (*Storage, error) {
+	db := OpenSQLite(dbPath, tuning)
 	s := &Storage{db: db}
 
 	// Set WAL mode
@@ -105,6 +107,9 @@ func New(dbPath string) (*Storage, error) {
 	if err := s.initSchema(); err != nil {
 		return nil, fmt.Errorf("failed to initialize schema: %v", err)
 	}
+	if n, err := s.KVPurgeExpired(); err == nil && n > 0 {
+		log.Printf("🧹 Storage: purged %d expired kv_cache entries", n)
+	}
 
 	// Optional: bind executable with database (build tag binddb)
 	if err := BindExecutable(s, dbPath); err != nil {
@@ -116,137 +121,230 @@ func New(dbPath string) (*Storage, error) {
 }
 
 func (s *Storage) initSchema() error {
-	// Messages table
-	_, err := s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS messages (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			session_key TEXT NOT NULL,
-			role TEXT NOT NULL,
-			content TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
-		return err
-	}
+	return Migrate(s.db, "storage", storageMigrations)
+}
 
-	// Memories table
-	_, err = s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS memories (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			key TEXT UNIQUE,
-			value TEXT,
-			category TEXT,
-			importance REAL DEFAULT 0.0,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+// storageMigrations is the ordered schema history; append new versions, never edit applied ones.
+var storageMigrations = []Migration{
+	{Version: 1, Name: "initial schema", Up: func(tx *sql.Tx) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS messages (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				session_key TEXT NOT NULL,
+				role TEXT NOT NULL,
+				content TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS memories (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				key TEXT UNIQUE,
+				value TEXT,
+				category TEXT,
+				importance REAL DEFAULT 0.0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS files (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				path TEXT UNIQUE,
+				content TEXT,
+				mime_type TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			// Config table (persistent config)
+			`CREATE TABLE IF NOT EXISTS config (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				section TEXT NOT NULL,
+				key TEXT NOT NULL,
+				value TEXT,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				UNIQUE(section, key)
+			)`,
+			`CREATE TABLE IF NOT EXISTS session_meta (
+				session_key TEXT PRIMARY KEY,
+				total_tokens INTEGER DEFAULT 0,
+				compaction_count INTEGER DEFAULT 0,
+				last_summary TEXT,
+				memory_flush_at DATETIME,
+				memory_flush_compaction_count INTEGER DEFAULT 0,
+				updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE TABLE IF NOT EXISTS messages_archive (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				session_key TEXT NOT NULL,
+				role TEXT NOT NULL,
+				content TEXT,
+				created_at DATETIME,
+				archived_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_messages_session ON messages(session_key)`,
+			`CREATE INDEX IF NOT EXISTS idx_memories_key ON memories(key)`,
+			`CREATE INDEX IF NOT EXISTS idx_config_section ON config(section, key)`,
+			`CREATE INDEX IF NOT EXISTS idx_session_meta ON session_meta(session_key)`,
+			// Events table (for pulse/heartbeat system)
+			`CREATE TABLE IF NOT EXISTS events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				title TEXT NOT NULL,
+				content TEXT,
+				priority INTEGER DEFAULT 2,
+				status TEXT DEFAULT 'pending',
+				channel TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				processed_at DATETIME
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_events_priority ON events(priority)`,
+			`CREATE INDEX IF NOT EXISTS idx_events_status ON events(status)`,
 		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Files table
-	_, err = s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS files (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			path TEXT UNIQUE,
-			content TEXT,
-			mime_type TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	}},
+	{Version: 2, Name: "kv_cache", Up: func(tx *sql.Tx) error {
+		return execAll(tx,
+			// Short-lived key/value entries (idempotency replies, tool scratch data)
+			`CREATE TABLE IF NOT EXISTS kv_cache (
+				namespace TEXT NOT NULL,
+				key TEXT NOT NULL,
+				value TEXT,
+				expires_at INTEGER NOT NULL DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (namespace, key)
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_kv_cache_expires ON kv_cache(expires_at)`,
 		)
-	`)
-	if err != nil {
-		return err
-	}
-
-	// Config table (persistent config)
-	_, err = s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS config (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			section TEXT NOT NULL,
-			key TEXT NOT NULL,
-			value TEXT,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			UNIQUE(section, key)
+	}},
+	{Version: 3, Name: "audit_log", Up: func(tx *sql.Tx) error {
+		return execAll(tx,
+			// Append-only record of admin and config-changing operations
+			`CREATE TABLE IF NOT EXISTS audit_log (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				actor TEXT NOT NULL,
+				key_id TEXT,
+				action TEXT NOT NULL,
+				target TEXT,
+				status INTEGER,
+				remote_addr TEXT,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action)`,
+			`CREATE INDEX IF NOT EXISTS idx_audit_log_created ON audit_log(created_at)`,
+			`CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log
+			BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END`,
+			`CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log
+			BEGIN SELECT RAISE(ABORT, 'audit_log is append-only'); END`,
 		)
-	`)
-	if err != nil {
-		return err
-	}
+	}},
+	{Version: 4, Name: "audit_log claimed_actor", Up: func(tx *sql.Tx) error {
+		// Unverified X-OCG-Actor label, kept apart from the credential-derived actor
+		return execAll(tx, `ALTER TABLE audit_log ADD COLUMN claimed_actor TEXT`)
+	}},
+}
 
-	// Session meta
-	_, err = s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS session_meta (
-			session_key TEXT PRIMARY KEY,
-			total_tokens INTEGER DEFAULT 0,
-			compaction_count INTEGER DEFAULT 0,
-			last_summary TEXT,
-			memory_flush_at DATETIME,
-			memory_flush_compaction_count INTEGER DEFAULT 0,
-			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
+// SchemaVersion returns the applied storage schema version.
+func (s *Storage) SchemaVersion() (int, error) {
+	return SchemaVersion(s.db, "storage")
+}
+
+// ============ Messages ============
+
+func (s *Storage) AddMessage(sessionKey, role, content string) error {
+	_, err := s.db.Exec(
+		"INSERT INTO messages (session_key, role, content) VALUES (?, ?, ?)",
+		sessionKey, role, content,
+	)
+	return err
+}
+
+// messageInsertBatch rows per INSERT statement, kept under SQLite's default
+// limit of 999 bound variables
+const (
+	messageInsertArgs  = 5 // id, session_key, role, content, created_at
+	messageInsertBatch = 999 / messageInsertArgs
+)
+
+// AddMessages inserts msgs for sessionKey in one transaction using multi-row inserts.
+// A zero CreatedAt gets the current time; otherwise the original timestamp is kept.
+func (s *Storage) AddMessages(sessionKey string, msgs []Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	// Archive table (optional)
-	_, err = s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS messages_archive (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			session_key TEXT NOT NULL,
-			role TEXT NOT NULL,
-			content TEXT,
-			created_at DATETIME,
-			archived_at DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
-	`)
-	if err != nil {
+	if err := addMessagesTx(tx, sessionKey, msgs, false); err != nil {
 		return err
 	}
+	return tx.Commit()
+}
 
-	// Create indexes
-	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_messages_session ON messages(session_key)`)
-	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_memories_key ON memories(key)`)
-	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_config_section ON config(section, key)`)
-	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_session_meta ON session_meta(session_key)`)
-
-	// Events table (for pulse/heartbeat system)
-	_, err = s.db.Exec(`
-		CREATE TABLE IF NOT EXISTS events (
-			id INTEGER PRIMARY KEY AUTOINCREMENT,
-			title TEXT NOT NULL,
-			content TEXT,
-			priority INTEGER DEFAULT 2,
-			status TEXT DEFAULT 'pending',
-			channel TEXT,
-			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-			processed_at DATETIME
-		)
-	`)
+// CompactMessages archives messages up to archiveThroughID and replaces the live
+// rows up to replaceThroughID with keep, all in one transaction so a crash cannot
+// lose the history. Rows added after replaceThroughID (by turns that ran while the
+// compaction was computed) are left in place. keep is inserted with its own IDs
+// and CreatedAt, which must not be above replaceThroughID, so the rewritten rows
+// still sort before those later turns.
+func (s *Storage) CompactMessages(sessionKey string, archiveThroughID, replaceThroughID int64, keep []Message) error {
+	tx, err := s.db.Begin()
 	if err != nil {
 		return err
 	}
-	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_events_priority ON events(priority)`)
-	s.db.Exec(`CREATE INDEX IF NOT EXISTS idx_events_status ON events(status)`)
-
-	return nil
+	defer tx.Rollback()
+
+	if archiveThroughID > 0 {
+		if _, err := tx.Exec(`
+			INSERT INTO messages_archive (session_key, role, content, created_at)
+			SELECT session_key, role, content, created_at FROM messages
+			WHERE session_key = ? AND id <= ?
+		`, sessionKey, archiveThroughID); err != nil {
+			return fmt.Errorf("archive: %v", err)
+		}
+	}
+	if _, err := tx.Exec("DELETE FROM messages WHERE session_key = ? AND id <= ?", sessionKey, replaceThroughID); err != nil {
+		return fmt.Errorf("clear: %v", err)
+	}
+	if err := addMessagesTx(tx, sessionKey, keep, true); err != nil {
+		return fmt.Errorf("insert: %v", err)
+	}
+	return tx.Commit()
 }
 
-// ============ Messages ============
-
-func (s *Storage) AddMessage(sessionKey, role, content string) error {
-	_, err := s.db.Exec(
-		"INSERT INTO messages (session_key, role, content) VALUES (?, ?, ?)",
-		sessionKey, role, content,
-	)
-	return err
+// addMessagesTx inserts msgs in batches. withIDs keeps each message's ID (0 still
+// gets a new one); otherwise every row gets a new ID.
+func addMessagesTx(tx *sql.Tx, sessionKey string, msgs []Message, withIDs bool) error {
+	for start := 0; start < len(msgs); start += messageInsertBatch {
+		end := start + messageInsertBatch
+		if end > len(msgs) {
+			end = len(msgs)
+		}
+		batch := msgs[start:end]
+
+		placeholders := make([]string, len(batch))
+		args := make([]interface{}, 0, len(batch)*messageInsertArgs)
+		for i, m := range batch {
+			placeholders[i] = "(?, ?, ?, ?, COALESCE(?, CURRENT_TIMESTAMP))"
+			var id, createdAt interface{}
+			if withIDs && m.ID != 0 {
+				id = m.ID
+			}
+			if !m.CreatedAt.IsZero() {
+				// Same layout as CURRENT_TIMESTAMP so text comparisons stay valid
+				createdAt = m.CreatedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			args = append(args, id, sessionKey, m.Role, m.Content, createdAt)
+		}
+		if _, err := tx.Exec(
+			"INSERT INTO messages (id, session_key, role, content, created_at) VALUES "+strings.Join(placeholders, ", "),
+			args...,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (s *Storage) GetMessages(sessionKey string, limit int) ([]Message, error) {
 	rows, err := s.db.Query(
-		"SELECT id, session_key, role, content, created_at FROM messages WHERE session_key = ? ORDER BY created_at DESC LIMIT ?",
+		"SELECT id, session_key, role, content, created_at FROM messages WHERE session_key = ? ORDER BY created_at DESC, id DESC LIMIT ?",
 		sessionKey, limit,
 	)
 	if err != nil {
@@ -257,15 +355,28 @@ func (s *Storage) GetMessages(sessionKey string, limit int) ([]Message, error) {
 	var msgs []Message
 	for rows.Next() {
 		var m Message
-		rows.Scan(&m.ID, &m.SessionKey, &m.Role, &m.Content, &m.CreatedAt)
+		if err = rows.Scan(&m.ID, &m.SessionKey, &m.Role, &m.Content, &m.CreatedAt); err != nil {
+			err = fmt.Errorf("scan message: %v", err)
+			break
+		}
 		msgs = append(msgs, m)
 	}
+	if err == nil {
+		err = rows.Err()
+	}
 
 	// Reverse order (oldest to newest)
 	for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
 		msgs[i], msgs[j] = msgs[j], msgs[i]
 	}
-	return msgs, nil
+	return msgs, err
+}
+
+// CountMessages returns the number of live messages in a session
+func (s *Storage) CountMessages(sessionKey string) (int, error) {
+	var n int
+	err := s.db.QueryRow("SELECT COUNT(*) FROM messages WHERE session_key = ?", sessionKey).Scan(&n)
+	return n, err
 }
 
 func (s *Storage) ClearMessages(sessionKey string) error {
@@ -319,6 +430,64 @@ func (s *Storage) ArchiveMessages(sessionKey string, beforeID int64) error {
 	return err
 }
 
+// ListSessionKeys returns every session that has live messages.
+func (s *Storage) ListSessionKeys() ([]string, error) {
+	rows, err := s.db.Query("SELECT DISTINCT session_key FROM messages")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// ArchiveBeyondRetention moves messages outside the retention policy into
+// messages_archive: everything but the newest maxMessages, and everything
+// older than maxAge. A zero limit disables that rule. Returns the number moved.
+func (s *Storage) ArchiveBeyondRetention(sessionKey string, maxMessages int, maxAge time.Duration) (int64, error) {
+	var conds []string
+	args := []interface{}{sessionKey}
+	if maxMessages > 0 {
+		conds = append(conds, "id NOT IN (SELECT id FROM messages WHERE session_key = ? ORDER BY id DESC LIMIT ?)")
+		args = append(args, sessionKey, maxMessages)
+	}
+	if maxAge > 0 {
+		conds = append(conds, "created_at < datetime('now', ?)")
+		args = append(args, fmt.Sprintf("-%d seconds", int64(maxAge.Seconds())))
+	}
+	if len(conds) == 0 {
+		return 0, nil
+	}
+	where := "session_key = ? AND (" + strings.Join(conds, " OR ") + ")"
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT INTO messages_archive (session_key, role, content, created_at)
+		SELECT session_key, role, content, created_at FROM messages
+		WHERE `+where+` ORDER BY id`, args...); err != nil {
+		return 0, err
+	}
+	res, err := tx.Exec("DELETE FROM messages WHERE "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return n, tx.Commit()
+}
+
 // ============ Memories ============
 
 func (s *Storage) SetMemory(key, text, category string) error {
@@ -351,7 +520,7 @@ func (s *Storage) GetMemory(idOrKey string) (Memory, error) {
 		SELECT id, key, value AS text, category, COALESCE(importance, 0.0), 
 		       COALESCE(created_at, datetime('now')), COALESCE(updated_at, datetime('now'))
 		FROM memories WHERE id = ? OR key = ?
-	`, idOrKey, idOrKey).Scan(&m.ID, &m.Key, &m.Text, &m.Category, &m.Importance, &m.CreatedAt, &m.UpdatedAt)
+	`, idOrKey, idOrKey).Scan(&m.ID, &m.Key, &m.Text, &m.Category, &m.Importance, timeScan{&m.CreatedAt}, timeScan{&m.UpdatedAt})
 	if err == sql.ErrNoRows {
 		return Memory{}, fmt.Errorf("memory not found: %s", idOrKey)
 	}
@@ -388,7 +557,7 @@ func (s *Storage) SearchMemories(keyword string) ([]Memory, error) {
 		SELECT id, key, value AS text, category, COALESCE(importance, 0.0), 
 		       COALESCE(created_at, datetime('now')), COALESCE(updated_at, datetime('now'))
 		FROM memories WHERE key = ?
-	`, keyword).Scan(&m.ID, &m.Key, &m.Text, &m.Category, &m.Importance, &m.CreatedAt, &m.UpdatedAt)
+	`, keyword).Scan(&m.ID, &m.Key, &m.Text, &m.Category, &m.Importance, timeScan{&m.CreatedAt}, timeScan{&m.UpdatedAt})
 	if err == nil {
 		return []Memory{m}, nil
 	}
@@ -411,14 +580,49 @@ func (s *Storage) SearchMemories(keyword string) ([]Memory, error) {
 	return scanMemories(rows)
 }
 
+// timeScan scans a timestamp column into *t. COALESCE(created_at, ...) loses the
+// column's declared type, so the driver hands back text instead of time.Time.
+type timeScan struct{ t *time.Time }
+
+var sqliteTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04:05.999999999-07:00",
+	time.RFC3339Nano,
+}
+
+func (ts timeScan) Scan(v interface{}) error {
+	switch x := v.(type) {
+	case nil:
+		*ts.t = time.Time{}
+		return nil
+	case time.Time:
+		*ts.t = x
+		return nil
+	case []byte:
+		return ts.Scan(string(x))
+	case string:
+		for _, layout := range sqliteTimeLayouts {
+			if t, err := time.Parse(layout, x); err == nil {
+				*ts.t = t
+				return nil
+			}
+		}
+		return fmt.Errorf("unrecognized timestamp %q", x)
+	}
+	return fmt.Errorf("unsupported timestamp type %T", v)
+}
+
+// scanMemories reads memory rows; on a bad row it returns the rows read so far and the error
 func scanMemories(rows *sql.Rows) ([]Memory, error) {
 	var memories []Memory
 	for rows.Next() {
 		var m Memory
-		rows.Scan(&m.ID, &m.Key, &m.Text, &m.Category, &m.Importance, &m.CreatedAt, &m.UpdatedAt)
+		if err := rows.Scan(&m.ID, &m.Key, &m.Text, &m.Category, &m.Importance, timeScan{&m.CreatedAt}, timeScan{&m.UpdatedAt}); err != nil {
+			return memories, fmt.Errorf("scan memory: %v", err)
+		}
 		memories = append(memories, m)
 	}
-	return memories, nil
+	return memories, rows.Err()
 }
 
 func (s *Storage) GetAllMemories(limit int) ([]Memory, error) {
@@ -471,10 +675,12 @@ func (s *Storage) ListFiles() ([]FileRecord, error) {
 	var files []FileRecord
 	for rows.Next() {
 		var f FileRecord
-		rows.Scan(&f.ID, &f.Path, &f.Content, &f.MimeType, &f.CreatedAt)
+		if err := rows.Scan(&f.ID, &f.Path, &f.Content, &f.MimeType, &f.CreatedAt); err != nil {
+			return files, fmt.Errorf("scan file: %v", err)
+		}
 		files = append(files, f)
 	}
-	return files, nil
+	return files, rows.Err()
 }
 
 // ============ Tools ============
@@ -486,15 +692,13 @@ func (s *Storage) Close() error {
 func (s *Storage) Stats() (map[string]int, error) {
 	stats := make(map[string]int)
 
-	var count int
-	s.db.QueryRow("SELECT COUNT(*) FROM messages").Scan(&count)
-	stats["messages"] = count
-
-	s.db.QueryRow("SELECT COUNT(*) FROM memories").Scan(&count)
-	stats["memories"] = count
-
-	s.db.QueryRow("SELECT COUNT(*) FROM files").Scan(&count)
-	stats["files"] = count
+	for _, table := range []string{"messages", "memories", "files"} {
+		var count int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM " + table).Scan(&count); err != nil {
+			return stats, fmt.Errorf("count %s: %v", table, err)
+		}
+		stats[table] = count
+	}
 
 	return stats, nil
 }
@@ -523,13 +727,97 @@ func (s *Storage) ExportMemories() ([]byte, error) {
 	var memories []ExportMem
 	for rows.Next() {
 		var m ExportMem
-		rows.Scan(&m.ID, &m.Key, &m.Value, &m.Category, &m.UpdatedAt)
+		if err := rows.Scan(&m.ID, &m.Key, &m.Value, &m.Category, &m.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan memory: %v", err)
+		}
 		memories = append(memories, m)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return json.MarshalIndent(memories, "", "  ")
 }
 
+// ============ KV cache ============
+
+// KVSet stores value under namespace/key. ttl <= 0 keeps it until deleted.
+func (s *Storage) KVSet(namespace, key, value string, ttl time.Duration) error {
+	var expiresAt int64
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl).Unix()
+	}
+	_, err := s.db.Exec(
+		"INSERT OR REPLACE INTO kv_cache (namespace, key, value, expires_at, created_at) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP)",
+		namespace, key, value, expiresAt,
+	)
+	return err
+}
+
+// KVGet returns the value for namespace/key; expired entries count as missing.
+func (s *Storage) KVGet(namespace, key string) (string, bool, error) {
+	var value string
+	err := s.db.QueryRow(
+		"SELECT value FROM kv_cache WHERE namespace = ? AND key = ? AND (expires_at = 0 OR expires_at > ?)",
+		namespace, key, time.Now().Unix(),
+	).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+// KVEntry is a live kv_cache row; ExpiresAt is zero when the entry never expires.
+type KVEntry struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// KVList returns the live entries of namespace ordered by key
+func (s *Storage) KVList(namespace string) ([]KVEntry, error) {
+	rows, err := s.db.Query(
+		"SELECT key, value, expires_at FROM kv_cache WHERE namespace = ? AND (expires_at = 0 OR expires_at > ?) ORDER BY key",
+		namespace, time.Now().Unix(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []KVEntry
+	for rows.Next() {
+		var e KVEntry
+		var expiresAt int64
+		if err := rows.Scan(&e.Key, &e.Value, &expiresAt); err != nil {
+			return nil, err
+		}
+		if expiresAt > 0 {
+			e.ExpiresAt = time.Unix(expiresAt, 0)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// KVDelete removes namespace/key
+func (s *Storage) KVDelete(namespace, key string) error {
+	_, err := s.db.Exec("DELETE FROM kv_cache WHERE namespace = ? AND key = ?", namespace, key)
+	return err
+}
+
+// KVPurgeExpired deletes expired entries and returns how many were removed
+func (s *Storage) KVPurgeExpired() (int64, error) {
+	res, err := s.db.Exec("DELETE FROM kv_cache WHERE expires_at > 0 AND expires_at <= ?", time.Now().Unix())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
 // ============ Config (persistence) ============
 
 // SetConfig writes a config entry to the database
@@ -612,9 +900,14 @@ func (s *Storage) ExportConfig() ([]byte, error) {
 	var configs []ExportConfig
 	for rows.Next() {
 		var c ExportConfig
-		rows.Scan(&c.Section, &c.Key, &c.Value)
+		if err := rows.Scan(&c.Section, &c.Key, &c.Value); err != nil {
+			return nil, fmt.Errorf("scan config: %v", err)
+		}
 		configs = append(configs, c)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	return json.MarshalIndent(configs, "", "  ")
 }
@@ -652,14 +945,14 @@ func (s *Storage) GetPendingEvents(limit int) ([]Event, error) {
 		var e Event
 		var processedAt sql.NullTime
 		if err := rows.Scan(&e.ID, &e.Title, &e.Content, &e.Priority, &e.Status, &e.Channel, &e.CreatedAt, &processedAt); err != nil {
-			return nil, err
+			return events, fmt.Errorf("scan event: %v", err)
 		}
 		if processedAt.Valid {
 			e.ProcessedAt = &processedAt.Time
 		}
 		events = append(events, e)
 	}
-	return events, nil
+	return events, rows.Err()
 }
 
 // GetNextEvent returns the highest priority pending event
@@ -711,11 +1004,11 @@ func (s *Storage) GetEventCount() (map[string]int, error) {
 		var status string
 		var count int
 		if err := rows.Scan(&status, &count); err != nil {
-			return nil, err
+			return counts, fmt.Errorf("scan event count: %v", err)
 		}
 		counts[status] = count
 	}
-	return counts, nil
+	return counts, rows.Err()
 }
 
 // ClearOldEvents removes completed/dismissed events older than specified hours
@@ -0,0 +1,164 @@
+// Tiktoken-compatible counting - byte-level BPE over a .tiktoken ranks file
+
+package tokens
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// splitPattern is the cl100k pre-tokenizer without its `\s+(?!\S)` branch, which
+// RE2 cannot express; splitChunks emulates it
+var splitPattern = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// BPE counts tokens exactly as tiktoken encodes ordinary text (special tokens
+// such as <|endoftext|> are counted as plain text)
+type BPE struct {
+	ranks map[string]int
+}
+
+// LoadTiktoken reads a tiktoken ranks file (cl100k_base.tiktoken, o200k_base.tiktoken):
+// one base64 token and its rank per line
+func LoadTiktoken(file string) (*BPE, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks := make(map[string]int)
+	sc := bufio.NewScanner(f)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+		tok, rank, ok := strings.Cut(text, " ")
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: want \"<base64> <rank>\"", file, line)
+		}
+		b, err := base64.StdEncoding.DecodeString(tok)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, line, err)
+		}
+		r, err := strconv.Atoi(rank)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, line, err)
+		}
+		ranks[string(b)] = r
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("%s: no tokens", file)
+	}
+	return &BPE{ranks: ranks}, nil
+}
+
+// Count implements Counter
+func (b *BPE) Count(text string) int {
+	n := 0
+	for _, chunk := range splitChunks(text) {
+		n += b.countChunk(chunk)
+	}
+	return n
+}
+
+// countChunk merges the pair with the lowest rank until none is in the vocabulary
+func (b *BPE) countChunk(chunk string) int {
+	if _, ok := b.ranks[chunk]; ok {
+		return 1
+	}
+	// parts[i] is the start offset of the i-th part; the last entry is len(chunk)
+	parts := make([]int, len(chunk)+1)
+	for i := range parts {
+		parts[i] = i
+	}
+	for len(parts) > 2 {
+		best, bestRank := -1, 0
+		for i := 0; i+2 < len(parts); i++ {
+			if r, ok := b.ranks[chunk[parts[i]:parts[i+2]]]; ok && (best < 0 || r < bestRank) {
+				best, bestRank = i, r
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return len(parts) - 1
+}
+
+// splitChunks applies splitPattern; a trailing whitespace run that is followed by
+// a word gives up its last rune, which then leads the next chunk (" word")
+func splitChunks(text string) []string {
+	var chunks []string
+	for pos := 0; pos < len(text); {
+		loc := splitPattern.FindStringIndex(text[pos:])
+		if loc == nil {
+			chunks = append(chunks, text[pos:])
+			break
+		}
+		if loc[0] > 0 {
+			chunks = append(chunks, text[pos:pos+loc[0]])
+			pos += loc[0]
+			continue
+		}
+		end := pos + loc[1]
+		m := text[pos:end]
+		if end < len(text) && isSpaceRun(m) && !strings.ContainsAny(m[len(m)-1:], "\r\n") {
+			if next, _ := utf8.DecodeRuneInString(text[end:]); !unicode.IsSpace(next) {
+				if _, size := utf8.DecodeLastRuneInString(m); size < len(m) {
+					end -= size
+				}
+			}
+		}
+		chunks = append(chunks, text[pos:end])
+		pos = end
+	}
+	return chunks
+}
+
+func isSpaceRun(s string) bool {
+	for _, r := range s {
+		if !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// RegisterFiles parses "pattern=file,..." (OPENCLAW_TOKENIZERS), loads each
+// ranks file once and registers it for its model pattern
+func RegisterFiles(spec string) error {
+	loaded := make(map[string]*BPE)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pattern, file, ok := strings.Cut(entry, "=")
+		pattern, file = strings.TrimSpace(pattern), strings.TrimSpace(file)
+		if !ok || pattern == "" || file == "" {
+			return fmt.Errorf("invalid entry %q (want model-glob=ranks-file)", entry)
+		}
+		bpe, ok := loaded[file]
+		if !ok {
+			var err error
+			if bpe, err = LoadTiktoken(file); err != nil {
+				return err
+			}
+			loaded[file] = bpe
+		}
+		Register(pattern, bpe)
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+// Package tokens estimates how many LLM tokens a text takes, shared by the agent
+// (compaction threshold, session totals) and the gateway (reported Usage).
+//
+// The default Heuristic needs no vocabulary. It counts ASCII words, digit groups,
+// punctuation and multibyte runes separately, so CJK and emoji text is no longer
+// undercounted the way len/4 did. For exact counts, load a tiktoken ranks file
+// with LoadTiktoken and Register it for the models that use it.
+package tokens
+
+import (
+	"path"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MessageOverhead is added per chat message for the role and separators
+const MessageOverhead = 4
+
+// Counter reports the number of tokens in a text
+type Counter interface {
+	Count(text string) int
+}
+
+// CountMessages sums the contents plus MessageOverhead for each message
+func CountMessages(c Counter, contents ...string) int {
+	total := 0
+	for _, s := range contents {
+		total += c.Count(s) + MessageOverhead
+	}
+	return total
+}
+
+// Heuristic approximates BPE tokenizers such as cl100k without a vocabulary:
+//   - an ASCII word is one token per 5 letters (a leading space is free)
+//   - digits go in groups of 3
+//   - each ASCII punctuation mark is one token
+//   - a run of newlines, or of 2+ spaces (indentation), is one token
+//   - a CJK or kana/hangul rune is one token; other letters take a token per 2 runes
+//   - any other rune (emoji, symbols) is one token per 2 bytes of UTF-8
+type Heuristic struct{}
+
+// Count implements Counter
+func (Heuristic) Count(text string) int {
+	n := 0
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+		j := i + size
+		switch {
+		case isASCIILetter(r):
+			for j < len(text) && isASCIILetter(rune(text[j])) {
+				j++
+			}
+			n += (j - i + 4) / 5
+		case r >= '0' && r <= '9':
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				j++
+			}
+			n += (j - i + 2) / 3
+		case r == '\n' || r == '\r':
+			for j < len(text) && (text[j] == '\n' || text[j] == '\r') {
+				j++
+			}
+			n++
+		case r == ' ' || r == '\t':
+			for j < len(text) && (text[j] == ' ' || text[j] == '\t') {
+				j++
+			}
+			if j-i > 1 {
+				n++
+			}
+		case r < utf8.RuneSelf:
+			n++
+		case isIdeographic(r):
+			n++
+		case unicode.IsLetter(r) || unicode.IsMark(r):
+			runes := 1
+			for j < len(text) {
+				r2, size2 := utf8.DecodeRuneInString(text[j:])
+				if r2 < utf8.RuneSelf || isIdeographic(r2) || !(unicode.IsLetter(r2) || unicode.IsMark(r2)) {
+					break
+				}
+				runes++
+				j += size2
+			}
+			n += (runes + 1) / 2
+		default:
+			n += (size + 1) / 2
+		}
+		i = j
+	}
+	return n
+}
+
+func isASCIILetter(r rune) bool {
+	return r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+}
+
+// isIdeographic covers scripts where BPE vocabularies hold about one token per rune
+func isIdeographic(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// Model-specific counters, matched in registration order
+var (
+	mu       sync.RWMutex
+	patterns []string
+	counters []Counter
+)
+
+// Register makes ForModel return c for models matching pattern, a lower-case
+// glob tried with and without the provider prefix ("gpt-4o*" matches "openai/gpt-4o-mini")
+func Register(pattern string, c Counter) {
+	mu.Lock()
+	defer mu.Unlock()
+	patterns = append(patterns, strings.ToLower(pattern))
+	counters = append(counters, c)
+}
+
+// ForModel returns the first registered counter whose pattern matches model,
+// or Heuristic
+func ForModel(model string) Counter {
+	model = strings.ToLower(model)
+	base := model
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		base = model[i+1:]
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for i, p := range patterns {
+		if ok, _ := path.Match(p, model); ok {
+			return counters[i]
+		}
+		if ok, _ := path.Match(p, base); ok {
+			return counters[i]
+		}
+	}
+	return Heuristic{}
+}
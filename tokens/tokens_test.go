@@ -0,0 +1,108 @@
+package tokens
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeuristic(t *testing.T) {
+	var h Heuristic
+	cases := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"hello world", 2},
+		{"internationalization", 4},
+		{"1234567", 3},
+		{"a, b.", 4},
+		{"你好世界", 4},
+		{"привет", 3},
+		{"😀", 2},
+		{"line\n\n    indented", 5},
+	}
+	for _, c := range cases {
+		if got := h.Count(c.text); got != c.want {
+			t.Errorf("Count(%q) = %d, want %d", c.text, got, c.want)
+		}
+	}
+
+	// The len/4 estimate undercounted CJK by ~3x; the heuristic must not
+	cjk := strings.Repeat("记忆", 50)
+	if got := h.Count(cjk); got < 100 {
+		t.Errorf("CJK text of 100 runes counted as %d tokens", got)
+	}
+	if got := CountMessages(h, "hi", ""); got != 1+2*MessageOverhead {
+		t.Errorf("CountMessages = %d", got)
+	}
+}
+
+func TestSplitChunks(t *testing.T) {
+	got := splitChunks("Hello  world's 12345!\n\nok")
+	want := []string{"Hello", " ", " world", "'s", " ", "123", "45", "!\n\n", "ok"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("splitChunks = %q, want %q", got, want)
+	}
+}
+
+// writeRanks writes a tiktoken file with every single byte plus the given merges
+func writeRanks(t *testing.T, merges ...string) string {
+	t.Helper()
+	var sb strings.Builder
+	rank := 0
+	for b := 0; b < 256; b++ {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte{byte(b)}), rank)
+		rank++
+	}
+	for _, m := range merges {
+		fmt.Fprintf(&sb, "%s %d\n", base64.StdEncoding.EncodeToString([]byte(m)), rank)
+		rank++
+	}
+	file := filepath.Join(t.TempDir(), "test.tiktoken")
+	if err := os.WriteFile(file, []byte(sb.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestTiktokenBPE(t *testing.T) {
+	bpe, err := LoadTiktoken(writeRanks(t, "he", "ll", "hell", "hello", " w", "or"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		text string
+		want int
+	}{
+		{"hello", 1},       // whole chunk is a token
+		{"help", 3},        // he + l + p
+		{" world", 4},      // " w" + "or" + l + d
+		{"hello world", 5}, // chunks "hello", " world"
+	}
+	for _, c := range cases {
+		if got := bpe.Count(c.text); got != c.want {
+			t.Errorf("Count(%q) = %d, want %d", c.text, got, c.want)
+		}
+	}
+}
+
+func TestForModel(t *testing.T) {
+	file := writeRanks(t, "hello")
+	if err := RegisterFiles("gpt-4o*=" + file); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ForModel("openai/GPT-4o-mini").(*BPE); !ok {
+		t.Error("provider-prefixed model did not match gpt-4o*")
+	}
+	if _, ok := ForModel("llama-3").(Heuristic); !ok {
+		t.Error("unmatched model should use the heuristic")
+	}
+	if err := RegisterFiles("gpt-4o"); err == nil {
+		t.Error("entry without a file was accepted")
+	}
+}
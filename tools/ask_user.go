@@ -0,0 +1,92 @@
+// Ask User tool - lets the model pause and request clarification
+package tools
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AskUserToolName is intercepted by the agent: the turn ends and the
+// question is returned to the caller instead of a normal reply.
+const AskUserToolName = "ask_user"
+
+type AskUserTool struct{}
+
+func (t *AskUserTool) Name() string {
+	return AskUserToolName
+}
+
+func (t *AskUserTool) Description() string {
+	return "Ask the user a clarifying question when required information is missing. Use instead of guessing; the answer arrives as the next user message."
+}
+
+func (t *AskUserTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"question": map[string]interface{}{
+				"type":        "string",
+				"description": "The question to ask the user",
+			},
+			"options": map[string]interface{}{
+				"type":        "array",
+				"items":       map[string]interface{}{"type": "string"},
+				"description": "Optional suggested answers",
+			},
+			"context": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional short note on why the information is needed",
+			},
+		},
+		"required": []string{"question"},
+	}
+}
+
+func (t *AskUserTool) Execute(args map[string]interface{}) (interface{}, error) {
+	req, err := ParseAskUserArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"status":   "awaiting_user",
+		"question": req.Question,
+		"options":  req.Options,
+		"context":  req.Context,
+	}, nil
+}
+
+// AskUserRequest is the parsed ask_user call
+type AskUserRequest struct {
+	Question string
+	Options  []string
+	Context  string
+}
+
+// ParseAskUserArgs validates ask_user arguments
+func ParseAskUserArgs(args map[string]interface{}) (AskUserRequest, error) {
+	req := AskUserRequest{
+		Question: strings.TrimSpace(GetString(args, "question")),
+		Context:  strings.TrimSpace(GetString(args, "context")),
+	}
+	if req.Question == "" {
+		return req, fmt.Errorf("question is required")
+	}
+	switch v := args["options"].(type) {
+	case []interface{}:
+		for _, o := range v {
+			if s, ok := o.(string); ok && strings.TrimSpace(s) != "" {
+				req.Options = append(req.Options, strings.TrimSpace(s))
+			}
+		}
+	case []string:
+		req.Options = v
+	case string:
+		// Custom tool-call formats pass arrays as comma-separated text
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				req.Options = append(req.Options, s)
+			}
+		}
+	}
+	return req, nil
+}
@@ -0,0 +1,231 @@
+// Datetime tool - current time, relative offsets, parsing and conversion across IANA timezones
+
+package tools
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	// Embedded zone database so IANA names resolve on hosts without tzdata
+	_ "time/tzdata"
+)
+
+// datetimeLayouts are tried in order when parsing a date without an explicit format
+var datetimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"2006/01/02 15:04",
+	"2006/01/02",
+	time.RFC1123,
+	time.RFC1123Z,
+	"Jan 2, 2006 15:04",
+	"Jan 2, 2006",
+	"2 Jan 2006",
+}
+
+var relativeUnit = regexp.MustCompile(`(?i)([+-]?\d+)\s*(years?|yrs?|y|months?|mo|weeks?|wks?|w|days?|d|hours?|hrs?|h|minutes?|mins?|m|seconds?|secs?|s)`)
+
+type DatetimeTool struct{}
+
+func (t *DatetimeTool) Name() string { return "datetime" }
+
+func (t *DatetimeTool) Description() string {
+	return "Current date/time in any IANA timezone, relative times (\"3 days from now\", \"2h ago\"), and date parsing/conversion. Use this instead of guessing dates."
+}
+
+func (t *DatetimeTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"description": "now (default), relative, parse, convert",
+				"enum":        []string{"now", "relative", "parse", "convert"},
+			},
+			"timezone": map[string]interface{}{
+				"type":        "string",
+				"description": "IANA timezone, e.g. Europe/Prague, America/New_York (default UTC)",
+			},
+			"offset": map[string]interface{}{
+				"type":        "string",
+				"description": "For relative: e.g. \"3 days from now\", \"in 2 hours\", \"1 week ago\", \"+90m\", \"-1d\"",
+			},
+			"value": map[string]interface{}{
+				"type":        "string",
+				"description": "Date to parse/convert (ISO 8601, \"2006-01-02 15:04\", unix seconds...); base time for relative",
+			},
+			"to": map[string]interface{}{
+				"type":        "string",
+				"description": "Target IANA timezone for convert",
+			},
+			"format": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional Go layout for the formatted output (e.g. \"Mon Jan 2 15:04\")",
+			},
+		},
+	}
+}
+
+func (t *DatetimeTool) Execute(args map[string]interface{}) (interface{}, error) {
+	loc, err := loadLocation(GetString(args, "timezone"))
+	if err != nil {
+		return nil, err
+	}
+	layout := GetString(args, "format")
+	value := strings.TrimSpace(GetString(args, "value"))
+
+	switch strings.ToLower(strings.TrimSpace(GetString(args, "action"))) {
+	case "", "now":
+		return describeTime(time.Now().In(loc), layout), nil
+	case "relative":
+		base := time.Now().In(loc)
+		if value != "" {
+			if base, err = parseDatetime(value, loc); err != nil {
+				return nil, err
+			}
+		}
+		result, err := applyRelative(base, GetString(args, "offset"))
+		if err != nil {
+			return nil, err
+		}
+		out := describeTime(result, layout)
+		out["base"] = base.Format(time.RFC3339)
+		return out, nil
+	case "parse":
+		if value == "" {
+			return nil, fmt.Errorf("value is required for parse")
+		}
+		parsed, err := parseDatetime(value, loc)
+		if err != nil {
+			return nil, err
+		}
+		return describeTime(parsed, layout), nil
+	case "convert":
+		if value == "" {
+			return nil, fmt.Errorf("value is required for convert")
+		}
+		to, err := loadLocation(GetString(args, "to"))
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseDatetime(value, loc)
+		if err != nil {
+			return nil, err
+		}
+		out := describeTime(parsed.In(to), layout)
+		out["from"] = parsed.Format(time.RFC3339)
+		return out, nil
+	default:
+		return nil, fmt.Errorf("unknown action: %s", GetString(args, "action"))
+	}
+}
+
+func loadLocation(name string) (*time.Location, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || strings.EqualFold(name, "utc") {
+		return time.UTC, nil
+	}
+	if strings.EqualFold(name, "local") {
+		return time.Local, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown timezone %q (use an IANA name like Europe/Prague)", name)
+	}
+	return loc, nil
+}
+
+// parseDatetime reads value in loc unless it carries its own offset
+func parseDatetime(value string, loc *time.Location) (time.Time, error) {
+	if secs, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(secs, 0).In(loc), nil
+	}
+	for _, layout := range datetimeLayouts {
+		if parsed, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse date %q (try ISO 8601, e.g. 2006-01-02T15:04:05Z)", value)
+}
+
+// applyRelative shifts base by phrases like "3 days from now", "2 weeks ago", "+1h30m"
+func applyRelative(base time.Time, offset string) (time.Time, error) {
+	offset = strings.TrimSpace(offset)
+	if offset == "" {
+		return time.Time{}, fmt.Errorf("offset is required for relative")
+	}
+	matches := relativeUnit.FindAllStringSubmatch(offset, -1)
+	if len(matches) == 0 {
+		return time.Time{}, fmt.Errorf("cannot parse offset %q (e.g. \"3 days from now\", \"-2h\")", offset)
+	}
+	sign := 1
+	if strings.Contains(strings.ToLower(offset), "ago") || strings.HasPrefix(offset, "-") {
+		sign = -1
+	}
+
+	result := base
+	for _, m := range matches {
+		n, _ := strconv.Atoi(strings.TrimLeft(m[1], "+-"))
+		n *= sign
+		unit := strings.ToLower(m[2])
+		switch {
+		case strings.HasPrefix(unit, "y"):
+			result = result.AddDate(n, 0, 0)
+		case strings.HasPrefix(unit, "mo"):
+			result = result.AddDate(0, n, 0)
+		case strings.HasPrefix(unit, "w"):
+			result = result.AddDate(0, 0, 7*n)
+		case strings.HasPrefix(unit, "d"):
+			result = result.AddDate(0, 0, n)
+		case strings.HasPrefix(unit, "h"):
+			result = result.Add(time.Duration(n) * time.Hour)
+		case strings.HasPrefix(unit, "m"):
+			result = result.Add(time.Duration(n) * time.Minute)
+		default:
+			result = result.Add(time.Duration(n) * time.Second)
+		}
+	}
+	return result, nil
+}
+
+func describeTime(tm time.Time, layout string) map[string]interface{} {
+	zone, offset := tm.Zone()
+	out := map[string]interface{}{
+		"iso":      tm.Format(time.RFC3339),
+		"unix":     tm.Unix(),
+		"date":     tm.Format("2006-01-02"),
+		"time":     tm.Format("15:04:05"),
+		"weekday":  tm.Weekday().String(),
+		"timezone": tm.Location().String(),
+		"zone":     zone,
+		"offset":   formatOffset(offset),
+	}
+	if layout != "" {
+		out["formatted"] = tm.Format(layout)
+	}
+	return out
+}
+
+// formatOffset renders a UTC offset in seconds as ±hh:mm; the sign comes from the
+// whole offset so zones between -01:00 and 00:00 keep their minus
+func formatOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+	}
+	abs := absInt(offset)
+	return fmt.Sprintf("%c%02d:%02d", sign, abs/3600, abs%3600/60)
+}
+
+func absInt(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
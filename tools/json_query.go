@@ -0,0 +1,497 @@
+// JSON query tool - extract values from JSON with a safe jq-style path subset
+
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	maxQueryInput   = 1 << 20 // 1MB of JSON
+	maxQueryLen     = 256
+	maxQueryStages  = 16
+	maxQueryResults = 1000
+	maxQueryOutput  = 64 * 1024
+)
+
+// JSONQueryTool evaluates paths like .data[0].id, .items[].name or .users | length.
+// Only paths, iteration, slices and a few builtins are supported: no recursion,
+// no user functions and bounded fan-out, so every query finishes quickly.
+type JSONQueryTool struct{}
+
+func (t *JSONQueryTool) Name() string { return "json_query" }
+
+func (t *JSONQueryTool) Description() string {
+	return "Extract values from JSON (e.g. an http_request/web_fetch response) with a jq-style path: .data[0].id, .items[].name, .[\"a key\"], .list[1:3], .users | length. Builtins: length, keys, type, first, last."
+}
+
+func (t *JSONQueryTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"json": map[string]interface{}{
+				"type":        "string",
+				"description": "JSON document to query",
+			},
+			"query": map[string]interface{}{
+				"type":        "string",
+				"description": "Path expression, stages separated by |",
+			},
+		},
+		"required": []string{"json", "query"},
+	}
+}
+
+func (t *JSONQueryTool) Execute(args map[string]interface{}) (interface{}, error) {
+	query := strings.TrimSpace(GetString(args, "query"))
+	if query == "" {
+		return nil, fmt.Errorf("query is required")
+	}
+	if len(query) > maxQueryLen {
+		return nil, fmt.Errorf("query too long (max %d chars)", maxQueryLen)
+	}
+	stages, err := parseJSONQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := queryInput(args["json"])
+	if err != nil {
+		return nil, err
+	}
+
+	values := []interface{}{doc}
+	for _, st := range stages {
+		if values, err = st.apply(values); err != nil {
+			return nil, err
+		}
+	}
+
+	out := map[string]interface{}{"query": query, "count": len(values)}
+	var result interface{} = values
+	if len(values) == 1 {
+		result = values[0]
+	}
+	data, _ := json.Marshal(result)
+	if len(data) > maxQueryOutput {
+		out["truncated"] = true
+		out["result"] = string(data[:maxQueryOutput])
+		return out, nil
+	}
+	out["result"] = result
+	return out, nil
+}
+
+// queryInput decodes the document; numbers stay json.Number so large IDs are exact
+func queryInput(v interface{}) (interface{}, error) {
+	var raw string
+	switch x := v.(type) {
+	case nil:
+		return nil, fmt.Errorf("json is required")
+	case string:
+		raw = x
+	default:
+		// Models sometimes pass the document as an object instead of a string
+		data, err := json.Marshal(x)
+		if err != nil {
+			return nil, fmt.Errorf("invalid json: %v", err)
+		}
+		raw = string(data)
+	}
+	if len(raw) > maxQueryInput {
+		return nil, fmt.Errorf("json too large (%d bytes, max %d)", len(raw), maxQueryInput)
+	}
+	dec := json.NewDecoder(strings.NewReader(raw))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid json: %v", err)
+	}
+	return doc, nil
+}
+
+// ===================== Query parsing =====================
+
+type querySegKind int
+
+const (
+	segField querySegKind = iota
+	segIndex
+	segIterate
+	segSlice
+)
+
+type querySeg struct {
+	kind     querySegKind
+	field    string
+	index    int
+	from, to *int
+}
+
+// queryStage is either a builtin or a path of segments
+type queryStage struct {
+	builtin string
+	path    []querySeg
+}
+
+var queryBuiltins = map[string]bool{"length": true, "keys": true, "type": true, "first": true, "last": true}
+
+func parseJSONQuery(query string) ([]queryStage, error) {
+	parts, err := splitQueryStages(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) > maxQueryStages {
+		return nil, fmt.Errorf("too many stages (max %d)", maxQueryStages)
+	}
+	stages := make([]queryStage, 0, len(parts))
+	for _, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			return nil, fmt.Errorf("empty stage in query")
+		}
+		if queryBuiltins[p] {
+			stages = append(stages, queryStage{builtin: p})
+			continue
+		}
+		if p[0] != '.' && p[0] != '[' {
+			// Accept GJSON-style "data[0].id" as well
+			p = "." + p
+		}
+		path, err := parseQueryPath(p)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, queryStage{path: path})
+	}
+	return stages, nil
+}
+
+// splitQueryStages splits on | outside quotes
+func splitQueryStages(query string) ([]string, error) {
+	var parts []string
+	start, inQuote := 0, false
+	for i := 0; i < len(query); i++ {
+		switch query[i] {
+		case '\\':
+			if inQuote {
+				i++
+			}
+		case '"':
+			inQuote = !inQuote
+		case '|':
+			if !inQuote {
+				parts = append(parts, query[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated string in query")
+	}
+	return append(parts, query[start:]), nil
+}
+
+func parseQueryPath(p string) ([]querySeg, error) {
+	var segs []querySeg
+	i := 0
+	for i < len(p) {
+		switch p[i] {
+		case '.':
+			i++
+			if i >= len(p) || p[i] == '[' || p[i] == '.' {
+				if i < len(p) && p[i] == '.' {
+					return nil, fmt.Errorf("recursive descent (..) is not supported")
+				}
+				continue // "." alone or ".[" handled by the bracket case
+			}
+			if p[i] == '"' {
+				name, n, err := readQueryString(p[i:])
+				if err != nil {
+					return nil, err
+				}
+				segs = append(segs, querySeg{kind: segField, field: name})
+				i += n
+				continue
+			}
+			j := i
+			for j < len(p) && isQueryIdent(p[j]) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected %q at position %d", p[i], i)
+			}
+			segs = append(segs, querySeg{kind: segField, field: p[i:j]})
+			i = j
+		case '[':
+			end := strings.IndexByte(p[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ] in query")
+			}
+			inner := strings.TrimSpace(p[i+1 : i+end])
+			if strings.HasPrefix(inner, "\"") {
+				q := i + 1 + strings.IndexByte(p[i+1:], '"')
+				name, n, err := readQueryString(p[q:])
+				if err != nil {
+					return nil, err
+				}
+				// The key may contain ']'; look for the bracket after it
+				rest := strings.TrimLeft(p[q+n:], " ")
+				if !strings.HasPrefix(rest, "]") {
+					return nil, fmt.Errorf("missing ] after key")
+				}
+				segs = append(segs, querySeg{kind: segField, field: name})
+				i = len(p) - len(rest) + 1
+				continue
+			}
+			seg, err := parseQueryBracket(inner)
+			if err != nil {
+				return nil, err
+			}
+			segs = append(segs, seg)
+			i += end + 1
+		case ' ':
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected %q at position %d (functions and operators are not supported)", p[i], i)
+		}
+	}
+	return segs, nil
+}
+
+func parseQueryBracket(inner string) (querySeg, error) {
+	if inner == "" {
+		return querySeg{kind: segIterate}, nil
+	}
+	if strings.Contains(inner, ":") {
+		bounds := strings.SplitN(inner, ":", 2)
+		seg := querySeg{kind: segSlice}
+		for k, b := range bounds {
+			b = strings.TrimSpace(b)
+			if b == "" {
+				continue
+			}
+			n, err := strconv.Atoi(b)
+			if err != nil {
+				return seg, fmt.Errorf("invalid slice bound %q", b)
+			}
+			if k == 0 {
+				seg.from = &n
+			} else {
+				seg.to = &n
+			}
+		}
+		return seg, nil
+	}
+	n, err := strconv.Atoi(inner)
+	if err != nil {
+		return querySeg{}, fmt.Errorf("invalid index %q (use [\"key\"] for object keys)", inner)
+	}
+	return querySeg{kind: segIndex, index: n}, nil
+}
+
+// readQueryString reads a quoted key at the start of s and returns it with its length
+func readQueryString(s string) (string, int, error) {
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			name, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return "", 0, fmt.Errorf("invalid key %s", s[:i+1])
+			}
+			return name, i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string in query")
+}
+
+func isQueryIdent(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// ===================== Evaluation =====================
+
+func (st queryStage) apply(values []interface{}) ([]interface{}, error) {
+	if st.builtin != "" {
+		out := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			r, err := applyQueryBuiltin(st.builtin, v)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, r)
+		}
+		return out, nil
+	}
+	for _, seg := range st.path {
+		next := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			r, err := seg.apply(v)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, r...)
+			if len(next) > maxQueryResults {
+				return nil, fmt.Errorf("query yields more than %d values; narrow it down", maxQueryResults)
+			}
+		}
+		values = next
+	}
+	return values, nil
+}
+
+func (seg querySeg) apply(v interface{}) ([]interface{}, error) {
+	switch seg.kind {
+	case segField:
+		switch x := v.(type) {
+		case nil:
+			return []interface{}{nil}, nil
+		case map[string]interface{}:
+			return []interface{}{x[seg.field]}, nil
+		case []interface{}:
+			// GJSON-style numeric keys index arrays (data.0.id)
+			if n, err := strconv.Atoi(seg.field); err == nil {
+				return []interface{}{queryIndex(x, n)}, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot get key %q of %s", seg.field, queryType(v))
+	case segIndex:
+		switch x := v.(type) {
+		case nil:
+			return []interface{}{nil}, nil
+		case []interface{}:
+			return []interface{}{queryIndex(x, seg.index)}, nil
+		}
+		return nil, fmt.Errorf("cannot index %s with [%d]", queryType(v), seg.index)
+	case segIterate:
+		switch x := v.(type) {
+		case []interface{}:
+			return x, nil
+		case map[string]interface{}:
+			keys := sortedKeys(x)
+			out := make([]interface{}, len(keys))
+			for i, k := range keys {
+				out[i] = x[k]
+			}
+			return out, nil
+		}
+		return nil, fmt.Errorf("cannot iterate over %s", queryType(v))
+	case segSlice:
+		x, ok := v.([]interface{})
+		if !ok {
+			if v == nil {
+				return []interface{}{nil}, nil
+			}
+			return nil, fmt.Errorf("cannot slice %s", queryType(v))
+		}
+		from, to := 0, len(x)
+		if seg.from != nil {
+			from = clampQueryIndex(*seg.from, len(x))
+		}
+		if seg.to != nil {
+			to = clampQueryIndex(*seg.to, len(x))
+		}
+		if from > to {
+			from = to
+		}
+		return []interface{}{x[from:to]}, nil
+	}
+	return nil, fmt.Errorf("unsupported path segment")
+}
+
+func applyQueryBuiltin(name string, v interface{}) (interface{}, error) {
+	switch name {
+	case "type":
+		return queryType(v), nil
+	case "length":
+		switch x := v.(type) {
+		case nil:
+			return 0, nil
+		case string:
+			return utf8.RuneCountInString(x), nil
+		case []interface{}:
+			return len(x), nil
+		case map[string]interface{}:
+			return len(x), nil
+		}
+	case "keys":
+		switch x := v.(type) {
+		case map[string]interface{}:
+			return sortedKeys(x), nil
+		case []interface{}:
+			idx := make([]int, len(x))
+			for i := range x {
+				idx[i] = i
+			}
+			return idx, nil
+		}
+	case "first", "last":
+		x, ok := v.([]interface{})
+		if !ok {
+			break
+		}
+		if name == "first" {
+			return queryIndex(x, 0), nil
+		}
+		return queryIndex(x, -1), nil
+	}
+	return nil, fmt.Errorf("%s is not defined for %s", name, queryType(v))
+}
+
+// queryIndex supports negative indices; out of range yields null like jq
+func queryIndex(arr []interface{}, n int) interface{} {
+	if n < 0 {
+		n += len(arr)
+	}
+	if n < 0 || n >= len(arr) {
+		return nil
+	}
+	return arr[n]
+}
+
+func clampQueryIndex(n, length int) int {
+	if n < 0 {
+		n += length
+	}
+	if n < 0 {
+		return 0
+	}
+	if n > length {
+		return length
+	}
+	return n
+}
+
+func queryType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number, float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,28 @@
+// Memorable tool results - let a tool ask the agent to keep part of its result as a memory
+package tools
+
+import "strings"
+
+// maxMemorableText caps the text a tool result may store as one memory
+const maxMemorableText = 1000
+
+// Memorable wraps a tool result that should also be stored in long-term memory.
+// The agent returns Result to the model as usual and stores Text with source "tool".
+type Memorable struct {
+	Result     interface{}
+	Text       string
+	Category   string  // "" = DetectCategory(Text)
+	Importance float64 // 0 = the agent's auto-capture importance for the category
+}
+
+// Remember flags result as memory-worthy; an empty text returns result unchanged
+func Remember(result interface{}, text, category string) interface{} {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return result
+	}
+	if r := []rune(text); len(r) > maxMemorableText {
+		text = string(r[:maxMemorableText])
+	}
+	return Memorable{Result: result, Text: text, Category: category}
+}
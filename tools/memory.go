@@ -13,6 +13,9 @@ import (
 
 // ===================== memory_search =====================
 
+// maxSearchEf caps the per-query ef a caller can ask memory_search for
+const maxSearchEf = 2000
+
 type MemoryTool struct {
 	Store *memory.VectorMemoryStore
 }
@@ -49,6 +52,18 @@ func (t *MemoryTool) Parameters() map[string]interface{} {
 				"description": "Min similarity 0-1 (default 0.7)",
 				"default":     0.7,
 			},
+			"ef": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Optional HNSW search breadth for a thorough, slower lookup (e.g. 400, max %d); omit for the default", maxSearchEf),
+			},
+			"fields": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional comma-separated result fields to return (" + strings.Join(memory.ResultFields, ", ") + "); omit for all except snippet",
+			},
+			"snippetLength": map[string]interface{}{
+				"type":        "integer",
+				"description": fmt.Sprintf("Characters of text in the snippet field (default %d)", memory.DefaultSnippetLen),
+			},
 		},
 		"required": []string{"query"},
 	}
@@ -59,10 +74,18 @@ func (t *MemoryTool) Execute(args map[string]interface{}) (interface{}, error) {
 	category := GetString(args, "category")
 	limit := GetInt(args, "limit")
 	minScore := GetFloat64(args, "minScore")
+	ef := GetInt(args, "ef")
+	proj, err := memory.ParseProjection(GetString(args, "fields"), GetInt(args, "snippetLength"))
+	if err != nil {
+		return nil, err
+	}
 
 	if limit <= 0 {
 		limit = 5
 	}
+	if ef > maxSearchEf {
+		ef = maxSearchEf
+	}
 	if minScore <= 0 {
 		minScore = 0.7
 	}
@@ -74,22 +97,18 @@ func (t *MemoryTool) Execute(args map[string]interface{}) (interface{}, error) {
 		return nil, fmt.Errorf("memory store is not initialized")
 	}
 
-	results, err := t.Store.Search(query, limit, float32(minScore))
+	// Category-scoped: only that category is searched (its own index when partitioned)
+	results, err := t.Store.SearchFiltered(query, memory.SearchOptions{
+		Limit:      limit,
+		MinScore:   float32(minScore),
+		Categories: []string{category},
+		EfSearch:   ef,
+		Projection: &proj,
+	})
 	if err != nil {
 		return nil, fmt.Errorf("search failed: %v", err)
 	}
 
-	// Optional category filter
-	if category != "" {
-		filtered := make([]memory.MemoryResult, 0)
-		for _, r := range results {
-			if r.Entry.Category == category {
-				filtered = append(filtered, r)
-			}
-		}
-		results = filtered
-	}
-
 	if len(results) == 0 {
 		return MemorySearchResult{Query: query, Count: 0, Result: "No relevant memories found."}, nil
 	}
@@ -98,18 +117,9 @@ func (t *MemoryTool) Execute(args map[string]interface{}) (interface{}, error) {
 	items := make([]map[string]interface{}, 0, len(results))
 	for i, r := range results {
 		scorePct := int(r.Score * 100)
+		// Text is already cut to the snippet (or empty) when the projection leaves it out
 		resultText += fmt.Sprintf("%d. [%s] %s (similarity %d%%)\n", i+1, r.Entry.Category, r.Entry.Text, scorePct)
-		items = append(items, map[string]interface{}{
-			"id":         r.Entry.ID,
-			"text":       r.Entry.Text,
-			"category":   r.Entry.Category,
-			"importance": r.Entry.Importance,
-			"score":      fmt.Sprintf("%.4f", r.Score),
-			"matched":    r.Matched,
-			"source":     r.Entry.Source,
-			"createdAt":  time.Unix(r.Entry.CreatedAt, 0).Format("2006-01-02 15:04"),
-			"updatedAt":  time.Unix(r.Entry.UpdatedAt, 0).Format("2006-01-02 15:04"),
-		})
+		items = append(items, proj.Item(r))
 	}
 
 	return MemorySearchResult{Query: query, Count: len(results), Items: items, Result: resultText}, nil
@@ -164,11 +174,83 @@ func (t *MemoryGetTool) Execute(args map[string]interface{}) (interface{}, error
 		"category":   entry.Category,
 		"importance": entry.Importance,
 		"source":     entry.Source,
+		"externalId": entry.ExternalID,
 		"createdAt":  time.Unix(entry.CreatedAt, 0).Format("2006-01-02 15:04:05"),
 		"updatedAt":  time.Unix(entry.UpdatedAt, 0).Format("2006-01-02 15:04:05"),
 	}, nil
 }
 
+// ===================== memory_related =====================
+
+type MemoryRelatedTool struct {
+	Store *memory.VectorMemoryStore
+}
+
+func NewMemoryRelatedTool(store *memory.VectorMemoryStore) *MemoryRelatedTool {
+	return &MemoryRelatedTool{Store: store}
+}
+
+func (t *MemoryRelatedTool) Name() string { return "memory_related" }
+
+func (t *MemoryRelatedTool) Description() string {
+	return "Find memories semantically related to a given memory ID (see also)."
+}
+
+func (t *MemoryRelatedTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id": map[string]interface{}{
+				"type":        "string",
+				"description": "Memory ID to expand from",
+			},
+			"limit": map[string]interface{}{
+				"type":        "integer",
+				"description": "Max results (default 5)",
+				"default":     5,
+			},
+		},
+		"required": []string{"id"},
+	}
+}
+
+func (t *MemoryRelatedTool) Execute(args map[string]interface{}) (interface{}, error) {
+	id := GetString(args, "id")
+	limit := GetInt(args, "limit")
+	if limit <= 0 {
+		limit = 5
+	}
+	if id == "" {
+		return nil, fmt.Errorf("id is required")
+	}
+	if t.Store == nil {
+		return nil, fmt.Errorf("memory store is not initialized")
+	}
+
+	results, err := t.Store.Related(id, limit)
+	if err != nil {
+		return nil, fmt.Errorf("related lookup failed: %v", err)
+	}
+	if len(results) == 0 {
+		return MemorySearchResult{Query: id, Count: 0, Result: "No related memories found."}, nil
+	}
+
+	resultText := fmt.Sprintf("Found %d memories related to %s:\n\n", len(results), id)
+	items := make([]map[string]interface{}, 0, len(results))
+	for i, r := range results {
+		resultText += fmt.Sprintf("%d. [%s] %s (similarity %d%%)\n", i+1, r.Entry.Category, r.Entry.Text, int(r.Score*100))
+		items = append(items, map[string]interface{}{
+			"id":         r.Entry.ID,
+			"text":       r.Entry.Text,
+			"category":   r.Entry.Category,
+			"importance": r.Entry.Importance,
+			"score":      fmt.Sprintf("%.4f", r.Score),
+		})
+	}
+
+	return MemorySearchResult{Query: id, Count: len(results), Items: items, Result: resultText}, nil
+}
+
 // ===================== memory_store =====================
 
 type MemoryStoreTool struct {
@@ -203,6 +285,10 @@ func (t *MemoryStoreTool) Parameters() map[string]interface{} {
 				"description": "Importance 0-1",
 				"default":     0.7,
 			},
+			"external_id": map[string]interface{}{
+				"type":        "string",
+				"description": "Optional caller ID; storing again with the same ID updates that memory instead of adding one",
+			},
 		},
 		"required": []string{"text"},
 	}
@@ -225,6 +311,23 @@ func (t *MemoryStoreTool) Execute(args map[string]interface{}) (interface{}, err
 		return nil, fmt.Errorf("memory store is not initialized")
 	}
 
+	if externalID := strings.TrimSpace(GetString(args, "external_id")); externalID != "" {
+		id, created, err := t.Store.Upsert(externalID, text, category, importance, "manual")
+		if err != nil {
+			return nil, fmt.Errorf("store failed: %v", err)
+		}
+		action := "updated"
+		if created {
+			action = "created"
+		}
+		return map[string]interface{}{
+			"action":     action,
+			"id":         id,
+			"externalId": externalID,
+			"result":     fmt.Sprintf("Stored: %s", Truncate(text, 50)),
+		}, nil
+	}
+
 	// Approximate duplicate detection (similarity > 0.95)
 	results, _ := t.Store.Search(text, 3, 0.95)
 	for _, r := range results {
@@ -250,6 +353,58 @@ func (t *MemoryStoreTool) Execute(args map[string]interface{}) (interface{}, err
 	}, nil
 }
 
+// ===================== memory_delete =====================
+
+type MemoryDeleteTool struct {
+	Store *memory.VectorMemoryStore
+}
+
+func NewMemoryDeleteTool(store *memory.VectorMemoryStore) *MemoryDeleteTool {
+	return &MemoryDeleteTool{Store: store}
+}
+
+func (t *MemoryDeleteTool) Name() string { return "memory_delete" }
+
+func (t *MemoryDeleteTool) Description() string {
+	return "Delete a memory by ID (e.g. one that is wrong or outdated)."
+}
+
+func (t *MemoryDeleteTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"path": map[string]interface{}{
+				"type":        "string",
+				"description": "Memory ID",
+			},
+		},
+		"required": []string{"path"},
+	}
+}
+
+// Execute reports deleted=false, not an error, when no memory has the ID
+func (t *MemoryDeleteTool) Execute(args map[string]interface{}) (interface{}, error) {
+	id := strings.TrimSpace(GetString(args, "path"))
+	if id == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	if t.Store == nil {
+		return nil, fmt.Errorf("memory store is not initialized")
+	}
+
+	deleted, err := t.Store.Delete(id)
+	if err != nil {
+		return nil, fmt.Errorf("delete failed: %v", err)
+	}
+	if deleted {
+		log.Printf("🗑️ memory deleted: %s", id)
+	}
+	return map[string]interface{}{
+		"deleted": deleted,
+		"id":      id,
+	}, nil
+}
+
 // ===================== Helpers =====================
 
 type MemorySearchResult struct {
@@ -333,16 +488,36 @@ func FindRelevantMemories(store *memory.VectorMemoryStore, prompt string, limit
 	return results, nil
 }
 
+// RecallPlaceholder marks where the bulleted memory list goes in a recall template
+const RecallPlaceholder = "{{memories}}"
+
+// DefaultRecallTemplate is the recall injection format used when none is configured
+const DefaultRecallTemplate = "<relevant-memories>\nThe following memories may be relevant to the current conversation:\n" +
+	RecallPlaceholder + "\n</relevant-memories>"
+
 // Format memories for context injection
 func FormatMemoriesForContext(results []memory.MemoryResult) string {
+	return FormatMemoriesWithTemplate(results, DefaultRecallTemplate)
+}
+
+// FormatMemoriesWithTemplate renders memories into template at RecallPlaceholder.
+// A template without the placeholder gets the list appended on a new line.
+func FormatMemoriesWithTemplate(results []memory.MemoryResult, template string) string {
 	if len(results) == 0 {
 		return ""
 	}
+	if strings.TrimSpace(template) == "" {
+		template = DefaultRecallTemplate
+	}
 	lines := make([]string, 0, len(results))
 	for _, r := range results {
 		lines = append(lines, fmt.Sprintf("- [%s] %s", r.Entry.Category, r.Entry.Text))
 	}
-	return fmt.Sprintf("<relevant-memories>\nThe following memories may be relevant to the current conversation:\n%s\n</relevant-memories>", strings.Join(lines, "\n"))
+	list := strings.Join(lines, "\n")
+	if !strings.Contains(template, RecallPlaceholder) {
+		return template + "\n" + list
+	}
+	return strings.ReplaceAll(template, RecallPlaceholder, list)
 }
 
 // Keyword extraction (very simple)
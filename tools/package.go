@@ -1,6 +1,6 @@
 // Package tools - OpenClaw-Go tool invocation framework
 //
-// Provides exec, read, write, process, edit, memory, web, browser, sessions tools
+// Provides exec, read, write, process, edit, memory, web, browser, sessions, ask_user tools
 // Also provides adapter-based plugin system for dynamic tool loading
 package tools
 
@@ -30,10 +30,15 @@ func NewDefaultRegistry() *Registry {
 	registry.Register(&SessionsHistoryTool{})
 	registry.Register(&SessionStatusTool{})
 	registry.Register(&AgentsListTool{})
+	registry.Register(&AskUserTool{})
+	registry.Register(&DatetimeTool{})
+	registry.Register(&JSONQueryTool{})
 	// Memory tools require storage; initialize separately
 	registry.Register(&MemoryTool{Store: nil})
 	registry.Register(&MemoryGetTool{Store: nil})
+	registry.Register(&MemoryRelatedTool{Store: nil})
 	registry.Register(&MemoryStoreTool{Store: nil})
+	registry.Register(&MemoryDeleteTool{Store: nil})
 
 	return registry
 }
@@ -58,9 +63,14 @@ func NewMemoryRegistry(store *memory.VectorMemoryStore) *Registry {
 	registry.Register(&SessionsHistoryTool{})
 	registry.Register(&SessionStatusTool{})
 	registry.Register(&AgentsListTool{})
+	registry.Register(&AskUserTool{})
+	registry.Register(&DatetimeTool{})
+	registry.Register(&JSONQueryTool{})
 	registry.Register(&MemoryTool{Store: store})
 	registry.Register(&MemoryGetTool{Store: store})
+	registry.Register(&MemoryRelatedTool{Store: store})
 	registry.Register(&MemoryStoreTool{Store: store})
+	registry.Register(&MemoryDeleteTool{Store: store})
 
 	return registry
 }
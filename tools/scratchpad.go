@@ -0,0 +1,172 @@
+// Scratchpad tool - session-scoped key/value notes for multi-step workflows
+
+package tools
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gliderlab/cogate/storage"
+)
+
+const (
+	// scratchpadTTL keeps notes for a day unless ttl_seconds says otherwise
+	scratchpadTTL       = 24 * time.Hour
+	maxScratchpadValue  = 64 * 1024
+	maxScratchpadKeys   = 100
+	maxScratchpadKeyLen = 128
+	scratchpadPreview   = 120
+)
+
+// ScratchpadStore is the slice of storage the scratchpad needs (kv_cache)
+type ScratchpadStore interface {
+	KVSet(namespace, key, value string, ttl time.Duration) error
+	KVGet(namespace, key string) (string, bool, error)
+	KVDelete(namespace, key string) error
+	KVList(namespace string) ([]storage.KVEntry, error)
+}
+
+// ScratchpadTool stores intermediate results outside the context window
+type ScratchpadTool struct {
+	Store      ScratchpadStore
+	SessionKey string
+}
+
+// NewScratchpadTool creates a scratchpad scoped to sessionKey ("default" when empty)
+func NewScratchpadTool(store ScratchpadStore, sessionKey string) *ScratchpadTool {
+	if sessionKey == "" {
+		sessionKey = "default"
+	}
+	return &ScratchpadTool{Store: store, SessionKey: sessionKey}
+}
+
+// ForSession returns the scratchpad of sessionKey on the same store
+func (t *ScratchpadTool) ForSession(sessionKey string) Tool {
+	return NewScratchpadTool(t.Store, sessionKey)
+}
+
+func (t *ScratchpadTool) Name() string { return "scratchpad" }
+
+func (t *ScratchpadTool) Description() string {
+	return "Session scratchpad for intermediate results between tool calls. Save findings with set and read them back with get instead of re-running expensive tools."
+}
+
+func (t *ScratchpadTool) Parameters() map[string]interface{} {
+	return map[string]interface{}{
+		"type": "object",
+		"properties": map[string]interface{}{
+			"action": map[string]interface{}{
+				"type":        "string",
+				"description": "Action to perform: set, get, list, delete",
+				"enum":        []string{"set", "get", "list", "delete"},
+			},
+			"key": map[string]interface{}{
+				"type":        "string",
+				"description": "Entry name (set/get/delete)",
+			},
+			"value": map[string]interface{}{
+				"type":        "string",
+				"description": "Content to store (set)",
+			},
+			"ttl_seconds": map[string]interface{}{
+				"type":        "integer",
+				"description": "Expiry for set (default 86400)",
+			},
+		},
+		"required": []string{"action"},
+	}
+}
+
+func (t *ScratchpadTool) namespace() string {
+	return "scratchpad:" + t.SessionKey
+}
+
+func (t *ScratchpadTool) Execute(args map[string]interface{}) (interface{}, error) {
+	if t.Store == nil {
+		return nil, fmt.Errorf("scratchpad storage is not initialized")
+	}
+
+	action := strings.ToLower(strings.TrimSpace(GetString(args, "action")))
+	key := strings.TrimSpace(GetString(args, "key"))
+	if action != "list" {
+		if key == "" {
+			return nil, fmt.Errorf("key is required for %s", action)
+		}
+		if len(key) > maxScratchpadKeyLen {
+			return nil, fmt.Errorf("key too long (max %d)", maxScratchpadKeyLen)
+		}
+	}
+
+	switch action {
+	case "set":
+		return t.executeSet(key, GetString(args, "value"), GetInt(args, "ttl_seconds"))
+	case "get":
+		value, found, err := t.Store.KVGet(t.namespace(), key)
+		if err != nil {
+			return nil, fmt.Errorf("scratchpad get failed: %v", err)
+		}
+		if !found {
+			return map[string]interface{}{"key": key, "found": false}, nil
+		}
+		return map[string]interface{}{"key": key, "found": true, "value": value}, nil
+	case "list":
+		entries, err := t.Store.KVList(t.namespace())
+		if err != nil {
+			return nil, fmt.Errorf("scratchpad list failed: %v", err)
+		}
+		items := make([]map[string]interface{}, 0, len(entries))
+		for _, e := range entries {
+			preview := e.Value
+			if len(preview) > scratchpadPreview {
+				preview = preview[:scratchpadPreview] + "..."
+			}
+			items = append(items, map[string]interface{}{
+				"key":     e.Key,
+				"size":    len(e.Value),
+				"preview": preview,
+			})
+		}
+		return map[string]interface{}{"count": len(items), "entries": items}, nil
+	case "delete":
+		if err := t.Store.KVDelete(t.namespace(), key); err != nil {
+			return nil, fmt.Errorf("scratchpad delete failed: %v", err)
+		}
+		return map[string]interface{}{"key": key, "deleted": true}, nil
+	default:
+		return nil, fmt.Errorf("unknown action: %s", action)
+	}
+}
+
+func (t *ScratchpadTool) executeSet(key, value string, ttlSeconds int) (interface{}, error) {
+	if len(value) > maxScratchpadValue {
+		return nil, fmt.Errorf("value too large (%d bytes, max %d)", len(value), maxScratchpadValue)
+	}
+
+	_, exists, err := t.Store.KVGet(t.namespace(), key)
+	if err != nil {
+		return nil, fmt.Errorf("scratchpad set failed: %v", err)
+	}
+	if !exists {
+		entries, err := t.Store.KVList(t.namespace())
+		if err != nil {
+			return nil, fmt.Errorf("scratchpad set failed: %v", err)
+		}
+		if len(entries) >= maxScratchpadKeys {
+			return nil, fmt.Errorf("scratchpad full (%d entries); delete some first", maxScratchpadKeys)
+		}
+	}
+
+	ttl := scratchpadTTL
+	if ttlSeconds > 0 {
+		ttl = time.Duration(ttlSeconds) * time.Second
+	}
+	if err := t.Store.KVSet(t.namespace(), key, value, ttl); err != nil {
+		return nil, fmt.Errorf("scratchpad set failed: %v", err)
+	}
+	return map[string]interface{}{
+		"key":       key,
+		"size":      len(value),
+		"expiresIn": int(ttl / time.Second),
+	}, nil
+}
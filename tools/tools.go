@@ -5,7 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"log/slog"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // Tool defines the tool interface
@@ -16,31 +19,49 @@ type Tool interface {
 	Execute(args map[string]interface{}) (interface{}, error)
 }
 
+// SessionScoped is a Tool whose state belongs to one session. Calls made for a
+// session run on the tool ForSession returns.
+type SessionScoped interface {
+	ForSession(sessionKey string) Tool
+}
+
 // Registry holds registered tools
 type Registry struct {
-	tools map[string]Tool
+	mu       sync.RWMutex
+	tools    map[string]Tool
+	disabled map[string]bool
+	// version changes on every register/enable/disable so callers can invalidate cached specs
+	version uint64
 }
 
 func NewRegistry() *Registry {
 	return &Registry{
-		tools: make(map[string]Tool),
+		tools:    make(map[string]Tool),
+		disabled: make(map[string]bool),
 	}
 }
 
 // Register a tool
 func (r *Registry) Register(t Tool) {
+	r.mu.Lock()
 	r.tools[t.Name()] = t
+	r.version++
+	r.mu.Unlock()
 	log.Printf("✅ tool registered: %s", t.Name())
 }
 
 // Get returns a tool by name
 func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	t, ok := r.tools[name]
 	return t, ok
 }
 
 // List all tools
 func (r *Registry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	names := make([]string, 0, len(r.tools))
 	for name := range r.tools {
 		names = append(names, name)
@@ -48,28 +69,104 @@ func (r *Registry) List() []string {
 	return names
 }
 
+// Enable makes a disabled tool visible to the model again
+func (r *Registry) Enable(name string) error {
+	return r.setEnabled(name, true)
+}
+
+// Disable hides a tool from the model and rejects calls to it
+func (r *Registry) Disable(name string) error {
+	return r.setEnabled(name, false)
+}
+
+func (r *Registry) setEnabled(name string, enabled bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.tools[name]; !ok {
+		return fmt.Errorf("tool not found: %s", name)
+	}
+	if enabled == !r.disabled[name] {
+		return nil
+	}
+	if enabled {
+		delete(r.disabled, name)
+	} else {
+		r.disabled[name] = true
+	}
+	r.version++
+	log.Printf("🔧 tool %s enabled=%v", name, enabled)
+	return nil
+}
+
+// IsEnabled reports whether a registered tool is enabled
+func (r *Registry) IsEnabled(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.tools[name]
+	return ok && !r.disabled[name]
+}
+
+// Version returns the registry change counter
+func (r *Registry) Version() uint64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.version
+}
+
 // CallTool and return its result
 func (r *Registry) CallTool(name string, args map[string]interface{}) (interface{}, error) {
 	t, ok := r.Get(name)
 	if !ok {
 		return nil, fmt.Errorf("tool not found: %s", name)
 	}
+	return r.call(t, name, args)
+}
+
+// CallToolForSession is CallTool on behalf of a turn of sessionKey, so that a
+// SessionScoped tool sees that session's state
+func (r *Registry) CallToolForSession(sessionKey, name string, args map[string]interface{}) (interface{}, error) {
+	t, ok := r.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("tool not found: %s", name)
+	}
+	if s, ok := t.(SessionScoped); ok {
+		t = s.ForSession(sessionKey)
+	}
+	return r.call(t, name, args)
+}
 
-	log.Printf("🔧 calling tool: %s, args: %v", name, args)
+func (r *Registry) call(t Tool, name string, args map[string]interface{}) (interface{}, error) {
+	if !r.IsEnabled(name) {
+		return nil, fmt.Errorf("tool disabled: %s", name)
+	}
+
+	slog.Info("calling tool", "tool", name)
+	slog.Debug("tool arguments", "tool", name, "args", args)
 	result, err := t.Execute(args)
 	if err != nil {
-		log.Printf("❌ tool failed: %s - %v", name, err)
+		slog.Error("tool failed", "tool", name, "error", err)
 		return nil, err
 	}
 
-	log.Printf("✅ tool succeeded: %s", name)
+	slog.Debug("tool succeeded", "tool", name)
 	return result, nil
 }
 
-// GetToolSpecs returns OpenAI-format specs with function wrapper
+// GetToolSpecs returns OpenAI-format specs with function wrapper (enabled tools only, sorted by name)
 func (r *Registry) GetToolSpecs() []map[string]interface{} {
-	specs := make([]map[string]interface{}, 0)
-	for _, t := range r.tools {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		if !r.disabled[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	specs := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		t := r.tools[name]
 		specs = append(specs, map[string]interface{}{
 			"type": "function",
 			"function": map[string]interface{}{
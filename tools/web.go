@@ -171,6 +171,10 @@ func (t *WebFetchTool) Parameters() map[string]interface{} {
 				"description": "Max characters (truncates beyond this)",
 				"default":     10000,
 			},
+			"remember": map[string]interface{}{
+				"type":        "string",
+				"description": "Only when the user asks to remember what the page says: the fact to save to long-term memory, in one or two sentences",
+			},
 		},
 		"required": []string{"url"},
 	}
@@ -201,12 +205,16 @@ func (t *WebFetchTool) Execute(args map[string]interface{}) (interface{}, error)
 		return nil, fmt.Errorf("fetch failed: %v", err)
 	}
 
-	return map[string]interface{}{
+	result := map[string]interface{}{
 		"url":         url,
 		"extractMode": extractMode,
 		"content":     content,
 		"truncated":   len(content) >= maxChars,
-	}, nil
+	}
+	if note := GetString(args, "remember"); note != "" {
+		return Remember(result, note+" (source: "+url+")", ""), nil
+	}
+	return result, nil
 }
 
 // fetchURL retrieves content and applies extraction